@@ -8,7 +8,7 @@ import (
 	"go-sample/api/utils"
 	"go-sample/types"
 	"net/http"
-	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi"
 )
@@ -34,6 +34,15 @@ func (ah *AccountHandler) CreateAccount(w http.ResponseWriter, r *http.Request)
 	}
 
 	entity := types.NewAccount(account.Owner, account.Balance)
+	if account.Type != "" {
+		entity.Type = types.AccountType(strings.ToUpper(string(account.Type)))
+	}
+	entity.Nickname = account.Nickname
+	if account.Currency != "" {
+		entity.Currency = types.Currency(strings.ToUpper(string(account.Currency)))
+		entity.Balance.Currency = entity.Currency
+		entity.CreditLimit.Currency = entity.Currency
+	}
 	isValidated := entity.ValidateAccount()
 
 	if !isValidated {
@@ -41,21 +50,16 @@ func (ah *AccountHandler) CreateAccount(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	accountExists, err := ah.accountSrv.IsThereAlreadyAccountWithThisOwner(r.Context(), account.Owner)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+	accountId, err := ah.accountSrv.CreateAccount(r.Context(), entity)
+
+	if errors.Is(err, services.ErrInexistentOwner) {
+		writeError(w, http.StatusBadRequest, err, "There's no any owner associated with this owner_id")
 		return
 	}
-	if accountExists {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "This owner already holds an account",
-		})
+	if errors.Is(err, services.ErrOwnerNotVerified) {
+		writeError(w, http.StatusForbidden, err, "The owner must pass KYC verification before opening an account")
 		return
 	}
-
-	accountId, err := ah.accountSrv.CreateAccount(r.Context(), entity)
-
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
@@ -84,20 +88,24 @@ func (ah *AccountHandler) ListAccounts(w http.ResponseWriter, r *http.Request) {
 
 	isValidated := filter.Validate()
 	if !isValidated {
+		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "invalid filters: dates must be RFC 3339 or YYYY-MM-DD, balances decimal amounts, status ACTIVE, FROZEN or CLOSED, sort_by created_at or balance, sort asc or desc, limit a positive number and cursor one returned by a previous page",
+		})
 		return
 	}
 	accounts, err := ah.accountSrv.ListAccounts(r.Context(), *filter)
 
+	if errors.Is(err, types.ErrInvalidCursor) {
+		writeInvalidCursor(w)
+		return
+	}
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	if len(accounts) == 0 {
-		json.NewEncoder(w).Encode([]map[string]string{})
-		return
-	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(accounts)
@@ -126,7 +134,7 @@ func (ah *AccountHandler) GetAccount(w http.ResponseWriter, r *http.Request) {
 
 func (ah *AccountHandler) GetAccountBalance(w http.ResponseWriter, r *http.Request) {
 	accountId := chi.URLParam(r, "id")
-	balance, err := ah.accountSrv.GetAccountBalance(r.Context(), accountId)
+	balance, err := ah.accountSrv.GetAvailableBalance(r.Context(), accountId)
 
 	if errors.Is(err, services.ErrInexistentAccount) {
 		w.WriteHeader(http.StatusBadRequest)
@@ -141,18 +149,64 @@ func (ah *AccountHandler) GetAccountBalance(w http.ResponseWriter, r *http.Reque
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]float64{
-		"balance": balance,
-	})
+	json.NewEncoder(w).Encode(balance)
+}
+
+func (ah *AccountHandler) SetCreditLimit(w http.ResponseWriter, r *http.Request) {
+	accountId := chi.URLParam(r, "id")
+	account, err := ah.accountSrv.GetAccount(r.Context(), accountId)
+	if errors.Is(err, services.ErrInexistentAccount) || (err == nil && account == nil) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "There's no any bank account associated with this id",
+		})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// a bare amount is read in the account currency
+	request := requestparams.SetCreditLimitRequest{CreditLimit: types.NewMoney(0, account.Currency)}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || !request.Validate() {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "credit_limit must be zero or greater",
+		})
+		return
+	}
+
+	account, err = ah.accountSrv.SetCreditLimit(r.Context(), accountId, request.CreditLimit)
+	if errors.Is(err, services.ErrInvalidCreditLimit) {
+		writeError(w, http.StatusBadRequest, err, "credit_limit must be in the account currency and zero or greater")
+		return
+	}
+	if errors.Is(err, services.ErrCreditLimitInUse) {
+		writeError(w, http.StatusConflict, err, "The account already owes more than the new credit limit")
+		return
+	}
+	if writeAccountStateError(w, err) {
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(account)
 }
 
 func (ah *AccountHandler) DepositMoney(w http.ResponseWriter, r *http.Request) {
 
 	accountId := chi.URLParam(r, "id")
 
-	amount, err := strconv.ParseFloat(r.URL.Query().Get("amount"), 64)
+	amount, err := types.ParseMoney(r.URL.Query().Get("amount"), queryCurrency(r))
 
-	if amount <= 0 {
+	if !amount.IsPositive() {
 		w.WriteHeader(http.StatusBadRequest)
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]string{
@@ -171,6 +225,9 @@ func (ah *AccountHandler) DepositMoney(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	err = ah.accountSrv.DepositMoney(r.Context(), accountId, amount)
+	if writeAccountStateError(w, err) || writeCurrencyError(w, err) {
+		return
+	}
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
@@ -181,9 +238,9 @@ func (ah *AccountHandler) DepositMoney(w http.ResponseWriter, r *http.Request) {
 
 func (ah *AccountHandler) WithdrawMoney(w http.ResponseWriter, r *http.Request) {
 	accountId := chi.URLParam(r, "id")
-	amount, err := strconv.ParseFloat(r.URL.Query().Get("amount"), 64)
+	amount, err := types.ParseMoney(r.URL.Query().Get("amount"), queryCurrency(r))
 
-	if amount <= 0 {
+	if !amount.IsPositive() {
 		w.WriteHeader(http.StatusBadRequest)
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]string{
@@ -203,14 +260,17 @@ func (ah *AccountHandler) WithdrawMoney(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if ah.accountSrv.HasInsufficientFunds(r.Context(), accountId, amount) {
+	err = ah.accountSrv.WithdrawMoney(r.Context(), accountId, amount)
+	if errors.Is(err, services.ErrInsufficientFunds) {
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(map[string]string{
 			"error": "Insufficient funds to withdraw",
 		})
 		return
 	}
-	err = ah.accountSrv.WithdrawMoney(r.Context(), accountId, amount)
+	if writeAccountStateError(w, err) || writeCurrencyError(w, err) || writeLimitError(w, err) {
+		return
+	}
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
@@ -244,15 +304,20 @@ func (ah *AccountHandler) TransferMoney(w http.ResponseWriter, r *http.Request)
 		})
 		return
 	}
-	if ah.accountSrv.HasInsufficientFunds(r.Context(), request.From, request.Amount) {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Insufficient funds to transfer",
-		})
+	if isAsync(r) {
+		operation, err := ah.accountSrv.SubmitTransfer(r.Context(), request)
+		if errors.Is(err, services.ErrInexistentAccount) {
+			writeError(w, http.StatusBadRequest, err, "From account does not exist")
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		writeOperationAccepted(w, operation)
 		return
 	}
-
-	err = ah.accountSrv.TransferMoney(r.Context(), request)
+	batch, err := ah.accountSrv.TransferMoney(r.Context(), request)
 
 	if errors.Is(err, services.ErrInexistentAccount) {
 		w.WriteHeader(http.StatusBadRequest)
@@ -262,10 +327,60 @@ func (ah *AccountHandler) TransferMoney(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if errors.Is(err, services.ErrInsufficientFunds) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":    "Insufficient funds to transfer",
+			"transfer": batch,
+		})
+		return
+	}
+	if errors.Is(err, services.ErrKYCTransferLimitExceeded) {
+		writeError(w, http.StatusForbidden, err, "The amount is above the transfer limit of the owner's KYC status")
+		return
+	}
+	if writeAccountStateError(w, err) || writeCurrencyError(w, err) || writeLimitError(w, err) {
+		return
+	}
+
+	if err != nil {
+		if batch == nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":    "The transfer failed and no money was moved",
+			"transfer": batch,
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(batch)
+}
+
+func (ah *AccountHandler) GetTransferBatch(w http.ResponseWriter, r *http.Request) {
+	batchId := chi.URLParam(r, "id")
+	batch, err := ah.accountSrv.GetTransferBatch(r.Context(), batchId)
+
+	if errors.Is(err, services.ErrInexistentTransferBatch) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "There's no any transfer batch associated with this id",
+		})
+		return
+	}
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(batch)
 }
 
 func (ah *AccountHandler) GetTransactionsHistory(w http.ResponseWriter, r *http.Request) {
@@ -284,17 +399,21 @@ func (ah *AccountHandler) GetTransactionsHistory(w http.ResponseWriter, r *http.
 
 	isValidated := filter.Validate()
 	if !isValidated {
+		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "invalid filters: dates must be RFC 3339 or YYYY-MM-DD, sort asc or desc, operation one of DEPOSIT, WITHDRAW, TRANSFER, REFUND or FEE, limit a positive number and cursor one returned by a previous page",
+		})
 		return
 	}
 
 	transactions, err := ah.accountSrv.GetTransactionsHistory(r.Context(), accountId, *filter)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+	if errors.Is(err, types.ErrInvalidCursor) {
+		writeInvalidCursor(w)
 		return
 	}
-	if len(transactions) == 0 {
-		json.NewEncoder(w).Encode([]map[string]string{})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -302,6 +421,26 @@ func (ah *AccountHandler) GetTransactionsHistory(w http.ResponseWriter, r *http.
 	json.NewEncoder(w).Encode(transactions)
 }
 
+func (ah *AccountHandler) GetTransaction(w http.ResponseWriter, r *http.Request) {
+	transactionId := chi.URLParam(r, "id")
+	transaction, err := ah.accountSrv.GetTransaction(r.Context(), transactionId)
+
+	if errors.Is(err, services.ErrInexistentTransaction) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "There's no any transaction associated with this id",
+		})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(transaction)
+}
+
 func (ah *AccountHandler) RefundMoney(w http.ResponseWriter, r *http.Request) {
 
 	var request requestparams.RefundMoneyRequest
@@ -318,6 +457,15 @@ func (ah *AccountHandler) RefundMoney(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(map[string]string{"error": "invalid request parameters"})
 		return
 	}
+	if isAsync(r) {
+		operation, err := ah.accountSrv.SubmitRefund(r.Context(), request)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		writeOperationAccepted(w, operation)
+		return
+	}
 	if request.IsMultibenificiary {
 
 		err := ah.accountSrv.RefundMultibeneficiaryTransfer(r.Context(), request.MultiBeneficiaryId)
@@ -331,6 +479,23 @@ func (ah *AccountHandler) RefundMoney(w http.ResponseWriter, r *http.Request) {
 				})
 				return
 			}
+			if err == services.ErrInsufficientFunds {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error": "Insufficient funds to refund",
+				})
+				return
+			}
+			if err == services.ErrTransactionNotRefundable {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error": "There are no completed transactions left to refund",
+				})
+				return
+			}
+			if writeAccountStateError(w, err) {
+				return
+			}
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
@@ -338,7 +503,7 @@ func (ah *AccountHandler) RefundMoney(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = ah.accountSrv.RefundMoneyNormalTransfer(r.Context(), request.TransactionId)
+	err = ah.accountSrv.RefundMoneyNormalTransfer(r.Context(), request.TransactionId, request.Amount)
 
 	if err != nil {
 
@@ -357,6 +522,9 @@ func (ah *AccountHandler) RefundMoney(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		if writeAccountStateError(w, err) {
+			return
+		}
 		if err == services.ErrInsufficientFunds {
 			w.WriteHeader(http.StatusBadRequest)
 			json.NewEncoder(w).Encode(map[string]string{
@@ -364,8 +532,224 @@ func (ah *AccountHandler) RefundMoney(w http.ResponseWriter, r *http.Request) {
 			})
 			return
 		}
+		if err == services.ErrTransactionNotRefundable {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Only completed transactions can be refunded",
+			})
+			return
+		}
+		if err == services.ErrRefundExceedsRefundableAmount {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "The refund amount exceeds what is left to refund on this transaction",
+			})
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (ah *AccountHandler) GetOwnerAccounts(w http.ResponseWriter, r *http.Request) {
+	ownerId := chi.URLParam(r, "id")
+	accounts, err := ah.accountSrv.GetOwnerAccounts(r.Context(), ownerId)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(accounts)
+}
+
+func (ah *AccountHandler) InternalTransfer(w http.ResponseWriter, r *http.Request) {
+	ownerId := chi.URLParam(r, "id")
+
+	var request requestparams.InternalTransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || !request.Validate() {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "from and to must be two different accounts and amount greater than 0",
+		})
+		return
+	}
+
+	batch, err := ah.accountSrv.InternalTransfer(r.Context(), ownerId, request)
+	if errors.Is(err, services.ErrInexistentAccount) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "There's no any bank account associated with this id",
+		})
+		return
+	}
+	if errors.Is(err, services.ErrAccountNotOwned) {
+		writeError(w, http.StatusForbidden, err, "Both accounts must belong to the owner")
+		return
+	}
+	if errors.Is(err, services.ErrInsufficientFunds) {
+		writeError(w, http.StatusBadRequest, err, "Insufficient funds to transfer")
+		return
+	}
+	if errors.Is(err, services.ErrKYCTransferLimitExceeded) {
+		writeError(w, http.StatusForbidden, err, "The amount is above the transfer limit of the owner's KYC status")
+		return
+	}
+	if writeAccountStateError(w, err) || writeCurrencyError(w, err) || writeLimitError(w, err) {
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(batch)
+}
+
+func (ah *AccountHandler) FreezeAccount(w http.ResponseWriter, r *http.Request) {
+	ah.changeAccountStatus(w, r, types.AccountFrozen)
+}
+
+func (ah *AccountHandler) UnfreezeAccount(w http.ResponseWriter, r *http.Request) {
+	ah.changeAccountStatus(w, r, types.AccountActive)
+}
+
+func (ah *AccountHandler) CloseAccount(w http.ResponseWriter, r *http.Request) {
+	ah.changeAccountStatus(w, r, types.AccountClosed)
+}
+
+func (ah *AccountHandler) changeAccountStatus(w http.ResponseWriter, r *http.Request, status types.AccountStatus) {
+	accountId := chi.URLParam(r, "id")
+
+	var request requestparams.ChangeAccountStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || !request.Validate() {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "reason and actor are required",
+		})
+		return
+	}
+
+	var account *types.Account
+	var err error
+	switch status {
+	case types.AccountFrozen:
+		account, err = ah.accountSrv.FreezeAccount(r.Context(), accountId, request.Reason, request.Actor)
+	case types.AccountActive:
+		account, err = ah.accountSrv.UnfreezeAccount(r.Context(), accountId, request.Reason, request.Actor)
+	case types.AccountClosed:
+		account, err = ah.accountSrv.CloseAccount(r.Context(), accountId, request.Reason, request.Actor, request.SweepTo)
+	}
+
+	if errors.Is(err, services.ErrInexistentAccount) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "There's no any bank account associated with this id",
+		})
+		return
+	}
+	if errors.Is(err, services.ErrInvalidAccountStatusTransition) {
+		writeError(w, http.StatusConflict, err, "The account can not go from its current status to "+string(status))
+		return
+	}
+	if errors.Is(err, services.ErrAccountHasBalance) {
+		writeError(w, http.StatusConflict, err, "The account still holds money, close it with a sweep_to account")
+		return
+	}
+	if errors.Is(err, services.ErrAccountHasActiveHolds) {
+		writeError(w, http.StatusConflict, err, "The account has active holds, capture or void them first")
+		return
+	}
+	if errors.Is(err, services.ErrInvalidSweepAccount) {
+		writeError(w, http.StatusBadRequest, err, "sweep_to must be another existing account")
+		return
+	}
+	if writeAccountStateError(w, err) || writeCurrencyError(w, err) {
+		return
+	}
+	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(account)
+}
+
+func (ah *AccountHandler) GetAccountStatusHistory(w http.ResponseWriter, r *http.Request) {
+	accountId := chi.URLParam(r, "id")
+	history, err := ah.accountSrv.GetAccountStatusHistory(r.Context(), accountId)
+
+	if errors.Is(err, services.ErrInexistentAccount) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "There's no any bank account associated with this id",
+		})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
+
+// writeAccountStateError answers 409 when the operation involved a frozen or closed account
+func writeAccountStateError(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, services.ErrAccountFrozen):
+		writeError(w, http.StatusConflict, err, "The operation involves a frozen account")
+	case errors.Is(err, services.ErrAccountClosed):
+		writeError(w, http.StatusConflict, err, "The operation involves a closed account")
+	default:
+		return false
+	}
+	return true
+}
+
+// writeCurrencyError answers 400 when the amount is in the wrong currency or there is no rate to exchange it
+func writeCurrencyError(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, services.ErrCurrencyMismatch):
+		writeError(w, http.StatusBadRequest, err, "The amount must be in the currency of the account it is taken from")
+	case errors.Is(err, services.ErrExchangeRateNotFound):
+		writeError(w, http.StatusBadRequest, err, "There's no exchange rate loaded between the currencies of the accounts")
+	case errors.Is(err, types.ErrMoneyOverflow):
+		writeError(w, http.StatusBadRequest, err, "The amount is too large")
+	default:
+		return false
+	}
+	return true
+}
+
+// queryCurrency is the currency query parameter, empty when the amount is in the account currency
+func queryCurrency(r *http.Request) types.Currency {
+	return types.Currency(strings.ToUpper(r.URL.Query().Get("currency")))
+}
+
+// writeError writes the message along with the error name as a machine readable code
+func writeError(w http.ResponseWriter, status int, err error, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": message,
+		"code":  err.Error(),
+	})
+}
+
+func writeInvalidCursor(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": "the cursor does not belong to this list or sort order",
+	})
 }
@@ -5,7 +5,17 @@ import (
 	"fmt"
 	requestparams "go-sample/api/handlers/request-params"
 	"go-sample/api/handlers/services"
+	"go-sample/storage"
 	accountrepo "go-sample/storage/account-repo"
+	feerepo "go-sample/storage/fee-repo"
+	fxrepo "go-sample/storage/fx-repo"
+	holdrepo "go-sample/storage/hold-repo"
+	ledgerrepo "go-sample/storage/ledger-repo"
+	limitrepo "go-sample/storage/limit-repo"
+	operationrepo "go-sample/storage/operation-repo"
+	outboxrepo "go-sample/storage/outbox-repo"
+	ownerrepo "go-sample/storage/owner-repo"
+	schedulerepo "go-sample/storage/schedule-repo"
 	transactionrepo "go-sample/storage/transaction-repo"
 	"go-sample/types"
 	"io"
@@ -26,13 +36,34 @@ func createTables(db *sqlx.DB) {
 		id VARCHAR(36) PRIMARY KEY,
 		from_account VARCHAR(36) NOT NULL,
 		to_account VARCHAR(36) NOT NULL,
-		tr_status TEXT NOT NULL,
+		subject TEXT NOT NULL DEFAULT '',
+		tr_status VARCHAR(20) NOT NULL,
+		failure_reason TEXT NOT NULL DEFAULT '',
 		operation VARCHAR(10) NOT NULL,
+		currency VARCHAR(3) NOT NULL DEFAULT 'AOA',
 		amount MONEY NOT NULL,
+		refunded_amount MONEY NOT NULL DEFAULT 0,
+		destination_currency VARCHAR(3) NOT NULL DEFAULT 'AOA',
+		destination_amount MONEY NULL,
+		exchange_rate VARCHAR(32) NOT NULL DEFAULT '',
 		multiBeneficiaryId VARCHAR(36) NOT NULL,
 		is_Refund BOOLEAN NOT NULL, 
 		refunded_transaction_id VARCHAR(36) NOT NULL,
-		createdAt TIMESTAMP NOT NULL
+		linked_transaction_id VARCHAR(36) NOT NULL DEFAULT '',
+		createdAt TIMESTAMP NOT NULL,
+		updated_at TIMESTAMP NOT NULL
+	  );`)
+
+	if err != nil {
+		panic(err)
+	}
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS public.transaction_status_history (
+		id BIGSERIAL PRIMARY KEY,
+		transaction_id VARCHAR(36) NOT NULL,
+		status VARCHAR(20) NOT NULL,
+		reason TEXT NOT NULL DEFAULT '',
+		changed_at TIMESTAMP NOT NULL
 	  );`)
 
 	if err != nil {
@@ -43,9 +74,257 @@ func createTables(db *sqlx.DB) {
 		  id VARCHAR(36) PRIMARY KEY,
 		  owner_id VARCHAR(36) NOT NULL,
 		  balance MONEY NOT NULL,
+		  credit_limit MONEY NOT NULL DEFAULT 0,
+		  held_amount MONEY NOT NULL DEFAULT 0,
+		  created_at TIMESTAMP NOT NULL,
+		  updated_at TIMESTAMP NOT NULL,
+		  deletedAt TIMESTAMP NULL,
+		  status VARCHAR(10) NOT NULL DEFAULT 'ACTIVE',
+		  account_type VARCHAR(10) NOT NULL DEFAULT 'CHECKING',
+		  nickname VARCHAR(100) NOT NULL DEFAULT '',
+		  currency VARCHAR(3) NOT NULL DEFAULT 'AOA'
+		);`)
+	if err != nil {
+		panic(err)
+	}
+	_, err = db.Exec(`
+	  CREATE TABLE IF NOT EXISTS public.owners (
+		  id VARCHAR(36) PRIMARY KEY,
+		  name VARCHAR(200) NOT NULL,
+		  national_id VARCHAR(14) NOT NULL UNIQUE,
+		  email VARCHAR(200) NOT NULL DEFAULT '',
+		  phone VARCHAR(30) NOT NULL DEFAULT '',
+		  address TEXT NOT NULL DEFAULT '',
+		  kyc_status VARCHAR(10) NOT NULL DEFAULT 'PENDING',
+		  created_at TIMESTAMP NOT NULL,
+		  updated_at TIMESTAMP NOT NULL,
+		  deleted_at TIMESTAMP NULL
+		);`)
+	if err != nil {
+		panic(err)
+	}
+	_, err = db.Exec(`
+	  CREATE TABLE IF NOT EXISTS public.account_status_history (
+		  id BIGSERIAL PRIMARY KEY,
+		  account_id VARCHAR(36) NOT NULL,
+		  from_status VARCHAR(10) NOT NULL,
+		  to_status VARCHAR(10) NOT NULL,
+		  reason TEXT NOT NULL,
+		  actor VARCHAR(100) NOT NULL,
+		  changed_at TIMESTAMP NOT NULL
+		);`)
+	if err != nil {
+		panic(err)
+	}
+	_, err = db.Exec(`
+	  CREATE TABLE IF NOT EXISTS public.transfer_batches (
+		  id VARCHAR(36) PRIMARY KEY,
+		  from_account VARCHAR(36) NOT NULL,
+		  currency VARCHAR(3) NOT NULL DEFAULT 'AOA',
+		  amount MONEY NOT NULL,
+		  subject TEXT NOT NULL,
+		  status VARCHAR(20) NOT NULL,
+		  failure_reason TEXT NOT NULL DEFAULT '',
+		  legs JSONB NOT NULL DEFAULT '[]',
+		  created_at TIMESTAMP NOT NULL,
+		  updated_at TIMESTAMP NOT NULL
+		);`)
+	if err != nil {
+		panic(err)
+	}
+	_, err = db.Exec(`
+	  CREATE TABLE IF NOT EXISTS public.journal_entries (
+		  id VARCHAR(36) PRIMARY KEY,
+		  transaction_id VARCHAR(36) NOT NULL,
+		  operation VARCHAR(10) NOT NULL,
+		  created_at TIMESTAMP NOT NULL
+		);`)
+	if err != nil {
+		panic(err)
+	}
+	_, err = db.Exec(`
+	  CREATE TABLE IF NOT EXISTS public.postings (
+		  id VARCHAR(36) PRIMARY KEY,
+		  entry_id VARCHAR(36) NOT NULL REFERENCES journal_entries(id),
+		  account_id VARCHAR(36) NOT NULL,
+		  currency VARCHAR(3) NOT NULL DEFAULT 'AOA',
+		  amount MONEY NOT NULL,
+		  created_at TIMESTAMP NOT NULL
+		);`)
+	if err != nil {
+		panic(err)
+	}
+	_, err = db.Exec(`
+	  CREATE TABLE IF NOT EXISTS public.fx_rates (
+		  base_currency VARCHAR(3) NOT NULL,
+		  quote_currency VARCHAR(3) NOT NULL,
+		  rate NUMERIC(20, 10) NOT NULL CHECK (rate > 0),
+		  updated_at TIMESTAMP NOT NULL,
+		  PRIMARY KEY (base_currency, quote_currency)
+		);`)
+	if err != nil {
+		panic(err)
+	}
+	_, err = db.Exec(`
+	  CREATE TABLE IF NOT EXISTS public.fee_schedules (
+		  operation VARCHAR(10) NOT NULL,
+		  account_type VARCHAR(20) NOT NULL DEFAULT '',
+		  currency VARCHAR(3) NOT NULL,
+		  kind VARCHAR(20) NOT NULL,
+		  amount MONEY NOT NULL DEFAULT 0,
+		  percentage NUMERIC(13, 10) NULL,
+		  tiers JSONB NOT NULL DEFAULT '[]',
+		  updated_at TIMESTAMP NOT NULL,
+		  PRIMARY KEY (operation, account_type, currency)
+		);`)
+	if err != nil {
+		panic(err)
+	}
+	_, err = db.Exec(`
+	  CREATE TABLE IF NOT EXISTS public.account_limits (
+		  account_id VARCHAR(36) NOT NULL,
+		  operation VARCHAR(10) NOT NULL,
+		  currency VARCHAR(3) NOT NULL DEFAULT 'AOA',
+		  per_transaction MONEY NULL,
+		  daily_amount MONEY NULL,
+		  monthly_amount MONEY NULL,
+		  daily_count INTEGER NULL CHECK (daily_count >= 0),
+		  updated_at TIMESTAMP NOT NULL,
+		  PRIMARY KEY (account_id, operation)
+		);`)
+	if err != nil {
+		panic(err)
+	}
+	_, err = db.Exec(`
+	  CREATE TABLE IF NOT EXISTS public.holds (
+		  id VARCHAR(36) PRIMARY KEY,
+		  account_id VARCHAR(36) NOT NULL,
+		  to_account VARCHAR(36) NOT NULL,
+		  currency VARCHAR(3) NOT NULL DEFAULT 'AOA',
+		  amount MONEY NOT NULL,
+		  captured_amount MONEY NOT NULL DEFAULT 0,
+		  subject TEXT NOT NULL DEFAULT '',
+		  status VARCHAR(20) NOT NULL,
+		  transaction_id VARCHAR(36) NOT NULL DEFAULT '',
+		  expires_at TIMESTAMP NOT NULL,
+		  created_at TIMESTAMP NOT NULL,
+		  updated_at TIMESTAMP NOT NULL
+		);`)
+	if err != nil {
+		panic(err)
+	}
+	_, err = db.Exec(`
+	  CREATE TABLE IF NOT EXISTS public.scheduled_transfers (
+		  id VARCHAR(36) PRIMARY KEY,
+		  from_account VARCHAR(36) NOT NULL,
+		  currency VARCHAR(3) NOT NULL DEFAULT 'AOA',
+		  amount MONEY NOT NULL,
+		  subject TEXT NOT NULL DEFAULT '',
+		  legs JSONB NOT NULL DEFAULT '[]',
+		  execute_at TIMESTAMP NOT NULL,
+		  status VARCHAR(20) NOT NULL,
+		  batch_id VARCHAR(36) NOT NULL DEFAULT '',
+		  failure_reason TEXT NOT NULL DEFAULT '',
+		  executed_at TIMESTAMP NULL,
+		  created_at TIMESTAMP NOT NULL,
+		  updated_at TIMESTAMP NOT NULL
+		);`)
+	if err != nil {
+		panic(err)
+	}
+	_, err = db.Exec(`
+	  CREATE TABLE IF NOT EXISTS public.standing_orders (
+		  id VARCHAR(36) PRIMARY KEY,
+		  from_account VARCHAR(36) NOT NULL,
+		  currency VARCHAR(3) NOT NULL DEFAULT 'AOA',
+		  amount MONEY NOT NULL,
+		  subject TEXT NOT NULL DEFAULT '',
+		  beneficiaries JSONB NOT NULL DEFAULT '[]',
+		  recurrence JSONB NOT NULL,
+		  retry_window INTEGER NOT NULL DEFAULT 0,
+		  max_failures INTEGER NOT NULL CHECK (max_failures > 0),
+		  status VARCHAR(20) NOT NULL,
+		  occurrence INTEGER NOT NULL DEFAULT 0,
+		  due_at TIMESTAMP NOT NULL,
+		  next_run_at TIMESTAMP NOT NULL,
+		  attempts INTEGER NOT NULL DEFAULT 0,
+		  consecutive_failures INTEGER NOT NULL DEFAULT 0,
+		  created_at TIMESTAMP NOT NULL,
+		  updated_at TIMESTAMP NOT NULL
+		);`)
+	if err != nil {
+		panic(err)
+	}
+	_, err = db.Exec(`
+	  CREATE TABLE IF NOT EXISTS public.standing_order_runs (
+		  id VARCHAR(36) PRIMARY KEY,
+		  order_id VARCHAR(36) NOT NULL REFERENCES standing_orders(id),
+		  occurrence INTEGER NOT NULL,
+		  due_at TIMESTAMP NOT NULL,
+		  attempt INTEGER NOT NULL,
+		  status VARCHAR(20) NOT NULL,
+		  batch_id VARCHAR(36) NOT NULL DEFAULT '',
+		  legs JSONB NOT NULL DEFAULT '[]',
+		  failure_reason TEXT NOT NULL DEFAULT '',
+		  will_retry BOOLEAN NOT NULL DEFAULT FALSE,
+		  ran_at TIMESTAMP NOT NULL
+		);`)
+	if err != nil {
+		panic(err)
+	}
+	_, err = db.Exec(`
+	  CREATE TABLE IF NOT EXISTS public.operations (
+		  id VARCHAR(36) PRIMARY KEY,
+		  kind VARCHAR(20) NOT NULL,
+		  request JSONB NOT NULL,
+		  status VARCHAR(20) NOT NULL,
+		  result JSONB NULL,
+		  error TEXT NOT NULL DEFAULT '',
+		  locked_until TIMESTAMP NULL,
 		  created_at TIMESTAMP NOT NULL,
 		  updated_at TIMESTAMP NOT NULL,
-		  deletedAt TIMESTAMP NULL
+		  completed_at TIMESTAMP NULL
+		);`)
+	if err != nil {
+		panic(err)
+	}
+	_, err = db.Exec(`
+	  CREATE TABLE IF NOT EXISTS public.outbox_events (
+		  id VARCHAR(36) PRIMARY KEY,
+		  type VARCHAR(40) NOT NULL,
+		  payload JSONB NOT NULL,
+		  created_at TIMESTAMP NOT NULL,
+		  dispatched_at TIMESTAMP NULL
+		);`)
+	if err != nil {
+		panic(err)
+	}
+	_, err = db.Exec(`
+	  CREATE TABLE IF NOT EXISTS public.webhook_endpoints (
+		  id VARCHAR(36) PRIMARY KEY,
+		  url TEXT NOT NULL,
+		  secret VARCHAR(64) NOT NULL,
+		  event_types JSONB NOT NULL DEFAULT '[]',
+		  created_at TIMESTAMP NOT NULL
+		);`)
+	if err != nil {
+		panic(err)
+	}
+	_, err = db.Exec(`
+	  CREATE TABLE IF NOT EXISTS public.webhook_deliveries (
+		  id VARCHAR(36) PRIMARY KEY,
+		  endpoint_id VARCHAR(36) NOT NULL REFERENCES webhook_endpoints(id) ON DELETE CASCADE,
+		  event_id VARCHAR(36) NOT NULL,
+		  event_type VARCHAR(40) NOT NULL,
+		  payload JSONB NOT NULL,
+		  status VARCHAR(20) NOT NULL,
+		  attempts INTEGER NOT NULL DEFAULT 0,
+		  next_attempt_at TIMESTAMP NOT NULL,
+		  last_error TEXT NOT NULL DEFAULT '',
+		  response_status INTEGER NOT NULL DEFAULT 0,
+		  created_at TIMESTAMP NOT NULL,
+		  updated_at TIMESTAMP NOT NULL,
+		  delivered_at TIMESTAMP NULL
 		);`)
 	if err != nil {
 		panic(err)
@@ -58,10 +337,83 @@ func dropTables(db *sqlx.DB) {
 		panic(err)
 	}
 
+	_, err = db.Exec(`DROP TABLE IF EXISTS public.transaction_status_history;`)
+	if err != nil {
+		panic(err)
+	}
+
 	_, err = db.Exec(`DROP TABLE IF EXISTS public.accounts;`)
 	if err != nil {
 		panic(err)
 	}
+	_, err = db.Exec(`DROP TABLE IF EXISTS public.account_status_history;`)
+	if err != nil {
+		panic(err)
+	}
+	_, err = db.Exec(`DROP TABLE IF EXISTS public.owners;`)
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = db.Exec(`DROP TABLE IF EXISTS public.transfer_batches;`)
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = db.Exec(`DROP TABLE IF EXISTS public.postings;`)
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = db.Exec(`DROP TABLE IF EXISTS public.journal_entries;`)
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = db.Exec(`DROP TABLE IF EXISTS public.fx_rates;`)
+	if err != nil {
+		panic(err)
+	}
+	_, err = db.Exec(`DROP TABLE IF EXISTS public.holds;`)
+	if err != nil {
+		panic(err)
+	}
+	_, err = db.Exec(`DROP TABLE IF EXISTS public.fee_schedules;`)
+	if err != nil {
+		panic(err)
+	}
+	_, err = db.Exec(`DROP TABLE IF EXISTS public.account_limits;`)
+	if err != nil {
+		panic(err)
+	}
+	_, err = db.Exec(`DROP TABLE IF EXISTS public.scheduled_transfers;`)
+	if err != nil {
+		panic(err)
+	}
+	_, err = db.Exec(`DROP TABLE IF EXISTS public.standing_order_runs;`)
+	if err != nil {
+		panic(err)
+	}
+	_, err = db.Exec(`DROP TABLE IF EXISTS public.standing_orders;`)
+	if err != nil {
+		panic(err)
+	}
+	_, err = db.Exec(`DROP TABLE IF EXISTS public.operations;`)
+	if err != nil {
+		panic(err)
+	}
+	_, err = db.Exec(`DROP TABLE IF EXISTS public.webhook_deliveries;`)
+	if err != nil {
+		panic(err)
+	}
+	_, err = db.Exec(`DROP TABLE IF EXISTS public.webhook_endpoints;`)
+	if err != nil {
+		panic(err)
+	}
+	_, err = db.Exec(`DROP TABLE IF EXISTS public.outbox_events;`)
+	if err != nil {
+		panic(err)
+	}
 }
 func TestAccountHandler(t *testing.T) {
 
@@ -74,7 +426,30 @@ func TestAccountHandler(t *testing.T) {
 	transactionRepo := transactionrepo.NewATransactionRepo(db)
 	accountRepo := accountrepo.NewAccountRepo(db)
 
-	accountSrv := services.NewAccount(accountRepo, transactionRepo)
+	ledgerRepo := ledgerrepo.NewLedgerRepo(db)
+	uow := storage.NewPostgresUnitOfWork(db)
+
+	ownerRepo := ownerrepo.NewOwnerRepo(db)
+	fxRepo := fxrepo.NewFXRepo(db)
+	holdRepo := holdrepo.NewHoldRepo(db)
+	feeRepo := feerepo.NewFeeRepo(db)
+	limitRepo := limitrepo.NewLimitRepo(db)
+	scheduleRepo := schedulerepo.NewScheduleRepo(db)
+	operationRepo := operationrepo.NewOperationRepo(db)
+	outboxRepo := outboxrepo.NewOutboxRepo(db)
+	accountSrv := services.NewAccount(accountRepo, transactionRepo, ledgerRepo, ownerRepo, fxRepo, holdRepo, feeRepo, limitRepo, scheduleRepo, operationRepo, outboxRepo, uow)
+
+	for i, ownerId := range []string{
+		"1e55e90d-427e-4f0b-b71f-5e38e57c2ae8",
+		"1e55e903-427e-4f0b-b71f-5e38e57c2ae8",
+		"1e55e903-427e-4f0b-b74f-5e38e57c2ae8",
+		"1e55e903-427e-4f0b-b74f-5e38e57c2ae2",
+	} {
+		owner := types.NewOwner("Some Dumb Owner", fmt.Sprintf("00000000%dLA000", i), "", "", "")
+		owner.ID = ownerId
+		owner.KYCStatus = types.KYCVerified
+		ownerRepo.CreateOwner(context.Background(), owner)
+	}
 
 	accountHandler := &AccountHandler{
 		accountSrv: accountSrv,
@@ -144,19 +519,21 @@ func TestAccountHandler(t *testing.T) {
 			})
 		})
 
-		t.Run("POST /accounts should respond with 400 if there's already an account the specified owner_id", func(t *testing.T) {
-			expected := 400
+		t.Run("POST /accounts should respond with 201 for another account of an owner who already holds one", func(t *testing.T) {
+			expected := 201
 
 			ctx := context.Background()
 			owner_id := "1e55e90d-427e-4f0b-b71f-5e38e57c2ae8"
 			jsonPayload := fmt.Sprintf(`
 				{
 					"owner_id": "%v",
-					"balance": 100.0
+					"balance": 100.0,
+					"type": "savings",
+					"nickname": "holidays"
 				}
 			`, owner_id)
 
-			account := types.NewAccount(owner_id, 0)
+			account := types.NewAccount(owner_id, types.NewMoney(0, types.AOA))
 			accountSrv.CreateAccount(ctx, account)
 
 			body := strings.NewReader(jsonPayload)
@@ -190,7 +567,7 @@ func TestAccountHandler(t *testing.T) {
 			ctx := context.Background()
 			owner_id := "1e55e903-427e-4f0b-b71f-5e38e57c2ae8"
 
-			account := types.NewAccount(owner_id, 0)
+			account := types.NewAccount(owner_id, types.NewMoney(0, types.AOA))
 			accountSrv.CreateAccount(ctx, account)
 
 			amount := 100
@@ -229,7 +606,7 @@ func TestAccountHandler(t *testing.T) {
 			ctx := context.Background()
 			owner_id := "1e55e903-427e-4f0b-b71f-5e38e57c2ae8"
 
-			account := types.NewAccount(owner_id, 0)
+			account := types.NewAccount(owner_id, types.NewMoney(0, types.AOA))
 			accountSrv.CreateAccount(ctx, account)
 
 			amount := -100
@@ -305,7 +682,7 @@ func TestAccountHandler(t *testing.T) {
 			ctx := context.Background()
 			owner_id := "1e55e903-427e-4f0b-b71f-5e38e57c2ae8"
 
-			account := types.NewAccount(owner_id, 200)
+			account := types.NewAccount(owner_id, types.NewMoney(20000, types.AOA))
 			accountSrv.CreateAccount(ctx, account)
 
 			amount := 100
@@ -343,7 +720,7 @@ func TestAccountHandler(t *testing.T) {
 			ctx := context.Background()
 			owner_id := "1e55e903-427e-4f0b-b71f-5e38e57c2ae8"
 
-			account := types.NewAccount(owner_id, 0)
+			account := types.NewAccount(owner_id, types.NewMoney(0, types.AOA))
 			accountSrv.CreateAccount(ctx, account)
 
 			amount := -100
@@ -422,11 +799,11 @@ func TestAccountHandler(t *testing.T) {
 			accounts := []*types.Account{
 				{
 					Owner:   "1e55e903-427e-4f0b-b71f-5e38e57c2ae8",
-					Balance: 100,
+					Balance: types.NewMoney(10000, types.AOA),
 				},
 				{
 					Owner:   "1e55e903-427e-4f0b-b74f-5e38e57c2ae8",
-					Balance: 0,
+					Balance: types.NewMoney(0, types.AOA),
 				},
 			}
 
@@ -657,11 +1034,11 @@ func TestAccountHandler(t *testing.T) {
 			accounts := []*types.Account{
 				{
 					Owner:   "1e55e903-427e-4f0b-b71f-5e38e57c2ae8",
-					Balance: 100,
+					Balance: types.NewMoney(10000, types.AOA),
 				},
 				{
 					Owner:   "1e55e903-427e-4f0b-b74f-5e38e57c2ae8",
-					Balance: 0,
+					Balance: types.NewMoney(0, types.AOA),
 				},
 			}
 
@@ -725,11 +1102,11 @@ func TestAccountHandler(t *testing.T) {
 			accounts := []*types.Account{
 				{
 					Owner:   "1e55e903-427e-4f0b-b71f-5e38e57c2ae8",
-					Balance: 100,
+					Balance: types.NewMoney(10000, types.AOA),
 				},
 				{
 					Owner:   "1e55e903-427e-4f0b-b74f-5e38e57c2ae8",
-					Balance: 0,
+					Balance: types.NewMoney(0, types.AOA),
 				},
 			}
 
@@ -799,11 +1176,11 @@ func TestAccountHandler(t *testing.T) {
 			accounts := []*types.Account{
 				{
 					Owner:   "1e55e903-427e-4f0b-b71f-5e38e57c2ae8",
-					Balance: 100,
+					Balance: types.NewMoney(10000, types.AOA),
 				},
 				{
 					Owner:   "1e55e903-427e-4f0b-b74f-5e38e57c2ae8",
-					Balance: 0,
+					Balance: types.NewMoney(0, types.AOA),
 				},
 			}
 
@@ -813,7 +1190,7 @@ func TestAccountHandler(t *testing.T) {
 				accounts[i].ID = entity.ID
 			}
 
-			amount := 100.0
+			amount := types.NewMoney(10000, types.AOA)
 			transferParams := requestparams.TransferMoneyRequest{
 				From:    accounts[0].ID,
 				Amount:  amount,
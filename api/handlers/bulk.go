@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	requestparams "go-sample/api/handlers/request-params"
+	"go-sample/api/handlers/services"
+	"go-sample/types"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi"
+)
+
+// maxBulkTransferUpload is the largest CSV accepted, well above MaxBulkTransferRows rows
+const maxBulkTransferUpload = 4 << 20
+
+// BulkTransfer accepts the CSV either as the request body or as the file field of a multipart form.
+// A valid upload answers 202 and runs as an operation, its progress is the transfer batch
+func (ah *AccountHandler) BulkTransfer(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBulkTransferUpload)
+	var upload io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			writeError(w, http.StatusBadRequest, types.ErrInvalidBulkTransfer, "The CSV must be sent in the file field of the form")
+			return
+		}
+		defer file.Close()
+		upload = file
+	}
+
+	request, err := requestparams.ParseBulkTransferCSV(upload)
+	if err != nil || !request.Validate() {
+		writeError(w, http.StatusBadRequest, types.ErrInvalidBulkTransfer, "The file must be a CSV of account_id, amount and subject lines, with at most 5000 of them")
+		return
+	}
+	request.Subject = r.URL.Query().Get("subject")
+
+	batch, err := ah.accountSrv.BulkTransfer(r.Context(), chi.URLParam(r, "id"), *request)
+	var invalid *types.BulkTransferError
+	if errors.As(err, &invalid) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "Some rows are not valid, nothing was transferred",
+			"code":  err.Error(),
+			"rows":  invalid.Rows,
+		})
+		return
+	}
+	if errors.Is(err, services.ErrInexistentAccount) {
+		writeError(w, http.StatusNotFound, err, "There's no any bank account associated with this id")
+		return
+	}
+	if errors.Is(err, services.ErrKYCTransferLimitExceeded) {
+		writeError(w, http.StatusForbidden, err, "The total is above the transfer limit of the owner's KYC status")
+		return
+	}
+	if writeAccountStateError(w, err) {
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/transfer_batches/"+batch.ID)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(batch)
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	requestparams "go-sample/api/handlers/request-params"
+	"go-sample/api/handlers/services"
+	"go-sample/api/utils"
+	"net/http"
+)
+
+type FeeHandler struct {
+	feeSrv services.Fee
+}
+
+func NewFeeHandler(
+	feeSrv services.Fee,
+) *FeeHandler {
+	return &FeeHandler{
+		feeSrv: feeSrv,
+	}
+}
+
+func (fh *FeeHandler) LoadSchedules(w http.ResponseWriter, r *http.Request) {
+	var request requestparams.LoadFeeSchedulesRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || !request.Validate() {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "schedules must list at least one schedule for DEPOSIT, WITHDRAW or TRANSFER",
+		})
+		return
+	}
+
+	schedules, err := fh.feeSrv.LoadSchedules(r.Context(), request)
+	if errors.Is(err, services.ErrInvalidFeeSchedule) {
+		writeError(w, http.StatusBadRequest, err, "Schedules need a supported currency and account type, a FIXED amount, a PERCENTAGE up to 100 or TIERED tiers with distinct up_to bounds")
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(schedules)
+}
+
+func (fh *FeeHandler) ListSchedules(w http.ResponseWriter, r *http.Request) {
+	schedules, err := fh.feeSrv.ListSchedules(r.Context())
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(schedules)
+}
+
+func (fh *FeeHandler) Quote(w http.ResponseWriter, r *http.Request) {
+	data, err := utils.ExtracteQueryParams(r)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	request := new(requestparams.FeeQuoteRequest)
+	if err = json.Unmarshal(data, request); err != nil || !request.Validate() {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "operation must be DEPOSIT, WITHDRAW or TRANSFER, account_id is required and amount must be positive",
+		})
+		return
+	}
+
+	quote, err := fh.feeSrv.Quote(r.Context(), *request)
+	if errors.Is(err, services.ErrInexistentAccount) {
+		writeError(w, http.StatusNotFound, err, "There's no any bank account associated with this id")
+		return
+	}
+	if writeCurrencyError(w, err) {
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(quote)
+}
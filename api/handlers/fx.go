@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	requestparams "go-sample/api/handlers/request-params"
+	"go-sample/api/handlers/services"
+	"net/http"
+)
+
+type FXHandler struct {
+	fxSrv services.FX
+}
+
+func NewFXHandler(
+	fxSrv services.FX,
+) *FXHandler {
+	return &FXHandler{
+		fxSrv: fxSrv,
+	}
+}
+
+func (fh *FXHandler) LoadRates(w http.ResponseWriter, r *http.Request) {
+	var request requestparams.LoadFXRatesRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || !request.Validate() {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "rates must list at least one {base, quote, rate}",
+		})
+		return
+	}
+
+	rates, err := fh.fxSrv.LoadRates(r.Context(), request)
+	if errors.Is(err, services.ErrInvalidExchangeRate) {
+		writeError(w, http.StatusBadRequest, err, "Rates need two different supported currencies and a positive rate with at most 10 decimal places")
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rates)
+}
+
+func (fh *FXHandler) ListRates(w http.ResponseWriter, r *http.Request) {
+	rates, err := fh.fxSrv.ListRates(r.Context())
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rates)
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	requestparams "go-sample/api/handlers/request-params"
+	"go-sample/api/handlers/services"
+	"go-sample/api/utils"
+	"go-sample/types"
+	"net/http"
+
+	"github.com/go-chi/chi"
+)
+
+func (ah *AccountHandler) AuthorizeHold(w http.ResponseWriter, r *http.Request) {
+	var request requestparams.AuthorizeHoldRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || !request.Validate() {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "to and a positive amount are required, expires_in must be at most 30 days in seconds",
+		})
+		return
+	}
+
+	hold, err := ah.accountSrv.AuthorizeHold(r.Context(), chi.URLParam(r, "id"), request)
+	if errors.Is(err, services.ErrInexistentAccount) {
+		writeError(w, http.StatusNotFound, err, "The account or the recipient does not exist")
+		return
+	}
+	if errors.Is(err, services.ErrInvalidHoldRecipient) {
+		writeError(w, http.StatusBadRequest, err, "A hold can not be placed in favour of the account itself")
+		return
+	}
+	if errors.Is(err, services.ErrInsufficientFunds) {
+		writeError(w, http.StatusBadRequest, err, "Insufficient available balance to place the hold")
+		return
+	}
+	if errors.Is(err, services.ErrKYCTransferLimitExceeded) {
+		writeError(w, http.StatusForbidden, err, "The amount is above the transfer limit of the owner's KYC status")
+		return
+	}
+	if writeAccountStateError(w, err) || writeCurrencyError(w, err) {
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(hold)
+}
+
+func (ah *AccountHandler) ListHolds(w http.ResponseWriter, r *http.Request) {
+	data, err := utils.ExtracteQueryParams(r)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	filter := new(requestparams.ListHoldsRequest)
+	if err = json.Unmarshal(data, filter); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if !filter.Validate() {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "invalid filters: status must be ACTIVE, CAPTURED, VOIDED or EXPIRED, limit a positive number and cursor one returned by a previous page",
+		})
+		return
+	}
+
+	holds, err := ah.accountSrv.ListHolds(r.Context(), chi.URLParam(r, "id"), *filter)
+	if errors.Is(err, services.ErrInexistentAccount) {
+		writeError(w, http.StatusNotFound, err, "There's no any bank account associated with this id")
+		return
+	}
+	if errors.Is(err, types.ErrInvalidCursor) {
+		writeInvalidCursor(w)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(holds)
+}
+
+func (ah *AccountHandler) GetHold(w http.ResponseWriter, r *http.Request) {
+	hold, err := ah.accountSrv.GetHold(r.Context(), chi.URLParam(r, "id"))
+	if writeHoldError(w, err) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hold)
+}
+
+func (ah *AccountHandler) CaptureHold(w http.ResponseWriter, r *http.Request) {
+	var request requestparams.CaptureHoldRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || !request.Validate() {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "amount must be positive, leave it out to capture the whole hold",
+			})
+			return
+		}
+	}
+
+	hold, err := ah.accountSrv.CaptureHold(r.Context(), chi.URLParam(r, "id"), request.Amount)
+	if errors.Is(err, services.ErrCaptureExceedsHold) {
+		writeError(w, http.StatusBadRequest, err, "The amount must be in the hold currency and not above the held amount")
+		return
+	}
+	if writeAccountStateError(w, err) || writeCurrencyError(w, err) || writeLimitError(w, err) || writeHoldError(w, err) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hold)
+}
+
+func (ah *AccountHandler) VoidHold(w http.ResponseWriter, r *http.Request) {
+	hold, err := ah.accountSrv.VoidHold(r.Context(), chi.URLParam(r, "id"))
+	if writeHoldError(w, err) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hold)
+}
+
+// writeHoldError answers for the errors shared by the hold endpoints, it reports whether anything was written
+func writeHoldError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, services.ErrInexistentHold):
+		writeError(w, http.StatusNotFound, err, "There's no hold associated with this id")
+	case errors.Is(err, services.ErrInexistentAccount):
+		writeError(w, http.StatusNotFound, err, "The recipient of the hold does not exist anymore")
+	case errors.Is(err, services.ErrHoldNotActive):
+		writeError(w, http.StatusConflict, err, "The hold was already captured, voided or expired")
+	case errors.Is(err, services.ErrHoldExpired):
+		writeError(w, http.StatusConflict, err, "The hold expired and can no longer be captured")
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	return true
+}
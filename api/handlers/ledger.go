@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"encoding/json"
+	"go-sample/api/handlers/services"
+	"net/http"
+
+	"github.com/go-chi/chi"
+)
+
+type LedgerHandler struct {
+	ledgerSrv services.Ledger
+}
+
+func NewLedgerHandler(
+	ledgerSrv services.Ledger,
+) *LedgerHandler {
+	return &LedgerHandler{
+		ledgerSrv: ledgerSrv,
+	}
+}
+
+func (lh *LedgerHandler) GetTrialBalance(w http.ResponseWriter, r *http.Request) {
+	trialBalance, err := lh.ledgerSrv.GetTrialBalance(r.Context())
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(trialBalance)
+}
+
+func (lh *LedgerHandler) GetPostedBalance(w http.ResponseWriter, r *http.Request) {
+	accountId := chi.URLParam(r, "id")
+	balance, err := lh.ledgerSrv.GetPostedBalance(r.Context(), accountId)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"account_id":     accountId,
+		"posted_balance": balance,
+	})
+}
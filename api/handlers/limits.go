@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	requestparams "go-sample/api/handlers/request-params"
+	"go-sample/api/handlers/services"
+	"go-sample/types"
+	"net/http"
+
+	"github.com/go-chi/chi"
+)
+
+func (ah *AccountHandler) SetLimit(w http.ResponseWriter, r *http.Request) {
+	accountId := chi.URLParam(r, "id")
+	account, err := ah.accountSrv.GetAccount(r.Context(), accountId)
+	if errors.Is(err, services.ErrInexistentAccount) || (err == nil && account == nil) {
+		writeError(w, http.StatusNotFound, services.ErrInexistentAccount, "There's no any bank account associated with this id")
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// a bare amount is read in the account currency
+	request := requestparams.SetLimitRequest{Currency: account.Currency}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || !request.Validate() {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "operation must be WITHDRAW or TRANSFER, the amounts valid and daily_count zero or greater",
+		})
+		return
+	}
+
+	limit, err := ah.accountSrv.SetLimit(r.Context(), accountId, request)
+	if errors.Is(err, services.ErrInexistentAccount) {
+		writeError(w, http.StatusNotFound, err, "There's no any bank account associated with this id")
+		return
+	}
+	if errors.Is(err, services.ErrInvalidLimit) {
+		writeError(w, http.StatusBadRequest, err, "Limits must be positive amounts in the account currency")
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(limit)
+}
+
+func (ah *AccountHandler) GetLimits(w http.ResponseWriter, r *http.Request) {
+	limits, err := ah.accountSrv.GetLimits(r.Context(), chi.URLParam(r, "id"))
+	if errors.Is(err, services.ErrInexistentAccount) {
+		writeError(w, http.StatusNotFound, err, "There's no any bank account associated with this id")
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(limits)
+}
+
+// writeLimitError answers 403 with the limit that was exceeded and what is left of every limit
+func writeLimitError(w http.ResponseWriter, err error) bool {
+	var exceeded *types.LimitExceededError
+	if !errors.As(err, &exceeded) {
+		return false
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":     "The operation goes past the " + exceeded.Limit + " limit of the account",
+		"code":      err.Error(),
+		"operation": exceeded.Operation,
+		"limit":     exceeded.Limit,
+		"remaining": exceeded.Remaining,
+	})
+	return true
+}
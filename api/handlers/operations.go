@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"go-sample/api/handlers/services"
+	"go-sample/types"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+)
+
+func (ah *AccountHandler) GetOperation(w http.ResponseWriter, r *http.Request) {
+	operation, err := ah.accountSrv.GetOperation(r.Context(), chi.URLParam(r, "id"))
+	if errors.Is(err, services.ErrInexistentOperation) {
+		writeError(w, http.StatusNotFound, err, "There's no operation associated with this id")
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(operation)
+}
+
+// isAsync reports whether the client asked with ?async=true to run the request as an operation
+func isAsync(r *http.Request) bool {
+	async, _ := strconv.ParseBool(r.URL.Query().Get("async"))
+	return async
+}
+
+// writeOperationAccepted answers a request stored as an operation, the client polls the Location for its outcome
+func writeOperationAccepted(w http.ResponseWriter, operation *types.Operation) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/operations/"+operation.ID)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(operation)
+}
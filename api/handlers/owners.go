@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	requestparams "go-sample/api/handlers/request-params"
+	"go-sample/api/handlers/services"
+	"go-sample/api/utils"
+	"go-sample/types"
+	"net/http"
+
+	"github.com/go-chi/chi"
+)
+
+type OwnerHandler struct {
+	ownerSrv services.Owner
+}
+
+func NewOwnerHandler(
+	ownerSrv services.Owner,
+) *OwnerHandler {
+	return &OwnerHandler{
+		ownerSrv: ownerSrv,
+	}
+}
+
+func (oh *OwnerHandler) CreateOwner(w http.ResponseWriter, r *http.Request) {
+	var owner types.Owner
+	if err := json.NewDecoder(r.Body).Decode(&owner); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	entity := types.NewOwner(owner.Name, owner.NationalId, owner.Email, owner.Phone, owner.Address)
+	if !entity.ValidateOwner() {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "name and a national id of 9 to 14 letters and digits are required",
+		})
+		return
+	}
+
+	err := oh.ownerSrv.CreateOwner(r.Context(), entity)
+	if errors.Is(err, services.ErrDuplicateNationalId) {
+		writeError(w, http.StatusConflict, err, "There's already an owner with this national id")
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(entity)
+}
+
+func (oh *OwnerHandler) ListOwners(w http.ResponseWriter, r *http.Request) {
+	data, err := utils.ExtracteQueryParams(r)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	filter := new(requestparams.ListOwnersRequest)
+	if err = json.Unmarshal(data, filter); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if !filter.Validate() {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "invalid filters: kyc_status must be PENDING, VERIFIED or REJECTED, limit a positive number and cursor one returned by a previous page",
+		})
+		return
+	}
+
+	owners, err := oh.ownerSrv.ListOwners(r.Context(), *filter)
+	if errors.Is(err, types.ErrInvalidCursor) {
+		writeInvalidCursor(w)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(owners)
+}
+
+func (oh *OwnerHandler) GetOwner(w http.ResponseWriter, r *http.Request) {
+	owner, err := oh.ownerSrv.GetOwner(r.Context(), chi.URLParam(r, "id"))
+	if writeOwnerError(w, err) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(owner)
+}
+
+func (oh *OwnerHandler) UpdateOwner(w http.ResponseWriter, r *http.Request) {
+	var request requestparams.UpdateOwnerRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	owner, err := oh.ownerSrv.UpdateOwner(r.Context(), chi.URLParam(r, "id"), request)
+	if writeOwnerError(w, err) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(owner)
+}
+
+func (oh *OwnerHandler) UpdateKYCStatus(w http.ResponseWriter, r *http.Request) {
+	var request requestparams.UpdateKYCStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || !request.Validate() {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "status must be PENDING, VERIFIED or REJECTED",
+		})
+		return
+	}
+
+	owner, err := oh.ownerSrv.SetKYCStatus(r.Context(), chi.URLParam(r, "id"), request.Status)
+	if writeOwnerError(w, err) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(owner)
+}
+
+func (oh *OwnerHandler) DeleteOwner(w http.ResponseWriter, r *http.Request) {
+	err := oh.ownerSrv.DeleteOwner(r.Context(), chi.URLParam(r, "id"))
+	if writeOwnerError(w, err) {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeOwnerError answers every error the owner service can return, it reports whether err was written
+func writeOwnerError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, services.ErrInexistentOwner):
+		writeError(w, http.StatusNotFound, err, "There's no any owner associated with this id")
+	case errors.Is(err, services.ErrInvalidOwner):
+		writeError(w, http.StatusBadRequest, err, "name and a national id of 9 to 14 letters and digits are required")
+	case errors.Is(err, services.ErrInvalidKYCStatusTransition):
+		writeError(w, http.StatusConflict, err, "The KYC status can not change from its current value to the requested one")
+	case errors.Is(err, services.ErrOwnerHasOpenAccounts):
+		writeError(w, http.StatusConflict, err, "Close every account of the owner before deleting it")
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	return true
+}
@@ -0,0 +1,62 @@
+package requestparams
+
+import (
+	"encoding/csv"
+	"errors"
+	"go-sample/types"
+	"io"
+	"strings"
+)
+
+// BulkTransferRow is a line of an uploaded bulk transfer, Row is its line number in the file
+type BulkTransferRow struct {
+	Row       int
+	AccountId string
+	Amount    string
+	Subject   string
+}
+
+type BulkTransferRequest struct {
+	Subject string
+	Rows    []BulkTransferRow
+
+	// lines that could not be read as a row, reported along with the rows refused later
+	Errors []types.BulkRowError
+}
+
+// ParseBulkTransferCSV reads account_id,amount,subject lines, the subject is optional and a first
+// line naming the columns is skipped. It only fails when the file is not CSV at all
+func ParseBulkTransferCSV(r io.Reader) (*BulkTransferRequest, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	request := &BulkTransferRequest{}
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return request, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		if first && strings.EqualFold(strings.TrimSpace(record[0]), "account_id") {
+			continue
+		}
+		if len(record) < 2 || len(record) > 3 {
+			request.Errors = append(request.Errors, types.BulkRowError{Row: line, Error: "InvalidColumns"})
+			continue
+		}
+		row := BulkTransferRow{Row: line, AccountId: strings.TrimSpace(record[0]), Amount: strings.TrimSpace(record[1])}
+		if len(record) == 3 {
+			row.Subject = strings.TrimSpace(record[2])
+		}
+		request.Rows = append(request.Rows, row)
+	}
+}
+
+func (r *BulkTransferRequest) Validate() bool {
+	count := len(r.Rows) + len(r.Errors)
+	return count > 0 && count <= types.MaxBulkTransferRows
+}
@@ -0,0 +1,11 @@
+package requestparams
+
+type ChangeAccountStatusRequest struct {
+	Reason  string `json:"reason"`
+	Actor   string `json:"actor"`
+	SweepTo string `json:"sweep_to"`
+}
+
+func (req *ChangeAccountStatusRequest) Validate() bool {
+	return req.Reason != "" && req.Actor != ""
+}
@@ -0,0 +1,11 @@
+package requestparams
+
+import "go-sample/types"
+
+type SetCreditLimitRequest struct {
+	CreditLimit types.Money `json:"credit_limit"`
+}
+
+func (req *SetCreditLimitRequest) Validate() bool {
+	return !req.CreditLimit.IsNegative()
+}
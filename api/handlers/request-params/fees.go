@@ -0,0 +1,73 @@
+package requestparams
+
+import (
+	"encoding/json"
+	"go-sample/api/utils"
+	"go-sample/types"
+	"strings"
+)
+
+// amounts are plain decimals in the currency of the schedule
+type FeeTier struct {
+	UpTo       json.Number `json:"up_to"`
+	Amount     json.Number `json:"amount"`
+	Percentage json.Number `json:"percentage"`
+}
+
+type FeeSchedule struct {
+	Operation   string            `json:"operation"`
+	AccountType types.AccountType `json:"account_type"`
+	Currency    types.Currency    `json:"currency"`
+	Kind        types.FeeKind     `json:"kind"`
+	Amount      json.Number       `json:"amount"`
+	Percentage  json.Number       `json:"percentage"`
+	Tiers       []FeeTier         `json:"tiers"`
+}
+
+type LoadFeeSchedulesRequest struct {
+	Schedules []FeeSchedule `json:"schedules"`
+}
+
+func (req *LoadFeeSchedulesRequest) Validate() bool {
+	if len(req.Schedules) == 0 {
+		return false
+	}
+	for i := range req.Schedules {
+		if !IsChargeableOperation(req.Schedules[i].Operation) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsChargeableOperation reports whether fees can be charged on the operation, refunds never are
+func IsChargeableOperation(operation string) bool {
+	switch utils.OP(strings.ToUpper(operation)) {
+	case utils.DEPOSIT, utils.WITHDRAW, utils.TRANSFER:
+		return true
+	}
+	return false
+}
+
+type FeeQuoteRequest struct {
+	Operation string `json:"operation"`
+	AccountId string `json:"account_id"`
+	Amount    string `json:"amount"`
+	Currency  string `json:"currency"`
+
+	// parsed by Validate
+	AmountOf types.Money `json:"-"`
+}
+
+func (req *FeeQuoteRequest) Validate() bool {
+	req.Operation = strings.ToUpper(req.Operation)
+	if !IsChargeableOperation(req.Operation) || req.AccountId == "" {
+		return false
+	}
+	amount, err := types.ParseMoney(req.Amount, types.Currency(strings.ToUpper(req.Currency)))
+	if err != nil || !amount.IsPositive() {
+		return false
+	}
+	req.AmountOf = amount
+	return true
+}
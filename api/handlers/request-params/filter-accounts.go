@@ -1,26 +1,120 @@
 package requestparams
 
-import "strconv"
+import (
+	"go-sample/types"
+	"strings"
+	"time"
+)
 
 type ListAccountsRequest struct {
-	Limit      string `json:"limit"`
-	Page       string `json:"page"`
-	OwnerId    string `json:"owner_id"`
-	CreateadAt string `json:"createad_at"`
+	Pagination
+	OwnerId       string `json:"owner_id"`
+	CreateadAt    string `json:"createad_at"`
+	CreatedAfter  string `json:"created_after"`
+	CreatedBefore string `json:"created_before"`
+	MinBalance    string `json:"min_balance"`
+	MaxBalance    string `json:"max_balance"`
+	Status        string `json:"status"`
+	Currency      string `json:"currency"`
+	SortBy        string `json:"sort_by"`
+	Sort          string `json:"sort"`
+
+	// parsed by Validate
+	CreatedFrom  *time.Time          `json:"-"`
+	CreatedUntil *time.Time          `json:"-"`
+	MinBalanceOf *types.Money        `json:"-"`
+	MaxBalanceOf *types.Money        `json:"-"`
+	SortColumn   string              `json:"-"`
+	SortOrder    string              `json:"-"`
+	StatusFilter types.AccountStatus `json:"-"`
+	CurrencyOf   types.Currency      `json:"-"`
 }
 
+// Validate parses the filters, created_after is inclusive and created_before exclusive
 func (r *ListAccountsRequest) Validate() bool {
-	if r.Limit == "" {
-		r.Limit = "10"
+	if !r.Pagination.Validate() {
+		return false
+	}
+
+	if r.CreateadAt != "" {
+		day, err := time.ParseInLocation(dateLayout, r.CreateadAt, time.Local)
+		if err != nil {
+			return false
+		}
+		nextDay := day.AddDate(0, 0, 1)
+		r.CreatedFrom, r.CreatedUntil = &day, &nextDay
+	}
+	if r.CreatedAfter != "" {
+		after, _, ok := parseDate(r.CreatedAfter)
+		if !ok {
+			return false
+		}
+		if r.CreatedFrom == nil || after.After(*r.CreatedFrom) {
+			r.CreatedFrom = &after
+		}
+	}
+	if r.CreatedBefore != "" {
+		before, _, ok := parseDate(r.CreatedBefore)
+		if !ok {
+			return false
+		}
+		if r.CreatedUntil == nil || before.Before(*r.CreatedUntil) {
+			r.CreatedUntil = &before
+		}
+	}
+	if r.CreatedFrom != nil && r.CreatedUntil != nil && !r.CreatedFrom.Before(*r.CreatedUntil) {
+		return false
+	}
 
+	if r.MinBalance != "" {
+		balance, err := types.ParseMoney(r.MinBalance, types.DefaultCurrency)
+		if err != nil {
+			return false
+		}
+		r.MinBalanceOf = &balance
+	}
+	if r.MaxBalance != "" {
+		balance, err := types.ParseMoney(r.MaxBalance, types.DefaultCurrency)
+		if err != nil {
+			return false
+		}
+		r.MaxBalanceOf = &balance
+	}
+	if r.MinBalanceOf != nil && r.MaxBalanceOf != nil && r.MinBalanceOf.GreaterThan(*r.MaxBalanceOf) {
+		return false
+	}
+
+	switch status := types.AccountStatus(strings.ToUpper(r.Status)); status {
+	case "":
+	case types.AccountActive, types.AccountFrozen, types.AccountClosed:
+		r.StatusFilter = status
+	default:
+		return false
 	}
 
-	if r.Page == "" {
-		r.Page = "1"
+	if r.Currency != "" {
+		r.CurrencyOf = types.Currency(strings.ToUpper(r.Currency))
+		if !r.CurrencyOf.IsValid() {
+			return false
+		}
 	}
-	page, _ := strconv.ParseInt(r.Page, 10, 64)
-	if page <= 0 {
-		r.Page = "1"
+
+	switch strings.ToLower(r.SortBy) {
+	case "", "created_at":
+		r.SortColumn = "created_at"
+	case "balance":
+		r.SortColumn = "balance"
+	default:
+		return false
+	}
+
+	switch strings.ToLower(r.Sort) {
+	case "", "asc":
+		r.SortOrder = "ASC"
+	case "desc":
+		r.SortOrder = "DESC"
+	default:
+		return false
 	}
 
 	return true
@@ -1,29 +1,132 @@
 package requestparams
 
-import "strconv"
+import (
+	"go-sample/api/utils"
+	"go-sample/types"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
 
 type GetTransactionsHistoryRequest struct {
-	Limit    string `json:"limit"`
-	Page     string `json:"page"`
-	Sort     string `json:"sort"`
-	Date     string `json:"date"`
-	FromDate string `json:"from_date"`
-	Todate   string `json:"to_date"`
+	Pagination
+	Sort               string `json:"sort"`
+	Date               string `json:"date"`
+	FromDate           string `json:"from_date"`
+	Todate             string `json:"to_date"`
+	Operation          string `json:"operation"`
+	MinAmount          string `json:"min_amount"`
+	MaxAmount          string `json:"max_amount"`
+	IsRefund           string `json:"is_refund"`
+	MultiBeneficiaryId string `json:"multi_beneficiary_id"`
+
+	// parsed by Validate
+	SortOrder     string       `json:"-"`
+	CreatedFrom   *time.Time   `json:"-"`
+	CreatedBefore *time.Time   `json:"-"`
+	MinAmountOf   *types.Money `json:"-"`
+	MaxAmountOf   *types.Money `json:"-"`
+	IsRefundValue *bool        `json:"-"`
 }
 
 func (r *GetTransactionsHistoryRequest) Validate() bool {
-	if r.Limit == "" {
-		r.Limit = "10"
+	if !r.Pagination.Validate() {
+		return false
+	}
 
+	switch strings.ToLower(r.Sort) {
+	case "", "desc":
+		r.SortOrder = "DESC"
+	case "asc":
+		r.SortOrder = "ASC"
+	default:
+		return false
 	}
 
-	if r.Page == "" {
-		r.Page = "1"
+	if r.Date != "" {
+		day, err := time.ParseInLocation(dateLayout, r.Date, time.Local)
+		if err != nil {
+			return false
+		}
+		nextDay := day.AddDate(0, 0, 1)
+		r.CreatedFrom, r.CreatedBefore = &day, &nextDay
+	}
+	if r.FromDate != "" {
+		from, _, ok := parseDate(r.FromDate)
+		if !ok {
+			return false
+		}
+		if r.CreatedFrom == nil || from.After(*r.CreatedFrom) {
+			r.CreatedFrom = &from
+		}
+	}
+	if r.Todate != "" {
+		to, isDay, ok := parseDate(r.Todate)
+		if !ok {
+			return false
+		}
+		// to_date is inclusive: the whole day for dates, up to the microsecond postgres keeps otherwise
+		if isDay {
+			to = to.AddDate(0, 0, 1)
+		} else {
+			to = to.Add(time.Microsecond)
+		}
+		if r.CreatedBefore == nil || to.Before(*r.CreatedBefore) {
+			r.CreatedBefore = &to
+		}
 	}
-	page, _ := strconv.ParseInt(r.Page, 10, 64)
-	if page <= 0 {
-		r.Page = "1"
+	if r.CreatedFrom != nil && r.CreatedBefore != nil && !r.CreatedFrom.Before(*r.CreatedBefore) {
+		return false
+	}
+
+	if r.Operation != "" {
+		r.Operation = strings.ToUpper(r.Operation)
+		switch utils.OP(r.Operation) {
+		case utils.DEPOSIT, utils.WITHDRAW, utils.TRANSFER, utils.REFUND, utils.FEE:
+		default:
+			return false
+		}
+	}
+
+	if r.MinAmount != "" {
+		amount, err := types.ParseMoney(r.MinAmount, types.DefaultCurrency)
+		if err != nil {
+			return false
+		}
+		r.MinAmountOf = &amount
+	}
+	if r.MaxAmount != "" {
+		amount, err := types.ParseMoney(r.MaxAmount, types.DefaultCurrency)
+		if err != nil {
+			return false
+		}
+		r.MaxAmountOf = &amount
+	}
+	if r.MinAmountOf != nil && r.MaxAmountOf != nil && r.MinAmountOf.GreaterThan(*r.MaxAmountOf) {
+		return false
+	}
+
+	if r.IsRefund != "" {
+		isRefund, err := strconv.ParseBool(r.IsRefund)
+		if err != nil {
+			return false
+		}
+		r.IsRefundValue = &isRefund
 	}
 
 	return true
 }
+
+// parseDate accepts RFC 3339 timestamps and YYYY-MM-DD dates, isDay tells which one it was.
+// Timestamps are converted to local time, which is how the timestamp columns are stored
+func parseDate(value string) (t time.Time, isDay bool, ok bool) {
+	if day, err := time.ParseInLocation(dateLayout, value, time.Local); err == nil {
+		return day, true, true
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.In(time.Local), false, true
+	}
+	return time.Time{}, false, false
+}
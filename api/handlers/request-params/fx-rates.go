@@ -0,0 +1,20 @@
+package requestparams
+
+import (
+	"encoding/json"
+	"go-sample/types"
+)
+
+type FXRate struct {
+	Base  types.Currency `json:"base"`
+	Quote types.Currency `json:"quote"`
+	Rate  json.Number    `json:"rate"`
+}
+
+type LoadFXRatesRequest struct {
+	Rates []FXRate `json:"rates"`
+}
+
+func (req *LoadFXRatesRequest) Validate() bool {
+	return len(req.Rates) > 0
+}
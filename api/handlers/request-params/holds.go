@@ -0,0 +1,59 @@
+package requestparams
+
+import (
+	"go-sample/types"
+	"strings"
+	"time"
+)
+
+type AuthorizeHoldRequest struct {
+	To        string      `json:"to"`
+	Amount    types.Money `json:"amount"`
+	Subject   string      `json:"subject"`
+	ExpiresIn int64       `json:"expires_in"`
+}
+
+func (r *AuthorizeHoldRequest) Validate() bool {
+	if r.To == "" || !r.Amount.IsPositive() || r.ExpiresIn < 0 {
+		return false
+	}
+	return r.Duration() <= types.MaxHoldDuration
+}
+
+// Duration is ExpiresIn seconds, holds sent without it last types.DefaultHoldDuration
+func (r *AuthorizeHoldRequest) Duration() time.Duration {
+	if r.ExpiresIn == 0 {
+		return types.DefaultHoldDuration
+	}
+	return time.Duration(r.ExpiresIn) * time.Second
+}
+
+// CaptureHoldRequest captures the whole hold when Amount is not sent
+type CaptureHoldRequest struct {
+	Amount *types.Money `json:"amount"`
+}
+
+func (r *CaptureHoldRequest) Validate() bool {
+	return r.Amount == nil || r.Amount.IsPositive()
+}
+
+type ListHoldsRequest struct {
+	Pagination
+	Status string `json:"status"`
+
+	// parsed by Validate
+	StatusFilter types.HoldStatus `json:"-"`
+}
+
+func (r *ListHoldsRequest) Validate() bool {
+	if !r.Pagination.Validate() {
+		return false
+	}
+	if r.Status != "" {
+		r.StatusFilter = types.HoldStatus(strings.ToUpper(r.Status))
+		if !r.StatusFilter.IsValid() {
+			return false
+		}
+	}
+	return true
+}
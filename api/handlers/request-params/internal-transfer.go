@@ -0,0 +1,14 @@
+package requestparams
+
+import "go-sample/types"
+
+type InternalTransferRequest struct {
+	From    string      `json:"from"`
+	To      string      `json:"to"`
+	Amount  types.Money `json:"amount"`
+	Subject string      `json:"subject"`
+}
+
+func (req *InternalTransferRequest) Validate() bool {
+	return req.From != "" && req.To != "" && req.From != req.To && req.Amount.IsPositive()
+}
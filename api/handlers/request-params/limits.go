@@ -0,0 +1,62 @@
+package requestparams
+
+import (
+	"encoding/json"
+	"go-sample/api/utils"
+	"go-sample/types"
+	"strings"
+)
+
+// SetLimitRequest replaces every limit of the operation, the ones left out are not limited.
+// Set Currency before decoding, bare amounts are read in it
+type SetLimitRequest struct {
+	Operation      string         `json:"operation"`
+	PerTransaction *types.Money   `json:"per_transaction"`
+	DailyAmount    *types.Money   `json:"daily_amount"`
+	MonthlyAmount  *types.Money   `json:"monthly_amount"`
+	DailyCount     *int           `json:"daily_count"`
+	Currency       types.Currency `json:"-"`
+}
+
+func (req *SetLimitRequest) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Operation      string          `json:"operation"`
+		PerTransaction json.RawMessage `json:"per_transaction"`
+		DailyAmount    json.RawMessage `json:"daily_amount"`
+		MonthlyAmount  json.RawMessage `json:"monthly_amount"`
+		DailyCount     *int            `json:"daily_count"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	req.Operation = raw.Operation
+	req.DailyCount = raw.DailyCount
+	for _, field := range []struct {
+		raw    json.RawMessage
+		amount **types.Money
+	}{
+		{raw.PerTransaction, &req.PerTransaction},
+		{raw.DailyAmount, &req.DailyAmount},
+		{raw.MonthlyAmount, &req.MonthlyAmount},
+	} {
+		if len(field.raw) == 0 || string(field.raw) == "null" {
+			continue
+		}
+		amount := types.NewMoney(0, req.Currency)
+		if err := json.Unmarshal(field.raw, &amount); err != nil {
+			return err
+		}
+		*field.amount = &amount
+	}
+	return nil
+}
+
+func (req *SetLimitRequest) Validate() bool {
+	req.Operation = strings.ToUpper(req.Operation)
+	switch utils.OP(req.Operation) {
+	case utils.WITHDRAW, utils.TRANSFER:
+		return req.DailyCount == nil || *req.DailyCount >= 0
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package requestparams
+
+import (
+	"go-sample/types"
+	"strings"
+)
+
+type ListOwnersRequest struct {
+	Pagination
+	Name      string `json:"name"`
+	KYCStatus string `json:"kyc_status"`
+
+	// parsed by Validate
+	KYCStatusFilter types.KYCStatus `json:"-"`
+}
+
+func (r *ListOwnersRequest) Validate() bool {
+	if !r.Pagination.Validate() {
+		return false
+	}
+	if r.KYCStatus != "" {
+		r.KYCStatusFilter = types.KYCStatus(strings.ToUpper(r.KYCStatus))
+		if !r.KYCStatusFilter.IsValid() {
+			return false
+		}
+	}
+	return true
+}
+
+// UpdateOwnerRequest only changes the fields that are sent
+type UpdateOwnerRequest struct {
+	Name    *string `json:"name"`
+	Email   *string `json:"email"`
+	Phone   *string `json:"phone"`
+	Address *string `json:"address"`
+}
+
+func (r *UpdateOwnerRequest) Apply(owner *types.Owner) {
+	if r.Name != nil {
+		owner.Name = *r.Name
+	}
+	if r.Email != nil {
+		owner.Email = *r.Email
+	}
+	if r.Phone != nil {
+		owner.Phone = *r.Phone
+	}
+	if r.Address != nil {
+		owner.Address = *r.Address
+	}
+}
+
+type UpdateKYCStatusRequest struct {
+	Status types.KYCStatus `json:"status"`
+}
+
+func (r *UpdateKYCStatusRequest) Validate() bool {
+	r.Status = types.KYCStatus(strings.ToUpper(string(r.Status)))
+	return r.Status.IsValid()
+}
@@ -0,0 +1,88 @@
+package requestparams
+
+import (
+	"go-sample/types"
+	"strconv"
+)
+
+const (
+	DefaultPageSize = 10
+	MaxPageSize     = 100
+)
+
+// Pagination is shared by every list endpoint. A cursor takes precedence over page,
+// which is only kept for clients that still page with offsets
+type Pagination struct {
+	Limit     string `json:"limit"`
+	Page      string `json:"page"`
+	Cursor    string `json:"cursor"`
+	WithTotal string `json:"with_total"`
+}
+
+func (p *Pagination) Validate() bool {
+	if p.Limit == "" {
+		p.Limit = strconv.Itoa(DefaultPageSize)
+	}
+	if limit, err := strconv.ParseInt(p.Limit, 10, 64); err != nil || limit <= 0 {
+		return false
+	}
+
+	if p.Page == "" {
+		p.Page = "1"
+	}
+	page, err := strconv.ParseInt(p.Page, 10, 64)
+	if err != nil {
+		return false
+	}
+	if page <= 0 {
+		p.Page = "1"
+	}
+
+	if p.Cursor != "" {
+		if _, err := types.DecodeCursor(p.Cursor); err != nil {
+			return false
+		}
+	}
+	if p.WithTotal != "" {
+		if _, err := strconv.ParseBool(p.WithTotal); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// PageSize is the requested limit capped at MaxPageSize
+func (p Pagination) PageSize() int64 {
+	limit, _ := strconv.ParseInt(p.Limit, 10, 64)
+	if limit <= 0 {
+		return DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		return MaxPageSize
+	}
+	return limit
+}
+
+func (p Pagination) Offset() int64 {
+	if p.Cursor != "" {
+		return 0
+	}
+	page, _ := strconv.ParseInt(p.Page, 10, 64)
+	if page <= 1 {
+		return 0
+	}
+	return (page - 1) * p.PageSize()
+}
+
+func (p Pagination) DecodedCursor() *types.Cursor {
+	if p.Cursor == "" {
+		return nil
+	}
+	cursor, _ := types.DecodeCursor(p.Cursor)
+	return cursor
+}
+
+func (p Pagination) IncludeTotal() bool {
+	withTotal, _ := strconv.ParseBool(p.WithTotal)
+	return withTotal
+}
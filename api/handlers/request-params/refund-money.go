@@ -1,9 +1,12 @@
 package requestparams
 
+import "go-sample/types"
+
 type RefundMoneyRequest struct {
-	IsMultibenificiary bool   `json:"is_multi_benificiary"`
-	MultiBeneficiaryId string `json:"multi_beneficiary_id"`
-	TransactionId      string `json:"transaction_id"`
+	IsMultibenificiary bool         `json:"is_multi_benificiary"`
+	MultiBeneficiaryId string       `json:"multi_beneficiary_id"`
+	TransactionId      string       `json:"transaction_id"`
+	Amount             *types.Money `json:"amount"`
 }
 
 func (req *RefundMoneyRequest) Validate() bool {
@@ -13,5 +16,9 @@ func (req *RefundMoneyRequest) Validate() bool {
 	if !req.IsMultibenificiary && req.TransactionId == "" {
 		return false
 	}
+	// partial refunds target a single transaction, multi beneficiary refunds always return what is left
+	if req.Amount != nil && (req.IsMultibenificiary || !req.Amount.IsPositive()) {
+		return false
+	}
 	return true
 }
@@ -0,0 +1,41 @@
+package requestparams
+
+import (
+	"go-sample/types"
+	"strings"
+	"time"
+)
+
+// ScheduleTransferRequest is a transfer request that runs at ExecuteAt instead of right away
+type ScheduleTransferRequest struct {
+	TransferMoneyRequest
+	ExecuteAt time.Time `json:"execute_at"`
+}
+
+func (r *ScheduleTransferRequest) Validate() bool {
+	if len(r.Repcipients) == 0 || !r.TransferMoneyRequest.Validate() {
+		return false
+	}
+	return r.ExecuteAt.After(time.Now())
+}
+
+type ListScheduledTransfersRequest struct {
+	Pagination
+	Status string `json:"status"`
+
+	// parsed by Validate
+	StatusFilter types.ScheduleStatus `json:"-"`
+}
+
+func (r *ListScheduledTransfersRequest) Validate() bool {
+	if !r.Pagination.Validate() {
+		return false
+	}
+	if r.Status != "" {
+		r.StatusFilter = types.ScheduleStatus(strings.ToUpper(r.Status))
+		if !r.StatusFilter.IsValid() {
+			return false
+		}
+	}
+	return true
+}
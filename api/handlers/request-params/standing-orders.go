@@ -0,0 +1,81 @@
+package requestparams
+
+import (
+	"go-sample/types"
+	"strings"
+	"time"
+)
+
+// CreateStandingOrderRequest repeats a transfer request on every occurrence of the recurrence.
+// RetryWindow is in seconds, orders sent without it or without MaxFailures get the defaults
+type CreateStandingOrderRequest struct {
+	TransferMoneyRequest
+	Frequency   types.Frequency `json:"frequency"`
+	DayOfMonth  int             `json:"day_of_month"`
+	StartAt     time.Time       `json:"start_at"`
+	EndAt       *time.Time      `json:"end_at"`
+	Count       *int            `json:"count"`
+	RetryWindow *int64          `json:"retry_window"`
+	MaxFailures int             `json:"max_failures"`
+}
+
+func (r *CreateStandingOrderRequest) Validate() bool {
+	if len(r.Repcipients) == 0 || !r.TransferMoneyRequest.Validate() {
+		return false
+	}
+	r.Frequency = types.Frequency(strings.ToUpper(string(r.Frequency)))
+	if r.StartAt.IsZero() {
+		r.StartAt = time.Now()
+	} else if r.StartAt.Before(time.Now()) {
+		return false
+	}
+	if r.RetryWindow != nil && (*r.RetryWindow < 0 || r.RetryWindowDuration() > types.MaxRetryWindow) {
+		return false
+	}
+	return r.MaxFailures >= 0 && r.Recurrence().Validate() == nil
+}
+
+func (r *CreateStandingOrderRequest) Recurrence() types.Recurrence {
+	return types.Recurrence{
+		Frequency:  r.Frequency,
+		DayOfMonth: r.DayOfMonth,
+		StartAt:    r.StartAt,
+		EndAt:      r.EndAt,
+		Count:      r.Count,
+	}
+}
+
+func (r *CreateStandingOrderRequest) RetryWindowDuration() time.Duration {
+	if r.RetryWindow == nil {
+		return types.DefaultRetryWindow
+	}
+	return time.Duration(*r.RetryWindow) * time.Second
+}
+
+func (r *CreateStandingOrderRequest) MaxConsecutiveFailures() int {
+	if r.MaxFailures == 0 {
+		return types.DefaultMaxFailures
+	}
+	return r.MaxFailures
+}
+
+type ListStandingOrdersRequest struct {
+	Pagination
+	Status string `json:"status"`
+
+	// parsed by Validate
+	StatusFilter types.StandingOrderStatus `json:"-"`
+}
+
+func (r *ListStandingOrdersRequest) Validate() bool {
+	if !r.Pagination.Validate() {
+		return false
+	}
+	if r.Status != "" {
+		r.StatusFilter = types.StandingOrderStatus(strings.ToUpper(r.Status))
+		if !r.StatusFilter.IsValid() {
+			return false
+		}
+	}
+	return true
+}
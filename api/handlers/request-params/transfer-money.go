@@ -1,28 +1,39 @@
 package requestparams
 
+import "go-sample/types"
+
 type Recipient struct {
 	AccountId string
-	Amount    float64
+	Amount    types.Money
+	// Subject of the recipient's transaction, the one of the transfer when empty
+	Subject string
 }
 type TransferMoneyRequest struct {
 	From        string      `json:"from"`
-	Amount      float64     `json:"amount"`
+	Amount      types.Money `json:"amount"`
 	Repcipients []Recipient `json:"recipients"`
 	Subject     string      `json:"subject"`
 }
 
 func (t *TransferMoneyRequest) Validate() bool {
 
-	var amountSum float64
+	amountSum := types.NewMoney(0, t.Amount.Currency)
 	for _, recipient := range t.Repcipients {
-		amountSum += recipient.Amount
+		if !recipient.Amount.IsPositive() || !recipient.Amount.SameCurrency(t.Amount) {
+			return false
+		}
+		sum, err := amountSum.CheckedAdd(recipient.Amount)
+		if err != nil {
+			return false
+		}
+		amountSum = sum
 	}
 
-	if t.Amount <= 0 {
+	if !t.Amount.IsPositive() {
 		return false
 	}
 
-	if amountSum != t.Amount {
+	if !amountSum.Equal(t.Amount) {
 		return false
 	}
 	return true
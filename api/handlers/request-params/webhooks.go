@@ -0,0 +1,25 @@
+package requestparams
+
+import (
+	"go-sample/types"
+	"net/url"
+)
+
+// RegisterWebhookRequest subscribes the url to the given event types, to every event when there are none
+type RegisterWebhookRequest struct {
+	URL        string            `json:"url"`
+	EventTypes []types.EventType `json:"event_types"`
+}
+
+func (r *RegisterWebhookRequest) Validate() bool {
+	target, err := url.Parse(r.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return false
+	}
+	for _, eventType := range r.EventTypes {
+		if !eventType.IsValid() {
+			return false
+		}
+	}
+	return true
+}
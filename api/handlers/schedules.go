@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	requestparams "go-sample/api/handlers/request-params"
+	"go-sample/api/handlers/services"
+	"go-sample/api/utils"
+	"go-sample/types"
+	"net/http"
+
+	"github.com/go-chi/chi"
+)
+
+func (ah *AccountHandler) ScheduleTransfer(w http.ResponseWriter, r *http.Request) {
+	var request requestparams.ScheduleTransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || !request.Validate() {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "recipients must add up to the positive amount and execute_at must be a future RFC 3339 time",
+		})
+		return
+	}
+
+	transfer, err := ah.accountSrv.ScheduleTransfer(r.Context(), chi.URLParam(r, "id"), request)
+	if errors.Is(err, services.ErrInexistentAccount) {
+		writeError(w, http.StatusNotFound, err, "The account or one of the recipients does not exist")
+		return
+	}
+	if errors.Is(err, services.ErrKYCTransferLimitExceeded) {
+		writeError(w, http.StatusForbidden, err, "The amount is above the transfer limit of the owner's KYC status")
+		return
+	}
+	if writeAccountStateError(w, err) || writeCurrencyError(w, err) {
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(transfer)
+}
+
+func (ah *AccountHandler) ListScheduledTransfers(w http.ResponseWriter, r *http.Request) {
+	data, err := utils.ExtracteQueryParams(r)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	filter := new(requestparams.ListScheduledTransfersRequest)
+	if err = json.Unmarshal(data, filter); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if !filter.Validate() {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "invalid filters: status must be SCHEDULED, EXECUTING, EXECUTED, FAILED or CANCELLED, limit a positive number and cursor one returned by a previous page",
+		})
+		return
+	}
+
+	transfers, err := ah.accountSrv.ListScheduledTransfers(r.Context(), chi.URLParam(r, "id"), *filter)
+	if errors.Is(err, services.ErrInexistentAccount) {
+		writeError(w, http.StatusNotFound, err, "There's no any bank account associated with this id")
+		return
+	}
+	if errors.Is(err, types.ErrInvalidCursor) {
+		writeInvalidCursor(w)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(transfers)
+}
+
+func (ah *AccountHandler) GetScheduledTransfer(w http.ResponseWriter, r *http.Request) {
+	transfer, err := ah.accountSrv.GetScheduledTransfer(r.Context(), chi.URLParam(r, "id"))
+	if writeScheduledTransferError(w, err) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(transfer)
+}
+
+func (ah *AccountHandler) CancelScheduledTransfer(w http.ResponseWriter, r *http.Request) {
+	transfer, err := ah.accountSrv.CancelScheduledTransfer(r.Context(), chi.URLParam(r, "id"))
+	if writeScheduledTransferError(w, err) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(transfer)
+}
+
+// writeScheduledTransferError answers for the errors shared by the scheduled transfer endpoints, it reports whether anything was written
+func writeScheduledTransferError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, services.ErrInexistentScheduledTransfer):
+		writeError(w, http.StatusNotFound, err, "There's no scheduled transfer associated with this id")
+	case errors.Is(err, services.ErrScheduledTransferNotPending):
+		writeError(w, http.StatusConflict, err, "The transfer already ran or was cancelled")
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	return true
+}
@@ -3,11 +3,25 @@ package services
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	requestparams "go-sample/api/handlers/request-params"
+	"go-sample/storage"
 	accountrepo "go-sample/storage/account-repo"
+	feerepo "go-sample/storage/fee-repo"
+	fxrepo "go-sample/storage/fx-repo"
+	holdrepo "go-sample/storage/hold-repo"
+	ledgerrepo "go-sample/storage/ledger-repo"
+	limitrepo "go-sample/storage/limit-repo"
+	operationrepo "go-sample/storage/operation-repo"
+	outboxrepo "go-sample/storage/outbox-repo"
+	ownerrepo "go-sample/storage/owner-repo"
+	schedulerepo "go-sample/storage/schedule-repo"
 	transactionrepo "go-sample/storage/transaction-repo"
 	"go-sample/types"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -16,11 +30,35 @@ func TestAccountSrv(t *testing.T) {
 
 	accountRepo := accountrepo.NewMockMemoAccountRepo()
 	transactionRepo := transactionrepo.NewMemoTransactionRepo()
-	accountSrv := NewAccount(accountRepo, transactionRepo)
+	ledgerRepo := ledgerrepo.NewMemoLedgerRepo()
+	ownerRepo := ownerrepo.NewMemoOwnerRepo()
+	fxRepo := fxrepo.NewMemoFXRepo()
+	holdRepo := holdrepo.NewMemoHoldRepo()
+	feeRepo := feerepo.NewMemoFeeRepo()
+	limitRepo := limitrepo.NewMemoLimitRepo()
+	scheduleRepo := schedulerepo.NewMemoScheduleRepo()
+	operationRepo := operationrepo.NewMemoOperationRepo()
+	outboxRepo := outboxrepo.NewMemoOutboxRepo()
+	uow := storage.NewMemoUnitOfWork()
+	accountSrv := NewAccount(accountRepo, transactionRepo, ledgerRepo, ownerRepo, fxRepo, holdRepo, feeRepo, limitRepo, scheduleRepo, operationRepo, outboxRepo, uow)
+
+	verifiedOwner := types.NewOwner("Some Dumb Owner", "000000000LA000", "", "", "")
+	verifiedOwner.KYCStatus = types.KYCVerified
+	ownerRepo.CreateOwner(context.Background(), verifiedOwner)
+	for _, id := range []string{
+		"some dumb id", "another dumb id", "yet another dumb id", "a-account", "b-account", "c-account",
+		"ledger dumb id", "failed withdraw dumb id", "refund from dumb id", "refund to dumb id",
+		"partial refund from dumb id", "partial refund to dumb id", "history dumb id", "history sender dumb id",
+		"frozen sender dumb id", "kyc recipient dumb id", "async to dumb id",
+	} {
+		account := types.NewAccount(verifiedOwner.ID, types.NewMoney(0, types.AOA))
+		account.ID = id
+		accountRepo.CreateAccount(context.Background(), account)
+	}
 	t.Run("accounts.CreateAccount should call AccountRepo.CreateAccount", func(t *testing.T) {
 
 		ctx := context.Background()
-		account := &types.Account{}
+		account := &types.Account{Owner: verifiedOwner.ID}
 		got := accountRepo.McreateAccount.Calls
 		expected := 1
 		accountSrv.CreateAccount(ctx, account)
@@ -31,7 +69,7 @@ func TestAccountSrv(t *testing.T) {
 	})
 	t.Run("srv.CreateAccount should return error if repo.CreateAccount returns error", func(t *testing.T) {
 		ctx := context.Background()
-		account := &types.Account{}
+		account := &types.Account{Owner: verifiedOwner.ID}
 
 		want := errors.New("some dumb error")
 
@@ -41,43 +79,6 @@ func TestAccountSrv(t *testing.T) {
 
 		assert.True(t, errors.Is(want, have))
 	})
-	t.Run("accounts.IsThereAlreadyAccountWithThisOwner should call AccountRepo.GetAccountByOwnerId", func(t *testing.T) {
-		ctx := context.Background()
-		ownerId := "some dumb id"
-		got := accountRepo.MgetAccountBYOwnerId.Calls
-		expected := 1
-		accountSrv.IsThereAlreadyAccountWithThisOwner(ctx, ownerId)
-
-		if !accountRepo.MgetAccountBYOwnerId.Called && expected != got {
-			t.Errorf("Expected %d call(s) but got %d calls", expected, got)
-		}
-	})
-	t.Run("accounts.IsThereAlreadyAccountThisOwner should return false if accountRepo.GetAccountByOwner returns a ErrNoRows error", func(t *testing.T) {
-		ctx := context.Background()
-		ownerId := "some dumb id"
-		expected := false
-
-		accountRepo.MgetAccountBYOwnerId.ExpectedReturnError = sql.ErrNoRows
-
-		got, _ := accountSrv.IsThereAlreadyAccountWithThisOwner(ctx, ownerId)
-
-		if got != expected {
-			t.Errorf("Expected %v but got %v calls", expected, got)
-		}
-	})
-	t.Run("accounts.IsThereAlreadyAccountWithThisOwner return true if accountRepo.GetAccountByOwner returns nil error object", func(t *testing.T) {
-		ctx := context.Background()
-		ownerId := "some dumb id"
-		expected := true
-
-		accountRepo.MgetAccountBYOwnerId.ExpectedReturnError = nil
-
-		got, _ := accountSrv.IsThereAlreadyAccountWithThisOwner(ctx, ownerId)
-
-		if got != expected {
-			t.Errorf("Expected %v but got %v calls", expected, got)
-		}
-	})
 	t.Run("accounts.GetAccountBalance should call repo.GetAccountBalance", func(t *testing.T) {
 		ctx := context.Background()
 		accountId := "some dumb id"
@@ -116,4 +117,1227 @@ func TestAccountSrv(t *testing.T) {
 		}
 	})
 
+	t.Run("accounts.DepositMoney should roll back and return error if transactionRepo.CreateTransaction fails", func(t *testing.T) {
+		ctx := context.Background()
+		want := errors.New("some dumb error")
+		rollbacks := uow.Rollbacks
+
+		transactionRepo.McreateTransaction.ExpectedReturnError = want
+
+		got := accountSrv.DepositMoney(ctx, "some dumb id", types.NewMoney(10000, types.AOA))
+
+		assert.True(t, errors.Is(got, want))
+		// recording the failed attempt fails the same way and is rolled back too
+		assert.Equal(t, rollbacks+2, uow.Rollbacks)
+
+		transactionRepo.McreateTransaction.ExpectedReturnError = nil
+	})
+	t.Run("accounts.TransferMoney should return error if transactionRepo.CreateTransaction fails after moving the money", func(t *testing.T) {
+		ctx := context.Background()
+		want := errors.New("some dumb error")
+		rollbacks := uow.Rollbacks
+
+		transactionRepo.McreateTransaction.ExpectedReturnError = want
+
+		amount := types.NewMoney(10000, types.AOA)
+		_, got := accountSrv.TransferMoney(ctx, requestparams.TransferMoneyRequest{
+			From:        "some dumb id",
+			Amount:      amount,
+			Repcipients: []requestparams.Recipient{{AccountId: "another dumb id", Amount: amount}},
+		})
+
+		assert.True(t, errors.Is(got, want))
+		assert.True(t, transactionRepo.MmakeTransferTransaction.Called)
+		assert.Equal(t, rollbacks+2, uow.Rollbacks)
+
+		transactionRepo.McreateTransaction.ExpectedReturnError = nil
+	})
+	t.Run("accounts.TransferMoney should fail the whole multi beneficiary batch and report every leg", func(t *testing.T) {
+		ctx := context.Background()
+		want := errors.New("some dumb error")
+
+		transactionRepo.McreateTransaction.ExpectedReturnError = want
+
+		amount := types.NewMoney(10000, types.AOA)
+		batch, got := accountSrv.TransferMoney(ctx, requestparams.TransferMoneyRequest{
+			From:   "some dumb id",
+			Amount: amount.Add(amount),
+			Repcipients: []requestparams.Recipient{
+				{AccountId: "another dumb id", Amount: amount},
+				{AccountId: "yet another dumb id", Amount: amount},
+			},
+		})
+
+		assert.True(t, errors.Is(got, want))
+		assert.Equal(t, types.BatchFailed, batch.Status)
+		assert.Equal(t, types.LegFailed, batch.Legs[0].Status)
+		assert.Equal(t, types.LegSkipped, batch.Legs[1].Status)
+
+		stored, err := accountSrv.GetTransferBatch(ctx, batch.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, types.BatchFailed, stored.Status)
+
+		transactionRepo.McreateTransaction.ExpectedReturnError = nil
+	})
+	t.Run("accounts.TransferMoney should complete every leg of a multi beneficiary batch", func(t *testing.T) {
+		ctx := context.Background()
+
+		amount := types.NewMoney(10000, types.AOA)
+		batch, err := accountSrv.TransferMoney(ctx, requestparams.TransferMoneyRequest{
+			From:   "some dumb id",
+			Amount: amount.Add(amount),
+			Repcipients: []requestparams.Recipient{
+				{AccountId: "another dumb id", Amount: amount},
+				{AccountId: "yet another dumb id", Amount: amount},
+			},
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, types.BatchCompleted, batch.Status)
+		for _, leg := range batch.Legs {
+			assert.Equal(t, types.LegCompleted, leg.Status)
+			assert.NotEmpty(t, leg.TransactionId)
+		}
+	})
+	t.Run("accounts.TransferMoney should lock every account once in ascending id order", func(t *testing.T) {
+		ctx := context.Background()
+
+		amount := types.NewMoney(10000, types.AOA)
+		accountSrv.TransferMoney(ctx, requestparams.TransferMoneyRequest{
+			From:   "c-account",
+			Amount: amount.Add(amount).Add(amount),
+			Repcipients: []requestparams.Recipient{
+				{AccountId: "b-account", Amount: amount},
+				{AccountId: "a-account", Amount: amount},
+				{AccountId: "b-account", Amount: amount},
+			},
+		})
+
+		assert.Equal(t, []string{"a-account", "b-account", "c-account"}, accountRepo.MlockAccounts.Locked)
+	})
+	t.Run("accounts.WithdrawMoney should return ErrInsufficientFunds if repo.DecrBalance returns it", func(t *testing.T) {
+		ctx := context.Background()
+
+		accountRepo.MdecrBalance.ExpectedReturnError = storage.ErrInsufficientFunds
+
+		got := accountSrv.WithdrawMoney(ctx, "some dumb id", types.NewMoney(10000, types.AOA))
+
+		assert.True(t, errors.Is(got, ErrInsufficientFunds))
+
+		accountRepo.MdecrBalance.ExpectedReturnError = nil
+	})
+	t.Run("every operation should write a balanced journal entry against the system accounts", func(t *testing.T) {
+		ctx := context.Background()
+		accountId := "ledger dumb id"
+		amount := types.NewMoney(10000, types.AOA)
+
+		assert.Nil(t, accountSrv.DepositMoney(ctx, accountId, amount))
+		assert.Nil(t, accountSrv.WithdrawMoney(ctx, accountId, types.NewMoney(2500, types.AOA)))
+
+		for _, entry := range ledgerRepo.Entries() {
+			assert.True(t, entry.IsBalanced())
+		}
+
+		posted, err := ledgerRepo.GetPostedBalance(ctx, accountId)
+		assert.Nil(t, err)
+		cashIn, err := ledgerRepo.GetPostedBalance(ctx, types.SystemCashInAccount)
+		assert.Nil(t, err)
+		cashOut, err := ledgerRepo.GetPostedBalance(ctx, types.SystemCashOutAccount)
+		assert.Nil(t, err)
+
+		assert.Equal(t, int64(7500), posted.Amount)
+		assert.Equal(t, int64(2500), cashOut.Amount)
+		assert.True(t, cashIn.IsNegative())
+
+		ledgerSrv := NewLedger(ledgerRepo)
+		trialBalance, err := ledgerSrv.GetTrialBalance(ctx)
+		assert.Nil(t, err)
+		assert.True(t, trialBalance.Balanced)
+	})
+	t.Run("accounts.WithdrawMoney should persist a FAILED transaction when funds are insufficient", func(t *testing.T) {
+		ctx := context.Background()
+		accountId := "failed withdraw dumb id"
+
+		accountRepo.MdecrBalance.ExpectedReturnError = storage.ErrInsufficientFunds
+
+		accountSrv.WithdrawMoney(ctx, accountId, types.NewMoney(10000, types.AOA))
+
+		var failed *types.Transaction
+		for _, transaction := range transactionRepo.Transactions() {
+			if transaction.From == accountId {
+				failed = transaction
+			}
+		}
+
+		assert.NotNil(t, failed)
+		assert.Equal(t, types.TransactionFailed, failed.Status)
+		assert.Equal(t, ErrInsufficientFunds.Error(), failed.FailureReason)
+		assert.Equal(t, types.TransactionPending, failed.StatusHistory[0].Status)
+		assert.Len(t, failed.StatusHistory, 2)
+
+		accountRepo.MdecrBalance.ExpectedReturnError = nil
+	})
+	t.Run("accounts.RefundMoneyNormalTransfer should reverse the original transaction and refuse a second refund", func(t *testing.T) {
+		ctx := context.Background()
+
+		amount := types.NewMoney(10000, types.AOA)
+		batch, err := accountSrv.TransferMoney(ctx, requestparams.TransferMoneyRequest{
+			From:        "refund from dumb id",
+			Amount:      amount,
+			Repcipients: []requestparams.Recipient{{AccountId: "refund to dumb id", Amount: amount}},
+		})
+		assert.Nil(t, err)
+		transactionId := batch.Legs[0].TransactionId
+
+		err = accountSrv.RefundMoneyNormalTransfer(ctx, transactionId, nil)
+		assert.Nil(t, err)
+
+		original, err := accountSrv.GetTransaction(ctx, transactionId)
+		assert.Nil(t, err)
+		assert.Equal(t, types.TransactionReversed, original.Status)
+
+		err = accountSrv.RefundMoneyNormalTransfer(ctx, transactionId, nil)
+		assert.True(t, errors.Is(err, ErrTransactionNotRefundable))
+	})
+	t.Run("accounts.RefundMoneyNormalTransfer should track partial refunds and reject going over the original amount", func(t *testing.T) {
+		ctx := context.Background()
+
+		amount := types.NewMoney(10000, types.AOA)
+		batch, err := accountSrv.TransferMoney(ctx, requestparams.TransferMoneyRequest{
+			From:        "partial refund from dumb id",
+			Amount:      amount,
+			Repcipients: []requestparams.Recipient{{AccountId: "partial refund to dumb id", Amount: amount}},
+		})
+		assert.Nil(t, err)
+		transactionId := batch.Legs[0].TransactionId
+
+		part := types.NewMoney(4000, types.AOA)
+		assert.Nil(t, accountSrv.RefundMoneyNormalTransfer(ctx, transactionId, &part))
+		assert.Nil(t, accountSrv.RefundMoneyNormalTransfer(ctx, transactionId, &part))
+
+		original, err := accountSrv.GetTransaction(ctx, transactionId)
+		assert.Nil(t, err)
+		assert.Equal(t, types.TransactionCompleted, original.Status)
+		assert.Equal(t, int64(8000), original.RefundedAmount.Amount)
+		assert.Equal(t, int64(2000), original.RefundableAmount().Amount)
+
+		err = accountSrv.RefundMoneyNormalTransfer(ctx, transactionId, &part)
+		assert.True(t, errors.Is(err, ErrRefundExceedsRefundableAmount))
+
+		assert.Nil(t, accountSrv.RefundMoneyNormalTransfer(ctx, transactionId, nil))
+		original, err = accountSrv.GetTransaction(ctx, transactionId)
+		assert.Nil(t, err)
+		assert.Equal(t, types.TransactionReversed, original.Status)
+		assert.True(t, original.RefundableAmount().IsZero())
+	})
+	t.Run("accounts.GetTransactionsHistory should list incoming and outgoing movements with a running balance", func(t *testing.T) {
+		ctx := context.Background()
+		accountId := "history dumb id"
+
+		accountSrv.DepositMoney(ctx, accountId, types.NewMoney(10000, types.AOA))
+		accountSrv.TransferMoney(ctx, requestparams.TransferMoneyRequest{
+			From:        "history sender dumb id",
+			Amount:      types.NewMoney(5000, types.AOA),
+			Repcipients: []requestparams.Recipient{{AccountId: accountId, Amount: types.NewMoney(5000, types.AOA)}},
+		})
+		accountRepo.MdecrBalance.ExpectedReturnError = storage.ErrInsufficientFunds
+		accountSrv.WithdrawMoney(ctx, accountId, types.NewMoney(90000, types.AOA))
+		accountRepo.MdecrBalance.ExpectedReturnError = nil
+		accountSrv.WithdrawMoney(ctx, accountId, types.NewMoney(3000, types.AOA))
+
+		page, err := accountSrv.GetTransactionsHistory(ctx, accountId, requestparams.GetTransactionsHistoryRequest{})
+		assert.Nil(t, err)
+		history := page.Data
+		assert.Len(t, history, 4)
+
+		assert.Equal(t, types.Credit, history[0].Direction)
+		assert.Equal(t, types.SystemCashInAccount, history[0].Counterparty)
+		assert.Equal(t, types.Credit, history[1].Direction)
+		assert.Equal(t, "history sender dumb id", history[1].Counterparty)
+		assert.Equal(t, types.Debit, history[2].Direction)
+		assert.Equal(t, types.TransactionFailed, history[2].Status)
+		assert.Equal(t, types.Debit, history[3].Direction)
+		assert.Equal(t, types.SystemCashOutAccount, history[3].Counterparty)
+
+		balances := []int64{10000, 15000, 15000, 12000}
+		for i, entry := range history {
+			assert.Equal(t, balances[i], entry.BalanceAfter.Amount)
+		}
+	})
+	t.Run("accounts.FreezeAccount should block money movements until the account is unfrozen", func(t *testing.T) {
+		ctx := context.Background()
+		accountRepo.McreateAccount.ExpectedReturnError = nil
+		account := types.NewAccount("frozen dumb owner", types.NewMoney(0, types.AOA))
+		accountRepo.CreateAccount(ctx, account)
+
+		_, err := accountSrv.FreezeAccount(ctx, account.ID, "suspicious activity", "back office")
+		assert.Nil(t, err)
+
+		err = accountSrv.DepositMoney(ctx, account.ID, types.NewMoney(1000, types.AOA))
+		assert.True(t, errors.Is(err, ErrAccountFrozen))
+
+		batch, err := accountSrv.TransferMoney(ctx, requestparams.TransferMoneyRequest{
+			From:        "frozen sender dumb id",
+			Amount:      types.NewMoney(1000, types.AOA),
+			Repcipients: []requestparams.Recipient{{AccountId: account.ID, Amount: types.NewMoney(1000, types.AOA)}},
+		})
+		assert.True(t, errors.Is(err, ErrAccountFrozen))
+		assert.Equal(t, types.BatchFailed, batch.Status)
+
+		_, err = accountSrv.FreezeAccount(ctx, account.ID, "again", "back office")
+		assert.True(t, errors.Is(err, ErrInvalidAccountStatusTransition))
+
+		_, err = accountSrv.UnfreezeAccount(ctx, account.ID, "cleared", "back office")
+		assert.Nil(t, err)
+		assert.Nil(t, accountSrv.DepositMoney(ctx, account.ID, types.NewMoney(1000, types.AOA)))
+
+		history, err := accountSrv.GetAccountStatusHistory(ctx, account.ID)
+		assert.Nil(t, err)
+		assert.Len(t, history, 2)
+		assert.Equal(t, "back office", history[0].Actor)
+		assert.Equal(t, types.AccountFrozen, history[0].To)
+	})
+	t.Run("accounts.CloseAccount should require a zero balance or a sweep account", func(t *testing.T) {
+		ctx := context.Background()
+		account := types.NewAccount("closing dumb owner", types.NewMoney(5000, types.AOA))
+		sweep := types.NewAccount("sweep dumb owner", types.NewMoney(0, types.AOA))
+		accountRepo.CreateAccount(ctx, account)
+		accountRepo.CreateAccount(ctx, sweep)
+
+		_, err := accountSrv.CloseAccount(ctx, account.ID, "customer request", "back office", "")
+		assert.True(t, errors.Is(err, ErrAccountHasBalance))
+
+		_, err = accountSrv.CloseAccount(ctx, account.ID, "customer request", "back office", account.ID)
+		assert.True(t, errors.Is(err, ErrInvalidSweepAccount))
+
+		closed, err := accountSrv.CloseAccount(ctx, account.ID, "customer request", "back office", sweep.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, types.AccountClosed, closed.Status)
+		assert.NotNil(t, closed.DeletedAt)
+
+		var swept *types.Transaction
+		for _, transaction := range transactionRepo.Transactions() {
+			if transaction.From == account.ID && transaction.To == sweep.ID {
+				swept = transaction
+			}
+		}
+		assert.NotNil(t, swept)
+		assert.Equal(t, int64(5000), swept.Amount.Amount)
+
+		err = accountSrv.WithdrawMoney(ctx, account.ID, types.NewMoney(100, types.AOA))
+		assert.True(t, errors.Is(err, ErrAccountClosed))
+	})
+	t.Run("accounts.InternalTransfer should only move money between accounts of the same owner", func(t *testing.T) {
+		ctx := context.Background()
+		owner := "internal dumb owner"
+		checking := types.NewAccount(owner, types.NewMoney(10000, types.AOA))
+		savings := types.NewAccount(owner, types.NewMoney(0, types.AOA))
+		savings.Type = types.SavingsAccount
+		stranger := types.NewAccount("stranger dumb owner", types.NewMoney(0, types.AOA))
+		for _, account := range []*types.Account{checking, savings, stranger} {
+			accountRepo.CreateAccount(ctx, account)
+		}
+
+		amount := types.NewMoney(2500, types.AOA)
+		batch, err := accountSrv.InternalTransfer(ctx, owner, requestparams.InternalTransferRequest{From: checking.ID, To: savings.ID, Amount: amount})
+		assert.Nil(t, err)
+		assert.Equal(t, types.BatchCompleted, batch.Status)
+
+		_, err = accountSrv.InternalTransfer(ctx, owner, requestparams.InternalTransferRequest{From: checking.ID, To: stranger.ID, Amount: amount})
+		assert.True(t, errors.Is(err, ErrAccountNotOwned))
+
+		owned, err := accountSrv.GetOwnerAccounts(ctx, owner)
+		assert.Nil(t, err)
+		assert.Len(t, owned.Accounts, 2)
+		assert.Equal(t, int64(10000), owned.Totals[types.AOA].Amount)
+	})
+	t.Run("accounts.CreateAccount should require an existing verified owner", func(t *testing.T) {
+		ctx := context.Background()
+		pending := types.NewOwner("Some Pending Owner", "000000001LA000", "", "", "")
+		ownerRepo.CreateOwner(ctx, pending)
+
+		_, err := accountSrv.CreateAccount(ctx, &types.Account{Owner: "some inexistent owner"})
+		assert.True(t, errors.Is(err, ErrInexistentOwner))
+
+		_, err = accountSrv.CreateAccount(ctx, &types.Account{Owner: pending.ID})
+		assert.True(t, errors.Is(err, ErrOwnerNotVerified))
+	})
+	t.Run("accounts.TransferMoney should cap transfers by the KYC status of the sender's owner", func(t *testing.T) {
+		ctx := context.Background()
+		accountRepo.McreateAccount.ExpectedReturnError = nil
+		rejected := types.NewOwner("Some Rejected Owner", "000000002LA000", "", "", "")
+		rejected.KYCStatus = types.KYCRejected
+		ownerRepo.CreateOwner(ctx, rejected)
+
+		sender := types.NewAccount(rejected.ID, types.NewMoney(10000, types.AOA))
+		accountRepo.CreateAccount(ctx, sender)
+
+		amount := types.NewMoney(100, types.AOA)
+		_, err := accountSrv.TransferMoney(ctx, requestparams.TransferMoneyRequest{
+			From:        sender.ID,
+			Amount:      amount,
+			Repcipients: []requestparams.Recipient{{AccountId: "kyc recipient dumb id", Amount: amount}},
+		})
+		assert.True(t, errors.Is(err, ErrKYCTransferLimitExceeded))
+	})
+	t.Run("accounts.TransferMoney should cap same currency transfers without any exchange rate", func(t *testing.T) {
+		ctx := context.Background()
+		from := types.NewAccount(verifiedOwner.ID, types.MustParseMoney("20000", types.EUR))
+		to := types.NewAccount(verifiedOwner.ID, types.MustParseMoney("0", types.EUR))
+		accountRepo.CreateAccount(ctx, from)
+		accountRepo.CreateAccount(ctx, to)
+
+		amount := types.MustParseMoney("50", types.EUR)
+		_, err := accountSrv.TransferMoney(ctx, requestparams.TransferMoneyRequest{
+			From:        from.ID,
+			Amount:      amount,
+			Repcipients: []requestparams.Recipient{{AccountId: to.ID, Amount: amount}},
+		})
+		assert.Nil(t, err)
+
+		amount = types.MustParseMoney("10000.01", types.EUR)
+		_, err = accountSrv.TransferMoney(ctx, requestparams.TransferMoneyRequest{
+			From:        from.ID,
+			Amount:      amount,
+			Repcipients: []requestparams.Recipient{{AccountId: to.ID, Amount: amount}},
+		})
+		assert.True(t, errors.Is(err, ErrKYCTransferLimitExceeded))
+	})
+	t.Run("accounts.TransferMoney should refuse cross currency transfers without an exchange rate", func(t *testing.T) {
+		ctx := context.Background()
+		dollars := types.NewAccount(verifiedOwner.ID, types.MustParseMoney("100", types.USD))
+		euros := types.NewAccount(verifiedOwner.ID, types.MustParseMoney("0", types.EUR))
+		accountRepo.CreateAccount(ctx, dollars)
+		accountRepo.CreateAccount(ctx, euros)
+		fxRepo.SaveRate(ctx, &types.ExchangeRate{Base: types.USD, Quote: types.AOA, Rate: "830"})
+
+		amount := types.MustParseMoney("10", types.USD)
+		batch, err := accountSrv.TransferMoney(ctx, requestparams.TransferMoneyRequest{
+			From:        dollars.ID,
+			Amount:      amount,
+			Repcipients: []requestparams.Recipient{{AccountId: euros.ID, Amount: amount}},
+		})
+		assert.True(t, errors.Is(err, ErrExchangeRateNotFound))
+		assert.Nil(t, batch)
+
+		aoa := types.MustParseMoney("10", types.AOA)
+		_, err = accountSrv.TransferMoney(ctx, requestparams.TransferMoneyRequest{
+			From:        dollars.ID,
+			Amount:      aoa,
+			Repcipients: []requestparams.Recipient{{AccountId: euros.ID, Amount: aoa}},
+		})
+		assert.True(t, errors.Is(err, ErrCurrencyMismatch))
+	})
+	t.Run("accounts.TransferMoney should convert at the stored rate and refunds should undo it", func(t *testing.T) {
+		ctx := context.Background()
+		dollars := types.NewAccount(verifiedOwner.ID, types.MustParseMoney("100", types.USD))
+		kwanzas := types.NewAccount(verifiedOwner.ID, types.MustParseMoney("0", types.AOA))
+		accountRepo.CreateAccount(ctx, dollars)
+		accountRepo.CreateAccount(ctx, kwanzas)
+		fxRepo.SaveRate(ctx, &types.ExchangeRate{Base: types.USD, Quote: types.AOA, Rate: "830.25"})
+
+		amount := types.MustParseMoney("10", types.USD)
+		batch, err := accountSrv.TransferMoney(ctx, requestparams.TransferMoneyRequest{
+			From:        dollars.ID,
+			Amount:      amount,
+			Repcipients: []requestparams.Recipient{{AccountId: kwanzas.ID, Amount: amount}},
+		})
+		assert.Nil(t, err)
+
+		transaction, err := accountSrv.GetTransaction(ctx, batch.Legs[0].TransactionId)
+		assert.Nil(t, err)
+		assert.Equal(t, "830.25", transaction.ExchangeRate)
+		assert.Equal(t, amount, transaction.Amount)
+		assert.Equal(t, types.MustParseMoney("8302.50", types.AOA), transaction.DestinationAmount)
+
+		entries := ledgerRepo.Entries()
+		assert.True(t, entries[len(entries)-1].IsBalanced())
+		assert.Equal(t, types.SystemFXAccount, entries[len(entries)-1].Postings[1].AccountId)
+
+		assert.Nil(t, accountSrv.RefundMoneyNormalTransfer(ctx, transaction.ID, nil))
+		transactions := transactionRepo.Transactions()
+		refund := transactions[len(transactions)-1]
+		assert.Equal(t, types.MustParseMoney("8302.50", types.AOA), refund.Amount)
+		assert.Equal(t, amount, refund.DestinationAmount)
+		assert.Equal(t, types.TransactionReversed, transaction.Status)
+	})
+	t.Run("accounts.DepositMoney should take amounts without currency in the account currency", func(t *testing.T) {
+		ctx := context.Background()
+		euros := types.NewAccount(verifiedOwner.ID, types.MustParseMoney("0", types.EUR))
+		accountRepo.CreateAccount(ctx, euros)
+
+		assert.Nil(t, accountSrv.DepositMoney(ctx, euros.ID, types.MustParseMoney("5", "")))
+		transactions := transactionRepo.Transactions()
+		assert.Equal(t, types.EUR, transactions[len(transactions)-1].Amount.Currency)
+
+		err := accountSrv.DepositMoney(ctx, euros.ID, types.MustParseMoney("5", types.USD))
+		assert.True(t, errors.Is(err, ErrCurrencyMismatch))
+	})
+	t.Run("accounts.SetCreditLimit should raise the available balance and refuse limits below the overdraft", func(t *testing.T) {
+		ctx := context.Background()
+		business := types.NewAccount(verifiedOwner.ID, types.NewMoney(0, types.AOA))
+		accountRepo.CreateAccount(ctx, business)
+
+		_, err := accountSrv.SetCreditLimit(ctx, business.ID, types.MustParseMoney("500", ""))
+		assert.Nil(t, err)
+
+		balance, err := accountSrv.GetAvailableBalance(ctx, business.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, types.MustParseMoney("0", types.AOA), balance.Balance)
+		assert.Equal(t, types.MustParseMoney("500", types.AOA), balance.Available)
+
+		business.Balance = types.MustParseMoney("-300", types.AOA)
+		_, err = accountSrv.SetCreditLimit(ctx, business.ID, types.MustParseMoney("100", types.AOA))
+		assert.True(t, errors.Is(err, ErrCreditLimitInUse))
+
+		_, err = accountSrv.SetCreditLimit(ctx, business.ID, types.MustParseMoney("100", types.USD))
+		assert.True(t, errors.Is(err, ErrInvalidCreditLimit))
+	})
+	t.Run("accounts.AuthorizeHold should reduce the available balance and CaptureHold should release it", func(t *testing.T) {
+		ctx := context.Background()
+		payer := types.NewAccount(verifiedOwner.ID, types.MustParseMoney("100", types.AOA))
+		merchant := types.NewAccount(verifiedOwner.ID, types.NewMoney(0, types.AOA))
+		accountRepo.CreateAccount(ctx, payer)
+		accountRepo.CreateAccount(ctx, merchant)
+
+		hold, err := accountSrv.AuthorizeHold(ctx, payer.ID, requestparams.AuthorizeHoldRequest{
+			To: merchant.ID, Amount: types.MustParseMoney("60", ""), Subject: "Reserva",
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, types.HoldActive, hold.Status)
+
+		t.Run("the held amount is not available", func(t *testing.T) {
+			balance, err := accountSrv.GetAvailableBalance(ctx, payer.ID)
+			assert.Nil(t, err)
+			assert.Equal(t, types.MustParseMoney("60", types.AOA), balance.Held)
+			assert.Equal(t, types.MustParseMoney("40", types.AOA), balance.Available)
+			assert.Len(t, balance.Holds, 1)
+
+			_, err = accountSrv.AuthorizeHold(ctx, payer.ID, requestparams.AuthorizeHoldRequest{
+				To: merchant.ID, Amount: types.MustParseMoney("50", types.AOA),
+			})
+			assert.True(t, errors.Is(err, ErrInsufficientFunds))
+		})
+		t.Run("an account with active holds cannot be closed", func(t *testing.T) {
+			_, err := accountSrv.CloseAccount(ctx, payer.ID, "closing", "tester", merchant.ID)
+			assert.True(t, errors.Is(err, ErrAccountHasActiveHolds))
+		})
+		t.Run("a partial capture releases the whole hold", func(t *testing.T) {
+			partial := types.MustParseMoney("45", types.AOA)
+			captured, err := accountSrv.CaptureHold(ctx, hold.ID, &partial)
+			assert.Nil(t, err)
+			assert.Equal(t, types.HoldCaptured, captured.Status)
+			assert.Equal(t, partial, captured.CapturedAmount)
+			assert.True(t, payer.HeldAmount.IsZero())
+
+			transaction, err := accountSrv.GetTransaction(ctx, captured.TransactionId)
+			assert.Nil(t, err)
+			assert.Equal(t, partial, transaction.Amount)
+		})
+		t.Run("a captured hold cannot be voided", func(t *testing.T) {
+			_, err := accountSrv.VoidHold(ctx, hold.ID)
+			assert.True(t, errors.Is(err, ErrHoldNotActive))
+		})
+	})
+	t.Run("accounts.CaptureHold should charge the transfer fee", func(t *testing.T) {
+		ctx := context.Background()
+		payer := types.NewAccount(verifiedOwner.ID, types.MustParseMoney("100", types.AOA))
+		payer.Type = types.EscrowAccount
+		merchant := types.NewAccount(verifiedOwner.ID, types.NewMoney(0, types.AOA))
+		accountRepo.CreateAccount(ctx, payer)
+		accountRepo.CreateAccount(ctx, merchant)
+
+		feeSrv := NewFee(feeRepo, accountRepo, uow)
+		_, err := feeSrv.LoadSchedules(ctx, requestparams.LoadFeeSchedulesRequest{Schedules: []requestparams.FeeSchedule{
+			{Operation: "TRANSFER", AccountType: types.EscrowAccount, Currency: types.AOA, Kind: types.FixedFee, Amount: "3"},
+		}})
+		assert.Nil(t, err)
+
+		hold, err := accountSrv.AuthorizeHold(ctx, payer.ID, requestparams.AuthorizeHoldRequest{
+			To: merchant.ID, Amount: types.MustParseMoney("60", types.AOA),
+		})
+		assert.Nil(t, err)
+		captured, err := accountSrv.CaptureHold(ctx, hold.ID, nil)
+		assert.Nil(t, err)
+
+		transaction, err := accountSrv.GetTransaction(ctx, captured.TransactionId)
+		assert.Nil(t, err)
+		assert.NotNil(t, transaction.Fee)
+		assert.Equal(t, "FEE", transaction.Fee.Operation)
+		assert.Equal(t, payer.ID, transaction.Fee.From)
+		assert.Equal(t, types.SystemFeesAccount, transaction.Fee.To)
+		assert.Equal(t, types.MustParseMoney("3", types.AOA), transaction.Fee.Amount)
+	})
+	t.Run("accounts.VoidHold and ExpireHolds should give the held amount back", func(t *testing.T) {
+		ctx := context.Background()
+		payer := types.NewAccount(verifiedOwner.ID, types.MustParseMoney("100", types.AOA))
+		merchant := types.NewAccount(verifiedOwner.ID, types.NewMoney(0, types.AOA))
+		accountRepo.CreateAccount(ctx, payer)
+		accountRepo.CreateAccount(ctx, merchant)
+
+		voided, err := accountSrv.AuthorizeHold(ctx, payer.ID, requestparams.AuthorizeHoldRequest{
+			To: merchant.ID, Amount: types.MustParseMoney("30", types.AOA),
+		})
+		assert.Nil(t, err)
+		expiring, err := accountSrv.AuthorizeHold(ctx, payer.ID, requestparams.AuthorizeHoldRequest{
+			To: merchant.ID, Amount: types.MustParseMoney("20", types.AOA), ExpiresIn: 1,
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, types.MustParseMoney("50", types.AOA), payer.HeldAmount)
+
+		t.Run("a voided hold gives its amount back", func(t *testing.T) {
+			hold, err := accountSrv.VoidHold(ctx, voided.ID)
+			assert.Nil(t, err)
+			assert.Equal(t, types.HoldVoided, hold.Status)
+			assert.Equal(t, types.MustParseMoney("20", types.AOA), payer.HeldAmount)
+		})
+		t.Run("an expired hold gives its amount back and cannot be captured", func(t *testing.T) {
+			expiring.ExpiresAt = time.Now().Add(-time.Second)
+			assert.Nil(t, holdRepo.UpdateHold(ctx, expiring))
+			expired, err := accountSrv.ExpireHolds(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, 1, expired)
+			assert.True(t, payer.HeldAmount.IsZero())
+
+			_, err = accountSrv.CaptureHold(ctx, expiring.ID, nil)
+			assert.True(t, errors.Is(err, ErrHoldNotActive))
+		})
+	})
+	t.Run("accounts.WithdrawMoney should charge the fee of the account type as a linked transaction", func(t *testing.T) {
+		ctx := context.Background()
+		savings := types.NewAccount(verifiedOwner.ID, types.MustParseMoney("1000", types.AOA))
+		savings.Type = types.SavingsAccount
+		accountRepo.CreateAccount(ctx, savings)
+
+		feeSrv := NewFee(feeRepo, accountRepo, uow)
+		_, err := feeSrv.LoadSchedules(ctx, requestparams.LoadFeeSchedulesRequest{Schedules: []requestparams.FeeSchedule{
+			{Operation: "WITHDRAW", Currency: types.AOA, Kind: types.FixedFee, Amount: "5"},
+			{Operation: "WITHDRAW", AccountType: types.SavingsAccount, Currency: types.AOA, Kind: types.PercentageFee, Percentage: "2"},
+		}})
+		assert.Nil(t, err)
+
+		t.Run("the quote uses the schedule of the account type", func(t *testing.T) {
+			quote, err := feeSrv.Quote(ctx, requestparams.FeeQuoteRequest{
+				Operation: "WITHDRAW", AccountId: savings.ID, AmountOf: types.MustParseMoney("100", ""),
+			})
+			assert.Nil(t, err)
+			assert.Equal(t, types.MustParseMoney("2", types.AOA), quote.Fee)
+			assert.Equal(t, types.MustParseMoney("-102", types.AOA), quote.BalanceChange)
+		})
+		t.Run("an unknown account gets no quote", func(t *testing.T) {
+			_, err := feeSrv.Quote(ctx, requestparams.FeeQuoteRequest{
+				Operation: "WITHDRAW", AccountId: "some inexistent account", AmountOf: types.MustParseMoney("100", types.AOA),
+			})
+			assert.Equal(t, ErrInexistentAccount, err)
+		})
+		t.Run("the withdrawal is linked to its fee", func(t *testing.T) {
+			assert.Nil(t, accountSrv.WithdrawMoney(ctx, savings.ID, types.MustParseMoney("100", types.AOA)))
+			transactions := transactionRepo.Transactions()
+			fee := transactions[len(transactions)-1]
+			withdrawal := transactions[len(transactions)-2]
+			assert.Equal(t, "FEE", fee.Operation)
+			assert.Equal(t, types.SystemFeesAccount, fee.To)
+			assert.Equal(t, withdrawal.ID, fee.LinkedTransactionId)
+			assert.Equal(t, types.MustParseMoney("2", types.AOA), fee.Amount)
+
+			withdrawal, err := accountSrv.GetTransaction(ctx, withdrawal.ID)
+			assert.Nil(t, err)
+			assert.Equal(t, fee.ID, withdrawal.Fee.ID)
+		})
+	})
+	t.Run("accounts.RefundMoneyNormalTransfer should only refund transfers", func(t *testing.T) {
+		ctx := context.Background()
+		lastTransaction := func(operation string) *types.Transaction {
+			transactions := transactionRepo.Transactions()
+			for i := len(transactions) - 1; i >= 0; i-- {
+				if transactions[i].Operation == operation {
+					return transactions[i]
+				}
+			}
+			return nil
+		}
+
+		t.Run("a deposit is not refundable", func(t *testing.T) {
+			account := types.NewAccount(verifiedOwner.ID, types.NewMoney(0, types.AOA))
+			accountRepo.CreateAccount(ctx, account)
+			assert.Nil(t, accountSrv.DepositMoney(ctx, account.ID, types.MustParseMoney("100", types.AOA)))
+
+			deposit := lastTransaction("DEPOSIT")
+			assert.Equal(t, account.ID, deposit.To)
+			err := accountSrv.RefundMoneyNormalTransfer(ctx, deposit.ID, nil)
+			assert.True(t, errors.Is(err, ErrTransactionNotRefundable))
+		})
+		t.Run("a withdrawal is not refundable", func(t *testing.T) {
+			account := types.NewAccount(verifiedOwner.ID, types.MustParseMoney("1000", types.AOA))
+			accountRepo.CreateAccount(ctx, account)
+			assert.Nil(t, accountSrv.WithdrawMoney(ctx, account.ID, types.MustParseMoney("100", types.AOA)))
+
+			withdrawal := lastTransaction("WITHDRAW")
+			assert.Equal(t, account.ID, withdrawal.From)
+			err := accountSrv.RefundMoneyNormalTransfer(ctx, withdrawal.ID, nil)
+			assert.True(t, errors.Is(err, ErrTransactionNotRefundable))
+		})
+		t.Run("a fee is not refundable", func(t *testing.T) {
+			account := types.NewAccount(verifiedOwner.ID, types.MustParseMoney("1000", types.AOA))
+			account.Type = types.SavingsAccount
+			accountRepo.CreateAccount(ctx, account)
+			feeSrv := NewFee(feeRepo, accountRepo, uow)
+			_, err := feeSrv.LoadSchedules(ctx, requestparams.LoadFeeSchedulesRequest{Schedules: []requestparams.FeeSchedule{
+				{Operation: "WITHDRAW", AccountType: types.SavingsAccount, Currency: types.AOA, Kind: types.PercentageFee, Percentage: "2"},
+			}})
+			assert.Nil(t, err)
+			assert.Nil(t, accountSrv.WithdrawMoney(ctx, account.ID, types.MustParseMoney("100", types.AOA)))
+
+			fee := lastTransaction("FEE")
+			assert.Equal(t, account.ID, fee.From)
+			err = accountSrv.RefundMoneyNormalTransfer(ctx, fee.ID, nil)
+			assert.True(t, errors.Is(err, ErrTransactionNotRefundable))
+		})
+	})
+	t.Run("accounts.WithdrawMoney should refuse going past the limits and report what is left", func(t *testing.T) {
+		ctx := context.Background()
+		limited := types.NewAccount(verifiedOwner.ID, types.MustParseMoney("1000", types.AOA))
+		accountRepo.CreateAccount(ctx, limited)
+
+		count := 2
+		perTransaction, daily := types.MustParseMoney("100", types.AOA), types.MustParseMoney("150", types.AOA)
+		_, err := accountSrv.SetLimit(ctx, limited.ID, requestparams.SetLimitRequest{
+			Operation: "WITHDRAW", PerTransaction: &perTransaction, DailyAmount: &daily, DailyCount: &count,
+		})
+		assert.Nil(t, err)
+
+		t.Run("an amount over the per transaction limit is refused", func(t *testing.T) {
+			err := accountSrv.WithdrawMoney(ctx, limited.ID, types.MustParseMoney("120", types.AOA))
+			var exceeded *types.LimitExceededError
+			assert.True(t, errors.As(err, &exceeded))
+			assert.True(t, errors.Is(err, ErrLimitExceeded))
+			assert.Equal(t, types.PerTransactionLimit, exceeded.Limit)
+		})
+		t.Run("an amount over what is left of the day is refused", func(t *testing.T) {
+			assert.Nil(t, accountSrv.WithdrawMoney(ctx, limited.ID, types.MustParseMoney("100", types.AOA)))
+			err := accountSrv.WithdrawMoney(ctx, limited.ID, types.MustParseMoney("60", types.AOA))
+			var exceeded *types.LimitExceededError
+			assert.True(t, errors.As(err, &exceeded))
+			assert.Equal(t, types.DailyAmountLimit, exceeded.Limit)
+			assert.Equal(t, types.MustParseMoney("50", types.AOA), *exceeded.Remaining.DailyAmount)
+			assert.Equal(t, 1, *exceeded.Remaining.DailyCount)
+		})
+		t.Run("the usage only counts what went through", func(t *testing.T) {
+			statuses, err := accountSrv.GetLimits(ctx, limited.ID)
+			assert.Nil(t, err)
+			assert.Len(t, statuses, 1)
+			assert.Equal(t, types.MustParseMoney("100", types.AOA), statuses[0].Usage.DailyAmount)
+			assert.Equal(t, 1, statuses[0].Usage.DailyCount)
+			assert.Nil(t, statuses[0].Remaining.MonthlyAmount)
+		})
+		t.Run("a negative limit is invalid", func(t *testing.T) {
+			negative := types.MustParseMoney("-5", types.AOA)
+			_, err := accountSrv.SetLimit(ctx, limited.ID, requestparams.SetLimitRequest{Operation: "WITHDRAW", DailyAmount: &negative})
+			assert.True(t, errors.Is(err, ErrInvalidLimit))
+		})
+	})
+	t.Run("accounts.TransferMoney should hold every leg to the per transaction limit and the batch to the others", func(t *testing.T) {
+		ctx := context.Background()
+		limited := types.NewAccount(verifiedOwner.ID, types.MustParseMoney("1000", types.USD))
+		first := types.NewAccount(verifiedOwner.ID, types.NewMoney(0, types.USD))
+		second := types.NewAccount(verifiedOwner.ID, types.NewMoney(0, types.USD))
+		accountRepo.CreateAccount(ctx, limited)
+		accountRepo.CreateAccount(ctx, first)
+		accountRepo.CreateAccount(ctx, second)
+
+		request := requestparams.SetLimitRequest{Currency: limited.Currency}
+		err := json.Unmarshal([]byte(`{"operation": "TRANSFER", "per_transaction": "100", "daily_amount": 150}`), &request)
+		assert.Nil(t, err)
+		assert.Equal(t, types.MustParseMoney("100", types.USD), *request.PerTransaction)
+		assert.Nil(t, request.MonthlyAmount)
+		_, err = accountSrv.SetLimit(ctx, limited.ID, request)
+		assert.Nil(t, err)
+
+		transfer := func(amounts ...string) error {
+			request := requestparams.TransferMoneyRequest{From: limited.ID, Amount: types.NewMoney(0, types.USD)}
+			for i, amount := range amounts {
+				leg := types.MustParseMoney(amount, types.USD)
+				request.Amount = request.Amount.Add(leg)
+				request.Repcipients = append(request.Repcipients, requestparams.Recipient{
+					AccountId: []string{first.ID, second.ID}[i], Amount: leg,
+				})
+			}
+			_, err := accountSrv.TransferMoney(ctx, request)
+			return err
+		}
+		t.Run("legs under the per transaction limit pass even when the batch is over it", func(t *testing.T) {
+			assert.Nil(t, transfer("80", "60"))
+		})
+		t.Run("a leg over the per transaction limit is refused", func(t *testing.T) {
+			var exceeded *types.LimitExceededError
+			assert.True(t, errors.As(transfer("5", "101"), &exceeded))
+			assert.Equal(t, types.PerTransactionLimit, exceeded.Limit)
+		})
+		t.Run("the daily amount counts the whole batch", func(t *testing.T) {
+			var exceeded *types.LimitExceededError
+			assert.True(t, errors.As(transfer("6", "5"), &exceeded))
+			assert.Equal(t, types.DailyAmountLimit, exceeded.Limit)
+		})
+	})
+	t.Run("accounts.ExecuteDueTransfers should run due transfers once and leave cancelled ones alone", func(t *testing.T) {
+		ctx := context.Background()
+		payer := types.NewAccount(verifiedOwner.ID, types.MustParseMoney("1000", types.AOA))
+		landlord := types.NewAccount(verifiedOwner.ID, types.NewMoney(0, types.AOA))
+		accountRepo.CreateAccount(ctx, payer)
+		accountRepo.CreateAccount(ctx, landlord)
+
+		request := requestparams.ScheduleTransferRequest{
+			TransferMoneyRequest: requestparams.TransferMoneyRequest{
+				Amount:      types.MustParseMoney("100", types.AOA),
+				Subject:     "Renda",
+				Repcipients: []requestparams.Recipient{{AccountId: landlord.ID, Amount: types.MustParseMoney("100", types.AOA)}},
+			},
+			ExecuteAt: time.Now().Add(time.Hour),
+		}
+		rent, err := accountSrv.ScheduleTransfer(ctx, payer.ID, request)
+		assert.Nil(t, err)
+		cancelled, err := accountSrv.ScheduleTransfer(ctx, payer.ID, request)
+		assert.Nil(t, err)
+
+		t.Run("nothing runs before it is due", func(t *testing.T) {
+			executed, err := accountSrv.ExecuteDueTransfers(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, 0, executed)
+		})
+		t.Run("a due transfer runs once", func(t *testing.T) {
+			_, err := accountSrv.CancelScheduledTransfer(ctx, cancelled.ID)
+			assert.Nil(t, err)
+			for _, transfer := range []*types.ScheduledTransfer{rent, cancelled} {
+				due, err := accountSrv.GetScheduledTransfer(ctx, transfer.ID)
+				assert.Nil(t, err)
+				due.ExecuteAt = time.Now().Add(-time.Second)
+				assert.Nil(t, scheduleRepo.UpdateScheduledTransfer(ctx, due))
+			}
+
+			executed, err := accountSrv.ExecuteDueTransfers(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, 1, executed)
+			executed, err = accountSrv.ExecuteDueTransfers(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, 0, executed)
+
+			rent, err := accountSrv.GetScheduledTransfer(ctx, rent.ID)
+			assert.Nil(t, err)
+			assert.Equal(t, types.ScheduleExecuted, rent.Status)
+			assert.NotNil(t, rent.ExecutedAt)
+			assert.Equal(t, types.LegCompleted, rent.Legs[0].Status)
+			assert.NotEmpty(t, rent.Legs[0].TransactionId)
+		})
+		t.Run("a cancelled transfer stays cancelled", func(t *testing.T) {
+			cancelled, err := accountSrv.GetScheduledTransfer(ctx, cancelled.ID)
+			assert.Nil(t, err)
+			assert.Equal(t, types.ScheduleCancelled, cancelled.Status)
+		})
+		t.Run("an executed transfer cannot be cancelled", func(t *testing.T) {
+			_, err := accountSrv.CancelScheduledTransfer(ctx, rent.ID)
+			assert.True(t, errors.Is(err, ErrScheduledTransferNotPending))
+		})
+	})
+	t.Run("accounts.ExecuteDueTransfers should record why a transfer failed at execution time", func(t *testing.T) {
+		ctx := context.Background()
+		payer := types.NewAccount(verifiedOwner.ID, types.MustParseMoney("1000", types.AOA))
+		landlord := types.NewAccount(verifiedOwner.ID, types.NewMoney(0, types.AOA))
+		accountRepo.CreateAccount(ctx, payer)
+		accountRepo.CreateAccount(ctx, landlord)
+
+		transfer, err := accountSrv.ScheduleTransfer(ctx, payer.ID, requestparams.ScheduleTransferRequest{
+			TransferMoneyRequest: requestparams.TransferMoneyRequest{
+				Amount:      types.MustParseMoney("100", types.AOA),
+				Repcipients: []requestparams.Recipient{{AccountId: landlord.ID, Amount: types.MustParseMoney("100", types.AOA)}},
+			},
+			ExecuteAt: time.Now().Add(time.Hour),
+		})
+		assert.Nil(t, err)
+		_, err = accountSrv.FreezeAccount(ctx, payer.ID, "suspected fraud", "ops")
+		assert.Nil(t, err)
+
+		transfer.ExecuteAt = time.Now().Add(-time.Second)
+		assert.Nil(t, scheduleRepo.UpdateScheduledTransfer(ctx, transfer))
+		executed, err := accountSrv.ExecuteDueTransfers(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, executed)
+
+		transfer, err = accountSrv.GetScheduledTransfer(ctx, transfer.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, types.ScheduleFailed, transfer.Status)
+		assert.Equal(t, ErrAccountFrozen.Error(), transfer.FailureReason)
+		assert.Equal(t, types.LegFailed, transfer.Legs[0].Status)
+	})
+	t.Run("accounts.ExecuteDueTransfers should roll back a run another scheduler finished first", func(t *testing.T) {
+		ctx := context.Background()
+		payer := types.NewAccount(verifiedOwner.ID, types.MustParseMoney("1000", types.AOA))
+		landlord := types.NewAccount(verifiedOwner.ID, types.NewMoney(0, types.AOA))
+		accountRepo.CreateAccount(ctx, payer)
+		accountRepo.CreateAccount(ctx, landlord)
+
+		transfer, err := accountSrv.ScheduleTransfer(ctx, payer.ID, requestparams.ScheduleTransferRequest{
+			TransferMoneyRequest: requestparams.TransferMoneyRequest{
+				Amount:      types.MustParseMoney("100", types.AOA),
+				Repcipients: []requestparams.Recipient{{AccountId: landlord.ID, Amount: types.MustParseMoney("100", types.AOA)}},
+			},
+			ExecuteAt: time.Now().Add(time.Hour),
+		})
+		assert.Nil(t, err)
+		transfer.ExecuteAt = time.Now().Add(-time.Second)
+		assert.Nil(t, scheduleRepo.UpdateScheduledTransfer(ctx, transfer))
+
+		transactionRepo.MmakeTransferTransaction.During = func() {
+			other, err := scheduleRepo.GetScheduledTransfer(ctx, transfer.ID)
+			assert.Nil(t, err)
+			other.Finish(nil, nil)
+			assert.Nil(t, scheduleRepo.UpdateScheduledTransfer(ctx, other))
+		}
+		defer func() { transactionRepo.MmakeTransferTransaction.During = nil }()
+
+		rollbacks := uow.Rollbacks
+		executed, err := accountSrv.ExecuteDueTransfers(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, executed)
+		assert.Equal(t, rollbacks+1, uow.Rollbacks)
+
+		transfer, err = accountSrv.GetScheduledTransfer(ctx, transfer.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, types.ScheduleExecuted, transfer.Status)
+		assert.Empty(t, transfer.FailureReason)
+	})
+	t.Run("accounts.ExecuteDueStandingOrders should retry for lack of funds and suspend after failed occurrences", func(t *testing.T) {
+		ctx := context.Background()
+		payer := types.NewAccount(verifiedOwner.ID, types.MustParseMoney("1000", types.AOA))
+		landlord := types.NewAccount(verifiedOwner.ID, types.NewMoney(0, types.AOA))
+		accountRepo.CreateAccount(ctx, payer)
+		accountRepo.CreateAccount(ctx, landlord)
+
+		window := int64(3 * 60 * 60)
+		request := requestparams.CreateStandingOrderRequest{
+			TransferMoneyRequest: requestparams.TransferMoneyRequest{
+				Amount:      types.MustParseMoney("100", types.AOA),
+				Subject:     "Renda",
+				Repcipients: []requestparams.Recipient{{AccountId: landlord.ID, Amount: types.MustParseMoney("100", types.AOA)}},
+			},
+			Frequency:   types.Daily,
+			RetryWindow: &window,
+			MaxFailures: 2,
+		}
+		assert.True(t, request.Validate())
+		order, err := accountSrv.CreateStandingOrder(ctx, payer.ID, request)
+		assert.Nil(t, err)
+
+		runDue := func(t *testing.T, fail error) int {
+			due, err := accountSrv.GetStandingOrder(ctx, order.ID)
+			assert.Nil(t, err)
+			due.NextRunAt = time.Now().Add(-time.Second)
+			assert.Nil(t, scheduleRepo.UpdateStandingOrder(ctx, due))
+
+			transactionRepo.MmakeTransferTransaction.ExpectedReturnError = fail
+			defer func() { transactionRepo.MmakeTransferTransaction.ExpectedReturnError = nil }()
+			attempted, err := accountSrv.ExecuteDueStandingOrders(ctx)
+			assert.Nil(t, err)
+			return attempted
+		}
+
+		t.Run("a lack of funds is retried within the same occurrence", func(t *testing.T) {
+			assert.Equal(t, 1, runDue(t, ErrInsufficientFunds))
+			order, err := accountSrv.GetStandingOrder(ctx, order.ID)
+			assert.Nil(t, err)
+			assert.Equal(t, 0, order.Occurrence)
+			assert.Equal(t, 0, order.ConsecutiveFailures)
+			assert.True(t, order.NextRunAt.After(time.Now()))
+		})
+		t.Run("a retry that goes through moves on to the next occurrence", func(t *testing.T) {
+			assert.Equal(t, 1, runDue(t, nil))
+			order, err := accountSrv.GetStandingOrder(ctx, order.ID)
+			assert.Nil(t, err)
+			assert.Equal(t, 1, order.Occurrence)
+
+			runs, err := accountSrv.ListStandingOrderRuns(ctx, order.ID, requestparams.Pagination{})
+			assert.Nil(t, err)
+			assert.Len(t, runs.Data, 2)
+			assert.Equal(t, types.RunSucceeded, runs.Data[0].Status)
+			assert.Equal(t, 2, runs.Data[0].Attempt)
+			assert.Equal(t, types.RunFailed, runs.Data[1].Status)
+			assert.True(t, runs.Data[1].WillRetry)
+		})
+		t.Run("an order is suspended after too many failed occurrences", func(t *testing.T) {
+			for i := 0; i < 2; i++ {
+				assert.Equal(t, 1, runDue(t, errors.New("some error")))
+			}
+			order, err := accountSrv.GetStandingOrder(ctx, order.ID)
+			assert.Nil(t, err)
+			assert.Equal(t, types.StandingOrderSuspended, order.Status)
+			assert.Equal(t, 3, order.Occurrence)
+
+			attempted, err := accountSrv.ExecuteDueStandingOrders(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, 0, attempted)
+		})
+		t.Run("a resumed order starts counting failures again", func(t *testing.T) {
+			order, err := accountSrv.ResumeStandingOrder(ctx, order.ID)
+			assert.Nil(t, err)
+			assert.Equal(t, types.StandingOrderActive, order.Status)
+			assert.Equal(t, 0, order.ConsecutiveFailures)
+		})
+		t.Run("an order is cancelled only once", func(t *testing.T) {
+			_, err := accountSrv.CancelStandingOrder(ctx, order.ID)
+			assert.Nil(t, err)
+			_, err = accountSrv.CancelStandingOrder(ctx, order.ID)
+			assert.True(t, errors.Is(err, ErrStandingOrderClosed))
+		})
+	})
+	t.Run("accounts.ExecuteDueStandingOrders should roll back an attempt that lost its claim", func(t *testing.T) {
+		ctx := context.Background()
+		payer := types.NewAccount(verifiedOwner.ID, types.MustParseMoney("1000", types.AOA))
+		landlord := types.NewAccount(verifiedOwner.ID, types.NewMoney(0, types.AOA))
+		accountRepo.CreateAccount(ctx, payer)
+		accountRepo.CreateAccount(ctx, landlord)
+
+		request := requestparams.CreateStandingOrderRequest{
+			TransferMoneyRequest: requestparams.TransferMoneyRequest{
+				Amount:      types.MustParseMoney("100", types.AOA),
+				Repcipients: []requestparams.Recipient{{AccountId: landlord.ID, Amount: types.MustParseMoney("100", types.AOA)}},
+			},
+			Frequency: types.Daily,
+		}
+		assert.True(t, request.Validate())
+		order, err := accountSrv.CreateStandingOrder(ctx, payer.ID, request)
+		assert.Nil(t, err)
+		order.NextRunAt = time.Now().Add(-time.Second)
+		assert.Nil(t, scheduleRepo.UpdateStandingOrder(ctx, order))
+
+		// another scheduler takes the order over once the lease of this attempt ran out
+		transactionRepo.MmakeTransferTransaction.During = func() {
+			stolen, err := scheduleRepo.GetStandingOrder(ctx, order.ID)
+			assert.Nil(t, err)
+			stolen.Attempts++
+			assert.Nil(t, scheduleRepo.UpdateStandingOrder(ctx, stolen))
+		}
+		defer func() { transactionRepo.MmakeTransferTransaction.During = nil }()
+
+		rollbacks := uow.Rollbacks
+		attempted, err := accountSrv.ExecuteDueStandingOrders(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, attempted)
+		assert.Equal(t, rollbacks+1, uow.Rollbacks)
+
+		order, err = accountSrv.GetStandingOrder(ctx, order.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, order.Occurrence)
+		runs, err := accountSrv.ListStandingOrderRuns(ctx, order.ID, requestparams.Pagination{})
+		assert.Nil(t, err)
+		assert.Empty(t, runs.Data)
+	})
+	t.Run("accounts.BulkTransfer should report every invalid row and run a valid upload as one batch", func(t *testing.T) {
+		ctx := context.Background()
+		employer := types.NewAccount(verifiedOwner.ID, types.MustParseMoney("10000", types.AOA))
+		alice := types.NewAccount(verifiedOwner.ID, types.NewMoney(0, types.AOA))
+		bob := types.NewAccount(verifiedOwner.ID, types.NewMoney(0, types.AOA))
+		for _, account := range []*types.Account{employer, alice, bob} {
+			accountRepo.CreateAccount(ctx, account)
+		}
+
+		t.Run("every invalid row is reported", func(t *testing.T) {
+			request, err := requestparams.ParseBulkTransferCSV(strings.NewReader(
+				"account_id,amount,subject\n" +
+					alice.ID + ",1500.50,Salario de Maio\n" +
+					"some inexistent account,100\n" +
+					bob.ID + ",-5\n" +
+					employer.ID + ",10\n" +
+					"just one column\n"))
+			assert.Nil(t, err)
+			assert.True(t, request.Validate())
+			_, err = accountSrv.BulkTransfer(ctx, employer.ID, *request)
+			var invalid *types.BulkTransferError
+			assert.True(t, errors.As(err, &invalid))
+			assert.True(t, errors.Is(err, ErrInvalidBulkTransfer))
+			assert.Equal(t, []types.BulkRowError{
+				{Row: 3, AccountId: "some inexistent account", Error: "InexistentAccount"},
+				{Row: 4, AccountId: bob.ID, Error: "InvalidAmount"},
+				{Row: 5, AccountId: employer.ID, Error: "SelfTransfer"},
+				{Row: 6, Error: "InvalidColumns"},
+			}, invalid.Rows)
+		})
+		t.Run("a valid upload runs as one batch", func(t *testing.T) {
+			request, err := requestparams.ParseBulkTransferCSV(strings.NewReader(
+				alice.ID + ",1500.50,Salario de Maio\n" + bob.ID + ",2000\n"))
+			assert.Nil(t, err)
+			batch, err := accountSrv.BulkTransfer(ctx, employer.ID, *request)
+			assert.Nil(t, err)
+			assert.Equal(t, types.BatchPending, batch.Status)
+			assert.Equal(t, types.MustParseMoney("3500.50", types.AOA), batch.Amount)
+
+			ids, err := accountSrv.NextOperations(ctx, 10)
+			assert.Nil(t, err)
+			assert.Len(t, ids, 1)
+			assert.Nil(t, accountSrv.RunOperation(ctx, ids[0]))
+			batch, err = accountSrv.GetTransferBatch(ctx, batch.ID)
+			assert.Nil(t, err)
+			assert.Equal(t, types.BatchCompleted, batch.Status)
+			transactions := transactionRepo.Transactions()
+			salary := transactions[len(transactions)-2]
+			assert.Equal(t, "Salario de Maio", salary.Subject)
+			assert.Equal(t, batch.ID, salary.MultiBeneficiaryTransactionId)
+			assert.Equal(t, defaultBulkTransferSubject, transactions[len(transactions)-1].Subject)
+		})
+	})
+	t.Run("accounts.RunOperation should run submitted requests once and record their outcome", func(t *testing.T) {
+		ctx := context.Background()
+		from := types.NewAccount(verifiedOwner.ID, types.MustParseMoney("1000", types.AOA))
+		accountRepo.CreateAccount(ctx, from)
+
+		t.Run("a request for an inexistent account is not submitted", func(t *testing.T) {
+			_, err := accountSrv.SubmitTransfer(ctx, requestparams.TransferMoneyRequest{From: "some inexistent account"})
+			assert.True(t, errors.Is(err, ErrInexistentAccount))
+		})
+
+		var original *types.Transaction
+		t.Run("a transfer runs once and keeps its result", func(t *testing.T) {
+			amount := types.MustParseMoney("400", types.AOA)
+			transfer, err := accountSrv.SubmitTransfer(ctx, requestparams.TransferMoneyRequest{
+				From:        from.ID,
+				Amount:      amount,
+				Repcipients: []requestparams.Recipient{{AccountId: "async to dumb id", Amount: amount}},
+			})
+			assert.Nil(t, err)
+			assert.Equal(t, types.OperationPending, transfer.Status)
+
+			ids, err := accountSrv.NextOperations(ctx, 10)
+			assert.Nil(t, err)
+			assert.Equal(t, []string{transfer.ID}, ids)
+			assert.Nil(t, accountSrv.RunOperation(ctx, transfer.ID))
+			assert.Nil(t, accountSrv.RunOperation(ctx, transfer.ID))
+
+			transfer, err = accountSrv.GetOperation(ctx, transfer.ID)
+			assert.Nil(t, err)
+			assert.Equal(t, types.OperationSucceeded, transfer.Status)
+			assert.NotNil(t, transfer.CompletedAt)
+			var batch types.TransferBatch
+			assert.Nil(t, json.Unmarshal(transfer.Result, &batch))
+			assert.Equal(t, types.BatchCompleted, batch.Status)
+			original, err = accountSrv.GetTransaction(ctx, batch.Legs[0].TransactionId)
+			assert.Nil(t, err)
+			assert.Equal(t, types.TransactionCompleted, original.Status)
+		})
+		t.Run("a refund submitted twice only refunds once", func(t *testing.T) {
+			request := requestparams.RefundMoneyRequest{TransactionId: original.ID}
+			refund, err := accountSrv.SubmitRefund(ctx, request)
+			assert.Nil(t, err)
+			again, err := accountSrv.SubmitRefund(ctx, request)
+			assert.Nil(t, err)
+			ids, err := accountSrv.NextOperations(ctx, 10)
+			assert.Nil(t, err)
+			assert.Equal(t, []string{refund.ID, again.ID}, ids)
+			for _, id := range ids {
+				assert.Nil(t, accountSrv.RunOperation(ctx, id))
+			}
+
+			refund, err = accountSrv.GetOperation(ctx, refund.ID)
+			assert.Nil(t, err)
+			assert.Equal(t, types.OperationSucceeded, refund.Status)
+			again, err = accountSrv.GetOperation(ctx, again.ID)
+			assert.Nil(t, err)
+			assert.Equal(t, types.OperationFailed, again.Status)
+			assert.Equal(t, ErrTransactionNotRefundable.Error(), again.Error)
+			original, err := accountSrv.GetTransaction(ctx, original.ID)
+			assert.Nil(t, err)
+			assert.Equal(t, types.TransactionReversed, original.Status)
+		})
+		t.Run("finished operations are not handed out again", func(t *testing.T) {
+			ids, err := accountSrv.NextOperations(ctx, 10)
+			assert.Nil(t, err)
+			assert.Empty(t, ids)
+			_, err = accountSrv.GetOperation(ctx, "some inexistent operation")
+			assert.Equal(t, ErrInexistentOperation, err)
+		})
+	})
+	t.Run("accounts.NextOperations should hand operations left running out again", func(t *testing.T) {
+		ctx := context.Background()
+		operation, err := accountSrv.SubmitRefund(ctx, requestparams.RefundMoneyRequest{TransactionId: "some transaction"})
+		assert.Nil(t, err)
+		assert.Nil(t, operation.Start(time.Now().Add(-time.Hour)))
+		assert.Nil(t, operationRepo.UpdateOperation(ctx, operation))
+
+		ids, err := accountSrv.NextOperations(ctx, 10)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{operation.ID}, ids)
+		operation, err = accountSrv.GetOperation(ctx, operation.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, types.OperationPending, operation.Status)
+
+		assert.Nil(t, accountSrv.RunOperation(ctx, operation.ID))
+		operation, err = accountSrv.GetOperation(ctx, operation.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, types.OperationFailed, operation.Status)
+	})
+	t.Run("accounts.RunOperation should roll back an operation interrupted while it ran and run it again", func(t *testing.T) {
+		ctx := context.Background()
+		from := types.NewAccount(verifiedOwner.ID, types.MustParseMoney("1000", types.AOA))
+		to := types.NewAccount(verifiedOwner.ID, types.NewMoney(0, types.AOA))
+		accountRepo.CreateAccount(ctx, from)
+		accountRepo.CreateAccount(ctx, to)
+
+		amount := types.MustParseMoney("100", types.AOA)
+		operation, err := accountSrv.SubmitTransfer(ctx, requestparams.TransferMoneyRequest{
+			From:        from.ID,
+			Amount:      amount,
+			Repcipients: []requestparams.Recipient{{AccountId: to.ID, Amount: amount}},
+		})
+		assert.Nil(t, err)
+
+		t.Run("the stalled run is rolled back", func(t *testing.T) {
+			// the worker stalls past its lease and another server puts the operation back meanwhile
+			transactionRepo.MmakeTransferTransaction.During = func() {
+				stalled, err := operationRepo.GetOperation(ctx, operation.ID)
+				assert.Nil(t, err)
+				expired := time.Now().Add(-time.Second)
+				stalled.LockedUntil = &expired
+				assert.Nil(t, operationRepo.UpdateOperation(ctx, stalled))
+				_, err = accountSrv.NextOperations(ctx, 10)
+				assert.Nil(t, err)
+			}
+			defer func() { transactionRepo.MmakeTransferTransaction.During = nil }()
+
+			rollbacks := uow.Rollbacks
+			err := accountSrv.RunOperation(ctx, operation.ID)
+			assert.True(t, errors.Is(err, types.ErrOperationLeaseLost))
+			assert.Equal(t, rollbacks+1, uow.Rollbacks)
+			operation, err := accountSrv.GetOperation(ctx, operation.ID)
+			assert.Nil(t, err)
+			assert.Equal(t, types.OperationPending, operation.Status)
+		})
+		t.Run("the next run records its outcome with the transfer", func(t *testing.T) {
+			commits := uow.Commits
+			assert.Nil(t, accountSrv.RunOperation(ctx, operation.ID))
+			// one to claim the operation, one to move the money and finish it
+			assert.Equal(t, commits+2, uow.Commits)
+			operation, err := accountSrv.GetOperation(ctx, operation.ID)
+			assert.Nil(t, err)
+			assert.Equal(t, types.OperationSucceeded, operation.Status)
+			var batch types.TransferBatch
+			assert.Nil(t, json.Unmarshal(operation.Result, &batch))
+			assert.Equal(t, types.BatchCompleted, batch.Status)
+		})
+	})
+	t.Run("accounts.DepositMoney should write the event of the deposit to the outbox with the transaction", func(t *testing.T) {
+		ctx := context.Background()
+		account := types.NewAccount(verifiedOwner.ID, types.NewMoney(0, types.AOA))
+		accountRepo.CreateAccount(ctx, account)
+
+		assert.Nil(t, accountSrv.DepositMoney(ctx, account.ID, types.MustParseMoney("250", types.AOA)))
+		events := outboxRepo.Events()
+		event := events[len(events)-1]
+		assert.Equal(t, types.EventDepositCompleted, event.Type)
+		var transaction types.Transaction
+		assert.Nil(t, json.Unmarshal(event.Data, &transaction))
+		assert.Equal(t, account.ID, transaction.To)
+		assert.Equal(t, types.TransactionCompleted, transaction.Status)
+
+		transactionRepo.McreateTransaction.ExpectedReturnError = errors.New("some error")
+		assert.NotNil(t, accountSrv.DepositMoney(ctx, account.ID, types.MustParseMoney("250", types.AOA)))
+		transactionRepo.McreateTransaction.ExpectedReturnError = nil
+		assert.Len(t, outboxRepo.Events(), len(events))
+	})
 }
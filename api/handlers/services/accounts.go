@@ -5,44 +5,149 @@ import (
 	"database/sql"
 	requestparams "go-sample/api/handlers/request-params"
 	"go-sample/api/utils"
+	"go-sample/storage"
 	accountrepo "go-sample/storage/account-repo"
+	feerepo "go-sample/storage/fee-repo"
+	fxrepo "go-sample/storage/fx-repo"
+	holdrepo "go-sample/storage/hold-repo"
+	ledgerrepo "go-sample/storage/ledger-repo"
+	limitrepo "go-sample/storage/limit-repo"
+	operationrepo "go-sample/storage/operation-repo"
+	outboxrepo "go-sample/storage/outbox-repo"
+	ownerrepo "go-sample/storage/owner-repo"
+	schedulerepo "go-sample/storage/schedule-repo"
 	transactionrepo "go-sample/storage/transaction-repo"
 	"go-sample/types"
-
-	"github.com/google/uuid"
+	"log"
+	"sort"
 )
 
 type Account struct {
 	accountRepo     accountrepo.IAccountRepo
 	transactionRepo transactionrepo.ITransactionRepo
+	ledgerRepo      ledgerrepo.ILedgerRepo
+	ownerRepo       ownerrepo.IOwnerRepo
+	fxRepo          fxrepo.IFXRepo
+	holdRepo        holdrepo.IHoldRepo
+	feeRepo         feerepo.IFeeRepo
+	limitRepo       limitrepo.ILimitRepo
+	scheduleRepo    schedulerepo.IScheduleRepo
+	operationRepo   operationrepo.IOperationRepo
+	outboxRepo      outboxrepo.IOutboxRepo
+	uow             storage.UnitOfWork
 }
 
-func NewAccount(accountRepo accountrepo.IAccountRepo, transactionRepo transactionrepo.ITransactionRepo) Account {
+func NewAccount(accountRepo accountrepo.IAccountRepo, transactionRepo transactionrepo.ITransactionRepo, ledgerRepo ledgerrepo.ILedgerRepo, ownerRepo ownerrepo.IOwnerRepo, fxRepo fxrepo.IFXRepo, holdRepo holdrepo.IHoldRepo, feeRepo feerepo.IFeeRepo, limitRepo limitrepo.ILimitRepo, scheduleRepo schedulerepo.IScheduleRepo, operationRepo operationrepo.IOperationRepo, outboxRepo outboxrepo.IOutboxRepo, uow storage.UnitOfWork) Account {
 	return Account{
 		accountRepo:     accountRepo,
 		transactionRepo: transactionRepo,
+		ledgerRepo:      ledgerRepo,
+		ownerRepo:       ownerRepo,
+		fxRepo:          fxRepo,
+		holdRepo:        holdRepo,
+		feeRepo:         feeRepo,
+		limitRepo:       limitRepo,
+		scheduleRepo:    scheduleRepo,
+		operationRepo:   operationRepo,
+		outboxRepo:      outboxRepo,
+		uow:             uow,
 	}
 }
 
+// CreateAccount opens an account for a verified owner. A non zero opening balance is booked as a deposit
+// from the cash-in system account, so the account balance can always be derived from the ledger
 func (as *Account) CreateAccount(ctx context.Context, account *types.Account) (string, error) {
-	return as.accountRepo.CreateAccount(ctx, account)
+	owner, err := as.ownerRepo.GetOwner(ctx, account.Owner)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrInexistentOwner
+		}
+		return "", err
+	}
+	if !owner.IsVerified() {
+		return "", ErrOwnerNotVerified
+	}
+
+	var accountId string
+	err = as.uow.Do(ctx, func(ctx context.Context) error {
+		var err error
+		accountId, err = as.accountRepo.CreateAccount(ctx, account)
+		if err != nil {
+			return err
+		}
+		if account.Balance.IsZero() {
+			return nil
+		}
+		transaction := types.NewTransaction(account.Balance, types.SystemCashInAccount, account.ID, "Saldo inicial", string(utils.DEPOSIT), "", false, "")
+		return as.completeTransaction(ctx, transaction)
+	})
+	return accountId, err
 }
 
-func (as *Account) ListAccounts(ctx context.Context, filters requestparams.ListAccountsRequest) ([]*types.Account, error) {
+func (as *Account) ListAccounts(ctx context.Context, filters requestparams.ListAccountsRequest) (*types.Page[*types.Account], error) {
 	return as.accountRepo.ListAccounts(ctx, filters)
 }
 
-func (as *Account) GetAccountBalance(ctx context.Context, accountId string) (float64, error) {
+func (as *Account) GetAccountBalance(ctx context.Context, accountId string) (types.Money, error) {
 	balance, err := as.accountRepo.GetAccountBalance(ctx, accountId)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return 0, ErrInexistentAccount
+			return types.Money{}, ErrInexistentAccount
 		}
-		return 0, err
+		return types.Money{}, err
 	}
 	return balance, nil
 }
 
+// GetAvailableBalance reports the ledger balance of the account next to what it can still spend
+// and the active holds that reduce it
+func (as *Account) GetAvailableBalance(ctx context.Context, accountId string) (*types.AccountBalance, error) {
+	account, err := as.GetAccount(ctx, accountId)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil {
+		return nil, ErrInexistentAccount
+	}
+	holds, err := as.holdRepo.ListActiveHolds(ctx, accountId)
+	if err != nil {
+		return nil, err
+	}
+	return types.NewAccountBalance(account, holds), nil
+}
+
+// SetCreditLimit approves an overdraft for the account. The row is locked so the limit can not be
+// lowered below the current overdraft while a debit is going through
+func (as *Account) SetCreditLimit(ctx context.Context, accountId string, limit types.Money) (*types.Account, error) {
+	var account *types.Account
+	err := as.uow.Do(ctx, func(ctx context.Context) error {
+		locked, err := as.lockAccounts(ctx, accountId)
+		if err != nil {
+			return err
+		}
+		if len(locked) == 0 {
+			return ErrInexistentAccount
+		}
+		if locked[0].Status == types.AccountClosed {
+			return ErrAccountClosed
+		}
+
+		updated := *locked[0]
+		account = &updated
+		if limit.Currency == "" {
+			limit.Currency = account.Currency
+		}
+		if err := account.SetCreditLimit(limit); err != nil {
+			return err
+		}
+		return as.accountRepo.UpdateCreditLimit(ctx, account)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
 func (as *Account) GetAccount(ctx context.Context, accountId string) (*types.Account, error) {
 	account, err := as.accountRepo.GetAccountById(ctx, accountId)
 	if err != nil {
@@ -54,15 +159,125 @@ func (as *Account) GetAccount(ctx context.Context, accountId string) (*types.Acc
 	return account, nil
 }
 
-func (as *Account) DepositMoney(ctx context.Context, accountId string, amount float64) error {
+func (as *Account) FreezeAccount(ctx context.Context, accountId string, reason string, actor string) (*types.Account, error) {
+	return as.changeAccountStatus(ctx, accountId, types.AccountFrozen, reason, actor, "")
+}
+
+func (as *Account) UnfreezeAccount(ctx context.Context, accountId string, reason string, actor string) (*types.Account, error) {
+	return as.changeAccountStatus(ctx, accountId, types.AccountActive, reason, actor, "")
+}
+
+// CloseAccount closes an account holding no money. When it still holds some, the whole balance
+// is first transferred to sweepTo in the same unit of work, without sweepTo it fails with ErrAccountHasBalance.
+// Accounts with active holds can not be closed until the holds are captured, voided or expired
+func (as *Account) CloseAccount(ctx context.Context, accountId string, reason string, actor string, sweepTo string) (*types.Account, error) {
+	return as.changeAccountStatus(ctx, accountId, types.AccountClosed, reason, actor, sweepTo)
+}
+
+func (as *Account) GetAccountStatusHistory(ctx context.Context, accountId string) ([]*types.AccountStatusChange, error) {
+	if _, err := as.GetAccount(ctx, accountId); err != nil {
+		return nil, err
+	}
+	return as.accountRepo.GetAccountStatusHistory(ctx, accountId)
+}
+
+func (as *Account) changeAccountStatus(ctx context.Context, accountId string, status types.AccountStatus, reason string, actor string, sweepTo string) (*types.Account, error) {
+	var account *types.Account
+	err := as.uow.Do(ctx, func(ctx context.Context) error {
+		locked, err := as.lockAccounts(ctx, accountId, sweepTo)
+		if err != nil {
+			return err
+		}
+		var sweepAccount *types.Account
+		for _, a := range locked {
+			switch a.ID {
+			case accountId:
+				// work on a copy, the locked row must stay untouched if the unit of work rolls back
+				updated := *a
+				account = &updated
+			case sweepTo:
+				sweepAccount = a
+			}
+		}
+		if account == nil {
+			return ErrInexistentAccount
+		}
+
+		change, err := account.TransitionTo(status, reason, actor)
+		if err != nil {
+			return err
+		}
+		if status == types.AccountClosed && account.HeldAmount.IsPositive() {
+			return ErrAccountHasActiveHolds
+		}
+		if status == types.AccountClosed && !account.Balance.IsZero() {
+			if err := as.sweepBalance(ctx, account, sweepTo, sweepAccount); err != nil {
+				return err
+			}
+		}
+		return as.accountRepo.UpdateAccountStatus(ctx, account, change)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// sweepBalance moves everything left in a closing account to the sweep account
+func (as *Account) sweepBalance(ctx context.Context, account *types.Account, sweepTo string, sweepAccount *types.Account) error {
+	if sweepTo == "" || account.Balance.IsNegative() {
+		return ErrAccountHasBalance
+	}
+	if sweepTo == account.ID || sweepAccount == nil {
+		return ErrInvalidSweepAccount
+	}
+	if err := ensureOperable(sweepAccount); err != nil {
+		return err
+	}
 
-	err := as.accountRepo.IncrBalance(ctx, accountId, amount)
+	transaction := types.NewTransaction(account.Balance, account.ID, sweepTo, "Encerramento de conta", string(utils.TRANSFER), "", false, "")
+	if err := as.exchangeTo(ctx, transaction, sweepAccount.Currency); err != nil {
+		return err
+	}
+	if err := as.transactionRepo.MakeTransferTransaction(ctx, account.ID, sweepTo, transaction.Amount, transaction.DestinationAmount); err != nil {
+		return err
+	}
+	if err := as.completeTransaction(ctx, transaction); err != nil {
+		return err
+	}
+	account.Balance = types.NewMoney(0, account.Balance.Currency)
+	return nil
+}
 
+// DepositMoney credits the account, an amount without currency is taken in the account currency.
+// The deposit fee, if any, is charged right after in the same unit of work
+func (as *Account) DepositMoney(ctx context.Context, accountId string, amount types.Money) error {
+	amount, err := as.inAccountCurrency(ctx, accountId, amount)
 	if err != nil {
 		return err
 	}
-	transaction := types.NewTransaction(amount, "", "", "Deposito", string(utils.DEPOSIT), "", false, "")
-	err = as.transactionRepo.CreateTransaction(ctx, transaction)
+	fee, err := as.quoteFee(ctx, accountId, utils.DEPOSIT, amount)
+	if err != nil {
+		return err
+	}
+	transaction := types.NewTransaction(amount, types.SystemCashInAccount, accountId, "Deposito", string(utils.DEPOSIT), "", false, "")
+	err = as.uow.Do(ctx, func(ctx context.Context) error {
+		if err := as.lockOperableAccounts(ctx, accountId); err != nil {
+			return err
+		}
+		err := as.accountRepo.IncrBalance(ctx, accountId, amount)
+
+		if err != nil {
+			return err
+		}
+		if err := as.completeTransaction(ctx, transaction); err != nil {
+			return err
+		}
+		return as.chargeFee(ctx, accountId, transaction, fee)
+	})
+	if err != nil {
+		as.recordFailure(ctx, transaction, err)
+	}
 	return err
 }
 
@@ -77,76 +292,284 @@ func (as *Account) IsAccountExistent(ctx context.Context, accountId string) bool
 	return true
 }
 
-func (as *Account) WithdrawMoney(ctx context.Context, accountId string, amount float64) error {
-	err := as.accountRepo.DecrBalance(ctx, accountId, amount)
+// WithdrawMoney debits the account, an amount without currency is taken in the account currency.
+// The balance must also cover the withdrawal fee, which is charged in the same unit of work
+func (as *Account) WithdrawMoney(ctx context.Context, accountId string, amount types.Money) error {
+	amount, err := as.inAccountCurrency(ctx, accountId, amount)
 	if err != nil {
 		return err
 	}
-	transaction := types.NewTransaction(amount, "", "", "Levantamento", string(utils.WITHDRAW), "", false, "")
-	err = as.transactionRepo.CreateTransaction(ctx, transaction)
+	fee, err := as.quoteFee(ctx, accountId, utils.WITHDRAW, amount)
+	if err != nil {
+		return err
+	}
+	transaction := types.NewTransaction(amount, accountId, types.SystemCashOutAccount, "Levantamento", string(utils.WITHDRAW), "", false, "")
+	err = as.uow.Do(ctx, func(ctx context.Context) error {
+		if err := as.lockOperableAccounts(ctx, accountId); err != nil {
+			return err
+		}
+		if err := as.checkLimit(ctx, accountId, utils.WITHDRAW, amount); err != nil {
+			return err
+		}
+		err := as.accountRepo.DecrBalance(ctx, accountId, amount)
+		if err != nil {
+			return err
+		}
+		if err := as.completeTransaction(ctx, transaction); err != nil {
+			return err
+		}
+		return as.chargeFee(ctx, accountId, transaction, fee)
+	})
+	if err != nil {
+		as.recordFailure(ctx, transaction, err)
+	}
 	return err
 }
 
-func (as *Account) IsThereAlreadyAccountWithThisOwner(ctx context.Context, ownerId string) (bool, error) {
-	_, err := as.accountRepo.GetAccountByOwnerId(ctx, ownerId)
+func (as *Account) GetOwnerAccounts(ctx context.Context, ownerId string) (*types.OwnerAccounts, error) {
+	accounts, err := as.accountRepo.GetAccountsByOwnerId(ctx, ownerId)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return false, nil
-		}
-		return false, err
+		return nil, err
 	}
-	return true, nil
+	return types.NewOwnerAccounts(ownerId, accounts), nil
 }
 
-func (as *Account) HasInsufficientFunds(ctx context.Context, accountId string, amountToOut float64) bool {
-	balance, _ := as.accountRepo.GetAccountBalance(ctx, accountId)
+// InternalTransfer moves money between two accounts of the same owner
+func (as *Account) InternalTransfer(ctx context.Context, ownerId string, params requestparams.InternalTransferRequest) (*types.TransferBatch, error) {
+	for _, accountId := range []string{params.From, params.To} {
+		account, err := as.GetAccount(ctx, accountId)
+		if err != nil {
+			return nil, err
+		}
+		if account == nil || account.Owner != ownerId {
+			return nil, ErrAccountNotOwned
+		}
+	}
 
-	return amountToOut > balance
+	subject := params.Subject
+	if subject == "" {
+		subject = "Transferencia entre contas"
+	}
+	return as.TransferMoney(ctx, requestparams.TransferMoneyRequest{
+		From:        params.From,
+		Amount:      params.Amount,
+		Subject:     subject,
+		Repcipients: []requestparams.Recipient{{AccountId: params.To, Amount: params.Amount}},
+	})
 }
 
-func (as *Account) TransferMoney(ctx context.Context, transferParams requestparams.TransferMoneyRequest) error {
-	var multiBeneficiaryTransactionId string = ""
+// TransferMoney moves the money to every recipient in a single unit of work: either all the legs
+// are committed or none is. Multi-beneficiary transfers also keep a parent batch record with the overall status.
+// The amount must be in the currency of the from account, recipients holding another currency
+// are credited at the stored exchange rate. The transfer fee is charged to from once per recipient
+func (as *Account) TransferMoney(ctx context.Context, transferParams requestparams.TransferMoneyRequest) (*types.TransferBatch, error) {
+	return as.transferMoney(ctx, transferParams, transferHooks{})
+}
 
-	if len(transferParams.Repcipients) > 1 {
-		multiBeneficiaryTransactionId = uuid.NewString()
+// transferHooks tie a transfer to what it was made for, both run in the unit of work moving its money.
+// claim runs before any leg, a transfer it refuses is dropped without a trace. settle runs once every
+// leg moved and the batch is completed, so the money only moves if what settle stores does too.
+// Refunds run them the same way, settle gets no batch
+type transferHooks struct {
+	claim  func(ctx context.Context) error
+	settle func(ctx context.Context, batch *types.TransferBatch) error
+}
+
+// transferMoney is TransferMoney with hooks
+func (as *Account) transferMoney(ctx context.Context, transferParams requestparams.TransferMoneyRequest, hooks transferHooks) (*types.TransferBatch, error) {
+	if _, err := as.inAccountCurrency(ctx, transferParams.From, transferParams.Amount); err != nil {
+		return nil, err
+	}
+	if err := as.checkKYCTransferLimit(ctx, transferParams.From, transferParams.Amount); err != nil {
+		return nil, err
 	}
 
+	currencies := map[string]types.Currency{}
 	for _, recipient := range transferParams.Repcipients {
-		_, err := as.accountRepo.GetAccountById(ctx, recipient.AccountId)
+		account, err := as.accountRepo.GetAccountById(ctx, recipient.AccountId)
 
 		if err == sql.ErrNoRows {
-			return ErrInexistentAccount
+			return nil, ErrInexistentAccount
+		}
+		if account != nil {
+			currencies[recipient.AccountId] = account.Currency
 		}
 	}
+
+	batch := types.NewTransferBatch(transferParams.From, transferParams.Subject, transferParams.Amount)
 	for _, recipient := range transferParams.Repcipients {
+		batch.AddLeg(recipient.AccountId, recipient.Amount).Subject = recipient.Subject
+	}
 
-		err := as.transactionRepo.MakeTransferTransaction(ctx, transferParams.From, recipient.AccountId, recipient.Amount)
+	grouped := len(batch.Legs) > 1
+	transactions, fees, err := as.prepareTransfer(ctx, batch, currencies, grouped)
+	if err != nil {
+		return nil, err
+	}
 
-		if err != nil {
-			return err
+	if grouped {
+		if err := as.transactionRepo.CreateTransferBatch(ctx, batch); err != nil {
+			return nil, err
 		}
+	}
+	return batch, as.commitTransfer(ctx, batch, transactions, fees, grouped, hooks)
+}
+
+// prepareTransfer builds the transaction and quotes the fee of every leg of the batch, exchanged into the
+// currency of its recipient. Grouped transactions carry the batch id as their multi-beneficiary id
+func (as *Account) prepareTransfer(ctx context.Context, batch *types.TransferBatch, currencies map[string]types.Currency, grouped bool) ([]*types.Transaction, []types.Money, error) {
+	var multiBeneficiaryTransactionId string = ""
+	if grouped {
+		multiBeneficiaryTransactionId = batch.ID
+	}
 
-		transaction := types.NewTransaction(
-			recipient.Amount,
-			transferParams.From,
-			recipient.AccountId,
-			transferParams.Subject,
+	transactions := make([]*types.Transaction, len(batch.Legs))
+	fees := make([]types.Money, len(batch.Legs))
+	for i, leg := range batch.Legs {
+		fee, err := as.quoteFee(ctx, batch.From, utils.TRANSFER, leg.Amount)
+		if err != nil {
+			return nil, nil, err
+		}
+		fees[i] = fee
+		subject := leg.Subject
+		if subject == "" {
+			subject = batch.Subject
+		}
+		transactions[i] = types.NewTransaction(
+			leg.Amount,
+			batch.From,
+			leg.AccountId,
+			subject,
 			string(utils.TRANSFER),
 			multiBeneficiaryTransactionId,
 			false,
 			"",
 		)
-		as.transactionRepo.CreateTransaction(ctx, transaction)
+		if err := as.exchangeTo(ctx, transactions[i], currencies[leg.AccountId]); err != nil {
+			return nil, nil, err
+		}
 	}
-	return nil
+	return transactions, fees, nil
 }
 
-func (as *Account) GetTransactionsHistory(ctx context.Context, accountId string, filters requestparams.GetTransactionsHistoryRequest) ([]*types.Transaction, error) {
+// commitTransfer moves the money of every leg in a single unit of work and records the outcome on the
+// batch, grouped batches are stored with it. When the settle hook fails every leg is rolled back and none
+// is recorded as failed, the legs themselves were fine
+func (as *Account) commitTransfer(ctx context.Context, batch *types.TransferBatch, transactions []*types.Transaction, fees []types.Money, grouped bool, hooks transferHooks) error {
+	accountIds := []string{batch.From}
+	amounts := make([]types.Money, len(batch.Legs))
+	for i, leg := range batch.Legs {
+		accountIds = append(accountIds, leg.AccountId)
+		amounts[i] = leg.Amount
+	}
+
+	claimed := true
+	failedLeg := 0
+	err := as.uow.Do(ctx, func(ctx context.Context) error {
+		if hooks.claim != nil {
+			if err := hooks.claim(ctx); err != nil {
+				claimed = false
+				return err
+			}
+		}
+		if err := as.lockOperableAccounts(ctx, accountIds...); err != nil {
+			return err
+		}
+		if err := as.checkLimit(ctx, batch.From, utils.TRANSFER, amounts...); err != nil {
+			return err
+		}
+		for i, leg := range batch.Legs {
+			failedLeg = i
+
+			err := as.transactionRepo.MakeTransferTransaction(ctx, batch.From, leg.AccountId, leg.Amount, transactions[i].DestinationAmount)
+
+			if err != nil {
+				return err
+			}
+
+			if err := as.completeTransaction(ctx, transactions[i]); err != nil {
+				return err
+			}
+			if err := as.chargeFee(ctx, batch.From, transactions[i], fees[i]); err != nil {
+				return err
+			}
+			leg.TransactionId = transactions[i].ID
+			leg.Status = types.LegCompleted
+		}
+		if hooks.settle == nil {
+			return nil
+		}
+		failedLeg = len(batch.Legs)
+		batch.Complete()
+		return hooks.settle(ctx, batch)
+	})
+
+	if !claimed {
+		return err
+	}
+	if err != nil {
+		batch.Fail(failedLeg, err)
+		if failedLeg < len(batch.Legs) && as.recordFailure(ctx, transactions[failedLeg], err) {
+			batch.Legs[failedLeg].TransactionId = transactions[failedLeg].ID
+		}
+	} else {
+		batch.Complete()
+	}
+
+	if grouped {
+		if updateErr := as.transactionRepo.UpdateTransferBatch(ctx, batch); updateErr != nil && err == nil {
+			return updateErr
+		}
+	}
+	return err
+}
+
+func (as *Account) GetTransferBatch(ctx context.Context, batchId string) (*types.TransferBatch, error) {
+	batch, err := as.transactionRepo.GetTransferBatch(ctx, batchId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInexistentTransferBatch
+		}
+		return nil, err
+	}
+	return batch, nil
+}
+
+func (as *Account) GetTransactionsHistory(ctx context.Context, accountId string, filters requestparams.GetTransactionsHistoryRequest) (*types.Page[*types.HistoryEntry], error) {
 	return as.transactionRepo.GetTransactionsHistory(ctx, accountId, filters)
 }
 
-func (as *Account) RefundMoneyNormalTransfer(ctx context.Context, transactionId string) error {
+func (as *Account) GetTransaction(ctx context.Context, transactionId string) (*types.Transaction, error) {
+	transaction, err := as.transactionRepo.GetTransaction(ctx, transactionId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInexistentTransaction
+		}
+		return nil, err
+	}
+	transaction.StatusHistory, err = as.transactionRepo.GetTransactionStatusHistory(ctx, transactionId)
+	if err != nil {
+		return nil, err
+	}
+	linked, err := as.transactionRepo.GetLinkedTransactions(ctx, transactionId)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range linked {
+		if t.Operation == string(utils.FEE) {
+			transaction.Fee = t
+		}
+	}
+	return transaction, nil
+}
+
+// RefundMoneyNormalTransfer refunds amount of the transaction, or everything that was not refunded yet when amount is nil
+func (as *Account) RefundMoneyNormalTransfer(ctx context.Context, transactionId string, amount *types.Money) error {
+	return as.refundMoneyNormalTransfer(ctx, transactionId, amount, transferHooks{})
+}
 
+// refundMoneyNormalTransfer is RefundMoneyNormalTransfer with hooks
+func (as *Account) refundMoneyNormalTransfer(ctx context.Context, transactionId string, amount *types.Money, hooks transferHooks) error {
 	transaction, err := as.transactionRepo.GetTransaction(ctx, transactionId)
 
 	if err != nil {
@@ -158,62 +581,294 @@ func (as *Account) RefundMoneyNormalTransfer(ctx context.Context, transactionId
 	if transaction.IsRefund {
 		return ErrUnableToRefundARefund
 	}
+	// deposits, withdrawals and fees move money to or from a system account, only transfers can be sent back
+	if transaction.Operation != string(utils.TRANSFER) || transaction.Status != types.TransactionCompleted {
+		return ErrTransactionNotRefundable
+	}
 
-	balance, _ := as.accountRepo.GetAccountBalance(ctx, transaction.To)
-	if transaction.Amount > balance {
-		return ErrInsufficientFunds
+	refundAmount := transaction.RefundableAmount()
+	if amount != nil {
+		refundAmount = *amount
+	}
+	if !refundAmount.SameCurrency(transaction.Amount) || refundAmount.GreaterThan(transaction.RefundableAmount()) {
+		return ErrRefundExceedsRefundableAmount
+	}
+
+	refund := newRefundTransaction(transaction, refundAmount)
+	claimed := true
+	err = as.uow.Do(ctx, func(ctx context.Context) error {
+		if hooks.claim != nil {
+			if err := hooks.claim(ctx); err != nil {
+				claimed = false
+				return err
+			}
+		}
+		if err := as.lockOperableAccounts(ctx, transaction.From, transaction.To); err != nil {
+			return err
+		}
+		if err := as.refundTransaction(ctx, transaction.ID, refund); err != nil {
+			return err
+		}
+		if hooks.settle == nil {
+			return nil
+		}
+		return hooks.settle(ctx, nil)
+	})
+	if err != nil && claimed {
+		as.recordFailure(ctx, refund, err)
 	}
+	return err
+}
 
-	err = as.transactionRepo.MakeTransferTransaction(ctx, transaction.To, transaction.From, transaction.Amount)
+func (as *Account) RefundMultibeneficiaryTransfer(ctx context.Context, multibeneficiaryId string) error {
+	return as.refundMultibeneficiaryTransfer(ctx, multibeneficiaryId, transferHooks{})
+}
+
+// refundMultibeneficiaryTransfer is RefundMultibeneficiaryTransfer with hooks
+func (as *Account) refundMultibeneficiaryTransfer(ctx context.Context, multibeneficiaryId string, hooks transferHooks) error {
+	transactions, err := as.transactionRepo.GetMultiBeneficiaryTransactions(ctx, multibeneficiaryId)
 
 	if err != nil {
 		return err
 	}
 
-	newTransaction := types.NewTransaction(
-		transaction.Amount,
-		transaction.From,
+	refundable := []*types.Transaction{}
+	for _, transaction := range transactions {
+		if transaction.IsRefund {
+			return ErrUnableToRefundARefund
+		}
+		if transaction.Operation == string(utils.TRANSFER) && transaction.RefundableAmount().IsPositive() {
+			refundable = append(refundable, transaction)
+		}
+	}
+	if len(refundable) == 0 {
+		return ErrTransactionNotRefundable
+	}
+
+	accountIds := []string{}
+	refunds := make([]*types.Transaction, len(refundable))
+	for i, transaction := range refundable {
+		accountIds = append(accountIds, transaction.From, transaction.To)
+		refunds[i] = newRefundTransaction(transaction, transaction.RefundableAmount())
+	}
+
+	claimed := true
+	failed := 0
+	err = as.uow.Do(ctx, func(ctx context.Context) error {
+		if hooks.claim != nil {
+			if err := hooks.claim(ctx); err != nil {
+				claimed = false
+				return err
+			}
+		}
+		if err := as.lockOperableAccounts(ctx, accountIds...); err != nil {
+			return err
+		}
+		for i, transaction := range refundable {
+			failed = i
+			if err := as.refundTransaction(ctx, transaction.ID, refunds[i]); err != nil {
+				return err
+			}
+		}
+		if hooks.settle == nil {
+			return nil
+		}
+		return hooks.settle(ctx, nil)
+	})
+	if err != nil && claimed {
+		as.recordFailure(ctx, refunds[failed], err)
+	}
+	return err
+}
+
+// newRefundTransaction sends amount back to the sender of transaction. When the transaction exchanged
+// currencies, the matching share of what the recipient got is taken back and amount is what the sender receives
+func newRefundTransaction(transaction *types.Transaction, amount types.Money) *types.Transaction {
+	refund := types.NewTransaction(
+		transaction.DestinationShare(amount),
 		transaction.To,
+		transaction.From,
 		transaction.Subject,
 		string(utils.REFUND), "",
 		true,
-		transactionId)
-	as.transactionRepo.CreateTransaction(ctx, newTransaction)
+		transaction.ID)
+	if transaction.IsExchange() {
+		rate := &types.ExchangeRate{Base: transaction.Amount.Currency, Quote: transaction.DestinationAmount.Currency, Rate: transaction.ExchangeRate}
+		refund.DestinationAmount = amount
+		refund.ExchangeRate = rate.Inverse().Rate
+	}
+	return refund
+}
 
+// refundTransaction moves the refund money back, records it and adds it to the refunded total of the
+// original transaction. The original row is locked and checked again
+// so concurrent refunds can never add up to more than the original amount
+func (as *Account) refundTransaction(ctx context.Context, transactionId string, refund *types.Transaction) error {
+	transaction, err := as.transactionRepo.GetTransactionForUpdate(ctx, transactionId)
+	if err != nil {
+		return err
+	}
+
+	previousStatus := transaction.Status
+	if err := transaction.ApplyRefund(refund.DestinationAmount, refund.ID); err != nil {
+		if transaction.Status != types.TransactionCompleted {
+			return ErrTransactionNotRefundable
+		}
+		return err
+	}
+
+	err = as.transactionRepo.MakeTransferTransaction(ctx, refund.From, refund.To, refund.Amount, refund.DestinationAmount)
+
+	if err != nil {
+		return err
+	}
+
+	if err := as.completeTransaction(ctx, refund); err != nil {
+		return err
+	}
+	if err := as.transactionRepo.UpdateRefundedAmount(ctx, transaction); err != nil {
+		return err
+	}
+	if transaction.Status != previousStatus {
+		return as.transactionRepo.UpdateTransactionStatus(ctx, transaction)
+	}
 	return nil
 }
 
-func (as *Account) RefundMultibeneficiaryTransfer(ctx context.Context, multibeneficiaryId string) error {
-	transactions, err := as.transactionRepo.GetMultiBeneficiaryTransactions(ctx, multibeneficiaryId)
+// completeTransaction marks the transaction COMPLETED and stores it with its journal entry and the event
+// announcing it
+func (as *Account) completeTransaction(ctx context.Context, transaction *types.Transaction) error {
+	if err := transaction.TransitionTo(types.TransactionCompleted, ""); err != nil {
+		return err
+	}
+	if err := as.transactionRepo.CreateTransaction(ctx, transaction); err != nil {
+		return err
+	}
+	if err := as.ledgerRepo.CreateJournalEntry(ctx, types.NewTransactionJournalEntry(transaction)); err != nil {
+		return err
+	}
+	return as.publishTransaction(ctx, transaction)
+}
 
+// recordFailure persists a FAILED attempt once its unit of work was rolled back, so rejected
+// operations still show up in the history. It reports whether the attempt could be stored
+func (as *Account) recordFailure(ctx context.Context, transaction *types.Transaction, cause error) bool {
+	transaction.Abort(cause.Error())
+	err := as.uow.Do(ctx, func(ctx context.Context) error {
+		return as.transactionRepo.CreateTransaction(ctx, transaction)
+	})
 	if err != nil {
+		log.Printf("unable to record failed transaction %s: %v", transaction.ID, err)
+		return false
+	}
+	return true
+}
+
+// checkKYCTransferLimit refuses transfers larger than the KYC status of the sender's owner allows
+func (as *Account) checkKYCTransferLimit(ctx context.Context, accountId string, amount types.Money) error {
+	account, err := as.accountRepo.GetAccountById(ctx, accountId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrInexistentAccount
+		}
 		return err
 	}
 
-	for _, transaction := range transactions {
-		if err != nil {
-			return nil
+	status := types.KYCPending
+	owner, err := as.ownerRepo.GetOwner(ctx, account.Owner)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if err == nil {
+		status = owner.KYCStatus
+	}
+	currency := amount.Currency
+	if currency == "" {
+		currency = account.Currency
+	}
+	if limit := status.TransferLimit(currency); amount.GreaterThan(limit) {
+		return ErrKYCTransferLimitExceeded
+	}
+	return nil
+}
+
+// inAccountCurrency denominates an amount sent without currency in the currency of the account
+// and refuses amounts in any other currency. Unknown accounts are left for the caller to report
+func (as *Account) inAccountCurrency(ctx context.Context, accountId string, amount types.Money) (types.Money, error) {
+	account, err := as.accountRepo.GetAccountById(ctx, accountId)
+	if err != nil || account == nil || account.Currency == "" {
+		return amount, nil
+	}
+	if amount.Currency == "" {
+		amount.Currency = account.Currency
+	}
+	if amount.Currency != account.Currency {
+		return amount, ErrCurrencyMismatch
+	}
+	return amount, nil
+}
+
+// exchangeTo settles the transaction in currency at the stored rate, it does nothing when the
+// currency is unknown or already the one of the transaction
+func (as *Account) exchangeTo(ctx context.Context, transaction *types.Transaction, currency types.Currency) error {
+	if currency == "" || transaction.Amount.SameCurrency(types.NewMoney(0, currency)) {
+		return nil
+	}
+	rate, err := as.exchangeRate(ctx, transaction.Amount.Currency, currency)
+	if err != nil {
+		return err
+	}
+	transaction.Exchange(rate)
+	return nil
+}
+
+func (as *Account) exchangeRate(ctx context.Context, base types.Currency, quote types.Currency) (*types.ExchangeRate, error) {
+	rate, err := as.fxRepo.GetRate(ctx, base, quote)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrExchangeRateNotFound
 		}
+		return nil, err
+	}
+	return rate, nil
+}
 
-		if transaction.IsRefund {
-			return ErrUnableToRefundARefund
+// lockAccounts locks every distinct account once, in ascending id order, so concurrent
+// multi-leg operations always acquire their row locks in the same order
+func (as *Account) lockAccounts(ctx context.Context, accountIds ...string) ([]*types.Account, error) {
+	unique := make([]string, 0, len(accountIds))
+	seen := make(map[string]bool, len(accountIds))
+	for _, id := range accountIds {
+		if id == "" || seen[id] {
+			continue
 		}
-		err = as.transactionRepo.MakeTransferTransaction(ctx, transaction.To, transaction.From, transaction.Amount)
+		seen[id] = true
+		unique = append(unique, id)
+	}
+	sort.Strings(unique)
+	return as.accountRepo.LockAccounts(ctx, unique...)
+}
 
-		if err != nil {
+// lockOperableAccounts locks the accounts and refuses to go on when any of them is frozen or closed
+func (as *Account) lockOperableAccounts(ctx context.Context, accountIds ...string) error {
+	accounts, err := as.lockAccounts(ctx, accountIds...)
+	if err != nil {
+		return err
+	}
+	for _, account := range accounts {
+		if err := ensureOperable(account); err != nil {
 			return err
 		}
-
-		newTransaction := types.NewTransaction(
-			transaction.Amount,
-			transaction.From,
-			transaction.To,
-			transaction.Subject,
-			string(utils.REFUND), "",
-			true,
-			transaction.ID)
-		as.transactionRepo.CreateTransaction(ctx, newTransaction)
 	}
+	return nil
+}
 
+func ensureOperable(account *types.Account) error {
+	switch account.Status {
+	case types.AccountFrozen:
+		return ErrAccountFrozen
+	case types.AccountClosed:
+		return ErrAccountClosed
+	}
 	return nil
 }
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	requestparams "go-sample/api/handlers/request-params"
+	"go-sample/types"
+	"sort"
+)
+
+const defaultBulkTransferSubject = "Transferencia em lote"
+
+// errBatchAlreadyRan stops a run of a batch another run already took
+var errBatchAlreadyRan = errors.New("BatchAlreadyRan")
+
+// BulkTransfer checks every row of the upload and, when all of them are valid, stores the batch as PENDING
+// with an operation for a worker to run it through ExecuteBulkTransfer. Invalid rows are all reported together
+// in a *types.BulkTransferError. Amounts are in the currency of the account, the funds are checked when the batch runs
+func (as *Account) BulkTransfer(ctx context.Context, accountId string, request requestparams.BulkTransferRequest) (*types.TransferBatch, error) {
+	from, err := as.GetAccount(ctx, accountId)
+	if err != nil {
+		return nil, err
+	}
+	if from == nil {
+		return nil, ErrInexistentAccount
+	}
+	if err := ensureOperable(from); err != nil {
+		return nil, err
+	}
+
+	subject := request.Subject
+	if subject == "" {
+		subject = defaultBulkTransferSubject
+	}
+	batch := types.NewTransferBatch(accountId, subject, types.NewMoney(0, from.Currency))
+	invalid := append([]types.BulkRowError{}, request.Errors...)
+	for _, row := range request.Rows {
+		amount, reason := as.checkBulkRow(ctx, from, row)
+		if reason != "" {
+			invalid = append(invalid, types.BulkRowError{Row: row.Row, AccountId: row.AccountId, Error: reason})
+			continue
+		}
+		total, err := batch.Amount.CheckedAdd(amount)
+		if err != nil {
+			invalid = append(invalid, types.BulkRowError{Row: row.Row, AccountId: row.AccountId, Error: "InvalidAmount"})
+			continue
+		}
+		batch.AddLeg(row.AccountId, amount).Subject = row.Subject
+		batch.Amount = total
+	}
+	if len(invalid) > 0 {
+		sort.SliceStable(invalid, func(i, j int) bool { return invalid[i].Row < invalid[j].Row })
+		return nil, &types.BulkTransferError{Rows: invalid}
+	}
+	if err := as.checkKYCTransferLimit(ctx, accountId, batch.Amount); err != nil {
+		return nil, err
+	}
+
+	operation, err := types.NewOperation(types.OperationBulkTransfer, bulkTransferOperation{BatchId: batch.ID})
+	if err != nil {
+		return nil, err
+	}
+	err = as.uow.Do(ctx, func(ctx context.Context) error {
+		if err := as.transactionRepo.CreateTransferBatch(ctx, batch); err != nil {
+			return err
+		}
+		return as.operationRepo.CreateOperation(ctx, operation)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// checkBulkRow returns the amount of the row in the currency of from, or why the row can not be paid
+func (as *Account) checkBulkRow(ctx context.Context, from *types.Account, row requestparams.BulkTransferRow) (types.Money, string) {
+	if row.AccountId == "" {
+		return types.Money{}, "MissingAccountId"
+	}
+	if row.AccountId == from.ID {
+		return types.Money{}, "SelfTransfer"
+	}
+	amount, err := types.ParseMoney(row.Amount, from.Currency)
+	if err != nil || !amount.IsPositive() {
+		return types.Money{}, "InvalidAmount"
+	}
+
+	recipient, err := as.accountRepo.GetAccountById(ctx, row.AccountId)
+	if err == sql.ErrNoRows {
+		return types.Money{}, ErrInexistentAccount.Error()
+	}
+	if err != nil {
+		return types.Money{}, err.Error()
+	}
+	if err := ensureOperable(recipient); err != nil {
+		return types.Money{}, err.Error()
+	}
+	if recipient.Currency != "" && recipient.Currency != from.Currency {
+		if _, err := as.exchangeRate(ctx, from.Currency, recipient.Currency); err != nil {
+			return types.Money{}, err.Error()
+		}
+	}
+	return amount, ""
+}
+
+// ExecuteBulkTransfer runs a pending bulk transfer like a multi-beneficiary TransferMoney, all the rows
+// are paid or none is. Batches that already ran are left as they are: the batch is locked and checked
+// again in the unit of work moving the money, and marked completed in it
+func (as *Account) ExecuteBulkTransfer(ctx context.Context, batchId string) error {
+	return as.executeBulkTransfer(ctx, batchId, transferHooks{})
+}
+
+// executeBulkTransfer is ExecuteBulkTransfer with hooks, they run after the ones of the batch
+func (as *Account) executeBulkTransfer(ctx context.Context, batchId string, hooks transferHooks) error {
+	batch, err := as.GetTransferBatch(ctx, batchId)
+	if err != nil {
+		return err
+	}
+	if batch.Status != types.BatchPending {
+		return nil
+	}
+
+	currencies := map[string]types.Currency{}
+	for _, leg := range batch.Legs {
+		account, err := as.accountRepo.GetAccountById(ctx, leg.AccountId)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		currencies[leg.AccountId] = account.Currency
+	}
+
+	// claim keeps a batch another run took first from running again
+	claim := func(ctx context.Context) error {
+		locked, err := as.transactionRepo.GetTransferBatchForUpdate(ctx, batchId)
+		if err != nil {
+			return err
+		}
+		if locked.Status != types.BatchPending {
+			return errBatchAlreadyRan
+		}
+		if hooks.claim == nil {
+			return nil
+		}
+		return hooks.claim(ctx)
+	}
+
+	transactions, fees, err := as.prepareTransfer(ctx, batch, currencies, true)
+	if err != nil {
+		batch.Abort(err)
+		err = as.uow.Do(ctx, func(ctx context.Context) error {
+			if err := claim(ctx); err != nil {
+				return err
+			}
+			return as.transactionRepo.UpdateTransferBatch(ctx, batch)
+		})
+	} else {
+		err = as.commitTransfer(ctx, batch, transactions, fees, true, transferHooks{
+			claim: claim,
+			settle: func(ctx context.Context, batch *types.TransferBatch) error {
+				if err := as.transactionRepo.UpdateTransferBatch(ctx, batch); err != nil {
+					return err
+				}
+				if hooks.settle == nil {
+					return nil
+				}
+				return hooks.settle(ctx, batch)
+			},
+		})
+	}
+	if err == errBatchAlreadyRan {
+		return nil
+	}
+	return err
+}
@@ -1,8 +1,53 @@
 package services
 
-import "errors"
+import (
+	"errors"
+	"go-sample/storage"
+	"go-sample/types"
+)
 
-var ErrInexistentAccount = errors.New("InexistentAccount")
+var ErrInexistentAccount = storage.ErrInexistentAccount
 var ErrUnableToRefundARefund = errors.New("UnableToRefundARefundItself")
-var ErrInsufficientFunds = errors.New("InsufficientFunds")
+var ErrInsufficientFunds = storage.ErrInsufficientFunds
 var ErrInexistentTransaction = errors.New("InexistentTransaction")
+var ErrInexistentTransferBatch = errors.New("InexistentTransferBatch")
+var ErrTransactionNotRefundable = errors.New("TransactionNotRefundable")
+var ErrRefundExceedsRefundableAmount = types.ErrRefundExceedsRefundableAmount
+var ErrAccountFrozen = errors.New("AccountFrozen")
+var ErrAccountClosed = errors.New("AccountClosed")
+var ErrAccountHasBalance = errors.New("AccountHasBalance")
+var ErrInvalidSweepAccount = errors.New("InvalidSweepAccount")
+var ErrInvalidAccountStatusTransition = types.ErrInvalidAccountStatusTransition
+var ErrAccountNotOwned = errors.New("AccountNotOwned")
+var ErrInexistentOwner = errors.New("InexistentOwner")
+var ErrOwnerNotVerified = errors.New("OwnerNotVerified")
+var ErrInvalidOwner = errors.New("InvalidOwner")
+var ErrDuplicateNationalId = errors.New("DuplicateNationalId")
+var ErrOwnerHasOpenAccounts = errors.New("OwnerHasOpenAccounts")
+var ErrInvalidKYCStatusTransition = types.ErrInvalidKYCStatusTransition
+var ErrKYCTransferLimitExceeded = errors.New("KYCTransferLimitExceeded")
+var ErrCurrencyMismatch = errors.New("CurrencyMismatch")
+var ErrExchangeRateNotFound = errors.New("ExchangeRateNotFound")
+var ErrInvalidExchangeRate = types.ErrInvalidExchangeRate
+var ErrInvalidCreditLimit = types.ErrInvalidCreditLimit
+var ErrCreditLimitInUse = types.ErrCreditLimitInUse
+var ErrInexistentHold = errors.New("InexistentHold")
+var ErrInvalidHoldRecipient = errors.New("InvalidHoldRecipient")
+var ErrAccountHasActiveHolds = errors.New("AccountHasActiveHolds")
+var ErrHoldNotActive = types.ErrHoldNotActive
+var ErrHoldExpired = types.ErrHoldExpired
+var ErrCaptureExceedsHold = types.ErrCaptureExceedsHold
+var ErrInvalidFeeSchedule = types.ErrInvalidFeeSchedule
+var ErrLimitExceeded = types.ErrLimitExceeded
+var ErrInvalidLimit = types.ErrInvalidLimit
+var ErrInexistentScheduledTransfer = errors.New("InexistentScheduledTransfer")
+var ErrScheduledTransferNotPending = types.ErrScheduledTransferNotPending
+var ErrInexistentStandingOrder = errors.New("InexistentStandingOrder")
+var ErrInvalidRecurrence = types.ErrInvalidRecurrence
+var ErrStandingOrderClosed = types.ErrStandingOrderClosed
+var ErrStandingOrderNotSuspended = types.ErrStandingOrderNotSuspended
+var ErrInvalidBulkTransfer = types.ErrInvalidBulkTransfer
+var ErrInexistentOperation = errors.New("InexistentOperation")
+var ErrInexistentWebhookEndpoint = errors.New("InexistentWebhookEndpoint")
+var ErrInexistentWebhookDelivery = errors.New("InexistentWebhookDelivery")
+var ErrDeliveryNotDead = types.ErrDeliveryNotDead
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	requestparams "go-sample/api/handlers/request-params"
+	"go-sample/api/utils"
+	"go-sample/storage"
+	accountrepo "go-sample/storage/account-repo"
+	feerepo "go-sample/storage/fee-repo"
+	"go-sample/types"
+	"strings"
+)
+
+type Fee struct {
+	feeRepo     feerepo.IFeeRepo
+	accountRepo accountrepo.IAccountRepo
+	uow         storage.UnitOfWork
+}
+
+func NewFee(feeRepo feerepo.IFeeRepo, accountRepo accountrepo.IAccountRepo, uow storage.UnitOfWork) Fee {
+	return Fee{
+		feeRepo:     feeRepo,
+		accountRepo: accountRepo,
+		uow:         uow,
+	}
+}
+
+// LoadSchedules replaces the schedules of the given operation, account type and currency,
+// either every schedule is stored or none is
+func (fs *Fee) LoadSchedules(ctx context.Context, request requestparams.LoadFeeSchedulesRequest) ([]*types.FeeSchedule, error) {
+	schedules := make([]*types.FeeSchedule, len(request.Schedules))
+	for i, s := range request.Schedules {
+		schedule, err := newFeeSchedule(s)
+		if err != nil {
+			return nil, err
+		}
+		schedules[i] = schedule
+	}
+
+	err := fs.uow.Do(ctx, func(ctx context.Context) error {
+		for _, schedule := range schedules {
+			if err := fs.feeRepo.SaveSchedule(ctx, schedule); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+func (fs *Fee) ListSchedules(ctx context.Context) ([]*types.FeeSchedule, error) {
+	return fs.feeRepo.ListSchedules(ctx)
+}
+
+// Quote previews the fee of the operation on the account without executing it. An amount
+// without currency is taken in the account currency
+func (fs *Fee) Quote(ctx context.Context, request requestparams.FeeQuoteRequest) (*types.FeeQuote, error) {
+	account, err := fs.accountRepo.GetAccountById(ctx, request.AccountId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInexistentAccount
+		}
+		return nil, err
+	}
+
+	amount := request.AmountOf
+	if amount.Currency == "" {
+		amount.Currency = account.Currency
+	}
+	if amount.Currency != account.Currency {
+		return nil, ErrCurrencyMismatch
+	}
+
+	fee, err := feeFor(ctx, fs.feeRepo, account, utils.OP(request.Operation), amount)
+	if err != nil {
+		return nil, err
+	}
+	change, err := amount.CheckedAdd(fee)
+	if err != nil {
+		return nil, err
+	}
+	change = change.Neg()
+	if utils.OP(request.Operation) == utils.DEPOSIT {
+		change = amount.Sub(fee)
+	}
+	return &types.FeeQuote{
+		Operation:     request.Operation,
+		AccountId:     account.ID,
+		Amount:        amount,
+		Fee:           fee,
+		BalanceChange: change,
+	}, nil
+}
+
+func newFeeSchedule(s requestparams.FeeSchedule) (*types.FeeSchedule, error) {
+	currency := types.Currency(strings.ToUpper(string(s.Currency)))
+	amount, err := parseFeeAmount(s.Amount, currency)
+	if err != nil {
+		return nil, err
+	}
+	tiers := make([]types.FeeTier, len(s.Tiers))
+	for i, t := range s.Tiers {
+		if tiers[i].UpTo, err = parseFeeAmount(t.UpTo, currency); err != nil {
+			return nil, err
+		}
+		if tiers[i].Amount, err = parseFeeAmount(t.Amount, currency); err != nil {
+			return nil, err
+		}
+		tiers[i].Percentage = t.Percentage.String()
+	}
+	return types.NewFeeSchedule(s.Operation, s.AccountType, currency, s.Kind, amount, s.Percentage.String(), tiers)
+}
+
+// parseFeeAmount reads an optional decimal amount, left out it is zero
+func parseFeeAmount(amount json.Number, currency types.Currency) (types.Money, error) {
+	if amount.String() == "" {
+		return types.NewMoney(0, currency), nil
+	}
+	parsed, err := types.ParseMoney(amount.String(), currency)
+	if err != nil {
+		return types.Money{}, ErrInvalidFeeSchedule
+	}
+	return parsed, nil
+}
+
+// feeFor is the fee the account pays for operation on amount. The schedule of the account type
+// wins over the one set for every type, without either the operation is free
+func feeFor(ctx context.Context, feeRepo feerepo.IFeeRepo, account *types.Account, operation utils.OP, amount types.Money) (types.Money, error) {
+	for _, accountType := range []types.AccountType{account.Type, ""} {
+		schedule, err := feeRepo.GetSchedule(ctx, string(operation), accountType, amount.Currency)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return types.Money{}, err
+		}
+		return schedule.Compute(amount), nil
+	}
+	return types.NewMoney(0, amount.Currency), nil
+}
+
+// quoteFee is the fee for operation on the account, unknown accounts are left for the caller to report
+func (as *Account) quoteFee(ctx context.Context, accountId string, operation utils.OP, amount types.Money) (types.Money, error) {
+	account, err := as.accountRepo.GetAccountById(ctx, accountId)
+	if err == sql.ErrNoRows {
+		return types.NewMoney(0, amount.Currency), nil
+	}
+	if err != nil {
+		return types.Money{}, err
+	}
+	return feeFor(ctx, as.feeRepo, account, operation, amount)
+}
+
+// chargeFee debits the fee from the account into the fees system account as a FEE transaction linked
+// to transaction, together with transaction or not at all
+func (as *Account) chargeFee(ctx context.Context, accountId string, transaction *types.Transaction, fee types.Money) error {
+	if !fee.IsPositive() {
+		return nil
+	}
+	if err := as.accountRepo.DecrBalance(ctx, accountId, fee); err != nil {
+		return err
+	}
+	charge := types.NewTransaction(fee, accountId, types.SystemFeesAccount, "Taxa: "+transaction.Subject, string(utils.FEE), "", false, "")
+	charge.LinkedTransactionId = transaction.ID
+	if err := as.completeTransaction(ctx, charge); err != nil {
+		return err
+	}
+	transaction.Fee = charge
+	return nil
+}
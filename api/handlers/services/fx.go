@@ -0,0 +1,51 @@
+package services
+
+import (
+	"context"
+	requestparams "go-sample/api/handlers/request-params"
+	"go-sample/storage"
+	fxrepo "go-sample/storage/fx-repo"
+	"go-sample/types"
+)
+
+type FX struct {
+	fxRepo fxrepo.IFXRepo
+	uow    storage.UnitOfWork
+}
+
+func NewFX(fxRepo fxrepo.IFXRepo, uow storage.UnitOfWork) FX {
+	return FX{
+		fxRepo: fxRepo,
+		uow:    uow,
+	}
+}
+
+// LoadRates replaces the rates of the given pairs, either every rate is stored or none is.
+// Rates only apply in the direction they were loaded for, the opposite pair needs its own rate
+func (fs *FX) LoadRates(ctx context.Context, request requestparams.LoadFXRatesRequest) ([]*types.ExchangeRate, error) {
+	rates := make([]*types.ExchangeRate, len(request.Rates))
+	for i, r := range request.Rates {
+		rate, err := types.NewExchangeRate(r.Base, r.Quote, r.Rate.String())
+		if err != nil {
+			return nil, err
+		}
+		rates[i] = rate
+	}
+
+	err := fs.uow.Do(ctx, func(ctx context.Context) error {
+		for _, rate := range rates {
+			if err := fs.fxRepo.SaveRate(ctx, rate); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
+
+func (fs *FX) ListRates(ctx context.Context) ([]*types.ExchangeRate, error) {
+	return fs.fxRepo.ListRates(ctx)
+}
@@ -0,0 +1,229 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	requestparams "go-sample/api/handlers/request-params"
+	"go-sample/api/utils"
+	"go-sample/types"
+	"time"
+)
+
+// expiredHoldsBatch is how many expired holds ExpireHolds loads at a time
+const expiredHoldsBatch = 100
+
+// AuthorizeHold reserves the amount on the account for a later transfer to params.To. Nothing moves
+// until the hold is captured, but the amount is no longer part of the available balance
+func (as *Account) AuthorizeHold(ctx context.Context, accountId string, params requestparams.AuthorizeHoldRequest) (*types.Hold, error) {
+	if params.To == accountId {
+		return nil, ErrInvalidHoldRecipient
+	}
+	amount, err := as.inAccountCurrency(ctx, accountId, params.Amount)
+	if err != nil {
+		return nil, err
+	}
+	if err := as.checkKYCTransferLimit(ctx, accountId, amount); err != nil {
+		return nil, err
+	}
+	recipient, err := as.GetAccount(ctx, params.To)
+	if err != nil {
+		return nil, err
+	}
+	if recipient == nil {
+		return nil, ErrInexistentAccount
+	}
+
+	hold := types.NewHold(accountId, params.To, params.Subject, amount, params.Duration())
+	err = as.uow.Do(ctx, func(ctx context.Context) error {
+		locked, err := as.lockAccounts(ctx, accountId)
+		if err != nil {
+			return err
+		}
+		if len(locked) == 0 {
+			return ErrInexistentAccount
+		}
+		if err := ensureOperable(locked[0]); err != nil {
+			return err
+		}
+		if err := as.accountRepo.HoldFunds(ctx, accountId, amount); err != nil {
+			return err
+		}
+		return as.holdRepo.CreateHold(ctx, hold)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
+
+func (as *Account) GetHold(ctx context.Context, holdId string) (*types.Hold, error) {
+	hold, err := as.holdRepo.GetHold(ctx, holdId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInexistentHold
+		}
+		return nil, err
+	}
+	return hold, nil
+}
+
+func (as *Account) ListHolds(ctx context.Context, accountId string, filters requestparams.ListHoldsRequest) (*types.Page[*types.Hold], error) {
+	account, err := as.GetAccount(ctx, accountId)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil {
+		return nil, ErrInexistentAccount
+	}
+	return as.holdRepo.ListHolds(ctx, accountId, filters)
+}
+
+// CaptureHold transfers amount of the hold to its recipient, or the whole hold when amount is nil.
+// The full hold is released in the same unit of work, so a partial capture gives the rest back,
+// and the transfer fee is charged in it like for any other transfer
+func (as *Account) CaptureHold(ctx context.Context, holdId string, amount *types.Money) (*types.Hold, error) {
+	hold, err := as.GetHold(ctx, holdId)
+	if err != nil {
+		return nil, err
+	}
+	recipient, err := as.GetAccount(ctx, hold.To)
+	if err != nil {
+		return nil, err
+	}
+	if recipient == nil {
+		return nil, ErrInexistentAccount
+	}
+
+	captured := hold.Amount
+	if amount != nil {
+		captured = *amount
+		if captured.Currency == "" {
+			captured.Currency = hold.Amount.Currency
+		}
+	}
+	if !captured.SameCurrency(hold.Amount) {
+		return nil, ErrCurrencyMismatch
+	}
+
+	transaction := types.NewTransaction(captured, hold.AccountId, hold.To, hold.Subject, string(utils.TRANSFER), "", false, "")
+	if err := as.exchangeTo(ctx, transaction, recipient.Currency); err != nil {
+		return nil, err
+	}
+	fee, err := as.quoteFee(ctx, hold.AccountId, utils.TRANSFER, captured)
+	if err != nil {
+		return nil, err
+	}
+
+	err = as.uow.Do(ctx, func(ctx context.Context) error {
+		locked, err := as.holdRepo.GetHoldForUpdate(ctx, holdId)
+		if err != nil {
+			return err
+		}
+		if err := as.lockOperableAccounts(ctx, locked.AccountId, locked.To); err != nil {
+			return err
+		}
+
+		updated := *locked
+		hold = &updated
+		if err := hold.Capture(captured, transaction.ID); err != nil {
+			return err
+		}
+		if err := as.checkLimit(ctx, hold.AccountId, utils.TRANSFER, captured); err != nil {
+			return err
+		}
+		if err := as.accountRepo.ReleaseFunds(ctx, hold.AccountId, hold.Amount); err != nil {
+			return err
+		}
+		err = as.transactionRepo.MakeTransferTransaction(ctx, hold.AccountId, hold.To, transaction.Amount, transaction.DestinationAmount)
+		if err != nil {
+			return err
+		}
+		if err := as.completeTransaction(ctx, transaction); err != nil {
+			return err
+		}
+		if err := as.chargeFee(ctx, hold.AccountId, transaction, fee); err != nil {
+			return err
+		}
+		return as.holdRepo.UpdateHold(ctx, hold)
+	})
+	if err != nil {
+		if !isHoldStateError(err) {
+			as.recordFailure(ctx, transaction, err)
+		}
+		return nil, err
+	}
+	return hold, nil
+}
+
+// VoidHold cancels an active hold and gives the amount back to the available balance
+func (as *Account) VoidHold(ctx context.Context, holdId string) (*types.Hold, error) {
+	var hold *types.Hold
+	err := as.uow.Do(ctx, func(ctx context.Context) error {
+		var err error
+		hold, err = as.releaseHold(ctx, holdId, func(h *types.Hold) error { return h.Void() })
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
+
+// ExpireHolds releases every active hold past its expiry date and returns how many it expired.
+// Holds captured or voided while it runs are skipped
+func (as *Account) ExpireHolds(ctx context.Context) (int, error) {
+	now := time.Now()
+	expired := 0
+	for {
+		holds, err := as.holdRepo.ListExpiredHolds(ctx, now, expiredHoldsBatch)
+		if err != nil {
+			return expired, err
+		}
+		for _, hold := range holds {
+			err := as.uow.Do(ctx, func(ctx context.Context) error {
+				_, err := as.releaseHold(ctx, hold.ID, func(h *types.Hold) error { return h.Expire(now) })
+				return err
+			})
+			if err != nil && err != ErrHoldNotActive {
+				return expired, err
+			}
+			if err == nil {
+				expired++
+			}
+		}
+		if len(holds) < expiredHoldsBatch {
+			return expired, nil
+		}
+	}
+}
+
+// releaseHold locks the hold, closes it with close and frees its amount
+func (as *Account) releaseHold(ctx context.Context, holdId string, close func(*types.Hold) error) (*types.Hold, error) {
+	locked, err := as.holdRepo.GetHoldForUpdate(ctx, holdId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInexistentHold
+		}
+		return nil, err
+	}
+	if _, err := as.lockAccounts(ctx, locked.AccountId); err != nil {
+		return nil, err
+	}
+
+	hold := *locked
+	if err := close(&hold); err != nil {
+		return nil, err
+	}
+	if err := as.accountRepo.ReleaseFunds(ctx, hold.AccountId, hold.Amount); err != nil {
+		return nil, err
+	}
+	if err := as.holdRepo.UpdateHold(ctx, &hold); err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+func isHoldStateError(err error) bool {
+	return errors.Is(err, ErrHoldNotActive) || errors.Is(err, ErrHoldExpired) || errors.Is(err, ErrCaptureExceedsHold)
+}
@@ -0,0 +1,29 @@
+package services
+
+import (
+	"context"
+	ledgerrepo "go-sample/storage/ledger-repo"
+	"go-sample/types"
+)
+
+type Ledger struct {
+	ledgerRepo ledgerrepo.ILedgerRepo
+}
+
+func NewLedger(ledgerRepo ledgerrepo.ILedgerRepo) Ledger {
+	return Ledger{
+		ledgerRepo: ledgerRepo,
+	}
+}
+
+func (ls *Ledger) GetTrialBalance(ctx context.Context) (*types.TrialBalance, error) {
+	lines, err := ls.ledgerRepo.GetTrialBalance(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return types.NewTrialBalance(lines), nil
+}
+
+func (ls *Ledger) GetPostedBalance(ctx context.Context, accountId string) (types.Money, error) {
+	return ls.ledgerRepo.GetPostedBalance(ctx, accountId)
+}
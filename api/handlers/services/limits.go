@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	requestparams "go-sample/api/handlers/request-params"
+	"go-sample/api/utils"
+	"go-sample/types"
+	"time"
+)
+
+// SetLimit replaces the limits of an operation on the account, they apply from the next operation on
+func (as *Account) SetLimit(ctx context.Context, accountId string, request requestparams.SetLimitRequest) (*types.Limit, error) {
+	account, err := as.GetAccount(ctx, accountId)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil {
+		return nil, ErrInexistentAccount
+	}
+
+	limit, err := types.NewLimit(accountId, request.Operation, account.Currency,
+		request.PerTransaction, request.DailyAmount, request.MonthlyAmount, request.DailyCount)
+	if err != nil {
+		return nil, err
+	}
+	if err := as.limitRepo.SaveLimit(ctx, limit); err != nil {
+		return nil, err
+	}
+	return limit, nil
+}
+
+// GetLimits reports every limit set on the account with what was already used today and this month
+func (as *Account) GetLimits(ctx context.Context, accountId string) ([]*types.LimitStatus, error) {
+	account, err := as.GetAccount(ctx, accountId)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil {
+		return nil, ErrInexistentAccount
+	}
+
+	limits, err := as.limitRepo.ListLimits(ctx, accountId)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]*types.LimitStatus, len(limits))
+	for i, limit := range limits {
+		usage, err := as.limitUsage(ctx, accountId, limit.Operation, account.Currency)
+		if err != nil {
+			return nil, err
+		}
+		statuses[i] = &types.LimitStatus{Limit: limit, Usage: usage, Remaining: limit.Remaining(usage)}
+	}
+	return statuses, nil
+}
+
+// checkLimit refuses to send amounts, one transaction each, when they go past a limit of the operation.
+// Call it once the account is locked, so concurrent operations see each other's usage
+func (as *Account) checkLimit(ctx context.Context, accountId string, operation utils.OP, amounts ...types.Money) error {
+	limit, err := as.limitRepo.GetLimit(ctx, accountId, string(operation))
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	usage, err := as.limitUsage(ctx, accountId, limit.Operation, amounts[0].Currency)
+	if err != nil {
+		return err
+	}
+	return limit.Check(usage, amounts...)
+}
+
+func (as *Account) limitUsage(ctx context.Context, accountId string, operation string, currency types.Currency) (types.LimitUsage, error) {
+	now := time.Now()
+	daily, count, err := as.transactionRepo.GetUsage(ctx, accountId, operation, types.StartOfDay(now))
+	if err != nil {
+		return types.LimitUsage{}, err
+	}
+	monthly, _, err := as.transactionRepo.GetUsage(ctx, accountId, operation, types.StartOfMonth(now))
+	if err != nil {
+		return types.LimitUsage{}, err
+	}
+	return types.LimitUsage{
+		DailyAmount:   types.NewMoney(daily.Amount, currency),
+		MonthlyAmount: types.NewMoney(monthly.Amount, currency),
+		DailyCount:    count,
+	}, nil
+}
@@ -0,0 +1,254 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	requestparams "go-sample/api/handlers/request-params"
+	"go-sample/types"
+	"log"
+	"sync"
+	"time"
+)
+
+// bulkTransferOperation is the request of a BULK_TRANSFER operation, the rows are on the pending batch
+type bulkTransferOperation struct {
+	BatchId string `json:"batch_id"`
+}
+
+// SubmitTransfer stores the transfer as an operation for a worker to run with TransferMoney.
+// Only the sender is checked here, anything else makes the operation fail
+func (as *Account) SubmitTransfer(ctx context.Context, request requestparams.TransferMoneyRequest) (*types.Operation, error) {
+	account, err := as.GetAccount(ctx, request.From)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil {
+		return nil, ErrInexistentAccount
+	}
+	return as.submitOperation(ctx, types.OperationTransfer, request)
+}
+
+// SubmitRefund stores the refund as an operation for a worker to run, like the synchronous refund would
+func (as *Account) SubmitRefund(ctx context.Context, request requestparams.RefundMoneyRequest) (*types.Operation, error) {
+	return as.submitOperation(ctx, types.OperationRefund, request)
+}
+
+func (as *Account) submitOperation(ctx context.Context, kind types.OperationKind, request interface{}) (*types.Operation, error) {
+	operation, err := types.NewOperation(kind, request)
+	if err != nil {
+		return nil, err
+	}
+	if err := as.operationRepo.CreateOperation(ctx, operation); err != nil {
+		return nil, err
+	}
+	return operation, nil
+}
+
+func (as *Account) GetOperation(ctx context.Context, operationId string) (*types.Operation, error) {
+	operation, err := as.operationRepo.GetOperation(ctx, operationId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInexistentOperation
+		}
+		return nil, err
+	}
+	return operation, nil
+}
+
+// NextOperations returns the ids of up to limit operations waiting for a worker, the oldest first.
+// Operations left running by a server that stopped are put back on the way and run again
+func (as *Account) NextOperations(ctx context.Context, limit int) ([]string, error) {
+	now := time.Now()
+	operations, err := as.operationRepo.ListRunnableOperations(ctx, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	ids := []string{}
+	for _, operation := range operations {
+		if operation.Status == types.OperationPending {
+			ids = append(ids, operation.ID)
+			continue
+		}
+		err := as.uow.Do(ctx, func(ctx context.Context) error {
+			_, err := as.updateOperation(ctx, operation.ID, func(o *types.Operation) error { return o.Interrupt(now) })
+			return err
+		})
+		if err == types.ErrOperationNotPending {
+			continue
+		}
+		if err != nil {
+			return ids, err
+		}
+		log.Printf("operation %s was interrupted, it runs again", operation.ID)
+		ids = append(ids, operation.ID)
+	}
+	return ids, nil
+}
+
+// RunOperation claims the operation and runs it. An operation moving money records its outcome in the unit
+// of work moving it, and only while the worker still holds its lease, so an operation is either finished with
+// the money it moved or moved nothing and can run again. Failures are recorded in a unit of work of their own.
+// The lease is renewed while the operation runs. Operations already claimed by another worker are skipped
+func (as *Account) RunOperation(ctx context.Context, operationId string) error {
+	var operation *types.Operation
+	err := as.uow.Do(ctx, func(ctx context.Context) error {
+		var err error
+		operation, err = as.updateOperation(ctx, operationId, func(o *types.Operation) error { return o.Start(time.Now()) })
+		return err
+	})
+	if err == types.ErrOperationNotPending {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	lease := as.renewLease(ctx, operationId, *operation.LockedUntil)
+	settled := false
+	result, runErr := as.runOperation(ctx, operation, transferHooks{
+		claim: func(ctx context.Context) error {
+			locked, err := as.operationRepo.GetOperationForUpdate(ctx, operationId)
+			if err != nil {
+				return err
+			}
+			if !locked.HoldsLease(lease.held()) {
+				return types.ErrOperationLeaseLost
+			}
+			return nil
+		},
+		settle: func(ctx context.Context, batch *types.TransferBatch) error {
+			var answer interface{}
+			if batch != nil {
+				answer = batch
+			}
+			_, err := as.updateOperation(ctx, operationId, func(o *types.Operation) error { return o.Complete(lease.held(), answer, nil) })
+			settled = err == nil
+			return err
+		},
+	})
+	held := lease.stop()
+	if runErr == nil && settled {
+		return nil
+	}
+	if runErr == types.ErrOperationLeaseLost {
+		return runErr
+	}
+	if runErr != nil {
+		log.Printf("operation %s failed: %v", operation.ID, runErr)
+	}
+	return as.uow.Do(ctx, func(ctx context.Context) error {
+		_, err := as.updateOperation(ctx, operationId, func(o *types.Operation) error { return o.Complete(held, result, runErr) })
+		return err
+	})
+}
+
+// operationLease is the lease a worker holds on the operation it runs, renewed in the background
+type operationLease struct {
+	mu      sync.Mutex
+	current time.Time
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// held is the lease the worker holds now
+func (l *operationLease) held() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.current
+}
+
+// stop ends the renewals and returns the lease held by then
+func (l *operationLease) stop() time.Time {
+	close(l.done)
+	<-l.stopped
+	return l.held()
+}
+
+// renewLease renews the lease of the operation every types.OperationLeaseRenewal until it is stopped
+func (as *Account) renewLease(ctx context.Context, operationId string, lease time.Time) *operationLease {
+	l := &operationLease{current: lease, done: make(chan struct{}), stopped: make(chan struct{})}
+	go func() {
+		defer close(l.stopped)
+		ticker := time.NewTicker(types.OperationLeaseRenewal)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-l.done:
+				return
+			case now := <-ticker.C:
+				var renewed *types.Operation
+				err := as.uow.Do(ctx, func(ctx context.Context) error {
+					var err error
+					renewed, err = as.updateOperation(ctx, operationId, func(o *types.Operation) error { return o.Renew(l.held(), now) })
+					return err
+				})
+				if err != nil {
+					log.Printf("operation %s: unable to renew its lease: %v", operationId, err)
+					continue
+				}
+				l.mu.Lock()
+				l.current = *renewed.LockedUntil
+				l.mu.Unlock()
+			}
+		}
+	}()
+	return l
+}
+
+// runOperation runs the request of the operation with hooks and returns what the synchronous endpoint answers with
+func (as *Account) runOperation(ctx context.Context, operation *types.Operation, hooks transferHooks) (interface{}, error) {
+	switch operation.Kind {
+	case types.OperationTransfer:
+		var request requestparams.TransferMoneyRequest
+		if err := json.Unmarshal(operation.Request, &request); err != nil {
+			return nil, err
+		}
+		batch, err := as.transferMoney(ctx, request, hooks)
+		if batch == nil {
+			return nil, err
+		}
+		return batch, err
+	case types.OperationRefund:
+		var request requestparams.RefundMoneyRequest
+		if err := json.Unmarshal(operation.Request, &request); err != nil {
+			return nil, err
+		}
+		if request.IsMultibenificiary {
+			return nil, as.refundMultibeneficiaryTransfer(ctx, request.MultiBeneficiaryId, hooks)
+		}
+		return nil, as.refundMoneyNormalTransfer(ctx, request.TransactionId, request.Amount, hooks)
+	case types.OperationBulkTransfer:
+		var request bulkTransferOperation
+		if err := json.Unmarshal(operation.Request, &request); err != nil {
+			return nil, err
+		}
+		err := as.executeBulkTransfer(ctx, request.BatchId, hooks)
+		batch, getErr := as.GetTransferBatch(ctx, request.BatchId)
+		if getErr != nil {
+			return nil, err
+		}
+		return batch, err
+	}
+	return nil, fmt.Errorf("unknown operation kind %s", operation.Kind)
+}
+
+// updateOperation locks the operation, applies change and stores it
+func (as *Account) updateOperation(ctx context.Context, operationId string, change func(*types.Operation) error) (*types.Operation, error) {
+	locked, err := as.operationRepo.GetOperationForUpdate(ctx, operationId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInexistentOperation
+		}
+		return nil, err
+	}
+	operation := *locked
+	if err := change(&operation); err != nil {
+		return nil, err
+	}
+	if err := as.operationRepo.UpdateOperation(ctx, &operation); err != nil {
+		return nil, err
+	}
+	return &operation, nil
+}
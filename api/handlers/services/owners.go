@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	requestparams "go-sample/api/handlers/request-params"
+	accountrepo "go-sample/storage/account-repo"
+	ownerrepo "go-sample/storage/owner-repo"
+	"go-sample/types"
+	"time"
+)
+
+type Owner struct {
+	ownerRepo   ownerrepo.IOwnerRepo
+	accountRepo accountrepo.IAccountRepo
+}
+
+func NewOwner(ownerRepo ownerrepo.IOwnerRepo, accountRepo accountrepo.IAccountRepo) Owner {
+	return Owner{
+		ownerRepo:   ownerRepo,
+		accountRepo: accountRepo,
+	}
+}
+
+func (ows *Owner) CreateOwner(ctx context.Context, owner *types.Owner) error {
+	err := ows.ownerRepo.CreateOwner(ctx, owner)
+	if errors.Is(err, ownerrepo.ErrDuplicateNationalId) {
+		return ErrDuplicateNationalId
+	}
+	return err
+}
+
+func (ows *Owner) GetOwner(ctx context.Context, ownerId string) (*types.Owner, error) {
+	owner, err := ows.ownerRepo.GetOwner(ctx, ownerId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInexistentOwner
+		}
+		return nil, err
+	}
+	return owner, nil
+}
+
+func (ows *Owner) ListOwners(ctx context.Context, filters requestparams.ListOwnersRequest) (*types.Page[*types.Owner], error) {
+	return ows.ownerRepo.ListOwners(ctx, filters)
+}
+
+func (ows *Owner) UpdateOwner(ctx context.Context, ownerId string, changes requestparams.UpdateOwnerRequest) (*types.Owner, error) {
+	owner, err := ows.GetOwner(ctx, ownerId)
+	if err != nil {
+		return nil, err
+	}
+	changes.Apply(owner)
+	if !owner.ValidateOwner() {
+		return nil, ErrInvalidOwner
+	}
+	owner.UpdatedAt = time.Now()
+	if err := ows.ownerRepo.UpdateOwner(ctx, owner); err != nil {
+		return nil, err
+	}
+	return owner, nil
+}
+
+func (ows *Owner) SetKYCStatus(ctx context.Context, ownerId string, status types.KYCStatus) (*types.Owner, error) {
+	owner, err := ows.GetOwner(ctx, ownerId)
+	if err != nil {
+		return nil, err
+	}
+	if err := owner.SetKYCStatus(status); err != nil {
+		return nil, err
+	}
+	if err := ows.ownerRepo.UpdateOwner(ctx, owner); err != nil {
+		return nil, err
+	}
+	return owner, nil
+}
+
+// DeleteOwner soft deletes an owner whose accounts are all closed
+func (ows *Owner) DeleteOwner(ctx context.Context, ownerId string) error {
+	owner, err := ows.GetOwner(ctx, ownerId)
+	if err != nil {
+		return err
+	}
+	accounts, err := ows.accountRepo.GetAccountsByOwnerId(ctx, ownerId)
+	if err != nil {
+		return err
+	}
+	for _, account := range accounts {
+		if account.Status != types.AccountClosed {
+			return ErrOwnerHasOpenAccounts
+		}
+	}
+	now := time.Now()
+	owner.DeletedAt = &now
+	return ows.ownerRepo.DeleteOwner(ctx, owner)
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"context"
+	"errors"
+	requestparams "go-sample/api/handlers/request-params"
+	accountrepo "go-sample/storage/account-repo"
+	ownerrepo "go-sample/storage/owner-repo"
+	"go-sample/types"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOwnerSrv(t *testing.T) {
+
+	ownerRepo := ownerrepo.NewMemoOwnerRepo()
+	accountRepo := accountrepo.NewMockMemoAccountRepo()
+	ownerSrv := NewOwner(ownerRepo, accountRepo)
+
+	t.Run("owners.CreateOwner should refuse a national id that is already taken", func(t *testing.T) {
+		ctx := context.Background()
+		assert.Nil(t, ownerSrv.CreateOwner(ctx, types.NewOwner("Some Dumb Owner", "123456789LA012", "", "", "")))
+
+		err := ownerSrv.CreateOwner(ctx, types.NewOwner("Another Dumb Owner", "123456789la012", "", "", ""))
+		assert.True(t, errors.Is(err, ErrDuplicateNationalId))
+	})
+	t.Run("owners.SetKYCStatus should only allow the KYC lifecycle transitions", func(t *testing.T) {
+		ctx := context.Background()
+		owner := types.NewOwner("Some Dumb Owner", "223456789LA012", "", "", "")
+		ownerSrv.CreateOwner(ctx, owner)
+
+		verified, err := ownerSrv.SetKYCStatus(ctx, owner.ID, types.KYCVerified)
+		assert.Nil(t, err)
+		assert.True(t, verified.IsVerified())
+
+		_, err = ownerSrv.SetKYCStatus(ctx, owner.ID, types.KYCPending)
+		assert.True(t, errors.Is(err, ErrInvalidKYCStatusTransition))
+	})
+	t.Run("owners.UpdateOwner should only change the fields sent and validate the result", func(t *testing.T) {
+		ctx := context.Background()
+		owner := types.NewOwner("Some Dumb Owner", "323456789LA012", "dumb@example.com", "", "")
+		ownerSrv.CreateOwner(ctx, owner)
+
+		phone := "+244 923 000 000"
+		updated, err := ownerSrv.UpdateOwner(ctx, owner.ID, requestparams.UpdateOwnerRequest{Phone: &phone})
+		assert.Nil(t, err)
+		assert.Equal(t, phone, updated.Phone)
+		assert.Equal(t, "dumb@example.com", updated.Email)
+
+		empty := ""
+		_, err = ownerSrv.UpdateOwner(ctx, owner.ID, requestparams.UpdateOwnerRequest{Name: &empty})
+		assert.True(t, errors.Is(err, ErrInvalidOwner))
+	})
+	t.Run("owners.DeleteOwner should refuse owners with open accounts", func(t *testing.T) {
+		ctx := context.Background()
+		owner := types.NewOwner("Some Dumb Owner", "423456789LA012", "", "", "")
+		ownerSrv.CreateOwner(ctx, owner)
+		accountRepo.CreateAccount(ctx, types.NewAccount(owner.ID, types.NewMoney(0, types.AOA)))
+
+		err := ownerSrv.DeleteOwner(ctx, owner.ID)
+		assert.True(t, errors.Is(err, ErrOwnerHasOpenAccounts))
+
+		_, err = ownerSrv.GetOwner(ctx, "some inexistent owner")
+		assert.True(t, errors.Is(err, ErrInexistentOwner))
+	})
+}
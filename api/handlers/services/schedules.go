@@ -0,0 +1,207 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	requestparams "go-sample/api/handlers/request-params"
+	"go-sample/types"
+	"log"
+	"time"
+)
+
+// dueTransfersBatch is how many due transfers ExecuteDueTransfers loads at a time
+const dueTransfersBatch = 100
+
+// ScheduleTransfer stores a transfer from the account to run at params.ExecuteAt. The accounts and
+// currencies are checked now, the funds only when it executes
+func (as *Account) ScheduleTransfer(ctx context.Context, accountId string, params requestparams.ScheduleTransferRequest) (*types.ScheduledTransfer, error) {
+	amount, beneficiaries, err := as.checkDeferredTransfer(ctx, accountId, params.TransferMoneyRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	transfer := types.NewScheduledTransfer(accountId, params.Subject, amount, params.ExecuteAt)
+	for _, beneficiary := range beneficiaries {
+		transfer.AddLeg(beneficiary.AccountId, beneficiary.Amount)
+	}
+	if err := as.scheduleRepo.CreateScheduledTransfer(ctx, transfer); err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
+
+// checkDeferredTransfer checks what can be checked of a transfer from the account before it runs: the
+// accounts, currencies, exchange rates and KYC limit. It returns the amount and recipients in the account currency
+func (as *Account) checkDeferredTransfer(ctx context.Context, accountId string, params requestparams.TransferMoneyRequest) (types.Money, []types.Beneficiary, error) {
+	from, err := as.GetAccount(ctx, accountId)
+	if err != nil {
+		return types.Money{}, nil, err
+	}
+	if from == nil {
+		return types.Money{}, nil, ErrInexistentAccount
+	}
+	if err := ensureOperable(from); err != nil {
+		return types.Money{}, nil, err
+	}
+	amount, err := as.inAccountCurrency(ctx, accountId, params.Amount)
+	if err != nil {
+		return types.Money{}, nil, err
+	}
+	if err := as.checkKYCTransferLimit(ctx, accountId, amount); err != nil {
+		return types.Money{}, nil, err
+	}
+
+	beneficiaries := make([]types.Beneficiary, len(params.Repcipients))
+	for i, recipient := range params.Repcipients {
+		account, err := as.GetAccount(ctx, recipient.AccountId)
+		if err != nil {
+			return types.Money{}, nil, err
+		}
+		if account == nil {
+			return types.Money{}, nil, ErrInexistentAccount
+		}
+		if account.Currency != "" && account.Currency != amount.Currency {
+			if _, err := as.exchangeRate(ctx, amount.Currency, account.Currency); err != nil {
+				return types.Money{}, nil, err
+			}
+		}
+		beneficiaries[i] = types.Beneficiary{AccountId: recipient.AccountId, Amount: types.NewMoney(recipient.Amount.Amount, amount.Currency)}
+	}
+	return amount, beneficiaries, nil
+}
+
+func (as *Account) GetScheduledTransfer(ctx context.Context, transferId string) (*types.ScheduledTransfer, error) {
+	transfer, err := as.scheduleRepo.GetScheduledTransfer(ctx, transferId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInexistentScheduledTransfer
+		}
+		return nil, err
+	}
+	return transfer, nil
+}
+
+func (as *Account) ListScheduledTransfers(ctx context.Context, accountId string, filters requestparams.ListScheduledTransfersRequest) (*types.Page[*types.ScheduledTransfer], error) {
+	account, err := as.GetAccount(ctx, accountId)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil {
+		return nil, ErrInexistentAccount
+	}
+	return as.scheduleRepo.ListScheduledTransfers(ctx, accountId, filters)
+}
+
+// CancelScheduledTransfer drops a transfer that the scheduler did not pick up yet
+func (as *Account) CancelScheduledTransfer(ctx context.Context, transferId string) (*types.ScheduledTransfer, error) {
+	var transfer *types.ScheduledTransfer
+	err := as.uow.Do(ctx, func(ctx context.Context) error {
+		var err error
+		transfer, err = as.updateScheduledTransfer(ctx, transferId, func(s *types.ScheduledTransfer) error { return s.Cancel() })
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
+
+// ExecuteDueTransfers runs every scheduled transfer due by now through TransferMoney and returns how many
+// it ran, whether they succeeded or failed. Transfers cancelled or claimed by another scheduler are skipped
+func (as *Account) ExecuteDueTransfers(ctx context.Context) (int, error) {
+	now := time.Now()
+	executed := 0
+	for {
+		transfers, err := as.scheduleRepo.ListDueTransfers(ctx, now, dueTransfersBatch)
+		if err != nil {
+			return executed, err
+		}
+		for _, transfer := range transfers {
+			ran, err := as.executeScheduledTransfer(ctx, transfer.ID, now)
+			if err != nil {
+				return executed, err
+			}
+			if ran {
+				executed++
+			}
+		}
+		if len(transfers) < dueTransfersBatch {
+			return executed, nil
+		}
+	}
+}
+
+// executeScheduledTransfer runs the transfer and marks it executed in the transfer's unit of work, so it is
+// never left half way: a scheduler stopping before the commit leaves it due for the next one. A failed
+// transfer is marked in a unit of work of its own once its own was rolled back. Concurrent schedulers may
+// both run it, only the first to commit keeps its outcome. It reports whether the transfer ran
+func (as *Account) executeScheduledTransfer(ctx context.Context, transferId string, now time.Time) (bool, error) {
+	transfer, err := as.scheduleRepo.GetScheduledTransfer(ctx, transferId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	if !transfer.IsDue(now) {
+		return false, nil
+	}
+
+	finish := func(ctx context.Context, batch *types.TransferBatch, transferErr error) error {
+		_, err := as.updateScheduledTransfer(ctx, transferId, func(s *types.ScheduledTransfer) error {
+			if err := s.Start(now); err != nil {
+				return err
+			}
+			s.Finish(batch, transferErr)
+			return nil
+		})
+		return err
+	}
+
+	recipients := make([]requestparams.Recipient, len(transfer.Legs))
+	for i, leg := range transfer.Legs {
+		recipients[i] = requestparams.Recipient{AccountId: leg.AccountId, Amount: leg.Amount}
+	}
+	batch, transferErr := as.transferMoney(ctx, requestparams.TransferMoneyRequest{
+		From:        transfer.From,
+		Amount:      transfer.Amount,
+		Subject:     transfer.Subject,
+		Repcipients: recipients,
+	}, transferHooks{settle: func(ctx context.Context, batch *types.TransferBatch) error {
+		return finish(ctx, batch, nil)
+	}})
+	if transferErr == nil {
+		return true, nil
+	}
+	if transferErr == ErrScheduledTransferNotPending {
+		return false, nil
+	}
+	log.Printf("scheduled transfer %s failed: %v", transfer.ID, transferErr)
+
+	err = as.uow.Do(ctx, func(ctx context.Context) error {
+		return finish(ctx, batch, transferErr)
+	})
+	if err == ErrScheduledTransferNotPending {
+		return false, nil
+	}
+	return true, err
+}
+
+// updateScheduledTransfer locks the transfer, applies change and stores it
+func (as *Account) updateScheduledTransfer(ctx context.Context, transferId string, change func(*types.ScheduledTransfer) error) (*types.ScheduledTransfer, error) {
+	locked, err := as.scheduleRepo.GetScheduledTransferForUpdate(ctx, transferId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInexistentScheduledTransfer
+		}
+		return nil, err
+	}
+	transfer := *locked
+	if err := change(&transfer); err != nil {
+		return nil, err
+	}
+	if err := as.scheduleRepo.UpdateScheduledTransfer(ctx, &transfer); err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
@@ -0,0 +1,193 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	requestparams "go-sample/api/handlers/request-params"
+	"go-sample/types"
+	"log"
+	"time"
+)
+
+// dueStandingOrdersBatch is how many due standing orders ExecuteDueStandingOrders loads at a time
+const dueStandingOrdersBatch = 100
+
+// CreateStandingOrder sets up a transfer from the account that repeats on every occurrence of the recurrence.
+// Like a scheduled transfer, the funds are only checked when each occurrence runs
+func (as *Account) CreateStandingOrder(ctx context.Context, accountId string, params requestparams.CreateStandingOrderRequest) (*types.StandingOrder, error) {
+	amount, beneficiaries, err := as.checkDeferredTransfer(ctx, accountId, params.TransferMoneyRequest)
+	if err != nil {
+		return nil, err
+	}
+	order, err := types.NewStandingOrder(accountId, params.Subject, amount, beneficiaries, params.Recurrence(),
+		params.RetryWindowDuration(), params.MaxConsecutiveFailures())
+	if err != nil {
+		return nil, err
+	}
+	if err := as.scheduleRepo.CreateStandingOrder(ctx, order); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+func (as *Account) GetStandingOrder(ctx context.Context, orderId string) (*types.StandingOrder, error) {
+	order, err := as.scheduleRepo.GetStandingOrder(ctx, orderId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInexistentStandingOrder
+		}
+		return nil, err
+	}
+	return order, nil
+}
+
+func (as *Account) ListStandingOrders(ctx context.Context, accountId string, filters requestparams.ListStandingOrdersRequest) (*types.Page[*types.StandingOrder], error) {
+	account, err := as.GetAccount(ctx, accountId)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil {
+		return nil, ErrInexistentAccount
+	}
+	return as.scheduleRepo.ListStandingOrders(ctx, accountId, filters)
+}
+
+// ListStandingOrderRuns is the run history of the order, every attempt including the retries
+func (as *Account) ListStandingOrderRuns(ctx context.Context, orderId string, pagination requestparams.Pagination) (*types.Page[*types.StandingOrderRun], error) {
+	if _, err := as.GetStandingOrder(ctx, orderId); err != nil {
+		return nil, err
+	}
+	return as.scheduleRepo.ListStandingOrderRuns(ctx, orderId, pagination)
+}
+
+func (as *Account) CancelStandingOrder(ctx context.Context, orderId string) (*types.StandingOrder, error) {
+	return as.changeStandingOrder(ctx, orderId, func(o *types.StandingOrder) error { return o.Cancel() })
+}
+
+// ResumeStandingOrder reactivates an order suspended after too many failures, from its next occurrence
+func (as *Account) ResumeStandingOrder(ctx context.Context, orderId string) (*types.StandingOrder, error) {
+	now := time.Now()
+	return as.changeStandingOrder(ctx, orderId, func(o *types.StandingOrder) error { return o.Resume(now) })
+}
+
+func (as *Account) changeStandingOrder(ctx context.Context, orderId string, change func(*types.StandingOrder) error) (*types.StandingOrder, error) {
+	var order *types.StandingOrder
+	err := as.uow.Do(ctx, func(ctx context.Context) error {
+		var err error
+		order, err = as.updateStandingOrder(ctx, orderId, change)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// ExecuteDueStandingOrders makes an attempt at every standing order due by now and returns how many it made,
+// whether they succeeded or failed. Orders cancelled or claimed by another scheduler are skipped
+func (as *Account) ExecuteDueStandingOrders(ctx context.Context) (int, error) {
+	now := time.Now()
+	attempted := 0
+	for {
+		orders, err := as.scheduleRepo.ListDueStandingOrders(ctx, now, dueStandingOrdersBatch)
+		if err != nil {
+			return attempted, err
+		}
+		for _, order := range orders {
+			ran, err := as.runStandingOrder(ctx, order.ID, now)
+			if err != nil {
+				return attempted, err
+			}
+			if ran {
+				attempted++
+			}
+		}
+		if len(orders) < dueStandingOrdersBatch {
+			return attempted, nil
+		}
+	}
+}
+
+// runStandingOrder claims the order in a unit of work of its own, then runs the transfer, recording the run
+// and moving the order on in the transfer's unit of work so an occurrence is never paid twice. A failed
+// attempt is recorded afterwards. The claim keeps other schedulers away until its lease ends, an attempt
+// that outlives it loses the order and is rolled back. It reports whether the order ran
+func (as *Account) runStandingOrder(ctx context.Context, orderId string, now time.Time) (bool, error) {
+	var order *types.StandingOrder
+	err := as.uow.Do(ctx, func(ctx context.Context) error {
+		var err error
+		order, err = as.updateStandingOrder(ctx, orderId, func(o *types.StandingOrder) error { return o.Claim(now) })
+		return err
+	})
+	if err == types.ErrStandingOrderNotDue {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// record changes the order only while this attempt still holds it
+	record := func(ctx context.Context, batch *types.TransferBatch, transferErr error) error {
+		_, err := as.updateStandingOrder(ctx, orderId, func(o *types.StandingOrder) error {
+			if o.Occurrence != order.Occurrence || o.Attempts != order.Attempts {
+				return types.ErrStandingOrderNotDue
+			}
+			run := types.NewStandingOrderRun(o, batch, transferErr)
+			if transferErr == nil {
+				o.Succeed()
+			} else {
+				run.WillRetry = o.Fail(now, errors.Is(transferErr, ErrInsufficientFunds))
+			}
+			return as.scheduleRepo.CreateStandingOrderRun(ctx, run)
+		})
+		return err
+	}
+
+	recipients := make([]requestparams.Recipient, len(order.Beneficiaries))
+	for i, beneficiary := range order.Beneficiaries {
+		recipients[i] = requestparams.Recipient{AccountId: beneficiary.AccountId, Amount: beneficiary.Amount}
+	}
+	batch, transferErr := as.transferMoney(ctx, requestparams.TransferMoneyRequest{
+		From:        order.From,
+		Amount:      order.Amount,
+		Subject:     order.Subject,
+		Repcipients: recipients,
+	}, transferHooks{settle: func(ctx context.Context, batch *types.TransferBatch) error {
+		return record(ctx, batch, nil)
+	}})
+	if transferErr == nil {
+		return true, nil
+	}
+	if transferErr == types.ErrStandingOrderNotDue {
+		return false, nil
+	}
+	log.Printf("standing order %s failed: %v", order.ID, transferErr)
+
+	err = as.uow.Do(ctx, func(ctx context.Context) error {
+		return record(ctx, batch, transferErr)
+	})
+	if err == types.ErrStandingOrderNotDue {
+		return false, nil
+	}
+	return true, err
+}
+
+// updateStandingOrder locks the order, applies change and stores it
+func (as *Account) updateStandingOrder(ctx context.Context, orderId string, change func(*types.StandingOrder) error) (*types.StandingOrder, error) {
+	locked, err := as.scheduleRepo.GetStandingOrderForUpdate(ctx, orderId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInexistentStandingOrder
+		}
+		return nil, err
+	}
+	order := *locked
+	if err := change(&order); err != nil {
+		return nil, err
+	}
+	if err := as.scheduleRepo.UpdateStandingOrder(ctx, &order); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
@@ -0,0 +1,275 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	requestparams "go-sample/api/handlers/request-params"
+	"go-sample/api/utils"
+	"go-sample/storage"
+	outboxrepo "go-sample/storage/outbox-repo"
+	webhookrepo "go-sample/storage/webhook-repo"
+	"go-sample/types"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// webhookTimeout bounds an attempt, an endpoint slower than that fails it like an unreachable one
+	webhookTimeout = 10 * time.Second
+	// pendingEventsBatch is how many outbox events DispatchEvents fans out in one unit of work
+	pendingEventsBatch = 100
+	// dueDeliveriesBatch is how many due deliveries DeliverDue loads at a time
+	dueDeliveriesBatch = 100
+)
+
+// transactionEvents is the event announcing a completed transaction of each operation
+var transactionEvents = map[string]types.EventType{
+	string(utils.DEPOSIT):  types.EventDepositCompleted,
+	string(utils.WITHDRAW): types.EventWithdrawalCompleted,
+	string(utils.TRANSFER): types.EventTransferCompleted,
+	string(utils.REFUND):   types.EventRefundCompleted,
+	string(utils.FEE):      types.EventFeeCharged,
+}
+
+// publishTransaction writes the event of a completed transaction to the outbox, in the unit of work
+// that completes it so the event is only sent if the ledger change is committed
+func (as *Account) publishTransaction(ctx context.Context, transaction *types.Transaction) error {
+	eventType, ok := transactionEvents[transaction.Operation]
+	if !ok {
+		return nil
+	}
+	event, err := types.NewEvent(eventType, transaction)
+	if err != nil {
+		return err
+	}
+	return as.outboxRepo.CreateEvent(ctx, event)
+}
+
+type Webhook struct {
+	webhookRepo webhookrepo.IWebhookRepo
+	outboxRepo  outboxrepo.IOutboxRepo
+	uow         storage.UnitOfWork
+	client      *http.Client
+}
+
+func NewWebhook(webhookRepo webhookrepo.IWebhookRepo, outboxRepo outboxrepo.IOutboxRepo, uow storage.UnitOfWork) Webhook {
+	return Webhook{
+		webhookRepo: webhookRepo,
+		outboxRepo:  outboxRepo,
+		uow:         uow,
+		client:      &http.Client{Timeout: webhookTimeout},
+	}
+}
+
+// RegisterEndpoint returns the endpoint with its secret, the only time the secret is shown
+func (ws *Webhook) RegisterEndpoint(ctx context.Context, request requestparams.RegisterWebhookRequest) (*types.WebhookEndpoint, error) {
+	endpoint, err := types.NewWebhookEndpoint(request.URL, request.EventTypes)
+	if err != nil {
+		return nil, err
+	}
+	if err := ws.webhookRepo.CreateEndpoint(ctx, endpoint); err != nil {
+		return nil, err
+	}
+	return endpoint, nil
+}
+
+func (ws *Webhook) ListEndpoints(ctx context.Context) ([]*types.WebhookEndpoint, error) {
+	endpoints, err := ws.webhookRepo.ListEndpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, endpoint := range endpoints {
+		endpoint.Secret = ""
+	}
+	return endpoints, nil
+}
+
+// DeleteEndpoint stops the deliveries to the endpoint, the pending and dead ones are dropped with it
+func (ws *Webhook) DeleteEndpoint(ctx context.Context, endpointId string) error {
+	err := ws.webhookRepo.DeleteEndpoint(ctx, endpointId)
+	if err == sql.ErrNoRows {
+		return ErrInexistentWebhookEndpoint
+	}
+	return err
+}
+
+// ListDeadLetters pages through the deliveries that used all their attempts
+func (ws *Webhook) ListDeadLetters(ctx context.Context, pagination requestparams.Pagination) (*types.Page[*types.WebhookDelivery], error) {
+	return ws.webhookRepo.ListDeadDeliveries(ctx, pagination)
+}
+
+// ReplayDelivery sends a dead delivery again, with a new round of attempts
+func (ws *Webhook) ReplayDelivery(ctx context.Context, deliveryId string) (*types.WebhookDelivery, error) {
+	now := time.Now()
+	var delivery *types.WebhookDelivery
+	err := ws.uow.Do(ctx, func(ctx context.Context) error {
+		var err error
+		delivery, err = ws.updateDelivery(ctx, deliveryId, func(d *types.WebhookDelivery) error { return d.Replay(now) })
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// DispatchEvents turns the outbox events into one delivery per endpoint subscribed to them and returns how
+// many events it handled. Events nobody is subscribed to are only marked dispatched
+func (ws *Webhook) DispatchEvents(ctx context.Context) (int, error) {
+	dispatched := 0
+	for {
+		count := 0
+		err := ws.uow.Do(ctx, func(ctx context.Context) error {
+			events, err := ws.outboxRepo.ListPendingEvents(ctx, pendingEventsBatch)
+			if err != nil {
+				return err
+			}
+			endpoints, err := ws.webhookRepo.ListEndpoints(ctx)
+			if err != nil {
+				return err
+			}
+			now := time.Now()
+			for _, event := range events {
+				for _, endpoint := range endpoints {
+					if !endpoint.Accepts(event.Type) {
+						continue
+					}
+					delivery, err := types.NewWebhookDelivery(endpoint, event)
+					if err != nil {
+						return err
+					}
+					if err := ws.webhookRepo.CreateDelivery(ctx, delivery); err != nil {
+						return err
+					}
+				}
+				event.DispatchedAt = &now
+				if err := ws.outboxRepo.MarkEventDispatched(ctx, event); err != nil {
+					return err
+				}
+			}
+			count = len(events)
+			return nil
+		})
+		if err != nil {
+			return dispatched, err
+		}
+		dispatched += count
+		if count < pendingEventsBatch {
+			return dispatched, nil
+		}
+	}
+}
+
+// DeliverDue makes an attempt at every delivery due by now and returns how many it made, whether they
+// succeeded or failed. Deliveries claimed by another dispatcher are skipped
+func (ws *Webhook) DeliverDue(ctx context.Context) (int, error) {
+	now := time.Now()
+	attempted := 0
+	for {
+		deliveries, err := ws.webhookRepo.ListDueDeliveries(ctx, now, dueDeliveriesBatch)
+		if err != nil {
+			return attempted, err
+		}
+		for _, delivery := range deliveries {
+			sent, err := ws.deliver(ctx, delivery.ID)
+			if err != nil {
+				return attempted, err
+			}
+			if sent {
+				attempted++
+			}
+		}
+		if len(deliveries) < dueDeliveriesBatch {
+			return attempted, nil
+		}
+	}
+}
+
+// deliver claims the delivery, sends it and records the outcome, each step in a unit of work of its own like
+// runStandingOrder. A dispatcher stopping after sending means the event is sent again, receivers should
+// ignore the Webhook-Id they already got. The lease and the backoff start when each step happens, not when
+// DeliverDue started, as every attempt before may have taken up to webhookTimeout. It reports whether an attempt was made
+func (ws *Webhook) deliver(ctx context.Context, deliveryId string) (bool, error) {
+	var delivery *types.WebhookDelivery
+	err := ws.uow.Do(ctx, func(ctx context.Context) error {
+		var err error
+		delivery, err = ws.updateDelivery(ctx, deliveryId, func(d *types.WebhookDelivery) error { return d.Claim(time.Now()) })
+		return err
+	})
+	if err == types.ErrDeliveryNotDue || err == ErrInexistentWebhookDelivery {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	endpoint, err := ws.webhookRepo.GetEndpoint(ctx, delivery.EndpointId)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	status, sendErr := ws.send(ctx, endpoint, delivery)
+	err = ws.uow.Do(ctx, func(ctx context.Context) error {
+		_, err := ws.updateDelivery(ctx, deliveryId, func(d *types.WebhookDelivery) error {
+			if sendErr != nil {
+				d.Fail(time.Now(), status, sendErr.Error())
+			} else {
+				d.Succeed(status)
+			}
+			return nil
+		})
+		return err
+	})
+	return true, err
+}
+
+// send posts the event to the endpoint, signed with its secret. Any answer but a 2xx is a failure,
+// status is 0 when there was no answer
+func (ws *Webhook) send(ctx context.Context, endpoint *types.WebhookEndpoint, delivery *types.WebhookDelivery) (int, error) {
+	timestamp := time.Now().Unix()
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Webhook-Id", delivery.EventId)
+	request.Header.Set("Webhook-Event", string(delivery.EventType))
+	request.Header.Set("Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	request.Header.Set("Webhook-Signature", "sha256="+endpoint.Sign(timestamp, delivery.Payload))
+
+	response, err := ws.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, 64<<10))
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return response.StatusCode, fmt.Errorf("endpoint answered %s", response.Status)
+	}
+	return response.StatusCode, nil
+}
+
+// updateDelivery locks the delivery, applies change and stores it
+func (ws *Webhook) updateDelivery(ctx context.Context, deliveryId string, change func(*types.WebhookDelivery) error) (*types.WebhookDelivery, error) {
+	locked, err := ws.webhookRepo.GetDeliveryForUpdate(ctx, deliveryId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInexistentWebhookDelivery
+		}
+		return nil, err
+	}
+	delivery := *locked
+	if err := change(&delivery); err != nil {
+		return nil, err
+	}
+	if err := ws.webhookRepo.UpdateDelivery(ctx, &delivery); err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"errors"
+	requestparams "go-sample/api/handlers/request-params"
+	"go-sample/storage"
+	outboxrepo "go-sample/storage/outbox-repo"
+	webhookrepo "go-sample/storage/webhook-repo"
+	"go-sample/types"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookSrv(t *testing.T) {
+
+	webhookRepo := webhookrepo.NewMemoWebhookRepo()
+	outboxRepo := outboxrepo.NewMemoOutboxRepo()
+	webhookSrv := NewWebhook(webhookRepo, outboxRepo, storage.NewMemoUnitOfWork())
+
+	answer := http.StatusInternalServerError
+	received := []*http.Request{}
+	bodies := [][]byte{}
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, r)
+		bodies = append(bodies, body)
+		w.WriteHeader(answer)
+	}))
+	defer receiver.Close()
+
+	t.Run("webhooks.DeliverDue should send signed events, retry failures and replay dead letters", func(t *testing.T) {
+		ctx := context.Background()
+		endpoint, err := webhookSrv.RegisterEndpoint(ctx, requestparams.RegisterWebhookRequest{
+			URL:        receiver.URL,
+			EventTypes: []types.EventType{types.EventDepositCompleted},
+		})
+		assert.Nil(t, err)
+		assert.NotEmpty(t, endpoint.Secret)
+
+		t.Run("the secret is only shown when the endpoint is registered", func(t *testing.T) {
+			endpoints, err := webhookSrv.ListEndpoints(ctx)
+			assert.Nil(t, err)
+			assert.Empty(t, endpoints[0].Secret)
+		})
+		var deposit *types.Event
+		t.Run("events are dispatched to the endpoints subscribed to them", func(t *testing.T) {
+			var err error
+			deposit, err = types.NewEvent(types.EventDepositCompleted, map[string]string{"id": "some transaction"})
+			assert.Nil(t, err)
+			fee, err := types.NewEvent(types.EventFeeCharged, map[string]string{"id": "some fee"})
+			assert.Nil(t, err)
+			assert.Nil(t, outboxRepo.CreateEvent(ctx, deposit))
+			assert.Nil(t, outboxRepo.CreateEvent(ctx, fee))
+
+			dispatched, err := webhookSrv.DispatchEvents(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, 2, dispatched)
+			pending, err := outboxRepo.ListPendingEvents(ctx, 10)
+			assert.Nil(t, err)
+			assert.Empty(t, pending)
+		})
+		t.Run("a delivery is signed with the secret of the endpoint", func(t *testing.T) {
+			attempted, err := webhookSrv.DeliverDue(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, 1, attempted)
+			assert.Len(t, received, 1)
+			request := received[0]
+			assert.Equal(t, deposit.ID, request.Header.Get("Webhook-Id"))
+			assert.Equal(t, string(types.EventDepositCompleted), request.Header.Get("Webhook-Event"))
+			timestamp, err := strconv.ParseInt(request.Header.Get("Webhook-Timestamp"), 10, 64)
+			assert.Nil(t, err)
+			assert.Equal(t, "sha256="+endpoint.Sign(timestamp, bodies[0]), request.Header.Get("Webhook-Signature"))
+		})
+		var delivery *types.WebhookDelivery
+		t.Run("a failed delivery is retried later", func(t *testing.T) {
+			attempted, err := webhookSrv.DeliverDue(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, 0, attempted)
+
+			due, err := webhookRepo.ListDueDeliveries(ctx, time.Now().Add(time.Minute), 10)
+			assert.Nil(t, err)
+			assert.Len(t, due, 1)
+			delivery = due[0]
+			assert.Equal(t, 1, delivery.Attempts)
+			assert.Equal(t, http.StatusInternalServerError, delivery.ResponseStatus)
+		})
+		t.Run("a delivery out of attempts becomes a dead letter", func(t *testing.T) {
+			delivery.Attempts = types.WebhookMaxAttempts - 1
+			delivery.NextAttemptAt = time.Now().Add(-time.Second)
+			assert.Nil(t, webhookRepo.UpdateDelivery(ctx, delivery))
+
+			_, err := webhookSrv.DeliverDue(ctx)
+			assert.Nil(t, err)
+			dead, err := webhookSrv.ListDeadLetters(ctx, requestparams.Pagination{})
+			assert.Nil(t, err)
+			assert.Len(t, dead.Data, 1)
+			assert.Equal(t, types.DeliveryDead, dead.Data[0].Status)
+		})
+		t.Run("a replayed dead letter is delivered again", func(t *testing.T) {
+			answer = http.StatusNoContent
+			replayed, err := webhookSrv.ReplayDelivery(ctx, delivery.ID)
+			assert.Nil(t, err)
+			assert.Equal(t, types.DeliveryPending, replayed.Status)
+			_, err = webhookSrv.ReplayDelivery(ctx, delivery.ID)
+			assert.True(t, errors.Is(err, ErrDeliveryNotDead))
+
+			attempted, err := webhookSrv.DeliverDue(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, 1, attempted)
+			dead, err := webhookSrv.ListDeadLetters(ctx, requestparams.Pagination{})
+			assert.Nil(t, err)
+			assert.Empty(t, dead.Data)
+			assert.Equal(t, bodies[0], bodies[len(bodies)-1])
+		})
+		t.Run("an endpoint is deleted once", func(t *testing.T) {
+			assert.Nil(t, webhookSrv.DeleteEndpoint(ctx, endpoint.ID))
+			assert.Equal(t, ErrInexistentWebhookEndpoint, webhookSrv.DeleteEndpoint(ctx, endpoint.ID))
+		})
+	})
+
+	t.Run("webhooks.DeliverDue should schedule a retry from when the attempt failed", func(t *testing.T) {
+		ctx := context.Background()
+		slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(100 * time.Millisecond)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer slow.Close()
+
+		webhookRepo := webhookrepo.NewMemoWebhookRepo()
+		outboxRepo := outboxrepo.NewMemoOutboxRepo()
+		webhookSrv := NewWebhook(webhookRepo, outboxRepo, storage.NewMemoUnitOfWork())
+		_, err := webhookSrv.RegisterEndpoint(ctx, requestparams.RegisterWebhookRequest{URL: slow.URL})
+		assert.Nil(t, err)
+		for i := 0; i < 2; i++ {
+			event, err := types.NewEvent(types.EventDepositCompleted, map[string]int{"deposit": i})
+			assert.Nil(t, err)
+			assert.Nil(t, outboxRepo.CreateEvent(ctx, event))
+		}
+		_, err = webhookSrv.DispatchEvents(ctx)
+		assert.Nil(t, err)
+
+		started := time.Now()
+		attempted, err := webhookSrv.DeliverDue(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, attempted)
+
+		due, err := webhookRepo.ListDueDeliveries(ctx, time.Now().Add(time.Hour), 10)
+		assert.Nil(t, err)
+		assert.Len(t, due, 2)
+		for i, delivery := range due {
+			sent := started.Add(time.Duration(i+1) * 100 * time.Millisecond)
+			assert.False(t, delivery.NextAttemptAt.Before(sent.Add(types.WebhookBackoff(1))))
+		}
+	})
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	requestparams "go-sample/api/handlers/request-params"
+	"go-sample/api/handlers/services"
+	"go-sample/api/utils"
+	"go-sample/types"
+	"net/http"
+
+	"github.com/go-chi/chi"
+)
+
+func (ah *AccountHandler) CreateStandingOrder(w http.ResponseWriter, r *http.Request) {
+	var request requestparams.CreateStandingOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || !request.Validate() {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "recipients must add up to the positive amount, frequency must be DAILY, WEEKLY or MONTHLY with a day_of_month, end_at after the first run, count positive and retry_window at most 7 days in seconds",
+		})
+		return
+	}
+
+	order, err := ah.accountSrv.CreateStandingOrder(r.Context(), chi.URLParam(r, "id"), request)
+	if errors.Is(err, services.ErrInexistentAccount) {
+		writeError(w, http.StatusNotFound, err, "The account or one of the recipients does not exist")
+		return
+	}
+	if errors.Is(err, services.ErrInvalidRecurrence) {
+		writeError(w, http.StatusBadRequest, err, "The recurrence, retry window or maximum failures are not valid")
+		return
+	}
+	if errors.Is(err, services.ErrKYCTransferLimitExceeded) {
+		writeError(w, http.StatusForbidden, err, "The amount is above the transfer limit of the owner's KYC status")
+		return
+	}
+	if writeAccountStateError(w, err) || writeCurrencyError(w, err) {
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(order)
+}
+
+func (ah *AccountHandler) ListStandingOrders(w http.ResponseWriter, r *http.Request) {
+	data, err := utils.ExtracteQueryParams(r)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	filter := new(requestparams.ListStandingOrdersRequest)
+	if err = json.Unmarshal(data, filter); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if !filter.Validate() {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "invalid filters: status must be ACTIVE, SUSPENDED, COMPLETED or CANCELLED, limit a positive number and cursor one returned by a previous page",
+		})
+		return
+	}
+
+	orders, err := ah.accountSrv.ListStandingOrders(r.Context(), chi.URLParam(r, "id"), *filter)
+	if errors.Is(err, services.ErrInexistentAccount) {
+		writeError(w, http.StatusNotFound, err, "There's no any bank account associated with this id")
+		return
+	}
+	if errors.Is(err, types.ErrInvalidCursor) {
+		writeInvalidCursor(w)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(orders)
+}
+
+func (ah *AccountHandler) GetStandingOrder(w http.ResponseWriter, r *http.Request) {
+	order, err := ah.accountSrv.GetStandingOrder(r.Context(), chi.URLParam(r, "id"))
+	if writeStandingOrderError(w, err) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(order)
+}
+
+func (ah *AccountHandler) ListStandingOrderRuns(w http.ResponseWriter, r *http.Request) {
+	data, err := utils.ExtracteQueryParams(r)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	pagination := new(requestparams.Pagination)
+	if err = json.Unmarshal(data, pagination); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if !pagination.Validate() {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "invalid filters: limit must be a positive number and cursor one returned by a previous page",
+		})
+		return
+	}
+
+	runs, err := ah.accountSrv.ListStandingOrderRuns(r.Context(), chi.URLParam(r, "id"), *pagination)
+	if errors.Is(err, types.ErrInvalidCursor) {
+		writeInvalidCursor(w)
+		return
+	}
+	if writeStandingOrderError(w, err) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(runs)
+}
+
+func (ah *AccountHandler) CancelStandingOrder(w http.ResponseWriter, r *http.Request) {
+	order, err := ah.accountSrv.CancelStandingOrder(r.Context(), chi.URLParam(r, "id"))
+	if writeStandingOrderError(w, err) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(order)
+}
+
+func (ah *AccountHandler) ResumeStandingOrder(w http.ResponseWriter, r *http.Request) {
+	order, err := ah.accountSrv.ResumeStandingOrder(r.Context(), chi.URLParam(r, "id"))
+	if writeStandingOrderError(w, err) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(order)
+}
+
+// writeStandingOrderError answers for the errors shared by the standing order endpoints, it reports whether anything was written
+func writeStandingOrderError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, services.ErrInexistentStandingOrder):
+		writeError(w, http.StatusNotFound, err, "There's no standing order associated with this id")
+	case errors.Is(err, services.ErrStandingOrderClosed):
+		writeError(w, http.StatusConflict, err, "The standing order was already completed or cancelled")
+	case errors.Is(err, services.ErrStandingOrderNotSuspended):
+		writeError(w, http.StatusConflict, err, "Only a suspended standing order can be resumed")
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	return true
+}
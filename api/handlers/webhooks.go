@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	requestparams "go-sample/api/handlers/request-params"
+	"go-sample/api/handlers/services"
+	"go-sample/api/utils"
+	"go-sample/types"
+	"net/http"
+
+	"github.com/go-chi/chi"
+)
+
+type WebhookHandler struct {
+	webhookSrv services.Webhook
+}
+
+func NewWebhookHandler(
+	webhookSrv services.Webhook,
+) *WebhookHandler {
+	return &WebhookHandler{
+		webhookSrv: webhookSrv,
+	}
+}
+
+func (wh *WebhookHandler) RegisterEndpoint(w http.ResponseWriter, r *http.Request) {
+	var request requestparams.RegisterWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || !request.Validate() {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "url must be an http or https URL and event_types any of deposit.completed, withdrawal.completed, transfer.completed, refund.completed and fee.charged",
+		})
+		return
+	}
+
+	endpoint, err := wh.webhookSrv.RegisterEndpoint(r.Context(), request)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(endpoint)
+}
+
+func (wh *WebhookHandler) ListEndpoints(w http.ResponseWriter, r *http.Request) {
+	endpoints, err := wh.webhookSrv.ListEndpoints(r.Context())
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(endpoints)
+}
+
+func (wh *WebhookHandler) DeleteEndpoint(w http.ResponseWriter, r *http.Request) {
+	err := wh.webhookSrv.DeleteEndpoint(r.Context(), chi.URLParam(r, "id"))
+	if errors.Is(err, services.ErrInexistentWebhookEndpoint) {
+		writeError(w, http.StatusNotFound, err, "There's no webhook endpoint associated with this id")
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (wh *WebhookHandler) ListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	data, err := utils.ExtracteQueryParams(r)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	pagination := new(requestparams.Pagination)
+	if err = json.Unmarshal(data, pagination); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if !pagination.Validate() {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "invalid filters: limit must be a positive number and cursor one returned by a previous page",
+		})
+		return
+	}
+
+	deliveries, err := wh.webhookSrv.ListDeadLetters(r.Context(), *pagination)
+	if errors.Is(err, types.ErrInvalidCursor) {
+		writeInvalidCursor(w)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deliveries)
+}
+
+func (wh *WebhookHandler) ReplayDelivery(w http.ResponseWriter, r *http.Request) {
+	delivery, err := wh.webhookSrv.ReplayDelivery(r.Context(), chi.URLParam(r, "id"))
+	if errors.Is(err, services.ErrInexistentWebhookDelivery) {
+		writeError(w, http.StatusNotFound, err, "There's no webhook delivery associated with this id")
+		return
+	}
+	if errors.Is(err, services.ErrDeliveryNotDead) {
+		writeError(w, http.StatusConflict, err, "Only a delivery in the dead letters can be replayed")
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(delivery)
+}
@@ -0,0 +1,36 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"time"
+)
+
+// Every runs fn once per interval until ctx is done. Runs never overlap, a failed run is logged and
+// the next one happens at the following tick. A run that panics counts as failed
+func Every(ctx context.Context, interval time.Duration, name string, fn func(context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := safely(func() error { return fn(ctx) }); err != nil {
+				log.Printf("job %s failed: %v", name, err)
+			}
+		}
+	}
+}
+
+// safely runs fn and turns a panic into its error, so a bug hit by one run does not stop the server
+func safely(fn func() error) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("panic: %v\n%s", recovered, debug.Stack())
+		}
+	}()
+	return fn()
+}
@@ -0,0 +1,43 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Pool runs fn on size workers for every id returned by next, which is polled once per interval. An id is
+// handed over only when a worker is free, so next is not polled again before the previous ids are all taken.
+// fn must tolerate an id it already got, next may return it again while the worker has not claimed it yet
+func Pool(ctx context.Context, size int, interval time.Duration, name string, next func(context.Context) ([]string, error), fn func(context.Context, string) error) {
+	queue := make(chan string)
+	for i := 0; i < size; i++ {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case id := <-queue:
+					if err := safely(func() error { return fn(ctx, id) }); err != nil {
+						log.Printf("job %s failed on %s: %v", name, id, err)
+					}
+				}
+			}
+		}()
+	}
+
+	Every(ctx, interval, name, func(ctx context.Context) error {
+		ids, err := next(ctx)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			select {
+			case <-ctx.Done():
+				return nil
+			case queue <- id:
+			}
+		}
+		return nil
+	})
+}
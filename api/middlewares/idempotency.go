@@ -0,0 +1,145 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	idempotencyrepo "go-sample/storage/idempotency-repo"
+	"go-sample/types"
+	"io"
+	"log"
+	"net/http"
+)
+
+const IdempotencyKeyHeader = "Idempotency-Key"
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+// responseRecorder passes the response through to the client while keeping a copy of it
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (rr *responseRecorder) WriteHeader(statusCode int) {
+	if rr.statusCode == 0 {
+		rr.statusCode = statusCode
+	}
+	rr.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rr *responseRecorder) Write(data []byte) (int, error) {
+	if rr.statusCode == 0 {
+		rr.statusCode = http.StatusOK
+	}
+	rr.body.Write(data)
+	return rr.ResponseWriter.Write(data)
+}
+
+func (rr *responseRecorder) status() int {
+	if rr.statusCode == 0 {
+		return http.StatusOK
+	}
+	return rr.statusCode
+}
+
+// Idempotency makes money-moving endpoints safe to retry. The first request carrying an
+// Idempotency-Key header runs normally and its response is stored, later requests with the same key
+// get that response back without running the handler again. Reusing a key with a different
+// method, path, query or body is rejected with 422, and a key whose first request is still running gets 409.
+// A key left reserved for longer than types.IdempotencyLease belongs to a request that never finished,
+// a retry takes it over and runs again. Server errors are not stored, the key is released so the client can retry it.
+// Requests without the header are not affected.
+func Idempotency(repo idempotencyrepo.IIdempotencyRepo) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > 255 {
+				writeError(w, http.StatusBadRequest, "Idempotency-Key must have at most 255 characters")
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			r.Body.Close()
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			record := types.NewIdempotencyRecord(key, r.Method, r.URL.Path, requestHash(r, body))
+			stored, owned, err := repo.Reserve(r.Context(), record)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			if !owned {
+				replay(w, record, stored)
+				return
+			}
+
+			recorder := &responseRecorder{ResponseWriter: w}
+			defer func() {
+				// the client may be gone already, the outcome must be stored regardless
+				ctx := context.WithoutCancel(r.Context())
+				if p := recover(); p != nil {
+					repo.Release(ctx, record)
+					panic(p)
+				}
+				if recorder.status() >= http.StatusInternalServerError {
+					err = repo.Release(ctx, record)
+				} else {
+					err = repo.Complete(ctx, record, recorder.status(), recorder.Header().Get("Content-Type"), recorder.body.Bytes())
+				}
+				if err != nil {
+					log.Printf("idempotency: unable to store the outcome of key %s: %v", key, err)
+				}
+			}()
+			next.ServeHTTP(recorder, r)
+		})
+	}
+}
+
+func replay(w http.ResponseWriter, record *types.IdempotencyRecord, stored *types.IdempotencyRecord) {
+	if stored.RequestHash != record.RequestHash {
+		writeError(w, http.StatusUnprocessableEntity, "This Idempotency-Key was already used with a different request payload")
+		return
+	}
+	if !stored.IsCompleted() {
+		writeError(w, http.StatusConflict, "A request with this Idempotency-Key is still being processed")
+		return
+	}
+	if stored.ContentType != "" {
+		w.Header().Set("Content-Type", stored.ContentType)
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(stored.StatusCode)
+	w.Write(stored.ResponseBody)
+}
+
+func requestHash(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(r.Method))
+	hash.Write([]byte{0})
+	hash.Write([]byte(r.URL.Path))
+	hash.Write([]byte{0})
+	hash.Write([]byte(r.URL.Query().Encode()))
+	hash.Write([]byte{0})
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func writeError(w http.ResponseWriter, statusCode int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": message,
+	})
+}
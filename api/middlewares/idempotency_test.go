@@ -0,0 +1,107 @@
+package middlewares
+
+import (
+	"context"
+	"fmt"
+	"go-sample/storage"
+	idempotencyrepo "go-sample/storage/idempotency-repo"
+	"go-sample/types"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotency(t *testing.T) {
+	calls := 0
+	statusCode := http.StatusOK
+	repo := idempotencyrepo.NewMemoIdempotencyRepo()
+	handler := Idempotency(repo)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := io.ReadAll(r.Body)
+		w.WriteHeader(statusCode)
+		fmt.Fprintf(w, "call %d with %s", calls, body)
+	}))
+
+	send := func(key string, payload string) *http.Response {
+		req := httptest.NewRequest(http.MethodPost, "/accounts/some-id/transfer_money", strings.NewReader(payload))
+		if key != "" {
+			req.Header.Set(IdempotencyKeyHeader, key)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Result()
+	}
+
+	t.Run("should replay the stored response without calling the handler again", func(t *testing.T) {
+		first := send("key-1", `{"amount": 10}`)
+		second := send("key-1", `{"amount": 10}`)
+
+		firstBody, err := io.ReadAll(first.Body)
+		assert.Nil(t, err)
+		secondBody, err := io.ReadAll(second.Body)
+		assert.Nil(t, err)
+
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, string(firstBody), string(secondBody))
+		assert.Equal(t, "true", second.Header.Get(IdempotentReplayedHeader))
+	})
+
+	t.Run("should respond with 422 if the key is reused with a different payload", func(t *testing.T) {
+		res := send("key-1", `{"amount": 20}`)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("should not store server errors so the request can be retried", func(t *testing.T) {
+		statusCode = http.StatusInternalServerError
+		send("key-2", `{"amount": 10}`)
+		statusCode = http.StatusOK
+		res := send("key-2", `{"amount": 10}`)
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("should not interfere with requests without the header", func(t *testing.T) {
+		send("", `{"amount": 10}`)
+		send("", `{"amount": 10}`)
+
+		assert.Equal(t, 5, calls)
+	})
+
+	t.Run("should let a retry take over a key its first request never completed", func(t *testing.T) {
+		ctx := context.Background()
+		orphan := func(key string, reservedAt time.Time) *types.IdempotencyRecord {
+			req := httptest.NewRequest(http.MethodPost, "/accounts/some-id/transfer_money", nil)
+			record := types.NewIdempotencyRecord(key, req.Method, req.URL.Path, requestHash(req, []byte(`{"amount": 10}`)))
+			record.ReservedAt = reservedAt
+			_, owned, err := repo.Reserve(ctx, record)
+			assert.Nil(t, err)
+			assert.True(t, owned)
+			return record
+		}
+
+		orphan("key-3", time.Now())
+		res := send("key-3", `{"amount": 10}`)
+		assert.Equal(t, http.StatusConflict, res.StatusCode)
+		assert.Equal(t, 5, calls)
+
+		abandoned := orphan("key-4", time.Now().Add(-types.IdempotencyLease))
+		res = send("key-4", `{"amount": 20}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+		res = send("key-4", `{"amount": 10}`)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, 6, calls)
+
+		res = send("key-4", `{"amount": 10}`)
+		assert.Equal(t, "true", res.Header.Get(IdempotentReplayedHeader))
+		assert.Equal(t, 6, calls)
+		assert.ErrorIs(t, repo.Complete(ctx, abandoned, http.StatusOK, "", nil), storage.ErrReservationLost)
+	})
+}
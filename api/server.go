@@ -1,18 +1,37 @@
 package api
 
 import (
+	"context"
 	"go-sample/api/handlers"
 	"go-sample/api/handlers/services"
+	"go-sample/api/jobs"
+	"go-sample/api/middlewares"
+	"go-sample/storage"
 	accountrepo "go-sample/storage/account-repo"
+	feerepo "go-sample/storage/fee-repo"
+	fxrepo "go-sample/storage/fx-repo"
+	holdrepo "go-sample/storage/hold-repo"
+	idempotencyrepo "go-sample/storage/idempotency-repo"
+	ledgerrepo "go-sample/storage/ledger-repo"
+	limitrepo "go-sample/storage/limit-repo"
+	operationrepo "go-sample/storage/operation-repo"
+	outboxrepo "go-sample/storage/outbox-repo"
+	ownerrepo "go-sample/storage/owner-repo"
+	schedulerepo "go-sample/storage/schedule-repo"
 	transactionrepo "go-sample/storage/transaction-repo"
+	webhookrepo "go-sample/storage/webhook-repo"
 
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
 	"github.com/jmoiron/sqlx"
 )
 
+// operationWorkers is how many asynchronous operations run at the same time
+const operationWorkers = 8
+
 type Server struct {
 	listenAddr string
 	db         *sqlx.DB
@@ -27,10 +46,58 @@ func NewServer(listenAddr string, db *sqlx.DB) *Server {
 func (s *Server) Start() error {
 	accountRepo := accountrepo.NewAccountRepo(s.db)
 	transactionRepo := transactionrepo.NewATransactionRepo(s.db)
+	idempotencyRepo := idempotencyrepo.NewIdempotencyRepo(s.db)
+	ledgerRepo := ledgerrepo.NewLedgerRepo(s.db)
+	ownerRepo := ownerrepo.NewOwnerRepo(s.db)
+	fxRepo := fxrepo.NewFXRepo(s.db)
+	holdRepo := holdrepo.NewHoldRepo(s.db)
+	feeRepo := feerepo.NewFeeRepo(s.db)
+	limitRepo := limitrepo.NewLimitRepo(s.db)
+	scheduleRepo := schedulerepo.NewScheduleRepo(s.db)
+	operationRepo := operationrepo.NewOperationRepo(s.db)
+	outboxRepo := outboxrepo.NewOutboxRepo(s.db)
+	webhookRepo := webhookrepo.NewWebhookRepo(s.db)
+
+	uow := storage.NewPostgresUnitOfWork(s.db)
 
-	accountSrv := services.NewAccount(accountRepo, transactionRepo)
+	accountSrv := services.NewAccount(accountRepo, transactionRepo, ledgerRepo, ownerRepo, fxRepo, holdRepo, feeRepo, limitRepo, scheduleRepo, operationRepo, outboxRepo, uow)
+	ledgerSrv := services.NewLedger(ledgerRepo)
+	ownerSrv := services.NewOwner(ownerRepo, accountRepo)
+	fxSrv := services.NewFX(fxRepo, uow)
+	feeSrv := services.NewFee(feeRepo, accountRepo, uow)
+	webhookSrv := services.NewWebhook(webhookRepo, outboxRepo, uow)
 
 	accountHandler := handlers.NewAccountRepoHandler(accountSrv)
+	ledgerHandler := handlers.NewLedgerHandler(ledgerSrv)
+	ownerHandler := handlers.NewOwnerHandler(ownerSrv)
+	fxHandler := handlers.NewFXHandler(fxSrv)
+	feeHandler := handlers.NewFeeHandler(feeSrv)
+	webhookHandler := handlers.NewWebhookHandler(webhookSrv)
+	idempotent := middlewares.Idempotency(idempotencyRepo)
+
+	go jobs.Every(context.Background(), time.Minute, "expire holds", func(ctx context.Context) error {
+		_, err := accountSrv.ExpireHolds(ctx)
+		return err
+	})
+	go jobs.Every(context.Background(), time.Minute, "execute scheduled transfers", func(ctx context.Context) error {
+		_, err := accountSrv.ExecuteDueTransfers(ctx)
+		return err
+	})
+	go jobs.Every(context.Background(), time.Minute, "run standing orders", func(ctx context.Context) error {
+		_, err := accountSrv.ExecuteDueStandingOrders(ctx)
+		return err
+	})
+	go jobs.Pool(context.Background(), operationWorkers, time.Second, "run operations", func(ctx context.Context) ([]string, error) {
+		return accountSrv.NextOperations(ctx, 10*operationWorkers)
+	}, accountSrv.RunOperation)
+	go jobs.Every(context.Background(), 5*time.Second, "deliver webhooks", func(ctx context.Context) error {
+		if _, err := webhookSrv.DispatchEvents(ctx); err != nil {
+			return err
+		}
+		_, err := webhookSrv.DeliverDue(ctx)
+		return err
+	})
+
 	r := chi.NewRouter()
 	r.Use(middleware.Logger)
 	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
@@ -40,12 +107,58 @@ func (s *Server) Start() error {
 	r.Get("/accounts", accountHandler.ListAccounts)
 	r.Get("/accounts/{id}", accountHandler.GetAccount)
 	r.Get("/accounts/{id}/balance", accountHandler.GetAccountBalance)
+	r.Put("/accounts/{id}/credit_limit", accountHandler.SetCreditLimit)
+	r.With(idempotent).Post("/accounts/{id}/holds", accountHandler.AuthorizeHold)
+	r.Get("/accounts/{id}/holds", accountHandler.ListHolds)
+	r.With(idempotent).Post("/accounts/{id}/scheduled_transfers", accountHandler.ScheduleTransfer)
+	r.Get("/accounts/{id}/scheduled_transfers", accountHandler.ListScheduledTransfers)
+	r.With(idempotent).Post("/accounts/{id}/standing_orders", accountHandler.CreateStandingOrder)
+	r.Get("/accounts/{id}/standing_orders", accountHandler.ListStandingOrders)
+	r.Get("/accounts/{id}/limits", accountHandler.GetLimits)
+	r.Put("/accounts/{id}/limits", accountHandler.SetLimit)
+	r.Get("/accounts/{id}/status_history", accountHandler.GetAccountStatusHistory)
+	r.Post("/accounts/{id}/freeze", accountHandler.FreezeAccount)
+	r.Post("/accounts/{id}/unfreeze", accountHandler.UnfreezeAccount)
+	r.With(idempotent).Post("/accounts/{id}/close", accountHandler.CloseAccount)
 	r.Get("/accounts/{id}/transactions", accountHandler.GetTransactionsHistory)
-	r.Post("/accounts/{id}/deposit", accountHandler.DepositMoney)
-	r.Post("/accounts/{id}/withdraw", accountHandler.WithdrawMoney)
-	r.Post("/accounts/{id}/transfer_money", accountHandler.TransferMoney)
-	r.Post("/accounts/{id}/refund_money", accountHandler.RefundMoney)
+	r.With(idempotent).Post("/accounts/{id}/deposit", accountHandler.DepositMoney)
+	r.With(idempotent).Post("/accounts/{id}/withdraw", accountHandler.WithdrawMoney)
+	r.With(idempotent).Post("/accounts/{id}/transfer_money", accountHandler.TransferMoney)
+	r.With(idempotent).Post("/accounts/{id}/bulk_transfers", accountHandler.BulkTransfer)
+	r.With(idempotent).Post("/accounts/{id}/refund_money", accountHandler.RefundMoney)
 	r.Get("/accounts/{id}/transactions", accountHandler.GetTransactionsHistory)
+	r.Post("/owners", ownerHandler.CreateOwner)
+	r.Get("/owners", ownerHandler.ListOwners)
+	r.Get("/owners/{id}", ownerHandler.GetOwner)
+	r.Put("/owners/{id}", ownerHandler.UpdateOwner)
+	r.Delete("/owners/{id}", ownerHandler.DeleteOwner)
+	r.Post("/owners/{id}/kyc", ownerHandler.UpdateKYCStatus)
+	r.Get("/owners/{id}/accounts", accountHandler.GetOwnerAccounts)
+	r.With(idempotent).Post("/owners/{id}/internal_transfer", accountHandler.InternalTransfer)
+	r.Get("/transactions/{id}", accountHandler.GetTransaction)
+	r.Get("/holds/{id}", accountHandler.GetHold)
+	r.With(idempotent).Post("/holds/{id}/capture", accountHandler.CaptureHold)
+	r.With(idempotent).Post("/holds/{id}/void", accountHandler.VoidHold)
+	r.Get("/scheduled_transfers/{id}", accountHandler.GetScheduledTransfer)
+	r.With(idempotent).Post("/scheduled_transfers/{id}/cancel", accountHandler.CancelScheduledTransfer)
+	r.Get("/standing_orders/{id}", accountHandler.GetStandingOrder)
+	r.Get("/standing_orders/{id}/runs", accountHandler.ListStandingOrderRuns)
+	r.Post("/standing_orders/{id}/cancel", accountHandler.CancelStandingOrder)
+	r.Post("/standing_orders/{id}/resume", accountHandler.ResumeStandingOrder)
+	r.Get("/transfer_batches/{id}", accountHandler.GetTransferBatch)
+	r.Get("/operations/{id}", accountHandler.GetOperation)
+	r.Get("/ledger/trial_balance", ledgerHandler.GetTrialBalance)
+	r.Get("/ledger/accounts/{id}", ledgerHandler.GetPostedBalance)
+	r.Get("/admin/fx_rates", fxHandler.ListRates)
+	r.Put("/admin/fx_rates", fxHandler.LoadRates)
+	r.Get("/admin/fee_schedules", feeHandler.ListSchedules)
+	r.Put("/admin/fee_schedules", feeHandler.LoadSchedules)
+	r.Get("/fees/quote", feeHandler.Quote)
+	r.Post("/admin/webhooks", webhookHandler.RegisterEndpoint)
+	r.Get("/admin/webhooks", webhookHandler.ListEndpoints)
+	r.Delete("/admin/webhooks/{id}", webhookHandler.DeleteEndpoint)
+	r.Get("/admin/webhooks/dead_letters", webhookHandler.ListDeadLetters)
+	r.Post("/admin/webhooks/deliveries/{id}/replay", webhookHandler.ReplayDelivery)
 
 	return http.ListenAndServe(":"+s.listenAddr, r)
 }
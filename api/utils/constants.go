@@ -8,4 +8,5 @@ const (
 	WITHDRAW OP = "WITHDRAW"
 	TRANSFER OP = "TRANSFER"
 	REFUND   OP = "REFUND"
+	FEE      OP = "FEE"
 )
@@ -1,13 +1,51 @@
 DROP DATABASE IF EXISTS nell_challenge_test;
 CREATE DATABASE nell_challenge_test;
 
+CREATE TABLE IF NOT EXISTS public.owners (
+  id VARCHAR(36) PRIMARY KEY,
+  name VARCHAR(200) NOT NULL,
+  national_id VARCHAR(14) NOT NULL UNIQUE,
+  email VARCHAR(200) NOT NULL DEFAULT '',
+  phone VARCHAR(30) NOT NULL DEFAULT '',
+  address TEXT NOT NULL DEFAULT '',
+  kyc_status VARCHAR(10) NOT NULL DEFAULT 'PENDING',
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  deleted_at TIMESTAMP NULL
+);
+
 CREATE TABLE IF NOT EXISTS public.accounts (
   id VARCHAR(36) PRIMARY KEY,
   owner_id VARCHAR(36) NOT NULL,
   balance MONEY NOT NULL,
+  credit_limit MONEY NOT NULL DEFAULT 0,
+  held_amount MONEY NOT NULL DEFAULT 0,
   created_at TIMESTAMP NOT NULL,
   updated_at TIMESTAMP NOT NULL,
-  deletedAt TIMESTAMP NULL
+  deletedAt TIMESTAMP NULL,
+  status VARCHAR(10) NOT NULL DEFAULT 'ACTIVE',
+  account_type VARCHAR(10) NOT NULL DEFAULT 'CHECKING',
+  nickname VARCHAR(100) NOT NULL DEFAULT '',
+  currency VARCHAR(3) NOT NULL DEFAULT 'AOA'
+);
+
+ALTER TABLE public.accounts ADD COLUMN IF NOT EXISTS status VARCHAR(10) NOT NULL DEFAULT 'ACTIVE';
+UPDATE public.accounts SET status = 'CLOSED' WHERE deletedAt IS NOT NULL AND status <> 'CLOSED';
+ALTER TABLE public.accounts
+  ADD COLUMN IF NOT EXISTS account_type VARCHAR(10) NOT NULL DEFAULT 'CHECKING',
+  ADD COLUMN IF NOT EXISTS nickname VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE public.accounts ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'AOA';
+ALTER TABLE public.accounts ADD COLUMN IF NOT EXISTS credit_limit MONEY NOT NULL DEFAULT 0;
+ALTER TABLE public.accounts ADD COLUMN IF NOT EXISTS held_amount MONEY NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS public.account_status_history (
+  id BIGSERIAL PRIMARY KEY,
+  account_id VARCHAR(36) NOT NULL,
+  from_status VARCHAR(10) NOT NULL,
+  to_status VARCHAR(10) NOT NULL,
+  reason TEXT NOT NULL,
+  actor VARCHAR(100) NOT NULL,
+  changed_at TIMESTAMP NOT NULL
 );
 
 
@@ -15,12 +53,265 @@ CREATE TABLE IF NOT EXISTS public.transaction_ (
   id VARCHAR(36) PRIMARY KEY,
   from_account VARCHAR(36) NOT NULL,
   to_account VARCHAR(36) NOT NULL,
-  tr_status TEXT NOT NULL,
+  subject TEXT NOT NULL DEFAULT '',
+  tr_status VARCHAR(20) NOT NULL,
+  failure_reason TEXT NOT NULL DEFAULT '',
   operation VARCHAR(10) NOT NULL,
+  currency VARCHAR(3) NOT NULL DEFAULT 'AOA',
   amount MONEY NOT NULL,
+  refunded_amount MONEY NOT NULL DEFAULT 0,
+  destination_currency VARCHAR(3) NOT NULL DEFAULT 'AOA',
+  destination_amount MONEY NULL,
+  exchange_rate VARCHAR(32) NOT NULL DEFAULT '',
   multiBeneficiaryId VARCHAR(36) NOT NULL,
   is_Refund BOOLEAN NOT NULL, 
   refunded_transaction_id VARCHAR(36) NOT NULL,
-  createdAt TIMESTAMP NOT NULL
+  linked_transaction_id VARCHAR(36) NOT NULL DEFAULT '',
+  createdAt TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL
+);
+
+-- databases created before the status lifecycle kept the subject in tr_status and only stored completed transactions
+ALTER TABLE public.transaction_
+  ADD COLUMN IF NOT EXISTS subject TEXT NOT NULL DEFAULT '',
+  ADD COLUMN IF NOT EXISTS failure_reason TEXT NOT NULL DEFAULT '',
+  ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP NULL;
+UPDATE public.transaction_ SET subject = tr_status, tr_status = 'COMPLETED'
+  WHERE tr_status NOT IN ('PENDING', 'COMPLETED', 'FAILED', 'REVERSED');
+UPDATE public.transaction_ SET updated_at = createdAt WHERE updated_at IS NULL;
+ALTER TABLE public.transaction_
+  ALTER COLUMN tr_status TYPE VARCHAR(20),
+  ALTER COLUMN updated_at SET NOT NULL;
+
+-- refunds used to always give back the whole amount
+ALTER TABLE public.transaction_ ADD COLUMN IF NOT EXISTS refunded_amount MONEY NOT NULL DEFAULT 0;
+UPDATE public.transaction_ t SET refunded_amount = r.total,
+  tr_status = CASE WHEN r.total >= t.amount THEN 'REVERSED' ELSE t.tr_status END
+  FROM (SELECT refunded_transaction_id, SUM(amount) AS total FROM public.transaction_
+    WHERE is_refund GROUP BY refunded_transaction_id) r
+  WHERE t.id = r.refunded_transaction_id AND t.refunded_amount = 0::money;
+ALTER TABLE public.transaction_
+  ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'AOA',
+  ADD COLUMN IF NOT EXISTS destination_currency VARCHAR(3) NOT NULL DEFAULT 'AOA',
+  ADD COLUMN IF NOT EXISTS destination_amount MONEY NULL,
+  ADD COLUMN IF NOT EXISTS exchange_rate VARCHAR(32) NOT NULL DEFAULT '';
+ALTER TABLE public.transaction_ ADD COLUMN IF NOT EXISTS linked_transaction_id VARCHAR(36) NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS public.transaction_status_history (
+  id BIGSERIAL PRIMARY KEY,
+  transaction_id VARCHAR(36) NOT NULL,
+  status VARCHAR(20) NOT NULL,
+  reason TEXT NOT NULL DEFAULT '',
+  changed_at TIMESTAMP NOT NULL
+);
+
+
+CREATE TABLE IF NOT EXISTS public.transfer_batches (
+  id VARCHAR(36) PRIMARY KEY,
+  from_account VARCHAR(36) NOT NULL,
+  currency VARCHAR(3) NOT NULL DEFAULT 'AOA',
+  amount MONEY NOT NULL,
+  subject TEXT NOT NULL,
+  status VARCHAR(20) NOT NULL,
+  failure_reason TEXT NOT NULL DEFAULT '',
+  legs JSONB NOT NULL DEFAULT '[]',
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL
+);
+
+ALTER TABLE public.transfer_batches ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'AOA';
+ALTER TABLE public.transfer_batches ADD COLUMN IF NOT EXISTS legs JSONB NOT NULL DEFAULT '[]';
+
+CREATE TABLE IF NOT EXISTS public.idempotency_keys (
+  key VARCHAR(255) PRIMARY KEY,
+  method VARCHAR(10) NOT NULL,
+  path TEXT NOT NULL,
+  request_hash VARCHAR(64) NOT NULL,
+  status_code INTEGER NOT NULL DEFAULT 0,
+  content_type TEXT NOT NULL DEFAULT '',
+  response_body BYTEA NULL,
+  created_at TIMESTAMP NOT NULL,
+  reserved_at TIMESTAMP NOT NULL,
+  completed_at TIMESTAMP NULL
+);
+
+ALTER TABLE public.idempotency_keys ADD COLUMN IF NOT EXISTS reserved_at TIMESTAMP NULL;
+UPDATE public.idempotency_keys SET reserved_at = created_at WHERE reserved_at IS NULL;
+ALTER TABLE public.idempotency_keys ALTER COLUMN reserved_at SET NOT NULL;
+
+CREATE TABLE IF NOT EXISTS public.journal_entries (
+  id VARCHAR(36) PRIMARY KEY,
+  transaction_id VARCHAR(36) NOT NULL,
+  operation VARCHAR(10) NOT NULL,
+  created_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS public.postings (
+  id VARCHAR(36) PRIMARY KEY,
+  entry_id VARCHAR(36) NOT NULL REFERENCES journal_entries(id),
+  account_id VARCHAR(36) NOT NULL,
+  currency VARCHAR(3) NOT NULL DEFAULT 'AOA',
+  amount MONEY NOT NULL,
+  created_at TIMESTAMP NOT NULL
+);
+
+ALTER TABLE public.postings ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'AOA';
+
+CREATE TABLE IF NOT EXISTS public.fx_rates (
+  base_currency VARCHAR(3) NOT NULL,
+  quote_currency VARCHAR(3) NOT NULL,
+  rate NUMERIC(20, 10) NOT NULL CHECK (rate > 0),
+  updated_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (base_currency, quote_currency)
+);
+
+CREATE TABLE IF NOT EXISTS public.holds (
+  id VARCHAR(36) PRIMARY KEY,
+  account_id VARCHAR(36) NOT NULL,
+  to_account VARCHAR(36) NOT NULL,
+  currency VARCHAR(3) NOT NULL DEFAULT 'AOA',
+  amount MONEY NOT NULL,
+  captured_amount MONEY NOT NULL DEFAULT 0,
+  subject TEXT NOT NULL DEFAULT '',
+  status VARCHAR(20) NOT NULL,
+  transaction_id VARCHAR(36) NOT NULL DEFAULT '',
+  expires_at TIMESTAMP NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS public.fee_schedules (
+  operation VARCHAR(10) NOT NULL,
+  account_type VARCHAR(20) NOT NULL DEFAULT '',
+  currency VARCHAR(3) NOT NULL,
+  kind VARCHAR(20) NOT NULL,
+  amount MONEY NOT NULL DEFAULT 0,
+  percentage NUMERIC(13, 10) NULL,
+  tiers JSONB NOT NULL DEFAULT '[]',
+  updated_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (operation, account_type, currency)
+);
+
+CREATE TABLE IF NOT EXISTS public.account_limits (
+  account_id VARCHAR(36) NOT NULL,
+  operation VARCHAR(10) NOT NULL,
+  currency VARCHAR(3) NOT NULL DEFAULT 'AOA',
+  per_transaction MONEY NULL,
+  daily_amount MONEY NULL,
+  monthly_amount MONEY NULL,
+  daily_count INTEGER NULL CHECK (daily_count >= 0),
+  updated_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (account_id, operation)
+);
+
+CREATE TABLE IF NOT EXISTS public.scheduled_transfers (
+  id VARCHAR(36) PRIMARY KEY,
+  from_account VARCHAR(36) NOT NULL,
+  currency VARCHAR(3) NOT NULL DEFAULT 'AOA',
+  amount MONEY NOT NULL,
+  subject TEXT NOT NULL DEFAULT '',
+  legs JSONB NOT NULL DEFAULT '[]',
+  execute_at TIMESTAMP NOT NULL,
+  status VARCHAR(20) NOT NULL,
+  batch_id VARCHAR(36) NOT NULL DEFAULT '',
+  failure_reason TEXT NOT NULL DEFAULT '',
+  executed_at TIMESTAMP NULL,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS public.standing_orders (
+  id VARCHAR(36) PRIMARY KEY,
+  from_account VARCHAR(36) NOT NULL,
+  currency VARCHAR(3) NOT NULL DEFAULT 'AOA',
+  amount MONEY NOT NULL,
+  subject TEXT NOT NULL DEFAULT '',
+  beneficiaries JSONB NOT NULL DEFAULT '[]',
+  recurrence JSONB NOT NULL,
+  retry_window INTEGER NOT NULL DEFAULT 0,
+  max_failures INTEGER NOT NULL CHECK (max_failures > 0),
+  status VARCHAR(20) NOT NULL,
+  occurrence INTEGER NOT NULL DEFAULT 0,
+  due_at TIMESTAMP NOT NULL,
+  next_run_at TIMESTAMP NOT NULL,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  consecutive_failures INTEGER NOT NULL DEFAULT 0,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS public.standing_order_runs (
+  id VARCHAR(36) PRIMARY KEY,
+  order_id VARCHAR(36) NOT NULL REFERENCES standing_orders(id),
+  occurrence INTEGER NOT NULL,
+  due_at TIMESTAMP NOT NULL,
+  attempt INTEGER NOT NULL,
+  status VARCHAR(20) NOT NULL,
+  batch_id VARCHAR(36) NOT NULL DEFAULT '',
+  legs JSONB NOT NULL DEFAULT '[]',
+  failure_reason TEXT NOT NULL DEFAULT '',
+  will_retry BOOLEAN NOT NULL DEFAULT FALSE,
+  ran_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS public.operations (
+  id VARCHAR(36) PRIMARY KEY,
+  kind VARCHAR(20) NOT NULL,
+  request JSONB NOT NULL,
+  status VARCHAR(20) NOT NULL,
+  result JSONB NULL,
+  error TEXT NOT NULL DEFAULT '',
+  locked_until TIMESTAMP NULL,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  completed_at TIMESTAMP NULL
+);
+
+CREATE TABLE IF NOT EXISTS public.outbox_events (
+  id VARCHAR(36) PRIMARY KEY,
+  type VARCHAR(40) NOT NULL,
+  payload JSONB NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  dispatched_at TIMESTAMP NULL
+);
+
+CREATE TABLE IF NOT EXISTS public.webhook_endpoints (
+  id VARCHAR(36) PRIMARY KEY,
+  url TEXT NOT NULL,
+  secret VARCHAR(64) NOT NULL,
+  event_types JSONB NOT NULL DEFAULT '[]',
+  created_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS public.webhook_deliveries (
+  id VARCHAR(36) PRIMARY KEY,
+  endpoint_id VARCHAR(36) NOT NULL REFERENCES webhook_endpoints(id) ON DELETE CASCADE,
+  event_id VARCHAR(36) NOT NULL,
+  event_type VARCHAR(40) NOT NULL,
+  payload JSONB NOT NULL,
+  status VARCHAR(20) NOT NULL,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  next_attempt_at TIMESTAMP NOT NULL,
+  last_error TEXT NOT NULL DEFAULT '',
+  response_status INTEGER NOT NULL DEFAULT 0,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  delivered_at TIMESTAMP NULL
 );
 
+CREATE INDEX IF NOT EXISTS postings_account_id_idx ON public.postings (account_id);
+CREATE INDEX IF NOT EXISTS accounts_owner_id_idx ON public.accounts (owner_id);
+CREATE INDEX IF NOT EXISTS accounts_created_at_id_idx ON public.accounts (created_at, id);
+CREATE INDEX IF NOT EXISTS transaction_from_account_createdat_idx ON public.transaction_ (from_account, createdat, id);
+CREATE INDEX IF NOT EXISTS transaction_to_account_createdat_idx ON public.transaction_ (to_account, createdat, id);
+CREATE INDEX IF NOT EXISTS holds_account_id_created_at_idx ON public.holds (account_id, created_at, id);
+CREATE INDEX IF NOT EXISTS holds_active_expires_at_idx ON public.holds (expires_at) WHERE status = 'ACTIVE';
+CREATE INDEX IF NOT EXISTS scheduled_transfers_from_account_created_at_idx ON public.scheduled_transfers (from_account, created_at, id);
+CREATE INDEX IF NOT EXISTS scheduled_transfers_due_idx ON public.scheduled_transfers (execute_at) WHERE status = 'SCHEDULED';
+CREATE INDEX IF NOT EXISTS standing_orders_from_account_created_at_idx ON public.standing_orders (from_account, created_at, id);
+CREATE INDEX IF NOT EXISTS standing_orders_due_idx ON public.standing_orders (next_run_at) WHERE status = 'ACTIVE';
+CREATE INDEX IF NOT EXISTS standing_order_runs_order_id_ran_at_idx ON public.standing_order_runs (order_id, ran_at, id);
+CREATE INDEX IF NOT EXISTS operations_runnable_idx ON public.operations (created_at, id) WHERE status IN ('PENDING', 'RUNNING');
+CREATE INDEX IF NOT EXISTS outbox_events_pending_idx ON public.outbox_events (created_at, id) WHERE dispatched_at IS NULL;
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON public.webhook_deliveries (next_attempt_at) WHERE status = 'PENDING';
+CREATE INDEX IF NOT EXISTS webhook_deliveries_dead_idx ON public.webhook_deliveries (updated_at, id) WHERE status = 'DEAD';
@@ -2,7 +2,9 @@ package accountrepo
 
 import (
 	"context"
+	"database/sql"
 	requestparams "go-sample/api/handlers/request-params"
+	"go-sample/storage"
 	"go-sample/types"
 )
 
@@ -14,27 +16,33 @@ type MockCreateAccount struct {
 	ExpectedReturn             string
 }
 
-type MockGetAccountByOwnerId struct {
+type MockGetAccountBalance struct {
 	Called                     bool
 	Calls                      int
 	ExpectedReturnError        error
 	ExpectedReturnErrorMessage string
-	ExpectedReturn             *types.Account
+	ExpectedReturn             types.Money
 }
 
-type MockGetAccountBalance struct {
-	Called                     bool
-	Calls                      int
-	ExpectedReturnError        error
-	ExpectedReturnErrorMessage string
-	ExpectedReturn             float64
+type MockDecrBalance struct {
+	Called              bool
+	Calls               int
+	ExpectedReturnError error
+}
+
+type MockLockAccounts struct {
+	Called bool
+	Calls  int
+	Locked []string
 }
 
 type MockMemoAccountRepo struct {
-	accounts             []*types.Account
-	McreateAccount       MockCreateAccount
-	MgetAccountBYOwnerId MockGetAccountByOwnerId
-	MgetAccountBalance   MockGetAccountBalance
+	accounts           []*types.Account
+	statusHistory      []*types.AccountStatusChange
+	McreateAccount     MockCreateAccount
+	MgetAccountBalance MockGetAccountBalance
+	MdecrBalance       MockDecrBalance
+	MlockAccounts      MockLockAccounts
 }
 
 func NewMockMemoAccountRepo() *MockMemoAccountRepo {
@@ -47,33 +55,116 @@ func NewMockMemoAccountRepo() *MockMemoAccountRepo {
 func (m *MockMemoAccountRepo) CreateAccount(ctx context.Context, account *types.Account) (string, error) {
 	m.McreateAccount.Called = true
 	m.McreateAccount.Calls++
+	if m.McreateAccount.ExpectedReturnError == nil {
+		m.accounts = append(m.accounts, account)
+	}
 	return m.McreateAccount.ExpectedReturn, m.McreateAccount.ExpectedReturnError
 }
 
-func (m *MockMemoAccountRepo) ListAccounts(context.Context, requestparams.ListAccountsRequest) ([]*types.Account, error) {
-	return nil, nil
+func (m *MockMemoAccountRepo) ListAccounts(context.Context, requestparams.ListAccountsRequest) (*types.Page[*types.Account], error) {
+	return &types.Page[*types.Account]{Data: []*types.Account{}}, nil
 }
 
 func (m *MockMemoAccountRepo) GetAccountById(ctx context.Context, id string) (*types.Account, error) {
-	return nil, nil
+	for _, account := range m.accounts {
+		if account.ID == id {
+			return account, nil
+		}
+	}
+	return nil, sql.ErrNoRows
 }
 
-func (m *MockMemoAccountRepo) GetAccountByOwnerId(ctx context.Context, ownerId string) (*types.Account, error) {
-	m.MgetAccountBYOwnerId.Called = true
-	m.MgetAccountBYOwnerId.Calls++
-	return m.MgetAccountBYOwnerId.ExpectedReturn, m.MgetAccountBYOwnerId.ExpectedReturnError
+func (m *MockMemoAccountRepo) GetAccountsByOwnerId(ctx context.Context, ownerId string) ([]*types.Account, error) {
+	owned := []*types.Account{}
+	for _, account := range m.accounts {
+		if account.Owner == ownerId {
+			owned = append(owned, account)
+		}
+	}
+	return owned, nil
 }
 
-func (m *MockMemoAccountRepo) GetAccountBalance(ctx context.Context, accountId string) (float64, error) {
+func (m *MockMemoAccountRepo) GetAccountBalance(ctx context.Context, accountId string) (types.Money, error) {
 	m.MgetAccountBalance.Called = true
 	m.MgetAccountBalance.Calls++
 	return m.MgetAccountBalance.ExpectedReturn, m.MgetAccountBalance.ExpectedReturnError
 }
 
-func (m *MockMemoAccountRepo) IncrBalance(ctx context.Context, accountId string, incr float64) error {
+func (m *MockMemoAccountRepo) IncrBalance(ctx context.Context, accountId string, incr types.Money) error {
 	return nil
 }
 
-func (m *MockMemoAccountRepo) DecrBalance(ctx context.Context, accountId string, incr float64) error {
+func (m *MockMemoAccountRepo) DecrBalance(ctx context.Context, accountId string, incr types.Money) error {
+	m.MdecrBalance.Called = true
+	m.MdecrBalance.Calls++
+	return m.MdecrBalance.ExpectedReturnError
+}
+
+// LockAccounts returns the accounts stored through CreateAccount, unknown ids are skipped like missing rows
+func (m *MockMemoAccountRepo) LockAccounts(ctx context.Context, accountIds ...string) ([]*types.Account, error) {
+	m.MlockAccounts.Called = true
+	m.MlockAccounts.Calls++
+	m.MlockAccounts.Locked = append([]string{}, accountIds...)
+
+	locked := []*types.Account{}
+	for _, id := range accountIds {
+		for _, account := range m.accounts {
+			if account.ID == id {
+				locked = append(locked, account)
+			}
+		}
+	}
+	return locked, nil
+}
+
+func (m *MockMemoAccountRepo) UpdateAccountStatus(ctx context.Context, account *types.Account, change *types.AccountStatusChange) error {
+	for _, stored := range m.accounts {
+		if stored.ID == account.ID {
+			*stored = *account
+		}
+	}
+	m.statusHistory = append(m.statusHistory, change)
+	return nil
+}
+
+func (m *MockMemoAccountRepo) UpdateCreditLimit(ctx context.Context, account *types.Account) error {
+	for _, stored := range m.accounts {
+		if stored.ID == account.ID {
+			stored.CreditLimit = account.CreditLimit
+			stored.UpdatedAt = account.UpdatedAt
+		}
+	}
+	return nil
+}
+
+// HoldFunds refuses holds the available balance of a stored account can not cover, unknown ids are ignored
+func (m *MockMemoAccountRepo) HoldFunds(ctx context.Context, accountId string, amount types.Money) error {
+	for _, stored := range m.accounts {
+		if stored.ID == accountId {
+			if amount.GreaterThan(stored.AvailableBalance()) {
+				return storage.ErrInsufficientFunds
+			}
+			stored.HeldAmount = stored.HeldAmount.Add(amount)
+		}
+	}
+	return nil
+}
+
+func (m *MockMemoAccountRepo) ReleaseFunds(ctx context.Context, accountId string, amount types.Money) error {
+	for _, stored := range m.accounts {
+		if stored.ID == accountId {
+			stored.HeldAmount = stored.HeldAmount.Sub(amount)
+		}
+	}
 	return nil
 }
+
+func (m *MockMemoAccountRepo) GetAccountStatusHistory(ctx context.Context, accountId string) ([]*types.AccountStatusChange, error) {
+	history := []*types.AccountStatusChange{}
+	for _, change := range m.statusHistory {
+		if change.AccountId == accountId {
+			history = append(history, change)
+		}
+	}
+	return history, nil
+}
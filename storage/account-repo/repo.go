@@ -3,20 +3,27 @@ package accountrepo
 import (
 	"context"
 	requestparams "go-sample/api/handlers/request-params"
+	"go-sample/storage"
 	"go-sample/types"
-	"strconv"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 type IAccountRepo interface {
 	CreateAccount(context.Context, *types.Account) (string, error)
-	ListAccounts(context.Context, requestparams.ListAccountsRequest) ([]*types.Account, error)
+	ListAccounts(context.Context, requestparams.ListAccountsRequest) (*types.Page[*types.Account], error)
 	GetAccountById(context.Context, string) (*types.Account, error)
-	GetAccountByOwnerId(context.Context, string) (*types.Account, error)
-	GetAccountBalance(context.Context, string) (float64, error)
-	IncrBalance(context.Context, string, float64) error
-	DecrBalance(context.Context, string, float64) error
+	GetAccountsByOwnerId(context.Context, string) ([]*types.Account, error)
+	GetAccountBalance(context.Context, string) (types.Money, error)
+	IncrBalance(context.Context, string, types.Money) error
+	DecrBalance(context.Context, string, types.Money) error
+	LockAccounts(context.Context, ...string) ([]*types.Account, error)
+	UpdateAccountStatus(context.Context, *types.Account, *types.AccountStatusChange) error
+	UpdateCreditLimit(context.Context, *types.Account) error
+	HoldFunds(context.Context, string, types.Money) error
+	ReleaseFunds(context.Context, string, types.Money) error
+	GetAccountStatusHistory(context.Context, string) ([]*types.AccountStatusChange, error)
 }
 type AccountRepo struct {
 	db *sqlx.DB
@@ -26,82 +33,282 @@ func NewAccountRepo(db *sqlx.DB) AccountRepo {
 	return AccountRepo{db}
 }
 
+const accountColumns = `id, owner_id, account_type, nickname, currency, balance::decimal, credit_limit::decimal, held_amount::decimal, status, created_at, updated_at, deletedat`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAccount(row scanner) (*types.Account, error) {
+	var account types.Account
+	err := row.Scan(
+		&account.ID,
+		&account.Owner,
+		&account.Type,
+		&account.Nickname,
+		&account.Currency,
+		&account.Balance,
+		&account.CreditLimit,
+		&account.HeldAmount,
+		&account.Status,
+		&account.CreatedAt,
+		&account.UpdatedAt,
+		&account.DeletedAt,
+	)
+	account.Balance.Currency = account.Currency
+	account.CreditLimit.Currency = account.Currency
+	account.HeldAmount.Currency = account.Currency
+	return &account, err
+}
+
 func (ar AccountRepo) CreateAccount(ctx context.Context, account *types.Account) (string, error) {
-	_, err := ar.db.ExecContext(ctx, "INSERT INTO accounts VALUES($1, $2, $3, $4, $5, $6)", account.ID, account.Owner, account.Balance, account.CreatedAt, account.UpdatedAt, account.DeletedAt)
+	status := account.Status
+	if status == "" {
+		status = types.AccountActive
+	}
+	accountType := account.Type
+	if accountType == "" {
+		accountType = types.CheckingAccount
+	}
+	currency := account.Currency
+	if currency == "" {
+		currency = types.DefaultCurrency
+	}
+	_, err := storage.Conn(ctx, ar.db).ExecContext(ctx,
+		`INSERT INTO accounts (id, owner_id, account_type, nickname, currency, balance, credit_limit, created_at, updated_at, deletedat, status)
+			VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		account.ID, account.Owner, accountType, account.Nickname, currency, account.Balance, account.CreditLimit, account.CreatedAt, account.UpdatedAt, account.DeletedAt, status)
 
 	if err != nil {
 		return "", err
 	}
 	var accountId string
-	err = ar.db.QueryRowContext(ctx, "SELECT id FROM accounts WHERE id = $1", account.ID).Scan(&accountId)
+	err = storage.Conn(ctx, ar.db).QueryRowContext(ctx, "SELECT id FROM accounts WHERE id = $1", account.ID).Scan(&accountId)
 
 	return accountId, err
 }
 
-func (ar AccountRepo) ListAccounts(ctx context.Context, filters requestparams.ListAccountsRequest) ([]*types.Account, error) {
-	page, _ := strconv.ParseInt(filters.Page, 10, 64)
-	limit, _ := strconv.ParseInt(filters.Limit, 10, 64)
-
-	offset := limit * (page - 1)
+func (ar AccountRepo) ListAccounts(ctx context.Context, filters requestparams.ListAccountsRequest) (*types.Page[*types.Account], error) {
+	var where storage.Where
+	if filters.OwnerId != "" {
+		where.Add("owner_id = ?", filters.OwnerId)
+	}
+	if filters.CreatedFrom != nil {
+		where.Add("created_at >= ?", *filters.CreatedFrom)
+	}
+	if filters.CreatedUntil != nil {
+		where.Add("created_at < ?", *filters.CreatedUntil)
+	}
+	if filters.MinBalanceOf != nil {
+		where.Add("balance >= ?", *filters.MinBalanceOf)
+	}
+	if filters.MaxBalanceOf != nil {
+		where.Add("balance <= ?", *filters.MaxBalanceOf)
+	}
+	if filters.StatusFilter != "" {
+		where.Add("status = ?", filters.StatusFilter)
+	}
+	if filters.CurrencyOf != "" {
+		where.Add("currency = ?", filters.CurrencyOf)
+	}
 
-	var accounts []*types.Account
+	var total *int64
+	if filters.IncludeTotal() {
+		var count int64
+		err := storage.Conn(ctx, ar.db).QueryRowContext(ctx, "SELECT COUNT(*) FROM accounts "+where.String(), where.Args()...).Scan(&count)
+		if err != nil {
+			return nil, err
+		}
+		total = &count
+	}
 
-	rows, err := ar.db.QueryContext(
+	column, order := filters.SortColumn, filters.SortOrder
+	if column != "balance" {
+		column = "created_at"
+	}
+	if order != "DESC" {
+		order = "ASC"
+	}
+	tail, err := storage.PageQuery(&where, filters.Pagination, column, order)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := storage.Conn(ctx, ar.db).QueryContext(
 		ctx,
-		`SELECT 
-			id, owner_id, balance::decimal, created_at, updated_at, deletedat FROM accounts 
-			LIMIT $1  OFFSET $2`, limit, offset)
+		`SELECT `+accountColumns+` FROM accounts 
+			`+where.String()+` `+tail, where.Args()...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	for rows.Next() {
-		var account types.Account
-		err = rows.Scan(
-			&account.ID,
-			&account.Owner,
-			&account.Balance,
-			&account.CreatedAt,
-			&account.UpdatedAt,
-			&account.DeletedAt,
-		)
 
+	var accounts []*types.Account
+	for rows.Next() {
+		account, err := scanAccount(rows)
 		if err != nil {
 			return nil, err
 		}
-		accounts = append(accounts, &account)
+		accounts = append(accounts, account)
 	}
-	return accounts, nil
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	page := storage.NewPage(accounts, filters.Pagination, func(account *types.Account) types.Cursor {
+		if column == "balance" {
+			return types.Cursor{Column: column, Value: account.Balance.String(), ID: account.ID}
+		}
+		return types.NewTimeCursor(column, account.CreatedAt, account.ID)
+	})
+	page.Total = total
+	return page, nil
 }
 
 func (ar AccountRepo) GetAccountById(ctx context.Context, id string) (*types.Account, error) {
-	var account types.Account
-	err := ar.db.QueryRowContext(ctx, "SELECT id, owner_id, balance::decimal, created_at, updated_at, deletedat FROM accounts WHERE id=$1", id).Scan(
-		&account.ID, &account.Owner, &account.Balance, &account.CreatedAt, &account.UpdatedAt, &account.DeletedAt)
-
-	return &account, err
+	return scanAccount(storage.Conn(ctx, ar.db).QueryRowContext(ctx, "SELECT "+accountColumns+" FROM accounts WHERE id=$1", id))
 }
 
-func (ar AccountRepo) GetAccountByOwnerId(ctx context.Context, ownerId string) (*types.Account, error) {
-	var account types.Account
-	err := ar.db.QueryRowContext(ctx, "SELECT id, owner_id, balance::decimal, created_at, updated_at, deletedat FROM accounts WHERE owner_id = $1", ownerId).Scan(
-		&account.ID, &account.Owner, &account.Balance, &account.CreatedAt, &account.UpdatedAt, &account.DeletedAt)
+func (ar AccountRepo) GetAccountsByOwnerId(ctx context.Context, ownerId string) ([]*types.Account, error) {
+	rows, err := storage.Conn(ctx, ar.db).QueryContext(ctx, "SELECT "+accountColumns+" FROM accounts WHERE owner_id = $1 ORDER BY created_at, id", ownerId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-	return &account, err
+	var accounts []*types.Account
+	for rows.Next() {
+		account, err := scanAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts, rows.Err()
 }
 
-func (ar AccountRepo) GetAccountBalance(ctx context.Context, accountId string) (float64, error) {
-	var balance float64
-	err := ar.db.QueryRowContext(ctx, "SELECT balance::decimal FROM accounts WHERE accounts.id = $1", accountId).Scan(&balance)
+func (ar AccountRepo) GetAccountBalance(ctx context.Context, accountId string) (types.Money, error) {
+	var balance types.Money
+	var currency types.Currency
+	err := storage.Conn(ctx, ar.db).QueryRowContext(ctx, "SELECT balance::decimal, currency FROM accounts WHERE accounts.id = $1", accountId).Scan(&balance, &currency)
+	balance.Currency = currency
 	return balance, err
 }
 
-func (ar AccountRepo) IncrBalance(ctx context.Context, accountId string, incr float64) error {
-	_, err := ar.db.ExecContext(ctx, "UPDATE accounts SET balance = balance + $1 WHERE id = $2", incr, accountId)
+func (ar AccountRepo) IncrBalance(ctx context.Context, accountId string, incr types.Money) error {
+	_, err := storage.Conn(ctx, ar.db).ExecContext(ctx, "UPDATE accounts SET balance = balance + $1 WHERE id = $2", incr, accountId)
+	return err
+}
+
+// DecrBalance only debits the account when its available balance (balance plus credit limit minus held funds) covers
+// the amount, the check and the update are a single statement so concurrent debits cannot overdraw it.
+// Returns storage.ErrInsufficientFunds otherwise
+func (ar AccountRepo) DecrBalance(ctx context.Context, accountId string, incr types.Money) error {
+	conn := storage.Conn(ctx, ar.db)
+	res, err := conn.ExecContext(ctx, "UPDATE accounts SET balance = balance - $1 WHERE id = $2 AND balance + credit_limit - held_amount >= $1", incr, accountId)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		var id string
+		if err := conn.QueryRowContext(ctx, "SELECT id FROM accounts WHERE id = $1", accountId).Scan(&id); err != nil {
+			return err
+		}
+		return storage.ErrInsufficientFunds
+	}
+	return nil
+}
+
+// LockAccounts takes row locks on the accounts until the surrounding unit of work ends and returns them,
+// so callers can check their status knowing it cannot change underneath them.
+// Rows are always locked ordered by id, so two operations touching the same accounts cannot deadlock
+func (ar AccountRepo) LockAccounts(ctx context.Context, accountIds ...string) ([]*types.Account, error) {
+	rows, err := storage.Conn(ctx, ar.db).QueryContext(ctx,
+		"SELECT "+accountColumns+" FROM accounts WHERE id = ANY($1) ORDER BY id FOR UPDATE", pq.Array(accountIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []*types.Account
+	for rows.Next() {
+		account, err := scanAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts, rows.Err()
+}
+
+// UpdateAccountStatus stores the new status of the account along with the change that led to it
+func (ar AccountRepo) UpdateAccountStatus(ctx context.Context, account *types.Account, change *types.AccountStatusChange) error {
+	conn := storage.Conn(ctx, ar.db)
+	_, err := conn.ExecContext(ctx,
+		"UPDATE accounts SET status = $1, updated_at = $2, deletedat = $3 WHERE id = $4",
+		account.Status, account.UpdatedAt, account.DeletedAt, account.ID)
+	if err != nil {
+		return err
+	}
+	_, err = conn.ExecContext(ctx,
+		`INSERT INTO account_status_history (account_id, from_status, to_status, reason, actor, changed_at)
+			VALUES ($1, $2, $3, $4, $5, $6)`,
+		change.AccountId, change.From, change.To, change.Reason, change.Actor, change.ChangedAt)
+	return err
+}
+
+func (ar AccountRepo) UpdateCreditLimit(ctx context.Context, account *types.Account) error {
+	_, err := storage.Conn(ctx, ar.db).ExecContext(ctx,
+		"UPDATE accounts SET credit_limit = $1, updated_at = $2 WHERE id = $3",
+		account.CreditLimit, account.UpdatedAt, account.ID)
 	return err
 }
 
-func (ar AccountRepo) DecrBalance(ctx context.Context, accountId string, incr float64) error {
-	_, err := ar.db.ExecContext(ctx, "UPDATE accounts SET balance = balance - $1 WHERE id = $2", incr, accountId)
+// HoldFunds takes amount out of the available balance without moving it, under the same conditional
+// update as DecrBalance. Returns storage.ErrInsufficientFunds when the available balance can not cover it
+func (ar AccountRepo) HoldFunds(ctx context.Context, accountId string, amount types.Money) error {
+	res, err := storage.Conn(ctx, ar.db).ExecContext(ctx,
+		"UPDATE accounts SET held_amount = held_amount + $1 WHERE id = $2 AND balance + credit_limit - held_amount >= $1",
+		amount, accountId)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return storage.ErrInsufficientFunds
+	}
+	return nil
+}
+
+// ReleaseFunds gives back to the available balance what HoldFunds took
+func (ar AccountRepo) ReleaseFunds(ctx context.Context, accountId string, amount types.Money) error {
+	_, err := storage.Conn(ctx, ar.db).ExecContext(ctx,
+		"UPDATE accounts SET held_amount = held_amount - $1 WHERE id = $2", amount, accountId)
 	return err
 }
+
+func (ar AccountRepo) GetAccountStatusHistory(ctx context.Context, accountId string) ([]*types.AccountStatusChange, error) {
+	rows, err := storage.Conn(ctx, ar.db).QueryContext(ctx,
+		`SELECT account_id, from_status, to_status, reason, actor, changed_at FROM account_status_history
+			WHERE account_id = $1 ORDER BY id`, accountId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []*types.AccountStatusChange{}
+	for rows.Next() {
+		var change types.AccountStatusChange
+		if err := rows.Scan(&change.AccountId, &change.From, &change.To, &change.Reason, &change.Actor, &change.ChangedAt); err != nil {
+			return nil, err
+		}
+		history = append(history, &change)
+	}
+	return history, rows.Err()
+}
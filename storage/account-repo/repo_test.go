@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"fmt"
 	requestparams "go-sample/api/handlers/request-params"
+	"go-sample/storage"
 	"go-sample/types"
 	"strconv"
 	"testing"
@@ -20,9 +21,28 @@ func createAccountTable(db *sqlx.DB) {
 		id VARCHAR(36) PRIMARY KEY,
 		owner_id VARCHAR(36) NOT NULL,
 		balance MONEY NOT NULL,
+		credit_limit MONEY NOT NULL DEFAULT 0,
+		held_amount MONEY NOT NULL DEFAULT 0,
 		created_at TIMESTAMP NOT NULL,
 		updated_at TIMESTAMP NOT NULL,
-		deletedAt TIMESTAMP NULL
+		deletedAt TIMESTAMP NULL,
+		status VARCHAR(10) NOT NULL DEFAULT 'ACTIVE',
+		account_type VARCHAR(10) NOT NULL DEFAULT 'CHECKING',
+		nickname VARCHAR(100) NOT NULL DEFAULT '',
+		currency VARCHAR(3) NOT NULL DEFAULT 'AOA'
+	  );`)
+	if err != nil {
+		panic(err)
+	}
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS public.account_status_history (
+		id BIGSERIAL PRIMARY KEY,
+		account_id VARCHAR(36) NOT NULL,
+		from_status VARCHAR(10) NOT NULL,
+		to_status VARCHAR(10) NOT NULL,
+		reason TEXT NOT NULL,
+		actor VARCHAR(100) NOT NULL,
+		changed_at TIMESTAMP NOT NULL
 	  );`)
 	if err != nil {
 		panic(err)
@@ -34,6 +54,10 @@ func dropAccountTable(db *sqlx.DB) {
 	if err != nil {
 		panic(err)
 	}
+	_, err = db.Exec(`DROP TABLE IF EXISTS public.account_status_history;`)
+	if err != nil {
+		panic(err)
+	}
 }
 func TestAccountRepo(t *testing.T) {
 
@@ -49,7 +73,7 @@ func TestAccountRepo(t *testing.T) {
 	t.Run("Create Account", func(t *testing.T) {
 		ctx := context.Background()
 		owner_id := "some dumb id"
-		balance := 100.00
+		balance := types.NewMoney(10000, types.AOA)
 
 		entity := types.NewAccount(owner_id, balance)
 
@@ -72,7 +96,7 @@ func TestAccountRepo(t *testing.T) {
 		t.Run("should return an account", func(t *testing.T) {
 			ctx := context.Background()
 			owner_id := "some dumb id"
-			balance := 100.00
+			balance := types.NewMoney(10000, types.AOA)
 
 			entity := types.NewAccount(owner_id, balance)
 
@@ -107,7 +131,7 @@ func TestAccountRepo(t *testing.T) {
 		t.Run("should return the account balance", func(t *testing.T) {
 			ctx := context.Background()
 			owner_id := "some dumb id"
-			balance := 100.00
+			balance := types.NewMoney(10000, types.AOA)
 
 			entity := types.NewAccount(owner_id, balance)
 
@@ -116,8 +140,8 @@ func TestAccountRepo(t *testing.T) {
 
 			res, _ := repo.GetAccountBalance(ctx, accountId)
 
-			if res != balance {
-				t.Errorf("res = %f, expected %f", res, balance)
+			if !res.Equal(balance) {
+				t.Errorf("res = %s, expected %s", res, balance)
 			}
 
 			t.Cleanup(func() {
@@ -139,42 +163,6 @@ func TestAccountRepo(t *testing.T) {
 		})
 	})
 
-	t.Run("GetAccountByOwnerId", func(t *testing.T) {
-		t.Run("should return the account by owner id", func(t *testing.T) {
-			ctx := context.Background()
-			owner_id := "some dumb id"
-			balance := 100.00
-
-			entity := types.NewAccount(owner_id, balance)
-
-			accountId := entity.ID
-			repo.CreateAccount(ctx, entity)
-
-			res, _ := repo.GetAccountByOwnerId(ctx, owner_id)
-
-			if res.Owner != entity.Owner {
-				t.Errorf("res.ID = %s, expected %s", res.ID, entity.ID)
-			}
-
-			t.Cleanup(func() {
-				db.ExecContext(ctx, "DELETE FROM accounts WHERE id=$1", accountId)
-			})
-		})
-
-		t.Run("should return a sql.ErrNoRows", func(t *testing.T) {
-
-			ctx := context.Background()
-			ownerId := "f57d2e6f-8a73-470b-9067-729cab4cabc5"
-
-			_, err := repo.GetAccountByOwnerId(ctx, ownerId)
-
-			if err != sql.ErrNoRows {
-				t.Errorf("err = %s, expected %s", err, sql.ErrNoRows)
-			}
-
-		})
-	})
-
 	t.Run("ListAccounts", func(t *testing.T) {
 		t.Run("should return the account by owner id", func(t *testing.T) {
 			ctx := context.Background()
@@ -183,18 +171,16 @@ func TestAccountRepo(t *testing.T) {
 				{
 					ID:        "",
 					Owner:     "1e55e90d-427e-4f0b-b71f-5e38e57c2ae8",
-					Balance:   100.0,
+					Balance:   types.NewMoney(10000, types.AOA),
 					CreatedAt: time.Now(),
 					UpdatedAt: time.Now(),
-					DeletedAt: time.Now(),
 				},
 				{
 					ID:        "",
 					Owner:     "1e55e901-427e-4f0b-b71f-5e38e57c2ae8",
-					Balance:   100.0,
+					Balance:   types.NewMoney(10000, types.AOA),
 					CreatedAt: time.Now(),
 					UpdatedAt: time.Now(),
-					DeletedAt: time.Now(),
 				},
 			}
 
@@ -206,12 +192,11 @@ func TestAccountRepo(t *testing.T) {
 			}
 
 			request := requestparams.ListAccountsRequest{
-				Page:  "1",
-				Limit: "10",
+				Pagination: requestparams.Pagination{Page: "1", Limit: "10"},
 			}
 			res, _ := repo.ListAccounts(ctx, request)
-			if len(res) != len(accounts) {
-				t.Errorf("len(res.Accounts) = %d, expected %d", len(res), len(accounts))
+			if len(res.Data) != len(accounts) {
+				t.Errorf("len(res.Accounts) = %d, expected %d", len(res.Data), len(accounts))
 			}
 			t.Cleanup(func() {
 				db.ExecContext(ctx, "DELETE FROM accounts WHERE id=$1", accounts[0].ID)
@@ -226,18 +211,16 @@ func TestAccountRepo(t *testing.T) {
 				{
 					ID:        "",
 					Owner:     "1e55e90d-427e-4f0b-b71f-5e38e57c2ae8",
-					Balance:   100.0,
+					Balance:   types.NewMoney(10000, types.AOA),
 					CreatedAt: time.Now(),
 					UpdatedAt: time.Now(),
-					DeletedAt: time.Now(),
 				},
 				{
 					ID:        "",
 					Owner:     "1e55e901-427e-4f0b-b71f-5e38e57c2ae8",
-					Balance:   100.0,
+					Balance:   types.NewMoney(10000, types.AOA),
 					CreatedAt: time.Now(),
 					UpdatedAt: time.Now(),
-					DeletedAt: time.Now(),
 				},
 			}
 
@@ -250,13 +233,12 @@ func TestAccountRepo(t *testing.T) {
 			limit := "1"
 
 			request := requestparams.ListAccountsRequest{
-				Page:  "1",
-				Limit: limit,
+				Pagination: requestparams.Pagination{Page: "1", Limit: limit},
 			}
 			res, _ := repo.ListAccounts(ctx, request)
 
-			if limit, _ := strconv.Atoi(limit); len(res) != limit {
-				t.Errorf("len(res.Accounts) = %d, expected %d", len(res), len(accounts))
+			if limit, _ := strconv.Atoi(limit); len(res.Data) != limit {
+				t.Errorf("len(res.Accounts) = %d, expected %d", len(res.Data), len(accounts))
 			}
 
 			t.Cleanup(func() {
@@ -265,41 +247,108 @@ func TestAccountRepo(t *testing.T) {
 			})
 		})
 
+		t.Run("should filter by owner and balance and sort by balance", func(t *testing.T) {
+			ctx := context.Background()
+			owner := "1e55e90d-0000-4f0b-b71f-5e38e57c2ae8"
+
+			poor := types.NewAccount(owner, types.NewMoney(100, types.AOA))
+			rich := types.NewAccount(owner, types.NewMoney(900000, types.AOA))
+			other := types.NewAccount("1e55e90d-1111-4f0b-b71f-5e38e57c2ae8", types.NewMoney(900000, types.AOA))
+			for _, account := range []*types.Account{poor, rich, other} {
+				repo.CreateAccount(ctx, account)
+			}
+
+			request := requestparams.ListAccountsRequest{OwnerId: owner, SortBy: "balance", Sort: "desc"}
+			request.Validate()
+			res, err := repo.ListAccounts(ctx, request)
+			if err != nil {
+				t.Fatalf("err = %v, expected nil", err)
+			}
+			if len(res.Data) != 2 || res.Data[0].ID != rich.ID {
+				t.Errorf("len(res.Data) = %d, expected the 2 accounts of the owner richest first", len(res.Data))
+			}
+
+			request = requestparams.ListAccountsRequest{OwnerId: owner, MinBalance: "10", Status: "active"}
+			request.Validate()
+			res, err = repo.ListAccounts(ctx, request)
+			if err != nil {
+				t.Fatalf("err = %v, expected nil", err)
+			}
+			if len(res.Data) != 1 || res.Data[0].ID != rich.ID {
+				t.Errorf("len(res.Data) = %d, expected only %s", len(res.Data), rich.ID)
+			}
+
+			t.Cleanup(func() {
+				for _, account := range []*types.Account{poor, rich, other} {
+					db.ExecContext(ctx, "DELETE FROM accounts WHERE id=$1", account.ID)
+				}
+			})
+		})
+
 		t.Run("should return an empty array", func(t *testing.T) {
 
 			ctx := context.Background()
 
 			request := requestparams.ListAccountsRequest{
-				Page:  "1",
-				Limit: "10",
+				Pagination: requestparams.Pagination{Page: "1", Limit: "10"},
 			}
 			accounts, err := repo.ListAccounts(ctx, request)
-			if len(accounts) != 0 {
+			if len(accounts.Data) != 0 {
 				t.Errorf("err = %s, expected %s", err, sql.ErrNoRows)
 			}
 
 		})
 	})
+	t.Run("UpdateAccountStatus should store the status and its history", func(t *testing.T) {
+		ctx := context.Background()
+		entity := types.NewAccount("some closing owner", types.NewMoney(0, types.AOA))
+		repo.CreateAccount(ctx, entity)
+
+		change, err := entity.TransitionTo(types.AccountClosed, "customer request", "back office")
+		if err != nil {
+			t.Fatalf("err = %v, expected nil", err)
+		}
+		repo.UpdateAccountStatus(ctx, entity, change)
+
+		account, err := repo.GetAccountById(ctx, entity.ID)
+		if err != nil {
+			t.Fatalf("err = %v, expected nil", err)
+		}
+		history, err := repo.GetAccountStatusHistory(ctx, entity.ID)
+		if err != nil {
+			t.Fatalf("err = %v, expected nil", err)
+		}
+
+		if account.Status != types.AccountClosed || account.DeletedAt == nil {
+			t.Errorf("account.Status = %s, expected %s with a deletion date", account.Status, types.AccountClosed)
+		}
+		if len(history) != 1 || history[0].Actor != "back office" {
+			t.Errorf("len(history) = %d, expected 1 change made by back office", len(history))
+		}
+		t.Cleanup(func() {
+			db.ExecContext(ctx, "DELETE FROM accounts WHERE id=$1", entity.ID)
+		})
+	})
 	t.Run("IncrBalance", func(t *testing.T) {
 
 		ctx := context.Background()
 		owner_id := "some dumb id"
-		balance := 100.00
+		balance := types.NewMoney(10000, types.AOA)
 
 		entity := types.NewAccount(owner_id, balance)
 
 		accountId := entity.ID
 		repo.CreateAccount(ctx, entity)
 
-		incr := 140
-		repo.IncrBalance(ctx, accountId, float64(incr))
+		incr := types.NewMoney(14000, types.AOA)
+		repo.IncrBalance(ctx, accountId, incr)
 
-		var finalBalance float64
+		var finalBalance types.Money
 		db.QueryRowContext(ctx, "SELECT balance::decimal FROM accounts WHERE id=$1", accountId).Scan(
 			&finalBalance)
 
-		if finalBalance != balance+float64(incr) {
-			t.Errorf("finalBalance = %f, expected %f", finalBalance, balance+float64(incr))
+		if !finalBalance.Equal(balance.Add(incr)) {
+			t.Errorf("finalBalance = %s, expected %s", finalBalance, balance.Add(incr))
 		}
 
 		t.Cleanup(func() {
@@ -311,22 +360,87 @@ func TestAccountRepo(t *testing.T) {
 
 		ctx := context.Background()
 		owner_id := "some dumb id"
-		balance := 100.00
+		balance := types.NewMoney(10000, types.AOA)
 
 		entity := types.NewAccount(owner_id, balance)
 
 		accountId := entity.ID
 		repo.CreateAccount(ctx, entity)
 
-		decr := 10
-		repo.DecrBalance(ctx, accountId, float64(decr))
+		decr := types.NewMoney(1000, types.AOA)
+		repo.DecrBalance(ctx, accountId, decr)
 
-		var finalBalance float64
+		var finalBalance types.Money
 		db.QueryRowContext(ctx, "SELECT balance::decimal FROM accounts WHERE id=$1", accountId).Scan(
 			&finalBalance)
 
-		if finalBalance != balance-float64(decr) {
-			t.Errorf("finalBalance = %f, expected %f", finalBalance, balance-float64(decr))
+		if !finalBalance.Equal(balance.Sub(decr)) {
+			t.Errorf("finalBalance = %s, expected %s", finalBalance, balance.Sub(decr))
+		}
+
+		t.Cleanup(func() {
+			db.ExecContext(ctx, "DELETE FROM accounts WHERE id=$1", accountId)
+		})
+	})
+
+	t.Run("DecrBalance should return ErrInsufficientFunds and keep the balance", func(t *testing.T) {
+
+		ctx := context.Background()
+		owner_id := "some dumb id"
+		balance := types.NewMoney(10000, types.AOA)
+
+		entity := types.NewAccount(owner_id, balance)
+
+		accountId := entity.ID
+		repo.CreateAccount(ctx, entity)
+
+		err := repo.DecrBalance(ctx, accountId, balance.Add(types.NewMoney(1, types.AOA)))
+
+		if err != storage.ErrInsufficientFunds {
+			t.Errorf("err = %v, expected %v", err, storage.ErrInsufficientFunds)
+		}
+
+		finalBalance, err := repo.GetAccountBalance(ctx, accountId)
+		if err != nil {
+			t.Fatalf("err = %v, expected nil", err)
+		}
+		if !finalBalance.Equal(balance) {
+			t.Errorf("finalBalance = %s, expected %s", finalBalance, balance)
+		}
+
+		t.Cleanup(func() {
+			db.ExecContext(ctx, "DELETE FROM accounts WHERE id=$1", accountId)
+		})
+	})
+
+	t.Run("DecrBalance should let the balance go down to minus the credit limit", func(t *testing.T) {
+
+		ctx := context.Background()
+		owner_id := "some dumb id"
+		balance := types.NewMoney(10000, types.AOA)
+
+		entity := types.NewAccount(owner_id, balance)
+		entity.SetCreditLimit(types.NewMoney(5000, types.AOA))
+
+		accountId := entity.ID
+		repo.CreateAccount(ctx, entity)
+
+		err := repo.DecrBalance(ctx, accountId, types.NewMoney(15000, types.AOA))
+		if err != nil {
+			t.Errorf("err = %v, expected nil", err)
+		}
+
+		err = repo.DecrBalance(ctx, accountId, types.NewMoney(1, types.AOA))
+		if err != storage.ErrInsufficientFunds {
+			t.Errorf("err = %v, expected %v", err, storage.ErrInsufficientFunds)
+		}
+
+		finalBalance, err := repo.GetAccountBalance(ctx, accountId)
+		if err != nil {
+			t.Fatalf("err = %v, expected nil", err)
+		}
+		if !finalBalance.Equal(types.NewMoney(-5000, types.AOA)) {
+			t.Errorf("finalBalance = %s, expected -50.00", finalBalance)
 		}
 
 		t.Cleanup(func() {
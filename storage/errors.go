@@ -0,0 +1,12 @@
+package storage
+
+import "errors"
+
+// ErrInsufficientFunds is returned by the write path when a debit would take the balance below the credit limit
+var ErrInsufficientFunds = errors.New("InsufficientFunds")
+
+// ErrInexistentAccount is returned by the write path when the account to credit has no row
+var ErrInexistentAccount = errors.New("InexistentAccount")
+
+// ErrReservationLost is returned when a request completes an Idempotency-Key another request took over
+var ErrReservationLost = errors.New("ReservationLost")
@@ -0,0 +1,56 @@
+package feerepo
+
+import (
+	"context"
+	"database/sql"
+	"go-sample/types"
+	"sort"
+)
+
+type MemoFeeRepo struct {
+	schedules []*types.FeeSchedule
+}
+
+func NewMemoFeeRepo() *MemoFeeRepo {
+	return &MemoFeeRepo{
+		schedules: []*types.FeeSchedule{},
+	}
+}
+
+func (fr *MemoFeeRepo) SaveSchedule(ctx context.Context, schedule *types.FeeSchedule) error {
+	stored := *schedule
+	for i, s := range fr.schedules {
+		if s.Operation == schedule.Operation && s.AccountType == schedule.AccountType && s.Currency == schedule.Currency {
+			fr.schedules[i] = &stored
+			return nil
+		}
+	}
+	fr.schedules = append(fr.schedules, &stored)
+	return nil
+}
+
+func (fr *MemoFeeRepo) GetSchedule(ctx context.Context, operation string, accountType types.AccountType, currency types.Currency) (*types.FeeSchedule, error) {
+	for _, s := range fr.schedules {
+		if s.Operation == operation && s.AccountType == accountType && s.Currency == currency {
+			schedule := *s
+			return &schedule, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (fr *MemoFeeRepo) ListSchedules(ctx context.Context) ([]*types.FeeSchedule, error) {
+	schedules := make([]*types.FeeSchedule, len(fr.schedules))
+	copy(schedules, fr.schedules)
+	sort.Slice(schedules, func(i, j int) bool {
+		a, b := schedules[i], schedules[j]
+		if a.Operation != b.Operation {
+			return a.Operation < b.Operation
+		}
+		if a.AccountType != b.AccountType {
+			return a.AccountType < b.AccountType
+		}
+		return a.Currency < b.Currency
+	})
+	return schedules, nil
+}
@@ -0,0 +1,106 @@
+package feerepo
+
+import (
+	"context"
+	"encoding/json"
+	"go-sample/storage"
+	"go-sample/types"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type IFeeRepo interface {
+	SaveSchedule(context.Context, *types.FeeSchedule) error
+	GetSchedule(context.Context, string, types.AccountType, types.Currency) (*types.FeeSchedule, error)
+	ListSchedules(context.Context) ([]*types.FeeSchedule, error)
+}
+
+type FeeRepo struct {
+	db *sqlx.DB
+}
+
+func NewFeeRepo(db *sqlx.DB) FeeRepo {
+	return FeeRepo{db}
+}
+
+const scheduleColumns = `operation, account_type, currency, kind, amount::decimal, COALESCE(percentage::text, ''), tiers, updated_at`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanSchedule reads scheduleColumns, postgres pads the percentage with zeros up to the column scale
+func scanSchedule(row scanner) (*types.FeeSchedule, error) {
+	var schedule types.FeeSchedule
+	var tiers []byte
+	err := row.Scan(
+		&schedule.Operation,
+		&schedule.AccountType,
+		&schedule.Currency,
+		&schedule.Kind,
+		&schedule.Amount,
+		&schedule.Percentage,
+		&tiers,
+		&schedule.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	schedule.Amount.Currency = schedule.Currency
+	if schedule.Percentage != "" {
+		if schedule.Percentage, err = types.ParseRate(schedule.Percentage); err != nil {
+			return nil, err
+		}
+	}
+	if err := json.Unmarshal(tiers, &schedule.Tiers); err != nil {
+		return nil, err
+	}
+	if len(schedule.Tiers) == 0 {
+		schedule.Tiers = nil
+	}
+	return &schedule, nil
+}
+
+// SaveSchedule stores the schedule, replacing the one already set for the same operation, account type and currency
+func (fr FeeRepo) SaveSchedule(ctx context.Context, schedule *types.FeeSchedule) error {
+	tiers, err := json.Marshal(schedule.Tiers)
+	if err != nil {
+		return err
+	}
+	if schedule.Tiers == nil {
+		tiers = []byte("[]")
+	}
+	_, err = storage.Conn(ctx, fr.db).ExecContext(ctx,
+		`INSERT INTO fee_schedules (operation, account_type, currency, kind, amount, percentage, tiers, updated_at)
+			VALUES ($1, $2, $3, $4, $5, NULLIF($6, '')::numeric, $7, $8)
+			ON CONFLICT (operation, account_type, currency) DO UPDATE SET kind = EXCLUDED.kind, amount = EXCLUDED.amount,
+				percentage = EXCLUDED.percentage, tiers = EXCLUDED.tiers, updated_at = EXCLUDED.updated_at`,
+		schedule.Operation, schedule.AccountType, schedule.Currency, schedule.Kind, schedule.Amount, schedule.Percentage,
+		tiers, schedule.UpdatedAt)
+	return err
+}
+
+func (fr FeeRepo) GetSchedule(ctx context.Context, operation string, accountType types.AccountType, currency types.Currency) (*types.FeeSchedule, error) {
+	return scanSchedule(storage.Conn(ctx, fr.db).QueryRowContext(ctx,
+		`SELECT `+scheduleColumns+` FROM fee_schedules WHERE operation = $1 AND account_type = $2 AND currency = $3`,
+		operation, accountType, currency))
+}
+
+func (fr FeeRepo) ListSchedules(ctx context.Context) ([]*types.FeeSchedule, error) {
+	rows, err := storage.Conn(ctx, fr.db).QueryContext(ctx,
+		`SELECT `+scheduleColumns+` FROM fee_schedules ORDER BY operation, account_type, currency`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schedules := []*types.FeeSchedule{}
+	for rows.Next() {
+		schedule, err := scanSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, schedule)
+	}
+	return schedules, rows.Err()
+}
@@ -0,0 +1,52 @@
+package fxrepo
+
+import (
+	"context"
+	"database/sql"
+	"go-sample/types"
+	"sort"
+)
+
+type MemoFXRepo struct {
+	rates []*types.ExchangeRate
+}
+
+func NewMemoFXRepo() *MemoFXRepo {
+	return &MemoFXRepo{
+		rates: []*types.ExchangeRate{},
+	}
+}
+
+func (fr *MemoFXRepo) SaveRate(ctx context.Context, rate *types.ExchangeRate) error {
+	stored := *rate
+	for i, r := range fr.rates {
+		if r.Base == rate.Base && r.Quote == rate.Quote {
+			fr.rates[i] = &stored
+			return nil
+		}
+	}
+	fr.rates = append(fr.rates, &stored)
+	return nil
+}
+
+func (fr *MemoFXRepo) GetRate(ctx context.Context, base types.Currency, quote types.Currency) (*types.ExchangeRate, error) {
+	for _, r := range fr.rates {
+		if r.Base == base && r.Quote == quote {
+			rate := *r
+			return &rate, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (fr *MemoFXRepo) ListRates(ctx context.Context) ([]*types.ExchangeRate, error) {
+	rates := make([]*types.ExchangeRate, len(fr.rates))
+	copy(rates, fr.rates)
+	sort.Slice(rates, func(i, j int) bool {
+		if rates[i].Base != rates[j].Base {
+			return rates[i].Base < rates[j].Base
+		}
+		return rates[i].Quote < rates[j].Quote
+	})
+	return rates, nil
+}
@@ -0,0 +1,72 @@
+package fxrepo
+
+import (
+	"context"
+	"go-sample/storage"
+	"go-sample/types"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type IFXRepo interface {
+	SaveRate(context.Context, *types.ExchangeRate) error
+	GetRate(context.Context, types.Currency, types.Currency) (*types.ExchangeRate, error)
+	ListRates(context.Context) ([]*types.ExchangeRate, error)
+}
+
+type FXRepo struct {
+	db *sqlx.DB
+}
+
+func NewFXRepo(db *sqlx.DB) FXRepo {
+	return FXRepo{db}
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanRate reads base, quote, rate::text and updated_at, postgres pads the rate with zeros up to the column scale
+func scanRate(row scanner) (*types.ExchangeRate, error) {
+	var rate types.ExchangeRate
+	err := row.Scan(&rate.Base, &rate.Quote, &rate.Rate, &rate.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	rate.Rate, err = types.ParseRate(rate.Rate)
+	return &rate, err
+}
+
+// SaveRate stores the rate, replacing the one already loaded for the same pair
+func (fr FXRepo) SaveRate(ctx context.Context, rate *types.ExchangeRate) error {
+	_, err := storage.Conn(ctx, fr.db).ExecContext(ctx,
+		`INSERT INTO fx_rates (base_currency, quote_currency, rate, updated_at) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (base_currency, quote_currency) DO UPDATE SET rate = EXCLUDED.rate, updated_at = EXCLUDED.updated_at`,
+		rate.Base, rate.Quote, rate.Rate, rate.UpdatedAt)
+	return err
+}
+
+func (fr FXRepo) GetRate(ctx context.Context, base types.Currency, quote types.Currency) (*types.ExchangeRate, error) {
+	return scanRate(storage.Conn(ctx, fr.db).QueryRowContext(ctx,
+		`SELECT base_currency, quote_currency, rate::text, updated_at FROM fx_rates WHERE base_currency = $1 AND quote_currency = $2`,
+		base, quote))
+}
+
+func (fr FXRepo) ListRates(ctx context.Context) ([]*types.ExchangeRate, error) {
+	rows, err := storage.Conn(ctx, fr.db).QueryContext(ctx,
+		`SELECT base_currency, quote_currency, rate::text, updated_at FROM fx_rates ORDER BY base_currency, quote_currency`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := []*types.ExchangeRate{}
+	for rows.Next() {
+		rate, err := scanRate(rows)
+		if err != nil {
+			return nil, err
+		}
+		rates = append(rates, rate)
+	}
+	return rates, rows.Err()
+}
@@ -0,0 +1,85 @@
+package holdrepo
+
+import (
+	"context"
+	"database/sql"
+	requestparams "go-sample/api/handlers/request-params"
+	"go-sample/types"
+	"time"
+)
+
+type MemoHoldRepo struct {
+	holds []*types.Hold
+}
+
+func NewMemoHoldRepo() *MemoHoldRepo {
+	return &MemoHoldRepo{
+		holds: []*types.Hold{},
+	}
+}
+
+func (hr *MemoHoldRepo) CreateHold(ctx context.Context, hold *types.Hold) error {
+	stored := *hold
+	hr.holds = append(hr.holds, &stored)
+	return nil
+}
+
+func (hr *MemoHoldRepo) GetHold(ctx context.Context, id string) (*types.Hold, error) {
+	for _, h := range hr.holds {
+		if h.ID == id {
+			hold := *h
+			return &hold, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (hr *MemoHoldRepo) GetHoldForUpdate(ctx context.Context, id string) (*types.Hold, error) {
+	return hr.GetHold(ctx, id)
+}
+
+func (hr *MemoHoldRepo) UpdateHold(ctx context.Context, hold *types.Hold) error {
+	for i, h := range hr.holds {
+		if h.ID == hold.ID {
+			stored := *hold
+			hr.holds[i] = &stored
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (hr *MemoHoldRepo) ListHolds(ctx context.Context, accountId string, filters requestparams.ListHoldsRequest) (*types.Page[*types.Hold], error) {
+	holds := []*types.Hold{}
+	for _, h := range hr.holds {
+		if h.AccountId == accountId && (filters.StatusFilter == "" || h.Status == filters.StatusFilter) {
+			holds = append(holds, h)
+		}
+	}
+	return &types.Page[*types.Hold]{Data: holds}, nil
+}
+
+func (hr *MemoHoldRepo) ListActiveHolds(ctx context.Context, accountId string) ([]*types.Hold, error) {
+	holds := []*types.Hold{}
+	for _, h := range hr.holds {
+		if h.AccountId == accountId && h.Status == types.HoldActive {
+			hold := *h
+			holds = append(holds, &hold)
+		}
+	}
+	return holds, nil
+}
+
+func (hr *MemoHoldRepo) ListExpiredHolds(ctx context.Context, now time.Time, limit int) ([]*types.Hold, error) {
+	holds := []*types.Hold{}
+	for _, h := range hr.holds {
+		if len(holds) == limit {
+			break
+		}
+		if h.Status == types.HoldActive && h.IsExpired(now) {
+			hold := *h
+			holds = append(holds, &hold)
+		}
+	}
+	return holds, nil
+}
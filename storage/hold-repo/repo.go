@@ -0,0 +1,161 @@
+package holdrepo
+
+import (
+	"context"
+	"database/sql"
+	requestparams "go-sample/api/handlers/request-params"
+	"go-sample/storage"
+	"go-sample/types"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type IHoldRepo interface {
+	CreateHold(context.Context, *types.Hold) error
+	GetHold(context.Context, string) (*types.Hold, error)
+	GetHoldForUpdate(context.Context, string) (*types.Hold, error)
+	UpdateHold(context.Context, *types.Hold) error
+	ListHolds(context.Context, string, requestparams.ListHoldsRequest) (*types.Page[*types.Hold], error)
+	ListActiveHolds(context.Context, string) ([]*types.Hold, error)
+	ListExpiredHolds(context.Context, time.Time, int) ([]*types.Hold, error)
+}
+
+type HoldRepo struct {
+	db *sqlx.DB
+}
+
+func NewHoldRepo(db *sqlx.DB) HoldRepo {
+	return HoldRepo{db}
+}
+
+const holdColumns = `id, account_id, to_account, subject, currency, amount::decimal, captured_amount::decimal, status,
+	transaction_id, expires_at, created_at, updated_at`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanHold(row scanner) (*types.Hold, error) {
+	var hold types.Hold
+	var currency types.Currency
+	err := row.Scan(
+		&hold.ID,
+		&hold.AccountId,
+		&hold.To,
+		&hold.Subject,
+		&currency,
+		&hold.Amount,
+		&hold.CapturedAmount,
+		&hold.Status,
+		&hold.TransactionId,
+		&hold.ExpiresAt,
+		&hold.CreatedAt,
+		&hold.UpdatedAt,
+	)
+	hold.Amount.Currency = currency
+	hold.CapturedAmount.Currency = currency
+	return &hold, err
+}
+
+func scanHolds(rows *sql.Rows) ([]*types.Hold, error) {
+	defer rows.Close()
+	holds := []*types.Hold{}
+	for rows.Next() {
+		hold, err := scanHold(rows)
+		if err != nil {
+			return nil, err
+		}
+		holds = append(holds, hold)
+	}
+	return holds, rows.Err()
+}
+
+func (hr HoldRepo) CreateHold(ctx context.Context, hold *types.Hold) error {
+	_, err := storage.Conn(ctx, hr.db).ExecContext(ctx,
+		`INSERT INTO holds (id, account_id, to_account, subject, currency, amount, captured_amount, status,
+			transaction_id, expires_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+		hold.ID, hold.AccountId, hold.To, hold.Subject, hold.Amount.Currency, hold.Amount, hold.CapturedAmount,
+		hold.Status, hold.TransactionId, hold.ExpiresAt, hold.CreatedAt, hold.UpdatedAt)
+	return err
+}
+
+func (hr HoldRepo) GetHold(ctx context.Context, id string) (*types.Hold, error) {
+	return scanHold(storage.Conn(ctx, hr.db).QueryRowContext(ctx,
+		"SELECT "+holdColumns+" FROM holds WHERE id = $1", id))
+}
+
+// GetHoldForUpdate reads the hold FOR UPDATE, so a hold is captured,
+// voided or expired only once
+func (hr HoldRepo) GetHoldForUpdate(ctx context.Context, id string) (*types.Hold, error) {
+	return scanHold(storage.Conn(ctx, hr.db).QueryRowContext(ctx,
+		"SELECT "+holdColumns+" FROM holds WHERE id = $1 FOR UPDATE", id))
+}
+
+func (hr HoldRepo) UpdateHold(ctx context.Context, hold *types.Hold) error {
+	_, err := storage.Conn(ctx, hr.db).ExecContext(ctx,
+		`UPDATE holds SET captured_amount = $1, status = $2, transaction_id = $3, updated_at = $4 WHERE id = $5`,
+		hold.CapturedAmount, hold.Status, hold.TransactionId, hold.UpdatedAt, hold.ID)
+	return err
+}
+
+// ListHolds pages through the holds placed on the account, newest first
+func (hr HoldRepo) ListHolds(ctx context.Context, accountId string, filters requestparams.ListHoldsRequest) (*types.Page[*types.Hold], error) {
+	var where storage.Where
+	where.Add("account_id = ?", accountId)
+	if filters.StatusFilter != "" {
+		where.Add("status = ?", filters.StatusFilter)
+	}
+
+	var total *int64
+	if filters.IncludeTotal() {
+		var count int64
+		err := storage.Conn(ctx, hr.db).QueryRowContext(ctx, "SELECT COUNT(*) FROM holds "+where.String(), where.Args()...).Scan(&count)
+		if err != nil {
+			return nil, err
+		}
+		total = &count
+	}
+
+	tail, err := storage.PageQuery(&where, filters.Pagination, "created_at", "DESC")
+	if err != nil {
+		return nil, err
+	}
+	rows, err := storage.Conn(ctx, hr.db).QueryContext(ctx,
+		"SELECT "+holdColumns+" FROM holds "+where.String()+" "+tail, where.Args()...)
+	if err != nil {
+		return nil, err
+	}
+	holds, err := scanHolds(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	page := storage.NewPage(holds, filters.Pagination, func(hold *types.Hold) types.Cursor {
+		return types.NewTimeCursor("created_at", hold.CreatedAt, hold.ID)
+	})
+	page.Total = total
+	return page, nil
+}
+
+func (hr HoldRepo) ListActiveHolds(ctx context.Context, accountId string) ([]*types.Hold, error) {
+	rows, err := storage.Conn(ctx, hr.db).QueryContext(ctx,
+		"SELECT "+holdColumns+" FROM holds WHERE account_id = $1 AND status = $2 ORDER BY created_at, id",
+		accountId, types.HoldActive)
+	if err != nil {
+		return nil, err
+	}
+	return scanHolds(rows)
+}
+
+// ListExpiredHolds returns up to limit active holds that expired before now, oldest first
+func (hr HoldRepo) ListExpiredHolds(ctx context.Context, now time.Time, limit int) ([]*types.Hold, error) {
+	rows, err := storage.Conn(ctx, hr.db).QueryContext(ctx,
+		"SELECT "+holdColumns+" FROM holds WHERE status = $1 AND expires_at <= $2 ORDER BY expires_at, id LIMIT $3",
+		types.HoldActive, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanHolds(rows)
+}
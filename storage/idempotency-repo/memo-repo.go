@@ -0,0 +1,62 @@
+package idempotencyrepo
+
+import (
+	"context"
+	"go-sample/storage"
+	"go-sample/types"
+	"sync"
+	"time"
+)
+
+type MemoIdempotencyRepo struct {
+	mu      sync.Mutex
+	records map[string]*types.IdempotencyRecord
+}
+
+func NewMemoIdempotencyRepo() *MemoIdempotencyRepo {
+	return &MemoIdempotencyRepo{
+		records: map[string]*types.IdempotencyRecord{},
+	}
+}
+
+func (ir *MemoIdempotencyRepo) Reserve(ctx context.Context, record *types.IdempotencyRecord) (*types.IdempotencyRecord, bool, error) {
+	ir.mu.Lock()
+	defer ir.mu.Unlock()
+	if existing, ok := ir.records[record.Key]; ok {
+		if record.CanTakeOver(existing) {
+			existing.ReservedAt = record.ReservedAt
+			return record, true, nil
+		}
+		copied := *existing
+		return &copied, false, nil
+	}
+	stored := *record
+	ir.records[record.Key] = &stored
+	return record, true, nil
+}
+
+func (ir *MemoIdempotencyRepo) Complete(ctx context.Context, reservation *types.IdempotencyRecord, statusCode int, contentType string, body []byte) error {
+	ir.mu.Lock()
+	defer ir.mu.Unlock()
+	record, ok := ir.records[reservation.Key]
+	if !ok || record.IsCompleted() || !record.ReservedAt.Equal(reservation.ReservedAt) {
+		return storage.ErrReservationLost
+	}
+	now := time.Now()
+	record.StatusCode = statusCode
+	record.ContentType = contentType
+	record.ResponseBody = body
+	record.CompletedAt = &now
+	return nil
+}
+
+func (ir *MemoIdempotencyRepo) Release(ctx context.Context, reservation *types.IdempotencyRecord) error {
+	ir.mu.Lock()
+	defer ir.mu.Unlock()
+	record, ok := ir.records[reservation.Key]
+	if !ok || record.IsCompleted() || !record.ReservedAt.Equal(reservation.ReservedAt) {
+		return storage.ErrReservationLost
+	}
+	delete(ir.records, reservation.Key)
+	return nil
+}
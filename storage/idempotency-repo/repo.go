@@ -0,0 +1,105 @@
+package idempotencyrepo
+
+import (
+	"context"
+	"database/sql"
+	"go-sample/storage"
+	"go-sample/types"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type IIdempotencyRepo interface {
+	Reserve(context.Context, *types.IdempotencyRecord) (*types.IdempotencyRecord, bool, error)
+	Complete(context.Context, *types.IdempotencyRecord, int, string, []byte) error
+	Release(context.Context, *types.IdempotencyRecord) error
+}
+type IdempotencyRepo struct {
+	db *sqlx.DB
+}
+
+func NewIdempotencyRepo(db *sqlx.DB) IdempotencyRepo {
+	return IdempotencyRepo{db}
+}
+
+// Reserve stores record unless its key is already taken. It returns true when the caller owns
+// the key, otherwise it returns the record stored by the first request. A reservation abandoned for
+// longer than types.IdempotencyLease is taken over by a retry of the same request
+func (ir IdempotencyRepo) Reserve(ctx context.Context, record *types.IdempotencyRecord) (*types.IdempotencyRecord, bool, error) {
+	conn := storage.Conn(ctx, ir.db)
+	res, err := conn.ExecContext(ctx,
+		`INSERT INTO idempotency_keys(key, method, path, request_hash, created_at, reserved_at)
+		VALUES($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (key) DO UPDATE SET reserved_at = EXCLUDED.reserved_at
+		WHERE idempotency_keys.completed_at IS NULL
+			AND idempotency_keys.request_hash = EXCLUDED.request_hash
+			AND idempotency_keys.reserved_at <= $7`,
+		record.Key, record.Method, record.Path, record.RequestHash, record.CreatedAt, record.ReservedAt,
+		record.ReservedAt.Add(-types.IdempotencyLease))
+	if err != nil {
+		return nil, false, err
+	}
+	if affected, err := res.RowsAffected(); err != nil {
+		return nil, false, err
+	} else if affected == 1 {
+		return record, true, nil
+	}
+
+	var existing types.IdempotencyRecord
+	var completedAt sql.NullTime
+	err = conn.QueryRowContext(ctx,
+		`SELECT key, method, path, request_hash, status_code, content_type, response_body, created_at, reserved_at, completed_at
+		FROM idempotency_keys WHERE key = $1`, record.Key).Scan(
+		&existing.Key,
+		&existing.Method,
+		&existing.Path,
+		&existing.RequestHash,
+		&existing.StatusCode,
+		&existing.ContentType,
+		&existing.ResponseBody,
+		&existing.CreatedAt,
+		&existing.ReservedAt,
+		&completedAt,
+	)
+	if err != nil {
+		return nil, false, err
+	}
+	if completedAt.Valid {
+		existing.CompletedAt = &completedAt.Time
+	}
+	return &existing, false, nil
+}
+
+// Complete stores the response of the request holding the reservation, storage.ErrReservationLost is
+// returned when another request took the key over in the meantime
+func (ir IdempotencyRepo) Complete(ctx context.Context, reservation *types.IdempotencyRecord, statusCode int, contentType string, body []byte) error {
+	res, err := storage.Conn(ctx, ir.db).ExecContext(ctx,
+		`UPDATE idempotency_keys SET status_code = $1, content_type = $2, response_body = $3, completed_at = $4
+		WHERE key = $5 AND reserved_at = $6 AND completed_at IS NULL`,
+		statusCode, contentType, body, time.Now(), reservation.Key, reservation.ReservedAt)
+	if err != nil {
+		return err
+	}
+	return checkReservation(res)
+}
+
+// Release forgets a reservation whose request did not complete, so the client can retry with the same key
+func (ir IdempotencyRepo) Release(ctx context.Context, reservation *types.IdempotencyRecord) error {
+	res, err := storage.Conn(ctx, ir.db).ExecContext(ctx,
+		`DELETE FROM idempotency_keys WHERE key = $1 AND reserved_at = $2 AND completed_at IS NULL`,
+		reservation.Key, reservation.ReservedAt)
+	if err != nil {
+		return err
+	}
+	return checkReservation(res)
+}
+
+func checkReservation(res sql.Result) error {
+	if affected, err := res.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return storage.ErrReservationLost
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package ledgerrepo
+
+import (
+	"context"
+	"go-sample/types"
+	"sort"
+)
+
+type MemoLedgerRepo struct {
+	entries []*types.JournalEntry
+}
+
+func NewMemoLedgerRepo() *MemoLedgerRepo {
+	return &MemoLedgerRepo{
+		entries: []*types.JournalEntry{},
+	}
+}
+
+func (lr *MemoLedgerRepo) CreateJournalEntry(ctx context.Context, entry *types.JournalEntry) error {
+	if !entry.IsBalanced() {
+		return types.ErrUnbalancedJournalEntry
+	}
+	lr.entries = append(lr.entries, entry)
+	return nil
+}
+
+func (lr *MemoLedgerRepo) GetPostedBalance(ctx context.Context, accountId string) (types.Money, error) {
+	balance := types.NewMoney(0, types.DefaultCurrency)
+	for _, entry := range lr.entries {
+		for _, posting := range entry.Postings {
+			if posting.AccountId == accountId {
+				balance = balance.Add(posting.Amount)
+			}
+		}
+	}
+	return balance, nil
+}
+
+func (lr *MemoLedgerRepo) GetTrialBalance(ctx context.Context) ([]*types.TrialBalanceLine, error) {
+	type key struct {
+		accountId string
+		currency  types.Currency
+	}
+	totals := map[key]types.Money{}
+	for _, entry := range lr.entries {
+		for _, posting := range entry.Postings {
+			k := key{posting.AccountId, posting.Amount.Currency}
+			totals[k] = totals[k].Add(posting.Amount)
+		}
+	}
+	lines := []*types.TrialBalanceLine{}
+	for k, total := range totals {
+		lines = append(lines, &types.TrialBalanceLine{AccountId: k.accountId, PostedBalance: total})
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		if lines[i].AccountId != lines[j].AccountId {
+			return lines[i].AccountId < lines[j].AccountId
+		}
+		return lines[i].PostedBalance.Currency < lines[j].PostedBalance.Currency
+	})
+	return lines, nil
+}
+
+func (lr *MemoLedgerRepo) Entries() []*types.JournalEntry {
+	return lr.entries
+}
@@ -0,0 +1,96 @@
+package ledgerrepo
+
+import (
+	"context"
+	"go-sample/storage"
+	"go-sample/types"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type ILedgerRepo interface {
+	CreateJournalEntry(context.Context, *types.JournalEntry) error
+	GetPostedBalance(context.Context, string) (types.Money, error)
+	GetTrialBalance(context.Context) ([]*types.TrialBalanceLine, error)
+}
+type LedgerRepo struct {
+	db *sqlx.DB
+}
+
+func NewLedgerRepo(db *sqlx.DB) LedgerRepo {
+	return LedgerRepo{db}
+}
+
+// CreateJournalEntry refuses entries whose postings do not sum to zero
+func (lr LedgerRepo) CreateJournalEntry(ctx context.Context, entry *types.JournalEntry) error {
+	if !entry.IsBalanced() {
+		return types.ErrUnbalancedJournalEntry
+	}
+	conn := storage.Conn(ctx, lr.db)
+	_, err := conn.ExecContext(ctx,
+		`INSERT INTO journal_entries(id, transaction_id, operation, created_at) VALUES($1, $2, $3, $4)`,
+		entry.ID, entry.TransactionId, entry.Operation, entry.CreatedAt)
+	if err != nil {
+		return err
+	}
+	for _, posting := range entry.Postings {
+		_, err = conn.ExecContext(ctx,
+			`INSERT INTO postings(id, entry_id, account_id, currency, amount, created_at) VALUES($1, $2, $3, $4, $5, $6)`,
+			posting.ID, posting.EntryId, posting.AccountId, currencyOf(posting.Amount), posting.Amount, posting.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func currencyOf(amount types.Money) types.Currency {
+	if amount.Currency == "" {
+		return types.DefaultCurrency
+	}
+	return amount.Currency
+}
+
+// GetPostedBalance derives the balance of an account from its postings, customer accounts only hold one currency
+func (lr LedgerRepo) GetPostedBalance(ctx context.Context, accountId string) (types.Money, error) {
+	var balance types.Money
+	var currency types.Currency
+	err := storage.Conn(ctx, lr.db).QueryRowContext(ctx,
+		`SELECT COALESCE(SUM(amount::decimal), 0), COALESCE(MIN(currency), $2) FROM postings WHERE account_id = $1`,
+		accountId, types.DefaultCurrency).Scan(&balance, &currency)
+	balance.Currency = currency
+	return balance, err
+}
+
+func (lr LedgerRepo) GetTrialBalance(ctx context.Context) ([]*types.TrialBalanceLine, error) {
+	rows, err := storage.Conn(ctx, lr.db).QueryContext(ctx,
+		`SELECT COALESCE(p.account_id, a.id), COALESCE(p.currency, a.currency), COALESCE(p.total, 0), a.balance::decimal
+		FROM (SELECT account_id, currency, SUM(amount::decimal) AS total FROM postings GROUP BY account_id, currency) p
+		FULL OUTER JOIN accounts a ON a.id = p.account_id
+		ORDER BY 1, 2`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lines := []*types.TrialBalanceLine{}
+	for rows.Next() {
+		var line types.TrialBalanceLine
+		var currency types.Currency
+		err = rows.Scan(
+			&line.AccountId,
+			&currency,
+			&line.PostedBalance,
+			&line.StoredBalance,
+		)
+		if err != nil {
+			return nil, err
+		}
+		line.PostedBalance.Currency = currency
+		if line.StoredBalance != nil {
+			line.StoredBalance.Currency = currency
+		}
+		lines = append(lines, &line)
+	}
+	return lines, rows.Err()
+}
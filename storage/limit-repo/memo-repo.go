@@ -0,0 +1,51 @@
+package limitrepo
+
+import (
+	"context"
+	"database/sql"
+	"go-sample/types"
+	"sort"
+)
+
+type MemoLimitRepo struct {
+	limits []*types.Limit
+}
+
+func NewMemoLimitRepo() *MemoLimitRepo {
+	return &MemoLimitRepo{
+		limits: []*types.Limit{},
+	}
+}
+
+func (lr *MemoLimitRepo) SaveLimit(ctx context.Context, limit *types.Limit) error {
+	stored := *limit
+	for i, l := range lr.limits {
+		if l.AccountId == limit.AccountId && l.Operation == limit.Operation {
+			lr.limits[i] = &stored
+			return nil
+		}
+	}
+	lr.limits = append(lr.limits, &stored)
+	return nil
+}
+
+func (lr *MemoLimitRepo) GetLimit(ctx context.Context, accountId string, operation string) (*types.Limit, error) {
+	for _, l := range lr.limits {
+		if l.AccountId == accountId && l.Operation == operation {
+			limit := *l
+			return &limit, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (lr *MemoLimitRepo) ListLimits(ctx context.Context, accountId string) ([]*types.Limit, error) {
+	limits := []*types.Limit{}
+	for _, l := range lr.limits {
+		if l.AccountId == accountId {
+			limits = append(limits, l)
+		}
+	}
+	sort.Slice(limits, func(i, j int) bool { return limits[i].Operation < limits[j].Operation })
+	return limits, nil
+}
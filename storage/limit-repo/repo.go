@@ -0,0 +1,107 @@
+package limitrepo
+
+import (
+	"context"
+	"database/sql"
+	"go-sample/storage"
+	"go-sample/types"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type ILimitRepo interface {
+	SaveLimit(context.Context, *types.Limit) error
+	GetLimit(context.Context, string, string) (*types.Limit, error)
+	ListLimits(context.Context, string) ([]*types.Limit, error)
+}
+
+type LimitRepo struct {
+	db *sqlx.DB
+}
+
+func NewLimitRepo(db *sqlx.DB) LimitRepo {
+	return LimitRepo{db}
+}
+
+const limitColumns = `account_id, operation, currency, per_transaction::decimal, daily_amount::decimal, monthly_amount::decimal,
+	daily_count, updated_at`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanLimit reads limitColumns, limits that are not set come back as nil
+func scanLimit(row scanner) (*types.Limit, error) {
+	var limit types.Limit
+	var currency types.Currency
+	err := row.Scan(
+		&limit.AccountId,
+		&limit.Operation,
+		&currency,
+		&limit.PerTransaction,
+		&limit.DailyAmount,
+		&limit.MonthlyAmount,
+		&limit.DailyCount,
+		&limit.UpdatedAt,
+	)
+	for _, amount := range []*types.Money{limit.PerTransaction, limit.DailyAmount, limit.MonthlyAmount} {
+		if amount != nil {
+			amount.Currency = currency
+		}
+	}
+	return &limit, err
+}
+
+// SaveLimit stores the limits of the operation, replacing the ones already set
+func (lr LimitRepo) SaveLimit(ctx context.Context, limit *types.Limit) error {
+	currency := types.DefaultCurrency
+	for _, amount := range []*types.Money{limit.PerTransaction, limit.DailyAmount, limit.MonthlyAmount} {
+		if amount != nil {
+			currency = amount.Currency
+		}
+	}
+	_, err := storage.Conn(ctx, lr.db).ExecContext(ctx,
+		`INSERT INTO account_limits (account_id, operation, currency, per_transaction, daily_amount, monthly_amount, daily_count, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			ON CONFLICT (account_id, operation) DO UPDATE SET currency = EXCLUDED.currency,
+				per_transaction = EXCLUDED.per_transaction, daily_amount = EXCLUDED.daily_amount,
+				monthly_amount = EXCLUDED.monthly_amount, daily_count = EXCLUDED.daily_count, updated_at = EXCLUDED.updated_at`,
+		limit.AccountId, limit.Operation, currency, nullMoney(limit.PerTransaction), nullMoney(limit.DailyAmount),
+		nullMoney(limit.MonthlyAmount), limit.DailyCount, limit.UpdatedAt)
+	return err
+}
+
+// nullMoney keeps unset limits as NULL, a nil *types.Money would otherwise be handed to its Value method
+func nullMoney(amount *types.Money) interface{} {
+	if amount == nil {
+		return nil
+	}
+	return *amount
+}
+
+func (lr LimitRepo) GetLimit(ctx context.Context, accountId string, operation string) (*types.Limit, error) {
+	return scanLimit(storage.Conn(ctx, lr.db).QueryRowContext(ctx,
+		"SELECT "+limitColumns+" FROM account_limits WHERE account_id = $1 AND operation = $2", accountId, operation))
+}
+
+func (lr LimitRepo) ListLimits(ctx context.Context, accountId string) ([]*types.Limit, error) {
+	rows, err := storage.Conn(ctx, lr.db).QueryContext(ctx,
+		"SELECT "+limitColumns+" FROM account_limits WHERE account_id = $1 ORDER BY operation", accountId)
+	if err != nil {
+		return nil, err
+	}
+	return scanLimits(rows)
+}
+
+func scanLimits(rows *sql.Rows) ([]*types.Limit, error) {
+	defer rows.Close()
+	limits := []*types.Limit{}
+	for rows.Next() {
+		limit, err := scanLimit(rows)
+		if err != nil {
+			return nil, err
+		}
+		limits = append(limits, limit)
+	}
+	return limits, rows.Err()
+}
@@ -0,0 +1,22 @@
+package storage
+
+import "context"
+
+// MemoUnitOfWork is used together with the memo repositories, it only counts the outcomes
+type MemoUnitOfWork struct {
+	Commits   int
+	Rollbacks int
+}
+
+func NewMemoUnitOfWork() *MemoUnitOfWork {
+	return &MemoUnitOfWork{}
+}
+
+func (u *MemoUnitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := fn(ctx); err != nil {
+		u.Rollbacks++
+		return err
+	}
+	u.Commits++
+	return nil
+}
@@ -0,0 +1,63 @@
+package operationrepo
+
+import (
+	"context"
+	"database/sql"
+	"go-sample/types"
+	"time"
+)
+
+type MemoOperationRepo struct {
+	operations []*types.Operation
+}
+
+func NewMemoOperationRepo() *MemoOperationRepo {
+	return &MemoOperationRepo{
+		operations: []*types.Operation{},
+	}
+}
+
+func (or *MemoOperationRepo) CreateOperation(ctx context.Context, operation *types.Operation) error {
+	stored := *operation
+	or.operations = append(or.operations, &stored)
+	return nil
+}
+
+func (or *MemoOperationRepo) GetOperation(ctx context.Context, id string) (*types.Operation, error) {
+	for _, o := range or.operations {
+		if o.ID == id {
+			operation := *o
+			return &operation, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (or *MemoOperationRepo) GetOperationForUpdate(ctx context.Context, id string) (*types.Operation, error) {
+	return or.GetOperation(ctx, id)
+}
+
+func (or *MemoOperationRepo) UpdateOperation(ctx context.Context, operation *types.Operation) error {
+	for i, o := range or.operations {
+		if o.ID == operation.ID {
+			stored := *operation
+			or.operations[i] = &stored
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (or *MemoOperationRepo) ListRunnableOperations(ctx context.Context, now time.Time, limit int) ([]*types.Operation, error) {
+	operations := []*types.Operation{}
+	for _, o := range or.operations {
+		if len(operations) == limit {
+			break
+		}
+		if o.Status == types.OperationPending || o.IsInterrupted(now) {
+			operation := *o
+			operations = append(operations, &operation)
+		}
+	}
+	return operations, nil
+}
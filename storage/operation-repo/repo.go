@@ -0,0 +1,122 @@
+package operationrepo
+
+import (
+	"context"
+	"database/sql"
+	"go-sample/storage"
+	"go-sample/types"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type IOperationRepo interface {
+	CreateOperation(context.Context, *types.Operation) error
+	GetOperation(context.Context, string) (*types.Operation, error)
+	GetOperationForUpdate(context.Context, string) (*types.Operation, error)
+	UpdateOperation(context.Context, *types.Operation) error
+	ListRunnableOperations(context.Context, time.Time, int) ([]*types.Operation, error)
+}
+
+type OperationRepo struct {
+	db *sqlx.DB
+}
+
+func NewOperationRepo(db *sqlx.DB) OperationRepo {
+	return OperationRepo{db}
+}
+
+const operationColumns = `id, kind, request, status, result, error, locked_until, created_at, updated_at, completed_at`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanOperation(row scanner) (*types.Operation, error) {
+	var operation types.Operation
+	var request, result []byte
+	var lockedUntil, completedAt sql.NullTime
+	err := row.Scan(
+		&operation.ID,
+		&operation.Kind,
+		&request,
+		&operation.Status,
+		&result,
+		&operation.Error,
+		&lockedUntil,
+		&operation.CreatedAt,
+		&operation.UpdatedAt,
+		&completedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	operation.Request = request
+	operation.Result = result
+	if lockedUntil.Valid {
+		operation.LockedUntil = &lockedUntil.Time
+	}
+	if completedAt.Valid {
+		operation.CompletedAt = &completedAt.Time
+	}
+	return &operation, nil
+}
+
+func (or OperationRepo) CreateOperation(ctx context.Context, operation *types.Operation) error {
+	_, err := storage.Conn(ctx, or.db).ExecContext(ctx,
+		`INSERT INTO operations (id, kind, request, status, result, error, locked_until, created_at, updated_at, completed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		operation.ID, operation.Kind, []byte(operation.Request), operation.Status, nullableJSON(operation.Result),
+		operation.Error, operation.LockedUntil, operation.CreatedAt, operation.UpdatedAt, operation.CompletedAt)
+	return err
+}
+
+func (or OperationRepo) GetOperation(ctx context.Context, id string) (*types.Operation, error) {
+	return scanOperation(storage.Conn(ctx, or.db).QueryRowContext(ctx,
+		"SELECT "+operationColumns+" FROM operations WHERE id = $1", id))
+}
+
+// GetOperationForUpdate locks the operation row so only one worker claims it
+func (or OperationRepo) GetOperationForUpdate(ctx context.Context, id string) (*types.Operation, error) {
+	return scanOperation(storage.Conn(ctx, or.db).QueryRowContext(ctx,
+		"SELECT "+operationColumns+" FROM operations WHERE id = $1 FOR UPDATE", id))
+}
+
+func (or OperationRepo) UpdateOperation(ctx context.Context, operation *types.Operation) error {
+	_, err := storage.Conn(ctx, or.db).ExecContext(ctx,
+		`UPDATE operations SET status = $1, result = $2, error = $3, locked_until = $4, updated_at = $5, completed_at = $6
+		WHERE id = $7`,
+		operation.Status, nullableJSON(operation.Result), operation.Error, operation.LockedUntil, operation.UpdatedAt,
+		operation.CompletedAt, operation.ID)
+	return err
+}
+
+// ListRunnableOperations returns up to limit operations waiting for a worker or left running past their
+// lease at now, the oldest first
+func (or OperationRepo) ListRunnableOperations(ctx context.Context, now time.Time, limit int) ([]*types.Operation, error) {
+	rows, err := storage.Conn(ctx, or.db).QueryContext(ctx,
+		"SELECT "+operationColumns+` FROM operations WHERE status = $1 OR (status = $2 AND locked_until <= $3)
+		ORDER BY created_at, id LIMIT $4`,
+		types.OperationPending, types.OperationRunning, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	operations := []*types.Operation{}
+	for rows.Next() {
+		operation, err := scanOperation(rows)
+		if err != nil {
+			return nil, err
+		}
+		operations = append(operations, operation)
+	}
+	return operations, rows.Err()
+}
+
+// nullableJSON stores an empty result as NULL rather than as an invalid JSONB value
+func nullableJSON(body []byte) interface{} {
+	if len(body) == 0 {
+		return nil
+	}
+	return body
+}
@@ -0,0 +1,52 @@
+package outboxrepo
+
+import (
+	"context"
+	"database/sql"
+	"go-sample/types"
+)
+
+type MemoOutboxRepo struct {
+	events []*types.Event
+}
+
+func NewMemoOutboxRepo() *MemoOutboxRepo {
+	return &MemoOutboxRepo{
+		events: []*types.Event{},
+	}
+}
+
+func (or *MemoOutboxRepo) CreateEvent(ctx context.Context, event *types.Event) error {
+	stored := *event
+	or.events = append(or.events, &stored)
+	return nil
+}
+
+func (or *MemoOutboxRepo) ListPendingEvents(ctx context.Context, limit int) ([]*types.Event, error) {
+	events := []*types.Event{}
+	for _, e := range or.events {
+		if len(events) == limit {
+			break
+		}
+		if e.DispatchedAt == nil {
+			event := *e
+			events = append(events, &event)
+		}
+	}
+	return events, nil
+}
+
+func (or *MemoOutboxRepo) MarkEventDispatched(ctx context.Context, event *types.Event) error {
+	for _, e := range or.events {
+		if e.ID == event.ID {
+			e.DispatchedAt = event.DispatchedAt
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+// Events returns every event written so far, the oldest first
+func (or *MemoOutboxRepo) Events() []*types.Event {
+	return or.events
+}
@@ -0,0 +1,68 @@
+package outboxrepo
+
+import (
+	"context"
+	"database/sql"
+	"go-sample/storage"
+	"go-sample/types"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type IOutboxRepo interface {
+	CreateEvent(context.Context, *types.Event) error
+	ListPendingEvents(context.Context, int) ([]*types.Event, error)
+	MarkEventDispatched(context.Context, *types.Event) error
+}
+
+type OutboxRepo struct {
+	db *sqlx.DB
+}
+
+func NewOutboxRepo(db *sqlx.DB) OutboxRepo {
+	return OutboxRepo{db}
+}
+
+const eventColumns = `id, type, payload, created_at, dispatched_at`
+
+// CreateEvent must run in the unit of work of the change it reports, so the event exists if and only if the change does
+func (or OutboxRepo) CreateEvent(ctx context.Context, event *types.Event) error {
+	_, err := storage.Conn(ctx, or.db).ExecContext(ctx,
+		`INSERT INTO outbox_events (id, type, payload, created_at, dispatched_at) VALUES ($1, $2, $3, $4, $5)`,
+		event.ID, event.Type, []byte(event.Data), event.CreatedAt, event.DispatchedAt)
+	return err
+}
+
+// ListPendingEvents locks up to limit events not dispatched yet, the oldest first. Events locked by another
+// dispatcher are skipped, so the surrounding unit of work must mark them dispatched before it ends
+func (or OutboxRepo) ListPendingEvents(ctx context.Context, limit int) ([]*types.Event, error) {
+	rows, err := storage.Conn(ctx, or.db).QueryContext(ctx,
+		"SELECT "+eventColumns+" FROM outbox_events WHERE dispatched_at IS NULL ORDER BY created_at, id LIMIT $1 FOR UPDATE SKIP LOCKED",
+		limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*types.Event{}
+	for rows.Next() {
+		var event types.Event
+		var data []byte
+		var dispatchedAt sql.NullTime
+		if err := rows.Scan(&event.ID, &event.Type, &data, &event.CreatedAt, &dispatchedAt); err != nil {
+			return nil, err
+		}
+		event.Data = data
+		if dispatchedAt.Valid {
+			event.DispatchedAt = &dispatchedAt.Time
+		}
+		events = append(events, &event)
+	}
+	return events, rows.Err()
+}
+
+func (or OutboxRepo) MarkEventDispatched(ctx context.Context, event *types.Event) error {
+	_, err := storage.Conn(ctx, or.db).ExecContext(ctx,
+		`UPDATE outbox_events SET dispatched_at = $1 WHERE id = $2`, event.DispatchedAt, event.ID)
+	return err
+}
@@ -0,0 +1,68 @@
+package ownerrepo
+
+import (
+	"context"
+	"database/sql"
+	requestparams "go-sample/api/handlers/request-params"
+	"go-sample/types"
+)
+
+type MemoOwnerRepo struct {
+	owners []*types.Owner
+}
+
+func NewMemoOwnerRepo() *MemoOwnerRepo {
+	return &MemoOwnerRepo{
+		owners: []*types.Owner{},
+	}
+}
+
+func (or *MemoOwnerRepo) CreateOwner(ctx context.Context, owner *types.Owner) error {
+	for _, o := range or.owners {
+		if o.NationalId == owner.NationalId {
+			return ErrDuplicateNationalId
+		}
+	}
+	stored := *owner
+	or.owners = append(or.owners, &stored)
+	return nil
+}
+
+func (or *MemoOwnerRepo) GetOwner(ctx context.Context, id string) (*types.Owner, error) {
+	for _, o := range or.owners {
+		if o.ID == id && o.DeletedAt == nil {
+			owner := *o
+			return &owner, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (or *MemoOwnerRepo) ListOwners(ctx context.Context, filters requestparams.ListOwnersRequest) (*types.Page[*types.Owner], error) {
+	owners := []*types.Owner{}
+	for _, o := range or.owners {
+		if o.DeletedAt == nil && (filters.KYCStatusFilter == "" || o.KYCStatus == filters.KYCStatusFilter) {
+			owners = append(owners, o)
+		}
+	}
+	return &types.Page[*types.Owner]{Data: owners}, nil
+}
+
+func (or *MemoOwnerRepo) UpdateOwner(ctx context.Context, owner *types.Owner) error {
+	return or.replace(owner)
+}
+
+func (or *MemoOwnerRepo) DeleteOwner(ctx context.Context, owner *types.Owner) error {
+	return or.replace(owner)
+}
+
+func (or *MemoOwnerRepo) replace(owner *types.Owner) error {
+	for i, o := range or.owners {
+		if o.ID == owner.ID {
+			stored := *owner
+			or.owners[i] = &stored
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
@@ -0,0 +1,137 @@
+package ownerrepo
+
+import (
+	"context"
+	"errors"
+	requestparams "go-sample/api/handlers/request-params"
+	"go-sample/storage"
+	"go-sample/types"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+var ErrDuplicateNationalId = errors.New("DuplicateNationalId")
+
+type IOwnerRepo interface {
+	CreateOwner(context.Context, *types.Owner) error
+	GetOwner(context.Context, string) (*types.Owner, error)
+	ListOwners(context.Context, requestparams.ListOwnersRequest) (*types.Page[*types.Owner], error)
+	UpdateOwner(context.Context, *types.Owner) error
+	DeleteOwner(context.Context, *types.Owner) error
+}
+
+type OwnerRepo struct {
+	db *sqlx.DB
+}
+
+func NewOwnerRepo(db *sqlx.DB) OwnerRepo {
+	return OwnerRepo{db}
+}
+
+const ownerColumns = `id, name, national_id, email, phone, address, kyc_status, created_at, updated_at, deleted_at`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanOwner(row scanner) (*types.Owner, error) {
+	var owner types.Owner
+	err := row.Scan(
+		&owner.ID,
+		&owner.Name,
+		&owner.NationalId,
+		&owner.Email,
+		&owner.Phone,
+		&owner.Address,
+		&owner.KYCStatus,
+		&owner.CreatedAt,
+		&owner.UpdatedAt,
+		&owner.DeletedAt,
+	)
+	return &owner, err
+}
+
+// CreateOwner returns ErrDuplicateNationalId when another owner already uses the national id
+func (or OwnerRepo) CreateOwner(ctx context.Context, owner *types.Owner) error {
+	_, err := storage.Conn(ctx, or.db).ExecContext(ctx,
+		`INSERT INTO owners (`+ownerColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		owner.ID, owner.Name, owner.NationalId, owner.Email, owner.Phone, owner.Address, owner.KYCStatus,
+		owner.CreatedAt, owner.UpdatedAt, owner.DeletedAt)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrDuplicateNationalId
+	}
+	return err
+}
+
+// GetOwner ignores deleted owners
+func (or OwnerRepo) GetOwner(ctx context.Context, id string) (*types.Owner, error) {
+	return scanOwner(storage.Conn(ctx, or.db).QueryRowContext(ctx,
+		"SELECT "+ownerColumns+" FROM owners WHERE id = $1 AND deleted_at IS NULL", id))
+}
+
+func (or OwnerRepo) ListOwners(ctx context.Context, filters requestparams.ListOwnersRequest) (*types.Page[*types.Owner], error) {
+	var where storage.Where
+	where.Add("deleted_at IS NULL")
+	if filters.Name != "" {
+		where.Add("name ILIKE ?", "%"+filters.Name+"%")
+	}
+	if filters.KYCStatusFilter != "" {
+		where.Add("kyc_status = ?", filters.KYCStatusFilter)
+	}
+
+	var total *int64
+	if filters.IncludeTotal() {
+		var count int64
+		err := storage.Conn(ctx, or.db).QueryRowContext(ctx, "SELECT COUNT(*) FROM owners "+where.String(), where.Args()...).Scan(&count)
+		if err != nil {
+			return nil, err
+		}
+		total = &count
+	}
+
+	tail, err := storage.PageQuery(&where, filters.Pagination, "created_at", "ASC")
+	if err != nil {
+		return nil, err
+	}
+	rows, err := storage.Conn(ctx, or.db).QueryContext(ctx,
+		"SELECT "+ownerColumns+" FROM owners "+where.String()+" "+tail, where.Args()...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var owners []*types.Owner
+	for rows.Next() {
+		owner, err := scanOwner(rows)
+		if err != nil {
+			return nil, err
+		}
+		owners = append(owners, owner)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	page := storage.NewPage(owners, filters.Pagination, func(owner *types.Owner) types.Cursor {
+		return types.NewTimeCursor("created_at", owner.CreatedAt, owner.ID)
+	})
+	page.Total = total
+	return page, nil
+}
+
+func (or OwnerRepo) UpdateOwner(ctx context.Context, owner *types.Owner) error {
+	_, err := storage.Conn(ctx, or.db).ExecContext(ctx,
+		`UPDATE owners SET name = $1, email = $2, phone = $3, address = $4, kyc_status = $5, updated_at = $6
+			WHERE id = $7`,
+		owner.Name, owner.Email, owner.Phone, owner.Address, owner.KYCStatus, owner.UpdatedAt, owner.ID)
+	return err
+}
+
+// DeleteOwner soft deletes the owner, the national id stays taken
+func (or OwnerRepo) DeleteOwner(ctx context.Context, owner *types.Owner) error {
+	_, err := storage.Conn(ctx, or.db).ExecContext(ctx,
+		"UPDATE owners SET deleted_at = $1, updated_at = $1 WHERE id = $2", owner.DeletedAt, owner.ID)
+	return err
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"fmt"
+	requestparams "go-sample/api/handlers/request-params"
+	"go-sample/types"
+)
+
+// PageQuery adds the keyset condition of the cursor to where and returns the ORDER BY ... LIMIT tail
+// of a list ordered by (column, id) in order. One row more than the page size is fetched so
+// NewPage can tell whether there is a next page. Cursors issued for another sort column are refused
+func PageQuery(where *Where, pagination requestparams.Pagination, column string, order string) (string, error) {
+	cursor := pagination.DecodedCursor()
+	if cursor != nil && cursor.Column != column {
+		return "", types.ErrInvalidCursor
+	}
+	if cursor != nil && cursor.Backward {
+		order = reverseOrder(order)
+	}
+	if cursor != nil {
+		operator := ">"
+		if order == "DESC" {
+			operator = "<"
+		}
+		where.Add(fmt.Sprintf("(%s, id) %s (?, ?)", column, operator), cursor.Value, cursor.ID)
+	}
+
+	tail := fmt.Sprintf("ORDER BY %s %s, id %s LIMIT %s", column, order, order, where.Arg(pagination.PageSize()+1))
+	if offset := pagination.Offset(); offset > 0 {
+		tail += " OFFSET " + where.Arg(offset)
+	}
+	return tail, nil
+}
+
+// NewPage drops the extra row fetched by PageQuery, restores the list order after a backward
+// cursor and sets the cursors of the neighbouring pages
+func NewPage[T any](items []T, pagination requestparams.Pagination, key func(T) types.Cursor) *types.Page[T] {
+	cursor := pagination.DecodedCursor()
+	backward := cursor != nil && cursor.Backward
+
+	hasMore := int64(len(items)) > pagination.PageSize()
+	if hasMore {
+		items = items[:pagination.PageSize()]
+	}
+	if backward {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+
+	page := &types.Page[T]{Data: items}
+	if items == nil {
+		page.Data = []T{}
+	}
+	if len(items) == 0 {
+		return page
+	}
+
+	hasNext := hasMore || backward
+	hasPrev := (backward && hasMore) || (!backward && (cursor != nil || pagination.Offset() > 0))
+	if hasNext {
+		page.NextCursor = key(items[len(items)-1]).Encode()
+	}
+	if hasPrev {
+		prev := key(items[0])
+		prev.Backward = true
+		page.PrevCursor = prev.Encode()
+	}
+	return page
+}
+
+func reverseOrder(order string) string {
+	if order == "DESC" {
+		return "ASC"
+	}
+	return "DESC"
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	requestparams "go-sample/api/handlers/request-params"
+	"go-sample/types"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPagination(t *testing.T) {
+	now := time.Now()
+	key := func(id string) types.Cursor { return types.NewTimeCursor("created_at", now, id) }
+
+	t.Run("PageQuery should use the cursor as a keyset condition and fetch one extra row", func(t *testing.T) {
+		var where Where
+		where.Add("owner_id = ?", "some owner")
+		pagination := requestparams.Pagination{Limit: "2", Cursor: key("b").Encode()}
+
+		tail, err := PageQuery(&where, pagination, "created_at", "ASC")
+		assert.Nil(t, err)
+
+		assert.Equal(t, "WHERE owner_id = $1 AND (created_at, id) > ($2, $3)", where.String())
+		assert.Equal(t, "ORDER BY created_at ASC, id ASC LIMIT $4", tail)
+		assert.Equal(t, int64(3), where.Args()[3])
+	})
+
+	t.Run("PageQuery should walk backwards and keep offsets for page numbers", func(t *testing.T) {
+		backward := key("b")
+		backward.Backward = true
+
+		var where Where
+		tail, err := PageQuery(&where, requestparams.Pagination{Limit: "2", Cursor: backward.Encode()}, "created_at", "DESC")
+		assert.Nil(t, err)
+		assert.Equal(t, "WHERE (created_at, id) > ($1, $2)", where.String())
+		assert.Equal(t, "ORDER BY created_at ASC, id ASC LIMIT $3", tail)
+
+		where = Where{}
+		tail, err = PageQuery(&where, requestparams.Pagination{Limit: "2", Page: "3"}, "created_at", "ASC")
+		assert.Nil(t, err)
+		assert.Equal(t, "ORDER BY created_at ASC, id ASC LIMIT $1 OFFSET $2", tail)
+		assert.Equal(t, []interface{}{int64(3), int64(4)}, where.Args())
+	})
+
+	t.Run("PageQuery should refuse a cursor issued for another sort column", func(t *testing.T) {
+		var where Where
+		_, err := PageQuery(&where, requestparams.Pagination{Cursor: key("b").Encode()}, "balance", "ASC")
+		assert.ErrorIs(t, err, types.ErrInvalidCursor)
+	})
+
+	t.Run("PageQuery should cap the page size", func(t *testing.T) {
+		var where Where
+		PageQuery(&where, requestparams.Pagination{Limit: "5000"}, "created_at", "ASC")
+		assert.Equal(t, int64(requestparams.MaxPageSize+1), where.Args()[0])
+	})
+
+	t.Run("NewPage should trim the extra row and point at the neighbouring pages", func(t *testing.T) {
+		first := NewPage([]string{"a", "b", "c"}, requestparams.Pagination{Limit: "2"}, key)
+		assert.Equal(t, []string{"a", "b"}, first.Data)
+		assert.Empty(t, first.PrevCursor)
+
+		next, err := types.DecodeCursor(first.NextCursor)
+		assert.Nil(t, err)
+		assert.Equal(t, "b", next.ID)
+
+		last := NewPage([]string{"c"}, requestparams.Pagination{Limit: "2", Cursor: first.NextCursor}, key)
+		assert.Empty(t, last.NextCursor)
+		prev, err := types.DecodeCursor(last.PrevCursor)
+		assert.Nil(t, err)
+		assert.Equal(t, "c", prev.ID)
+		assert.True(t, prev.Backward)
+	})
+
+	t.Run("NewPage should restore the order of a backward page", func(t *testing.T) {
+		backward := key("c")
+		backward.Backward = true
+
+		page := NewPage([]string{"b", "a"}, requestparams.Pagination{Limit: "2", Cursor: backward.Encode()}, key)
+		assert.Equal(t, []string{"a", "b"}, page.Data)
+		assert.Empty(t, page.PrevCursor)
+		assert.NotEmpty(t, page.NextCursor)
+	})
+
+	t.Run("NewPage should return an empty list rather than null", func(t *testing.T) {
+		page := NewPage[string](nil, requestparams.Pagination{}, key)
+		assert.NotNil(t, page.Data)
+	})
+}
@@ -1 +1,31 @@
 package storage
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// DBTX is the subset of *sqlx.DB and *sqlx.Tx the repositories use to run queries
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type txKey struct{}
+
+// Conn returns the transaction of the unit of work running in ctx, or db when there is none,
+// so every repository call made inside UnitOfWork.Do joins the same database transaction
+func Conn(ctx context.Context, db *sqlx.DB) DBTX {
+	if tx, ok := ctx.Value(txKey{}).(*sqlx.Tx); ok {
+		return tx
+	}
+	return db
+}
+
+func InTransaction(ctx context.Context) bool {
+	_, ok := ctx.Value(txKey{}).(*sqlx.Tx)
+	return ok
+}
@@ -0,0 +1,149 @@
+package schedulerepo
+
+import (
+	"context"
+	"database/sql"
+	requestparams "go-sample/api/handlers/request-params"
+	"go-sample/types"
+	"time"
+)
+
+type MemoScheduleRepo struct {
+	transfers []*types.ScheduledTransfer
+	orders    []*types.StandingOrder
+	runs      []*types.StandingOrderRun
+}
+
+func NewMemoScheduleRepo() *MemoScheduleRepo {
+	return &MemoScheduleRepo{
+		transfers: []*types.ScheduledTransfer{},
+		orders:    []*types.StandingOrder{},
+		runs:      []*types.StandingOrderRun{},
+	}
+}
+
+func (sr *MemoScheduleRepo) CreateScheduledTransfer(ctx context.Context, transfer *types.ScheduledTransfer) error {
+	stored := *transfer
+	sr.transfers = append(sr.transfers, &stored)
+	return nil
+}
+
+func (sr *MemoScheduleRepo) GetScheduledTransfer(ctx context.Context, id string) (*types.ScheduledTransfer, error) {
+	for _, s := range sr.transfers {
+		if s.ID == id {
+			transfer := *s
+			return &transfer, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (sr *MemoScheduleRepo) GetScheduledTransferForUpdate(ctx context.Context, id string) (*types.ScheduledTransfer, error) {
+	return sr.GetScheduledTransfer(ctx, id)
+}
+
+func (sr *MemoScheduleRepo) UpdateScheduledTransfer(ctx context.Context, transfer *types.ScheduledTransfer) error {
+	for i, s := range sr.transfers {
+		if s.ID == transfer.ID {
+			stored := *transfer
+			sr.transfers[i] = &stored
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (sr *MemoScheduleRepo) ListScheduledTransfers(ctx context.Context, accountId string, filters requestparams.ListScheduledTransfersRequest) (*types.Page[*types.ScheduledTransfer], error) {
+	transfers := []*types.ScheduledTransfer{}
+	for _, s := range sr.transfers {
+		if s.From == accountId && (filters.StatusFilter == "" || s.Status == filters.StatusFilter) {
+			transfers = append(transfers, s)
+		}
+	}
+	return &types.Page[*types.ScheduledTransfer]{Data: transfers}, nil
+}
+
+func (sr *MemoScheduleRepo) ListDueTransfers(ctx context.Context, now time.Time, limit int) ([]*types.ScheduledTransfer, error) {
+	transfers := []*types.ScheduledTransfer{}
+	for _, s := range sr.transfers {
+		if len(transfers) == limit {
+			break
+		}
+		if s.IsDue(now) {
+			transfer := *s
+			transfers = append(transfers, &transfer)
+		}
+	}
+	return transfers, nil
+}
+
+func (sr *MemoScheduleRepo) CreateStandingOrder(ctx context.Context, order *types.StandingOrder) error {
+	stored := *order
+	sr.orders = append(sr.orders, &stored)
+	return nil
+}
+
+func (sr *MemoScheduleRepo) GetStandingOrder(ctx context.Context, id string) (*types.StandingOrder, error) {
+	for _, o := range sr.orders {
+		if o.ID == id {
+			order := *o
+			return &order, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (sr *MemoScheduleRepo) GetStandingOrderForUpdate(ctx context.Context, id string) (*types.StandingOrder, error) {
+	return sr.GetStandingOrder(ctx, id)
+}
+
+func (sr *MemoScheduleRepo) UpdateStandingOrder(ctx context.Context, order *types.StandingOrder) error {
+	for i, o := range sr.orders {
+		if o.ID == order.ID {
+			stored := *order
+			sr.orders[i] = &stored
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (sr *MemoScheduleRepo) ListStandingOrders(ctx context.Context, accountId string, filters requestparams.ListStandingOrdersRequest) (*types.Page[*types.StandingOrder], error) {
+	orders := []*types.StandingOrder{}
+	for _, o := range sr.orders {
+		if o.From == accountId && (filters.StatusFilter == "" || o.Status == filters.StatusFilter) {
+			orders = append(orders, o)
+		}
+	}
+	return &types.Page[*types.StandingOrder]{Data: orders}, nil
+}
+
+func (sr *MemoScheduleRepo) ListDueStandingOrders(ctx context.Context, now time.Time, limit int) ([]*types.StandingOrder, error) {
+	orders := []*types.StandingOrder{}
+	for _, o := range sr.orders {
+		if len(orders) == limit {
+			break
+		}
+		if o.IsDue(now) {
+			order := *o
+			orders = append(orders, &order)
+		}
+	}
+	return orders, nil
+}
+
+func (sr *MemoScheduleRepo) CreateStandingOrderRun(ctx context.Context, run *types.StandingOrderRun) error {
+	stored := *run
+	sr.runs = append(sr.runs, &stored)
+	return nil
+}
+
+func (sr *MemoScheduleRepo) ListStandingOrderRuns(ctx context.Context, orderId string, pagination requestparams.Pagination) (*types.Page[*types.StandingOrderRun], error) {
+	runs := []*types.StandingOrderRun{}
+	for i := len(sr.runs) - 1; i >= 0; i-- {
+		if sr.runs[i].OrderId == orderId {
+			runs = append(runs, sr.runs[i])
+		}
+	}
+	return &types.Page[*types.StandingOrderRun]{Data: runs}, nil
+}
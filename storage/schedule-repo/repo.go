@@ -0,0 +1,406 @@
+package schedulerepo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	requestparams "go-sample/api/handlers/request-params"
+	"go-sample/storage"
+	"go-sample/types"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type IScheduleRepo interface {
+	CreateScheduledTransfer(context.Context, *types.ScheduledTransfer) error
+	GetScheduledTransfer(context.Context, string) (*types.ScheduledTransfer, error)
+	GetScheduledTransferForUpdate(context.Context, string) (*types.ScheduledTransfer, error)
+	UpdateScheduledTransfer(context.Context, *types.ScheduledTransfer) error
+	ListScheduledTransfers(context.Context, string, requestparams.ListScheduledTransfersRequest) (*types.Page[*types.ScheduledTransfer], error)
+	ListDueTransfers(context.Context, time.Time, int) ([]*types.ScheduledTransfer, error)
+	CreateStandingOrder(context.Context, *types.StandingOrder) error
+	GetStandingOrder(context.Context, string) (*types.StandingOrder, error)
+	GetStandingOrderForUpdate(context.Context, string) (*types.StandingOrder, error)
+	UpdateStandingOrder(context.Context, *types.StandingOrder) error
+	ListStandingOrders(context.Context, string, requestparams.ListStandingOrdersRequest) (*types.Page[*types.StandingOrder], error)
+	ListDueStandingOrders(context.Context, time.Time, int) ([]*types.StandingOrder, error)
+	CreateStandingOrderRun(context.Context, *types.StandingOrderRun) error
+	ListStandingOrderRuns(context.Context, string, requestparams.Pagination) (*types.Page[*types.StandingOrderRun], error)
+}
+
+type ScheduleRepo struct {
+	db *sqlx.DB
+}
+
+func NewScheduleRepo(db *sqlx.DB) ScheduleRepo {
+	return ScheduleRepo{db}
+}
+
+const scheduledTransferColumns = `id, from_account, currency, amount::decimal, subject, legs, execute_at, status, batch_id,
+	failure_reason, executed_at, created_at, updated_at`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanScheduledTransfer(row scanner) (*types.ScheduledTransfer, error) {
+	var transfer types.ScheduledTransfer
+	var currency types.Currency
+	var legs []byte
+	var executedAt sql.NullTime
+	err := row.Scan(
+		&transfer.ID,
+		&transfer.From,
+		&currency,
+		&transfer.Amount,
+		&transfer.Subject,
+		&legs,
+		&transfer.ExecuteAt,
+		&transfer.Status,
+		&transfer.BatchId,
+		&transfer.FailureReason,
+		&executedAt,
+		&transfer.CreatedAt,
+		&transfer.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	transfer.Amount.Currency = currency
+	if executedAt.Valid {
+		transfer.ExecutedAt = &executedAt.Time
+	}
+	if err := json.Unmarshal(legs, &transfer.Legs); err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+func scanScheduledTransfers(rows *sql.Rows) ([]*types.ScheduledTransfer, error) {
+	defer rows.Close()
+	transfers := []*types.ScheduledTransfer{}
+	for rows.Next() {
+		transfer, err := scanScheduledTransfer(rows)
+		if err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, transfer)
+	}
+	return transfers, rows.Err()
+}
+
+func (sr ScheduleRepo) CreateScheduledTransfer(ctx context.Context, transfer *types.ScheduledTransfer) error {
+	legs, err := json.Marshal(transfer.Legs)
+	if err != nil {
+		return err
+	}
+	_, err = storage.Conn(ctx, sr.db).ExecContext(ctx,
+		`INSERT INTO scheduled_transfers (id, from_account, currency, amount, subject, legs, execute_at, status, batch_id,
+			failure_reason, executed_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+		transfer.ID, transfer.From, transfer.Amount.Currency, transfer.Amount, transfer.Subject, legs, transfer.ExecuteAt,
+		transfer.Status, transfer.BatchId, transfer.FailureReason, transfer.ExecutedAt, transfer.CreatedAt, transfer.UpdatedAt)
+	return err
+}
+
+func (sr ScheduleRepo) GetScheduledTransfer(ctx context.Context, id string) (*types.ScheduledTransfer, error) {
+	return scanScheduledTransfer(storage.Conn(ctx, sr.db).QueryRowContext(ctx,
+		"SELECT "+scheduledTransferColumns+" FROM scheduled_transfers WHERE id = $1", id))
+}
+
+// GetScheduledTransferForUpdate locks the transfer until the surrounding unit of work ends, so it is
+// cancelled or picked up for execution only once
+func (sr ScheduleRepo) GetScheduledTransferForUpdate(ctx context.Context, id string) (*types.ScheduledTransfer, error) {
+	return scanScheduledTransfer(storage.Conn(ctx, sr.db).QueryRowContext(ctx,
+		"SELECT "+scheduledTransferColumns+" FROM scheduled_transfers WHERE id = $1 FOR UPDATE", id))
+}
+
+func (sr ScheduleRepo) UpdateScheduledTransfer(ctx context.Context, transfer *types.ScheduledTransfer) error {
+	legs, err := json.Marshal(transfer.Legs)
+	if err != nil {
+		return err
+	}
+	_, err = storage.Conn(ctx, sr.db).ExecContext(ctx,
+		`UPDATE scheduled_transfers SET legs = $1, status = $2, batch_id = $3, failure_reason = $4, executed_at = $5,
+			updated_at = $6 WHERE id = $7`,
+		legs, transfer.Status, transfer.BatchId, transfer.FailureReason, transfer.ExecutedAt, transfer.UpdatedAt, transfer.ID)
+	return err
+}
+
+// ListScheduledTransfers pages through the transfers scheduled from the account, newest first
+func (sr ScheduleRepo) ListScheduledTransfers(ctx context.Context, accountId string, filters requestparams.ListScheduledTransfersRequest) (*types.Page[*types.ScheduledTransfer], error) {
+	var where storage.Where
+	where.Add("from_account = ?", accountId)
+	if filters.StatusFilter != "" {
+		where.Add("status = ?", filters.StatusFilter)
+	}
+
+	var total *int64
+	if filters.IncludeTotal() {
+		var count int64
+		err := storage.Conn(ctx, sr.db).QueryRowContext(ctx, "SELECT COUNT(*) FROM scheduled_transfers "+where.String(), where.Args()...).Scan(&count)
+		if err != nil {
+			return nil, err
+		}
+		total = &count
+	}
+
+	tail, err := storage.PageQuery(&where, filters.Pagination, "created_at", "DESC")
+	if err != nil {
+		return nil, err
+	}
+	rows, err := storage.Conn(ctx, sr.db).QueryContext(ctx,
+		"SELECT "+scheduledTransferColumns+" FROM scheduled_transfers "+where.String()+" "+tail, where.Args()...)
+	if err != nil {
+		return nil, err
+	}
+	transfers, err := scanScheduledTransfers(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	page := storage.NewPage(transfers, filters.Pagination, func(transfer *types.ScheduledTransfer) types.Cursor {
+		return types.NewTimeCursor("created_at", transfer.CreatedAt, transfer.ID)
+	})
+	page.Total = total
+	return page, nil
+}
+
+// ListDueTransfers returns up to limit scheduled transfers due at now, the ones due first come first
+func (sr ScheduleRepo) ListDueTransfers(ctx context.Context, now time.Time, limit int) ([]*types.ScheduledTransfer, error) {
+	rows, err := storage.Conn(ctx, sr.db).QueryContext(ctx,
+		"SELECT "+scheduledTransferColumns+" FROM scheduled_transfers WHERE status = $1 AND execute_at <= $2 ORDER BY execute_at, id LIMIT $3",
+		types.ScheduleScheduled, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanScheduledTransfers(rows)
+}
+
+const standingOrderColumns = `id, from_account, currency, amount::decimal, subject, beneficiaries, recurrence, retry_window,
+	max_failures, status, occurrence, due_at, next_run_at, attempts, consecutive_failures, created_at, updated_at`
+
+func scanStandingOrder(row scanner) (*types.StandingOrder, error) {
+	var order types.StandingOrder
+	var currency types.Currency
+	var beneficiaries, recurrence []byte
+	err := row.Scan(
+		&order.ID,
+		&order.From,
+		&currency,
+		&order.Amount,
+		&order.Subject,
+		&beneficiaries,
+		&recurrence,
+		&order.RetryWindow,
+		&order.MaxFailures,
+		&order.Status,
+		&order.Occurrence,
+		&order.DueAt,
+		&order.NextRunAt,
+		&order.Attempts,
+		&order.ConsecutiveFailures,
+		&order.CreatedAt,
+		&order.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	order.Amount.Currency = currency
+	if err := json.Unmarshal(beneficiaries, &order.Beneficiaries); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(recurrence, &order.Recurrence); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func scanStandingOrders(rows *sql.Rows) ([]*types.StandingOrder, error) {
+	defer rows.Close()
+	orders := []*types.StandingOrder{}
+	for rows.Next() {
+		order, err := scanStandingOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+	return orders, rows.Err()
+}
+
+func (sr ScheduleRepo) CreateStandingOrder(ctx context.Context, order *types.StandingOrder) error {
+	beneficiaries, err := json.Marshal(order.Beneficiaries)
+	if err != nil {
+		return err
+	}
+	recurrence, err := json.Marshal(order.Recurrence)
+	if err != nil {
+		return err
+	}
+	_, err = storage.Conn(ctx, sr.db).ExecContext(ctx,
+		`INSERT INTO standing_orders (id, from_account, currency, amount, subject, beneficiaries, recurrence, retry_window,
+			max_failures, status, occurrence, due_at, next_run_at, attempts, consecutive_failures, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)`,
+		order.ID, order.From, order.Amount.Currency, order.Amount, order.Subject, beneficiaries, recurrence, order.RetryWindow,
+		order.MaxFailures, order.Status, order.Occurrence, order.DueAt, order.NextRunAt, order.Attempts,
+		order.ConsecutiveFailures, order.CreatedAt, order.UpdatedAt)
+	return err
+}
+
+func (sr ScheduleRepo) GetStandingOrder(ctx context.Context, id string) (*types.StandingOrder, error) {
+	return scanStandingOrder(storage.Conn(ctx, sr.db).QueryRowContext(ctx,
+		"SELECT "+standingOrderColumns+" FROM standing_orders WHERE id = $1", id))
+}
+
+// GetStandingOrderForUpdate reads the order FOR UPDATE, so every
+// attempt is claimed and recorded only once
+func (sr ScheduleRepo) GetStandingOrderForUpdate(ctx context.Context, id string) (*types.StandingOrder, error) {
+	return scanStandingOrder(storage.Conn(ctx, sr.db).QueryRowContext(ctx,
+		"SELECT "+standingOrderColumns+" FROM standing_orders WHERE id = $1 FOR UPDATE", id))
+}
+
+func (sr ScheduleRepo) UpdateStandingOrder(ctx context.Context, order *types.StandingOrder) error {
+	_, err := storage.Conn(ctx, sr.db).ExecContext(ctx,
+		`UPDATE standing_orders SET status = $1, occurrence = $2, due_at = $3, next_run_at = $4, attempts = $5,
+			consecutive_failures = $6, updated_at = $7 WHERE id = $8`,
+		order.Status, order.Occurrence, order.DueAt, order.NextRunAt, order.Attempts, order.ConsecutiveFailures,
+		order.UpdatedAt, order.ID)
+	return err
+}
+
+// ListStandingOrders pages through the standing orders of the account, newest first
+func (sr ScheduleRepo) ListStandingOrders(ctx context.Context, accountId string, filters requestparams.ListStandingOrdersRequest) (*types.Page[*types.StandingOrder], error) {
+	var where storage.Where
+	where.Add("from_account = ?", accountId)
+	if filters.StatusFilter != "" {
+		where.Add("status = ?", filters.StatusFilter)
+	}
+
+	var total *int64
+	if filters.IncludeTotal() {
+		var count int64
+		err := storage.Conn(ctx, sr.db).QueryRowContext(ctx, "SELECT COUNT(*) FROM standing_orders "+where.String(), where.Args()...).Scan(&count)
+		if err != nil {
+			return nil, err
+		}
+		total = &count
+	}
+
+	tail, err := storage.PageQuery(&where, filters.Pagination, "created_at", "DESC")
+	if err != nil {
+		return nil, err
+	}
+	rows, err := storage.Conn(ctx, sr.db).QueryContext(ctx,
+		"SELECT "+standingOrderColumns+" FROM standing_orders "+where.String()+" "+tail, where.Args()...)
+	if err != nil {
+		return nil, err
+	}
+	orders, err := scanStandingOrders(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	page := storage.NewPage(orders, filters.Pagination, func(order *types.StandingOrder) types.Cursor {
+		return types.NewTimeCursor("created_at", order.CreatedAt, order.ID)
+	})
+	page.Total = total
+	return page, nil
+}
+
+// ListDueStandingOrders returns up to limit active orders with an attempt due at now, the most overdue first
+func (sr ScheduleRepo) ListDueStandingOrders(ctx context.Context, now time.Time, limit int) ([]*types.StandingOrder, error) {
+	rows, err := storage.Conn(ctx, sr.db).QueryContext(ctx,
+		"SELECT "+standingOrderColumns+" FROM standing_orders WHERE status = $1 AND next_run_at <= $2 ORDER BY next_run_at, id LIMIT $3",
+		types.StandingOrderActive, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanStandingOrders(rows)
+}
+
+const standingOrderRunColumns = `id, order_id, occurrence, due_at, attempt, status, batch_id, legs, failure_reason, will_retry, ran_at`
+
+func scanStandingOrderRun(row scanner) (*types.StandingOrderRun, error) {
+	var run types.StandingOrderRun
+	var legs []byte
+	err := row.Scan(
+		&run.ID,
+		&run.OrderId,
+		&run.Occurrence,
+		&run.DueAt,
+		&run.Attempt,
+		&run.Status,
+		&run.BatchId,
+		&legs,
+		&run.FailureReason,
+		&run.WillRetry,
+		&run.RanAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(legs, &run.Legs); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+func (sr ScheduleRepo) CreateStandingOrderRun(ctx context.Context, run *types.StandingOrderRun) error {
+	legs, err := json.Marshal(run.Legs)
+	if err != nil {
+		return err
+	}
+	_, err = storage.Conn(ctx, sr.db).ExecContext(ctx,
+		`INSERT INTO standing_order_runs (id, order_id, occurrence, due_at, attempt, status, batch_id, legs, failure_reason,
+			will_retry, ran_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		run.ID, run.OrderId, run.Occurrence, run.DueAt, run.Attempt, run.Status, run.BatchId, legs, run.FailureReason,
+		run.WillRetry, run.RanAt)
+	return err
+}
+
+// ListStandingOrderRuns pages through the run history of the order, the latest attempt first
+func (sr ScheduleRepo) ListStandingOrderRuns(ctx context.Context, orderId string, pagination requestparams.Pagination) (*types.Page[*types.StandingOrderRun], error) {
+	var where storage.Where
+	where.Add("order_id = ?", orderId)
+
+	var total *int64
+	if pagination.IncludeTotal() {
+		var count int64
+		err := storage.Conn(ctx, sr.db).QueryRowContext(ctx, "SELECT COUNT(*) FROM standing_order_runs "+where.String(), where.Args()...).Scan(&count)
+		if err != nil {
+			return nil, err
+		}
+		total = &count
+	}
+
+	tail, err := storage.PageQuery(&where, pagination, "ran_at", "DESC")
+	if err != nil {
+		return nil, err
+	}
+	rows, err := storage.Conn(ctx, sr.db).QueryContext(ctx,
+		"SELECT "+standingOrderRunColumns+" FROM standing_order_runs "+where.String()+" "+tail, where.Args()...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	runs := []*types.StandingOrderRun{}
+	for rows.Next() {
+		run, err := scanStandingOrderRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	page := storage.NewPage(runs, pagination, func(run *types.StandingOrderRun) types.Cursor {
+		return types.NewTimeCursor("ran_at", run.RanAt, run.ID)
+	})
+	page.Total = total
+	return page, nil
+}
@@ -2,13 +2,32 @@ package transactionrepo
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	requestparams "go-sample/api/handlers/request-params"
 	"go-sample/types"
+	"time"
 )
 
+type MockCreateTransaction struct {
+	Called              bool
+	Calls               int
+	ExpectedReturnError error
+}
+
+type MockMakeTransferTransaction struct {
+	Called              bool
+	Calls               int
+	ExpectedReturnError error
+	// During, if set, runs while the money moves, to change the world under the transfer
+	During func()
+}
+
 type MemoTransactionRepo struct {
-	transactions []*types.Transaction
+	transactions             []*types.Transaction
+	batches                  []*types.TransferBatch
+	McreateTransaction       MockCreateTransaction
+	MmakeTransferTransaction MockMakeTransferTransaction
 }
 
 func NewMemoTransactionRepo() *MemoTransactionRepo {
@@ -19,12 +38,28 @@ func NewMemoTransactionRepo() *MemoTransactionRepo {
 }
 
 func (tr *MemoTransactionRepo) CreateTransaction(ctx context.Context, transaction *types.Transaction) error {
+	tr.McreateTransaction.Called = true
+	tr.McreateTransaction.Calls++
+	if tr.McreateTransaction.ExpectedReturnError != nil {
+		return tr.McreateTransaction.ExpectedReturnError
+	}
 	tr.transactions = append(tr.transactions, transaction)
 	return nil
 }
 
-func (tr *MemoTransactionRepo) GetTransactionsHistory(ctx context.Context, accountId string, filters requestparams.GetTransactionsHistoryRequest) ([]*types.Transaction, error) {
-	return tr.transactions, nil
+func (tr *MemoTransactionRepo) GetTransactionsHistory(ctx context.Context, accountId string, filters requestparams.GetTransactionsHistoryRequest) (*types.Page[*types.HistoryEntry], error) {
+	entries := []*types.HistoryEntry{}
+	var balance types.Money
+	for _, t := range tr.transactions {
+		if t.From != accountId && t.To != accountId {
+			continue
+		}
+		entry := types.NewHistoryEntry(t, accountId, balance)
+		balance = balance.Add(entry.SignedAmount())
+		entry.BalanceAfter = balance
+		entries = append(entries, entry)
+	}
+	return &types.Page[*types.HistoryEntry]{Data: entries}, nil
 }
 
 func (tr *MemoTransactionRepo) GetTransaction(ctx context.Context, id string) (*types.Transaction, error) {
@@ -36,10 +71,87 @@ func (tr *MemoTransactionRepo) GetTransaction(ctx context.Context, id string) (*
 	return nil, errors.New("")
 }
 
+func (tr *MemoTransactionRepo) GetUsage(ctx context.Context, accountId string, operation string, since time.Time) (types.Money, int, error) {
+	amount := types.Money{}
+	count := 0
+	for _, t := range tr.transactions {
+		if t.From != accountId || t.Operation != operation || t.CreatedAt.Before(since) {
+			continue
+		}
+		if t.Status == types.TransactionCompleted || t.Status == types.TransactionReversed {
+			amount = amount.Add(t.Amount)
+			count++
+		}
+	}
+	return amount, count, nil
+}
+
+func (tr *MemoTransactionRepo) GetLinkedTransactions(ctx context.Context, id string) ([]*types.Transaction, error) {
+	linked := []*types.Transaction{}
+	for _, t := range tr.transactions {
+		if t.LinkedTransactionId == id {
+			linked = append(linked, t)
+		}
+	}
+	return linked, nil
+}
+
 func (tr *MemoTransactionRepo) GetMultiBeneficiaryTransactions(ctx context.Context, multibeneficiaryid string) ([]*types.Transaction, error) {
 	return tr.transactions, nil
 }
 
-func (tr *MemoTransactionRepo) MakeTransferTransaction(ctx context.Context, from string, to string, amount float64) error {
+func (tr *MemoTransactionRepo) MakeTransferTransaction(ctx context.Context, from string, to string, amount types.Money, credited types.Money) error {
+	tr.MmakeTransferTransaction.Called = true
+	tr.MmakeTransferTransaction.Calls++
+	if tr.MmakeTransferTransaction.During != nil {
+		tr.MmakeTransferTransaction.During()
+	}
+	return tr.MmakeTransferTransaction.ExpectedReturnError
+}
+
+func (tr *MemoTransactionRepo) CreateTransferBatch(ctx context.Context, batch *types.TransferBatch) error {
+	tr.batches = append(tr.batches, batch)
+	return nil
+}
+
+func (tr *MemoTransactionRepo) UpdateTransferBatch(ctx context.Context, batch *types.TransferBatch) error {
+	return nil
+}
+
+func (tr *MemoTransactionRepo) GetTransferBatch(ctx context.Context, id string) (*types.TransferBatch, error) {
+	for i, b := range tr.batches {
+		if b.ID == id {
+			return tr.batches[i], nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (tr *MemoTransactionRepo) GetTransferBatchForUpdate(ctx context.Context, id string) (*types.TransferBatch, error) {
+	return tr.GetTransferBatch(ctx, id)
+}
+
+func (tr *MemoTransactionRepo) UpdateTransactionStatus(ctx context.Context, transaction *types.Transaction) error {
+	return nil
+}
+
+func (tr *MemoTransactionRepo) GetTransactionStatusHistory(ctx context.Context, id string) ([]*types.StatusTransition, error) {
+	transaction, err := tr.GetTransaction(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return transaction.StatusHistory, nil
+}
+
+// Transactions returns every stored transaction, including the failed attempts
+func (tr *MemoTransactionRepo) Transactions() []*types.Transaction {
+	return tr.transactions
+}
+
+func (tr *MemoTransactionRepo) GetTransactionForUpdate(ctx context.Context, id string) (*types.Transaction, error) {
+	return tr.GetTransaction(ctx, id)
+}
+
+func (tr *MemoTransactionRepo) UpdateRefundedAmount(ctx context.Context, transaction *types.Transaction) error {
 	return nil
 }
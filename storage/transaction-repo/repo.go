@@ -2,19 +2,33 @@ package transactionrepo
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
 	requestparams "go-sample/api/handlers/request-params"
+	"go-sample/storage"
 	"go-sample/types"
-	"strconv"
+	"time"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 type ITransactionRepo interface {
 	CreateTransaction(context.Context, *types.Transaction) error
-	GetTransactionsHistory(context.Context, string, requestparams.GetTransactionsHistoryRequest) ([]*types.Transaction, error)
+	GetTransactionsHistory(context.Context, string, requestparams.GetTransactionsHistoryRequest) (*types.Page[*types.HistoryEntry], error)
 	GetTransaction(context.Context, string) (*types.Transaction, error)
+	GetTransactionForUpdate(context.Context, string) (*types.Transaction, error)
+	UpdateTransactionStatus(context.Context, *types.Transaction) error
+	UpdateRefundedAmount(context.Context, *types.Transaction) error
+	GetTransactionStatusHistory(context.Context, string) ([]*types.StatusTransition, error)
 	GetMultiBeneficiaryTransactions(context.Context, string) ([]*types.Transaction, error)
-	MakeTransferTransaction(context.Context, string, string, float64) error
+	GetLinkedTransactions(context.Context, string) ([]*types.Transaction, error)
+	GetUsage(context.Context, string, string, time.Time) (types.Money, int, error)
+	MakeTransferTransaction(context.Context, string, string, types.Money, types.Money) error
+	CreateTransferBatch(context.Context, *types.TransferBatch) error
+	UpdateTransferBatch(context.Context, *types.TransferBatch) error
+	GetTransferBatch(context.Context, string) (*types.TransferBatch, error)
+	GetTransferBatchForUpdate(context.Context, string) (*types.TransferBatch, error)
 }
 type TransactionRepo struct {
 	db *sqlx.DB
@@ -24,154 +38,431 @@ func NewATransactionRepo(db *sqlx.DB) TransactionRepo {
 	return TransactionRepo{db}
 }
 
-func (tr TransactionRepo) CreateTransaction(ctx context.Context, transaction *types.Transaction) error {
-	tx, err := tr.db.Beginx()
-	if err != nil {
-		return err
+const transactionColumns = `id, from_account, to_account, subject, operation, currency, amount::decimal, refunded_amount::decimal,
+	destination_currency, COALESCE(destination_amount, amount)::decimal, exchange_rate, tr_status, failure_reason,
+	multibeneficiaryid, is_refund, refunded_transaction_id, linked_transaction_id, createdat, updated_at`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanTransaction reads transactionColumns, extra receives any column selected after them
+func scanTransaction(row scanner, extra ...interface{}) (*types.Transaction, error) {
+	var transaction types.Transaction
+	var currency, destinationCurrency types.Currency
+	dest := []interface{}{
+		&transaction.ID,
+		&transaction.From,
+		&transaction.To,
+		&transaction.Subject,
+		&transaction.Operation,
+		&currency,
+		&transaction.Amount,
+		&transaction.RefundedAmount,
+		&destinationCurrency,
+		&transaction.DestinationAmount,
+		&transaction.ExchangeRate,
+		&transaction.Status,
+		&transaction.FailureReason,
+		&transaction.MultiBeneficiaryTransactionId,
+		&transaction.IsRefund,
+		&transaction.RefundedTransactionId,
+		&transaction.LinkedTransactionId,
+		&transaction.CreatedAt,
+		&transaction.UpdatedAt,
 	}
-	_, err = tx.ExecContext(ctx,
-		`INSERT INTO transaction_(id, from_account, to_account, tr_status, operation, amount, multibeneficiaryid, is_refund, refunded_transaction_id, createdat) 
-		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+	err := row.Scan(append(dest, extra...)...)
+	transaction.Amount.Currency = currency
+	transaction.RefundedAmount.Currency = currency
+	transaction.DestinationAmount.Currency = destinationCurrency
+	return &transaction, err
+}
+
+func scanTransactions(rows *sql.Rows) ([]*types.Transaction, error) {
+	defer rows.Close()
+	var transactions []*types.Transaction
+	for rows.Next() {
+		transaction, err := scanTransaction(rows)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, transaction)
+	}
+	return transactions, rows.Err()
+}
+
+// CreateTransaction stores the transaction along with every status transition it went through so far
+func (tr TransactionRepo) CreateTransaction(ctx context.Context, transaction *types.Transaction) error {
+	_, err := storage.Conn(ctx, tr.db).ExecContext(ctx,
+		`INSERT INTO transaction_(id, from_account, to_account, subject, operation, currency, amount, refunded_amount,
+			destination_currency, destination_amount, exchange_rate, tr_status, failure_reason,
+			multibeneficiaryid, is_refund, refunded_transaction_id, linked_transaction_id, createdat, updated_at) 
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)`,
 		transaction.ID,
 		transaction.From,
 		transaction.To,
 		transaction.Subject,
 		transaction.Operation,
+		currencyOf(transaction.Amount),
 		transaction.Amount,
+		transaction.RefundedAmount,
+		currencyOf(transaction.DestinationAmount),
+		transaction.DestinationAmount,
+		transaction.ExchangeRate,
+		transaction.Status,
+		transaction.FailureReason,
 		transaction.MultiBeneficiaryTransactionId,
 		transaction.IsRefund,
 		transaction.RefundedTransactionId,
-		transaction.CreatedAt)
+		transaction.LinkedTransactionId,
+		transaction.CreatedAt,
+		transaction.UpdatedAt)
 
 	if err != nil {
 		return err
 	}
-	if err := tx.Commit(); err != nil {
-		tx.Rollback()
-		return err
+	for _, transition := range transaction.StatusHistory {
+		if err := tr.insertStatusTransition(ctx, transaction.ID, transition); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-func (tr TransactionRepo) GetTransactionsHistory(ctx context.Context, accountId string, filters requestparams.GetTransactionsHistoryRequest) ([]*types.Transaction, error) {
+func currencyOf(amount types.Money) types.Currency {
+	if amount.Currency == "" {
+		return types.DefaultCurrency
+	}
+	return amount.Currency
+}
+
+// UpdateTransactionStatus persists the last transition of the transaction
+func (tr TransactionRepo) UpdateTransactionStatus(ctx context.Context, transaction *types.Transaction) error {
+	transition := transaction.LastTransition()
+	if transition == nil {
+		return nil
+	}
+	_, err := storage.Conn(ctx, tr.db).ExecContext(ctx,
+		`UPDATE transaction_ SET tr_status = $1, failure_reason = $2, updated_at = $3 WHERE id = $4`,
+		transaction.Status, transaction.FailureReason, transaction.UpdatedAt, transaction.ID)
+	if err != nil {
+		return err
+	}
+	return tr.insertStatusTransition(ctx, transaction.ID, transition)
+}
 
-	page, _ := strconv.ParseInt(filters.Page, 10, 64)
-	limit, _ := strconv.ParseInt(filters.Limit, 10, 64)
+func (tr TransactionRepo) insertStatusTransition(ctx context.Context, transactionId string, transition *types.StatusTransition) error {
+	_, err := storage.Conn(ctx, tr.db).ExecContext(ctx,
+		`INSERT INTO transaction_status_history(transaction_id, status, reason, changed_at) VALUES($1, $2, $3, $4)`,
+		transactionId, transition.Status, transition.Reason, transition.ChangedAt)
+	return err
+}
 
-	offset := limit * (page - 1)
-	var transactions []*types.Transaction
-	rows, err := tr.db.QueryContext(
-		ctx,
-		`SELECT 
-			id, from_account, to_account, tr_status, operation, amount::decimal, multibeneficiaryid, createdat FROM transaction_ 
-			WHERE from_account = $1 LIMIT $2  OFFSET $3`, accountId, limit, offset)
+func (tr TransactionRepo) GetTransactionStatusHistory(ctx context.Context, transactionId string) ([]*types.StatusTransition, error) {
+	rows, err := storage.Conn(ctx, tr.db).QueryContext(ctx,
+		`SELECT status, reason, changed_at FROM transaction_status_history WHERE transaction_id = $1 ORDER BY changed_at, id`,
+		transactionId)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
+
+	history := []*types.StatusTransition{}
 	for rows.Next() {
-		var transaction types.Transaction
-		err = rows.Scan(
-			&transaction.ID,
-			&transaction.From,
-			&transaction.To,
-			&transaction.Subject,
-			&transaction.Operation,
-			&transaction.Amount,
-			&transaction.MultiBeneficiaryTransactionId,
-			&transaction.CreatedAt,
-		)
+		var transition types.StatusTransition
+		if err := rows.Scan(&transition.Status, &transition.Reason, &transition.ChangedAt); err != nil {
+			return nil, err
+		}
+		history = append(history, &transition)
+	}
+	return history, rows.Err()
+}
+
+// GetTransactionsHistory lists every transaction moving money in or out of the account.
+// The running balance is computed over the whole history before the filters are applied,
+// only completed (or later reversed) transactions count towards it
+func (tr TransactionRepo) GetTransactionsHistory(ctx context.Context, accountId string, filters requestparams.GetTransactionsHistoryRequest) (*types.Page[*types.HistoryEntry], error) {
 
+	var where storage.Where
+	account := where.Arg(accountId)
+	if filters.CreatedFrom != nil {
+		where.Add("createdat >= ?", *filters.CreatedFrom)
+	}
+	if filters.CreatedBefore != nil {
+		where.Add("createdat < ?", *filters.CreatedBefore)
+	}
+	if filters.Operation != "" {
+		where.Add("operation = ?", filters.Operation)
+	}
+	if filters.MinAmountOf != nil {
+		where.Add("amount >= ?", *filters.MinAmountOf)
+	}
+	if filters.MaxAmountOf != nil {
+		where.Add("amount <= ?", *filters.MaxAmountOf)
+	}
+	if filters.IsRefundValue != nil {
+		where.Add("is_refund = ?", *filters.IsRefundValue)
+	}
+	if filters.MultiBeneficiaryId != "" {
+		where.Add("multibeneficiaryid = ?", filters.MultiBeneficiaryId)
+	}
+
+	order := "DESC"
+	if filters.SortOrder == "ASC" {
+		order = "ASC"
+	}
+
+	movements := `WITH movements AS (
+			SELECT t.*, SUM(
+				CASE
+					WHEN t.tr_status NOT IN ('COMPLETED', 'REVERSED') THEN 0
+					WHEN t.to_account = ` + account + ` THEN COALESCE(t.destination_amount, t.amount)::decimal
+					ELSE -t.amount::decimal
+				END
+			) OVER (ORDER BY t.createdat, t.id) AS balance_after
+			FROM transaction_ t
+			WHERE t.from_account = ` + account + ` OR t.to_account = ` + account + `
+		)`
+
+	var total *int64
+	if filters.IncludeTotal() {
+		var count int64
+		err := storage.Conn(ctx, tr.db).QueryRowContext(ctx, movements+` SELECT COUNT(*) FROM movements `+where.String(), where.Args()...).Scan(&count)
 		if err != nil {
 			return nil, err
 		}
-		transactions = append(transactions, &transaction)
+		total = &count
+	}
+
+	tail, err := storage.PageQuery(&where, filters.Pagination, "createdat", order)
+	if err != nil {
+		return nil, err
 	}
-	return transactions, nil
+	rows, err := storage.Conn(ctx, tr.db).QueryContext(
+		ctx,
+		movements+`
+		SELECT `+transactionColumns+`, balance_after FROM movements
+			`+where.String()+` `+tail, where.Args()...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*types.HistoryEntry
+	for rows.Next() {
+		var balanceAfter types.Money
+		transaction, err := scanTransaction(rows, &balanceAfter)
+		if err != nil {
+			return nil, err
+		}
+		entry := types.NewHistoryEntry(transaction, accountId, balanceAfter)
+		entry.BalanceAfter.Currency = entry.Currency()
+		entries = append(entries, entry)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	page := storage.NewPage(entries, filters.Pagination, historyCursor)
+	page.Total = total
+	return page, nil
+}
+
+func historyCursor(entry *types.HistoryEntry) types.Cursor {
+	return types.NewTimeCursor("createdat", entry.CreatedAt, entry.ID)
 }
 
 func (tr TransactionRepo) GetTransaction(ctx context.Context, id string) (*types.Transaction, error) {
-	var transaction types.Transaction
-	err := tr.db.QueryRowContext(
+	return scanTransaction(storage.Conn(ctx, tr.db).QueryRowContext(
 		ctx,
-		`SELECT 
-			id, from_account, to_account, tr_status, operation, amount::decimal, multibeneficiaryid, is_refund, refunded_transaction_id, createdat 
-			FROM transaction_ WHERE id = $1`, id).Scan(
-		&transaction.ID,
-		&transaction.From,
-		&transaction.To,
-		&transaction.Subject,
-		&transaction.Operation,
-		&transaction.Amount,
-		&transaction.MultiBeneficiaryTransactionId,
-		&transaction.IsRefund,
-		&transaction.RefundedTransactionId,
-		&transaction.CreatedAt,
-	)
-	return &transaction, err
+		`SELECT `+transactionColumns+` FROM transaction_ WHERE id = $1`, id))
 }
 
-func (tr TransactionRepo) GetMultiBeneficiaryTransactions(ctx context.Context, multibeneficiaryid string) ([]*types.Transaction, error) {
+// GetTransactionForUpdate locks the transaction row until the surrounding unit of work ends,
+// concurrent refunds of the same transaction are applied one after the other
+func (tr TransactionRepo) GetTransactionForUpdate(ctx context.Context, id string) (*types.Transaction, error) {
+	return scanTransaction(storage.Conn(ctx, tr.db).QueryRowContext(
+		ctx,
+		`SELECT `+transactionColumns+` FROM transaction_ WHERE id = $1 FOR UPDATE`, id))
+}
 
-	var transactions []*types.Transaction
-	rows, err := tr.db.QueryContext(
-		ctx, `SELECT 
-				id, from_account, to_account, tr_status, operation, amount::decimal, multibeneficiaryid, is_refund, refunded_transaction_id, createdat 
-				FROM transaction_ WHERE multibeneficiaryid = $1`, multibeneficiaryid)
+func (tr TransactionRepo) UpdateRefundedAmount(ctx context.Context, transaction *types.Transaction) error {
+	_, err := storage.Conn(ctx, tr.db).ExecContext(ctx,
+		`UPDATE transaction_ SET refunded_amount = $1, updated_at = $2 WHERE id = $3`,
+		transaction.RefundedAmount, transaction.UpdatedAt, transaction.ID)
+	return err
+}
+
+// GetUsage adds up what the account sent with the operation since the given time and how many transactions
+// it took. Failed attempts do not count, refunded ones still do
+func (tr TransactionRepo) GetUsage(ctx context.Context, accountId string, operation string, since time.Time) (types.Money, int, error) {
+	var amount types.Money
+	var count int
+	err := storage.Conn(ctx, tr.db).QueryRowContext(ctx,
+		`SELECT COALESCE(SUM(amount::decimal), 0), COUNT(*) FROM transaction_
+			WHERE from_account = $1 AND operation = $2 AND tr_status IN ('COMPLETED', 'REVERSED') AND createdat >= $3`,
+		accountId, operation, since).Scan(&amount, &count)
+	return amount, count, err
+}
 
+// GetLinkedTransactions returns the transactions booked alongside the given one, like its fee
+func (tr TransactionRepo) GetLinkedTransactions(ctx context.Context, id string) ([]*types.Transaction, error) {
+	rows, err := storage.Conn(ctx, tr.db).QueryContext(
+		ctx, `SELECT `+transactionColumns+` FROM transaction_ WHERE linked_transaction_id = $1 ORDER BY createdat, id`, id)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-	for rows.Next() {
-		var transaction types.Transaction
-		err = rows.Scan(
-			&transaction.ID,
-			&transaction.From,
-			&transaction.To,
-			&transaction.Subject,
-			&transaction.Operation,
-			&transaction.Amount,
-			&transaction.MultiBeneficiaryTransactionId,
-			&transaction.IsRefund,
-			&transaction.RefundedTransactionId,
-			&transaction.CreatedAt,
-		)
+	return scanTransactions(rows)
+}
 
-		if err != nil {
-			return nil, err
-		}
-		transactions = append(transactions, &transaction)
+func (tr TransactionRepo) GetMultiBeneficiaryTransactions(ctx context.Context, multibeneficiaryid string) ([]*types.Transaction, error) {
+	rows, err := storage.Conn(ctx, tr.db).QueryContext(
+		ctx, `SELECT `+transactionColumns+` FROM transaction_ WHERE multibeneficiaryid = $1`, multibeneficiaryid)
+
+	if err != nil {
+		return nil, err
 	}
-	return transactions, nil
+	return scanTransactions(rows)
 }
 
-func (tr TransactionRepo) MakeTransferTransaction(ctx context.Context, from string, to string, amount float64) error {
-	tx, err := tr.db.Beginx()
+// MakeTransferTransaction debits amount from one account and credits credited to the other atomically, both are the same
+// unless the currency was exchanged, as long as the caller runs it in a unit of work.
+// Both rows are locked in id order and the debit is conditional, storage.ErrInsufficientFunds is returned when
+// the available balance of from (balance plus credit limit minus held funds) cannot cover it and
+// storage.ErrInexistentAccount when there is no account to credit
+func (tr TransactionRepo) MakeTransferTransaction(ctx context.Context, from string, to string, amount types.Money, credited types.Money) error {
+	conn := storage.Conn(ctx, tr.db)
+
+	rows, err := conn.QueryContext(ctx,
+		`SELECT id FROM accounts WHERE id = ANY($1) ORDER BY id FOR UPDATE`, pq.Array([]string{from, to}))
 	if err != nil {
 		return err
 	}
-	_, err = tx.ExecContext(ctx,
+	rows.Close()
+
+	res, err := conn.ExecContext(ctx,
 		`UPDATE 
 			accounts SET balance = balance - $1
-		WHERE id=$2`,
+		WHERE id=$2 AND balance + credit_limit - held_amount >= $1`,
 		amount, from)
 
 	if err != nil {
 		return err
 	}
+	if affected, err := res.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return storage.ErrInsufficientFunds
+	}
 
-	_, err = tx.ExecContext(ctx,
+	res, err = conn.ExecContext(ctx,
 		`UPDATE 
 			accounts SET balance = balance + $1
-		WHERE id=$2`, amount, to)
+		WHERE id=$2`, credited, to)
 
 	if err != nil {
 		return err
 	}
-	if err := tx.Commit(); err != nil {
-		tx.Rollback()
+	if affected, err := res.RowsAffected(); err != nil {
 		return err
+	} else if affected != 1 {
+		return storage.ErrInexistentAccount
 	}
 	return nil
 }
+
+func (tr TransactionRepo) CreateTransferBatch(ctx context.Context, batch *types.TransferBatch) error {
+	legs, err := json.Marshal(batch.Legs)
+	if err != nil {
+		return err
+	}
+	_, err = storage.Conn(ctx, tr.db).ExecContext(ctx,
+		`INSERT INTO transfer_batches(id, from_account, currency, amount, subject, status, failure_reason, legs, created_at, updated_at)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		batch.ID,
+		batch.From,
+		currencyOf(batch.Amount),
+		batch.Amount,
+		batch.Subject,
+		batch.Status,
+		batch.FailureReason,
+		legs,
+		batch.CreatedAt,
+		batch.UpdatedAt)
+	return err
+}
+
+func (tr TransactionRepo) UpdateTransferBatch(ctx context.Context, batch *types.TransferBatch) error {
+	legs, err := json.Marshal(batch.Legs)
+	if err != nil {
+		return err
+	}
+	_, err = storage.Conn(ctx, tr.db).ExecContext(ctx,
+		`UPDATE transfer_batches SET status = $1, failure_reason = $2, legs = $3, updated_at = $4 WHERE id = $5`,
+		batch.Status, batch.FailureReason, legs, batch.UpdatedAt, batch.ID)
+	return err
+}
+
+// GetTransferBatch returns the batch with the legs stored along with it. Batches stored before the legs
+// were kept get them rebuilt from the committed transactions
+func (tr TransactionRepo) GetTransferBatch(ctx context.Context, id string) (*types.TransferBatch, error) {
+	return tr.getTransferBatch(ctx, id, "")
+}
+
+// GetTransferBatchForUpdate is GetTransferBatch with the batch row locked, so a pending batch runs once
+func (tr TransactionRepo) GetTransferBatchForUpdate(ctx context.Context, id string) (*types.TransferBatch, error) {
+	return tr.getTransferBatch(ctx, id, " FOR UPDATE")
+}
+
+func (tr TransactionRepo) getTransferBatch(ctx context.Context, id string, lock string) (*types.TransferBatch, error) {
+	var batch types.TransferBatch
+	var currency types.Currency
+	var legs []byte
+	err := storage.Conn(ctx, tr.db).QueryRowContext(ctx,
+		`SELECT id, from_account, currency, amount::decimal, subject, status, failure_reason, legs, created_at, updated_at
+		FROM transfer_batches WHERE id = $1`+lock, id).Scan(
+		&batch.ID,
+		&batch.From,
+		&currency,
+		&batch.Amount,
+		&batch.Subject,
+		&batch.Status,
+		&batch.FailureReason,
+		&legs,
+		&batch.CreatedAt,
+		&batch.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	batch.Amount.Currency = currency
+	if err := json.Unmarshal(legs, &batch.Legs); err != nil {
+		return nil, err
+	}
+	if len(batch.Legs) > 0 {
+		return &batch, nil
+	}
+
+	transactions, err := tr.GetMultiBeneficiaryTransactions(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	batch.Legs = []*types.TransferLeg{}
+	for _, transaction := range transactions {
+		if transaction.IsRefund {
+			continue
+		}
+		leg := &types.TransferLeg{
+			AccountId:     transaction.To,
+			Amount:        transaction.Amount,
+			TransactionId: transaction.ID,
+			Status:        types.LegCompleted,
+		}
+		if transaction.Status == types.TransactionFailed {
+			leg.Status = types.LegFailed
+			leg.Error = transaction.FailureReason
+		}
+		batch.Legs = append(batch.Legs, leg)
+	}
+	return &batch, nil
+}
@@ -3,7 +3,9 @@ package transactionrepo
 import (
 	"context"
 	"fmt"
+	requestparams "go-sample/api/handlers/request-params"
 	"go-sample/api/utils"
+	"go-sample/storage"
 	accountrepo "go-sample/storage/account-repo"
 	"go-sample/types"
 	"testing"
@@ -20,13 +22,34 @@ func createTables(db *sqlx.DB) {
 		id VARCHAR(36) PRIMARY KEY,
 		from_account VARCHAR(36) NOT NULL,
 		to_account VARCHAR(36) NOT NULL,
-		tr_status TEXT NOT NULL,
+		subject TEXT NOT NULL DEFAULT '',
+		tr_status VARCHAR(20) NOT NULL,
+		failure_reason TEXT NOT NULL DEFAULT '',
 		operation VARCHAR(10) NOT NULL,
+		currency VARCHAR(3) NOT NULL DEFAULT 'AOA',
 		amount MONEY NOT NULL,
+		refunded_amount MONEY NOT NULL DEFAULT 0,
+		destination_currency VARCHAR(3) NOT NULL DEFAULT 'AOA',
+		destination_amount MONEY NULL,
+		exchange_rate VARCHAR(32) NOT NULL DEFAULT '',
 		multiBeneficiaryId VARCHAR(36) NOT NULL,
 		is_Refund BOOLEAN NOT NULL, 
 		refunded_transaction_id VARCHAR(36) NOT NULL,
-		createdAt TIMESTAMP NOT NULL
+		linked_transaction_id VARCHAR(36) NOT NULL DEFAULT '',
+		createdAt TIMESTAMP NOT NULL,
+		updated_at TIMESTAMP NOT NULL
+	  );`)
+
+	if err != nil {
+		panic(err)
+	}
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS public.transaction_status_history (
+		id BIGSERIAL PRIMARY KEY,
+		transaction_id VARCHAR(36) NOT NULL,
+		status VARCHAR(20) NOT NULL,
+		reason TEXT NOT NULL DEFAULT '',
+		changed_at TIMESTAMP NOT NULL
 	  );`)
 
 	if err != nil {
@@ -37,9 +60,31 @@ func createTables(db *sqlx.DB) {
 		  id VARCHAR(36) PRIMARY KEY,
 		  owner_id VARCHAR(36) NOT NULL,
 		  balance MONEY NOT NULL,
+		  credit_limit MONEY NOT NULL DEFAULT 0,
+		  held_amount MONEY NOT NULL DEFAULT 0,
 		  created_at TIMESTAMP NOT NULL,
 		  updated_at TIMESTAMP NOT NULL,
-		  deletedAt TIMESTAMP NULL
+		  deletedAt TIMESTAMP NULL,
+		  status VARCHAR(10) NOT NULL DEFAULT 'ACTIVE',
+		  account_type VARCHAR(10) NOT NULL DEFAULT 'CHECKING',
+		  nickname VARCHAR(100) NOT NULL DEFAULT '',
+		  currency VARCHAR(3) NOT NULL DEFAULT 'AOA'
+		);`)
+	if err != nil {
+		panic(err)
+	}
+	_, err = db.Exec(`
+	  CREATE TABLE IF NOT EXISTS public.transfer_batches (
+		  id VARCHAR(36) PRIMARY KEY,
+		  from_account VARCHAR(36) NOT NULL,
+		  currency VARCHAR(3) NOT NULL DEFAULT 'AOA',
+		  amount MONEY NOT NULL,
+		  subject TEXT NOT NULL,
+		  status VARCHAR(20) NOT NULL,
+		  failure_reason TEXT NOT NULL DEFAULT '',
+		  legs JSONB NOT NULL DEFAULT '[]',
+		  created_at TIMESTAMP NOT NULL,
+		  updated_at TIMESTAMP NOT NULL
 		);`)
 	if err != nil {
 		panic(err)
@@ -52,10 +97,20 @@ func dropTables(db *sqlx.DB) {
 		panic(err)
 	}
 
+	_, err = db.Exec(`DROP TABLE IF EXISTS public.transaction_status_history;`)
+	if err != nil {
+		panic(err)
+	}
+
 	_, err = db.Exec(`DROP TABLE IF EXISTS public.accounts;`)
 	if err != nil {
 		panic(err)
 	}
+
+	_, err = db.Exec(`DROP TABLE IF EXISTS public.transfer_batches;`)
+	if err != nil {
+		panic(err)
+	}
 }
 func TestTransactionRepo(t *testing.T) {
 
@@ -70,7 +125,7 @@ func TestTransactionRepo(t *testing.T) {
 
 	t.Run("Create Account", func(t *testing.T) {
 		ctx := context.Background()
-		amount := 100.0
+		amount := types.NewMoney(10000, types.AOA)
 		subject := "some dumb subject"
 		from := "some dumb from"
 		to := "some dumb to"
@@ -80,23 +135,10 @@ func TestTransactionRepo(t *testing.T) {
 		transactionId := entity.ID
 		repo.CreateTransaction(ctx, entity)
 
-		var transaction types.Transaction
-		db.QueryRowContext(
-			ctx,
-			`SELECT
-				id, from_account, to_account, tr_status, operation, amount::decimal, multibeneficiaryid, is_refund, refunded_transaction_id, createdat
-				FROM transaction_ WHERE id = $1`, entity.ID).Scan(
-			&transaction.ID,
-			&transaction.From,
-			&transaction.To,
-			&transaction.Subject,
-			&transaction.Operation,
-			&transaction.Amount,
-			&transaction.MultiBeneficiaryTransactionId,
-			&transaction.IsRefund,
-			&transaction.RefundedTransactionId,
-			&transaction.CreatedAt,
-		)
+		transaction, err := repo.GetTransaction(ctx, entity.ID)
+		if err != nil {
+			t.Fatalf("err = %v, expected nil", err)
+		}
 
 		if transaction.ID != entity.ID {
 			t.Errorf("account.ID = %s, expected %s", transaction.ID, entity.ID)
@@ -112,18 +154,16 @@ func TestTransactionRepo(t *testing.T) {
 			{
 				ID:        "",
 				Owner:     "1e55e90d-427e-4f0b-b71f-5e38e57c2ae8",
-				Balance:   100.0,
+				Balance:   types.NewMoney(10000, types.AOA),
 				CreatedAt: time.Now(),
 				UpdatedAt: time.Now(),
-				DeletedAt: time.Now(),
 			},
 			{
 				ID:        "",
 				Owner:     "1e55e901-427e-4f0b-b71f-5e38e57c2ae8",
-				Balance:   0,
+				Balance:   types.NewMoney(0, types.AOA),
 				CreatedAt: time.Now(),
 				UpdatedAt: time.Now(),
-				DeletedAt: time.Now(),
 			},
 		}
 
@@ -134,18 +174,115 @@ func TestTransactionRepo(t *testing.T) {
 			accountRepo.CreateAccount(ctx, entity)
 		}
 
-		amount := 100.0
+		amount := types.NewMoney(10000, types.AOA)
 		from := accounts[0].ID
 		to := accounts[1].ID
 
-		repo.MakeTransferTransaction(ctx, from, to, amount)
+		repo.MakeTransferTransaction(ctx, from, to, amount, amount)
 
 		fromBalance, _ := accountRepo.GetAccountBalance(ctx, from)
 		toBalance, _ := accountRepo.GetAccountBalance(ctx, to)
 
-		if fromBalance != toBalance-amount {
-			t.Errorf("fromBalance = %f, expected %f", fromBalance, amount)
+		if !fromBalance.Equal(toBalance.Sub(amount)) {
+			t.Errorf("fromBalance = %s, expected %s", fromBalance, amount)
+		}
+	})
+	t.Run("MakeTransferTransaction should refuse crediting an inexistent account", func(t *testing.T) {
+		ctx := context.Background()
+		from := types.NewAccount("1e55e90d-427e-4f0b-b71f-5e38e57c2ae8", types.NewMoney(10000, types.AOA))
+		accountRepo.CreateAccount(ctx, from)
+
+		amount := types.NewMoney(5000, types.AOA)
+		err := storage.NewPostgresUnitOfWork(db).Do(ctx, func(ctx context.Context) error {
+			return repo.MakeTransferTransaction(ctx, from.ID, "some inexistent account", amount, amount)
+		})
+		if err != storage.ErrInexistentAccount {
+			t.Errorf("err = %v, expected %v", err, storage.ErrInexistentAccount)
+		}
+
+		balance, err := accountRepo.GetAccountBalance(ctx, from.ID)
+		if err != nil {
+			t.Fatalf("err = %v, expected nil", err)
 		}
+		if !balance.Equal(from.Balance) {
+			t.Errorf("balance = %s, expected %s", balance, from.Balance)
+		}
+	})
+	t.Run("UpdateTransactionStatus should record every transition", func(t *testing.T) {
+		ctx := context.Background()
+		amount := types.NewMoney(10000, types.AOA)
+
+		entity := types.NewTransaction(amount, "some dumb from", "some dumb to", "some dumb subject", string(utils.TRANSFER), "", false, "")
+		entity.TransitionTo(types.TransactionCompleted, "")
+		repo.CreateTransaction(ctx, entity)
+
+		entity.TransitionTo(types.TransactionReversed, "refunded")
+		repo.UpdateTransactionStatus(ctx, entity)
+
+		transaction, err := repo.GetTransaction(ctx, entity.ID)
+		if err != nil {
+			t.Fatalf("err = %v, expected nil", err)
+		}
+		history, err := repo.GetTransactionStatusHistory(ctx, entity.ID)
+		if err != nil {
+			t.Fatalf("err = %v, expected nil", err)
+		}
+
+		if transaction.Status != types.TransactionReversed {
+			t.Errorf("transaction.Status = %s, expected %s", transaction.Status, types.TransactionReversed)
+		}
+		if len(history) != 3 {
+			t.Errorf("len(history) = %d, expected %d", len(history), 3)
+		}
+		t.Cleanup(func() {
+			db.ExecContext(ctx, "DELETE FROM transaction_ WHERE id=$1", entity.ID)
+		})
+	})
+	t.Run("GetTransactionsHistory should apply the filters", func(t *testing.T) {
+		ctx := context.Background()
+		from := "some filtered from"
+
+		small := types.NewTransaction(types.NewMoney(500, types.AOA), from, "some dumb to", "small", string(utils.TRANSFER), "", false, "")
+		big := types.NewTransaction(types.NewMoney(50000, types.AOA), from, "some dumb to", "big", string(utils.WITHDRAW), "", false, "")
+		big.CreatedAt = small.CreatedAt.Add(time.Second)
+		repo.CreateTransaction(ctx, small)
+		repo.CreateTransaction(ctx, big)
+
+		filters := requestparams.GetTransactionsHistoryRequest{MinAmount: "10", Sort: "asc"}
+		filters.Validate()
+		page, err := repo.GetTransactionsHistory(ctx, from, filters)
+		if err != nil {
+			t.Fatalf("err = %v, expected nil", err)
+		}
+		transactions := page.Data
+		if len(transactions) != 1 || transactions[0].ID != big.ID {
+			t.Errorf("min_amount filter returned %d transactions, expected only %s", len(transactions), big.ID)
+		}
+
+		filters = requestparams.GetTransactionsHistoryRequest{Operation: "transfer"}
+		filters.Validate()
+		page, err = repo.GetTransactionsHistory(ctx, from, filters)
+		if err != nil {
+			t.Fatalf("err = %v, expected nil", err)
+		}
+		transactions = page.Data
+		if len(transactions) != 1 || transactions[0].ID != small.ID {
+			t.Errorf("operation filter returned %d transactions, expected only %s", len(transactions), small.ID)
+		}
+
+		filters = requestparams.GetTransactionsHistoryRequest{Sort: "asc"}
+		filters.Validate()
+		page, err = repo.GetTransactionsHistory(ctx, from, filters)
+		if err != nil {
+			t.Fatalf("err = %v, expected nil", err)
+		}
+		transactions = page.Data
+		if len(transactions) != 2 || transactions[0].ID != small.ID {
+			t.Errorf("sort=asc should return the oldest transaction first")
+		}
+		t.Cleanup(func() {
+			db.ExecContext(ctx, "DELETE FROM transaction_ WHERE from_account=$1", from)
+		})
 	})
 	t.Cleanup(func() {
 		dropTables(db)
@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// UnitOfWork runs fn atomically: everything the repositories write through the ctx handed
+// to fn is committed together when fn returns nil, and rolled back otherwise.
+// Nested calls join the outer unit of work instead of opening a new transaction.
+// Repositories never open one themselves, and the unexported service helpers that lock rows or write
+// more than one are only called from inside Do: the caller decides what commits together.
+type UnitOfWork interface {
+	Do(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type PostgresUnitOfWork struct {
+	db *sqlx.DB
+}
+
+func NewPostgresUnitOfWork(db *sqlx.DB) PostgresUnitOfWork {
+	return PostgresUnitOfWork{db}
+}
+
+func (u PostgresUnitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	if InTransaction(ctx) {
+		return fn(ctx)
+	}
+
+	tx, err := u.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err = fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
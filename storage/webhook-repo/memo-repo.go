@@ -0,0 +1,115 @@
+package webhookrepo
+
+import (
+	"context"
+	"database/sql"
+	requestparams "go-sample/api/handlers/request-params"
+	"go-sample/types"
+	"time"
+)
+
+type MemoWebhookRepo struct {
+	endpoints  []*types.WebhookEndpoint
+	deliveries []*types.WebhookDelivery
+}
+
+func NewMemoWebhookRepo() *MemoWebhookRepo {
+	return &MemoWebhookRepo{
+		endpoints:  []*types.WebhookEndpoint{},
+		deliveries: []*types.WebhookDelivery{},
+	}
+}
+
+func (wr *MemoWebhookRepo) CreateEndpoint(ctx context.Context, endpoint *types.WebhookEndpoint) error {
+	stored := *endpoint
+	wr.endpoints = append(wr.endpoints, &stored)
+	return nil
+}
+
+func (wr *MemoWebhookRepo) GetEndpoint(ctx context.Context, id string) (*types.WebhookEndpoint, error) {
+	for _, e := range wr.endpoints {
+		if e.ID == id {
+			endpoint := *e
+			return &endpoint, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (wr *MemoWebhookRepo) ListEndpoints(ctx context.Context) ([]*types.WebhookEndpoint, error) {
+	endpoints := []*types.WebhookEndpoint{}
+	for _, e := range wr.endpoints {
+		endpoint := *e
+		endpoints = append(endpoints, &endpoint)
+	}
+	return endpoints, nil
+}
+
+func (wr *MemoWebhookRepo) DeleteEndpoint(ctx context.Context, id string) error {
+	for i, e := range wr.endpoints {
+		if e.ID == id {
+			wr.endpoints = append(wr.endpoints[:i], wr.endpoints[i+1:]...)
+			deliveries := []*types.WebhookDelivery{}
+			for _, d := range wr.deliveries {
+				if d.EndpointId != id {
+					deliveries = append(deliveries, d)
+				}
+			}
+			wr.deliveries = deliveries
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (wr *MemoWebhookRepo) CreateDelivery(ctx context.Context, delivery *types.WebhookDelivery) error {
+	stored := *delivery
+	wr.deliveries = append(wr.deliveries, &stored)
+	return nil
+}
+
+func (wr *MemoWebhookRepo) GetDeliveryForUpdate(ctx context.Context, id string) (*types.WebhookDelivery, error) {
+	for _, d := range wr.deliveries {
+		if d.ID == id {
+			delivery := *d
+			return &delivery, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (wr *MemoWebhookRepo) UpdateDelivery(ctx context.Context, delivery *types.WebhookDelivery) error {
+	for i, d := range wr.deliveries {
+		if d.ID == delivery.ID {
+			stored := *delivery
+			wr.deliveries[i] = &stored
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (wr *MemoWebhookRepo) ListDueDeliveries(ctx context.Context, now time.Time, limit int) ([]*types.WebhookDelivery, error) {
+	deliveries := []*types.WebhookDelivery{}
+	for _, d := range wr.deliveries {
+		if len(deliveries) == limit {
+			break
+		}
+		if d.IsDue(now) {
+			delivery := *d
+			deliveries = append(deliveries, &delivery)
+		}
+	}
+	return deliveries, nil
+}
+
+func (wr *MemoWebhookRepo) ListDeadDeliveries(ctx context.Context, pagination requestparams.Pagination) (*types.Page[*types.WebhookDelivery], error) {
+	deliveries := []*types.WebhookDelivery{}
+	for _, d := range wr.deliveries {
+		if d.Status == types.DeliveryDead {
+			delivery := *d
+			deliveries = append(deliveries, &delivery)
+		}
+	}
+	return &types.Page[*types.WebhookDelivery]{Data: deliveries}, nil
+}
@@ -0,0 +1,221 @@
+package webhookrepo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	requestparams "go-sample/api/handlers/request-params"
+	"go-sample/storage"
+	"go-sample/types"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type IWebhookRepo interface {
+	CreateEndpoint(context.Context, *types.WebhookEndpoint) error
+	GetEndpoint(context.Context, string) (*types.WebhookEndpoint, error)
+	ListEndpoints(context.Context) ([]*types.WebhookEndpoint, error)
+	DeleteEndpoint(context.Context, string) error
+	CreateDelivery(context.Context, *types.WebhookDelivery) error
+	GetDeliveryForUpdate(context.Context, string) (*types.WebhookDelivery, error)
+	UpdateDelivery(context.Context, *types.WebhookDelivery) error
+	ListDueDeliveries(context.Context, time.Time, int) ([]*types.WebhookDelivery, error)
+	ListDeadDeliveries(context.Context, requestparams.Pagination) (*types.Page[*types.WebhookDelivery], error)
+}
+
+type WebhookRepo struct {
+	db *sqlx.DB
+}
+
+func NewWebhookRepo(db *sqlx.DB) WebhookRepo {
+	return WebhookRepo{db}
+}
+
+const endpointColumns = `id, url, secret, event_types, created_at`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanEndpoint(row scanner) (*types.WebhookEndpoint, error) {
+	var endpoint types.WebhookEndpoint
+	var eventTypes []byte
+	err := row.Scan(&endpoint.ID, &endpoint.URL, &endpoint.Secret, &eventTypes, &endpoint.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(eventTypes, &endpoint.EventTypes); err != nil {
+		return nil, err
+	}
+	return &endpoint, nil
+}
+
+func (wr WebhookRepo) CreateEndpoint(ctx context.Context, endpoint *types.WebhookEndpoint) error {
+	eventTypes, err := json.Marshal(endpoint.EventTypes)
+	if err != nil {
+		return err
+	}
+	_, err = storage.Conn(ctx, wr.db).ExecContext(ctx,
+		`INSERT INTO webhook_endpoints (id, url, secret, event_types, created_at) VALUES ($1, $2, $3, $4, $5)`,
+		endpoint.ID, endpoint.URL, endpoint.Secret, eventTypes, endpoint.CreatedAt)
+	return err
+}
+
+func (wr WebhookRepo) GetEndpoint(ctx context.Context, id string) (*types.WebhookEndpoint, error) {
+	return scanEndpoint(storage.Conn(ctx, wr.db).QueryRowContext(ctx,
+		"SELECT "+endpointColumns+" FROM webhook_endpoints WHERE id = $1", id))
+}
+
+func (wr WebhookRepo) ListEndpoints(ctx context.Context) ([]*types.WebhookEndpoint, error) {
+	rows, err := storage.Conn(ctx, wr.db).QueryContext(ctx,
+		"SELECT "+endpointColumns+" FROM webhook_endpoints ORDER BY created_at, id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	endpoints := []*types.WebhookEndpoint{}
+	for rows.Next() {
+		endpoint, err := scanEndpoint(rows)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints, rows.Err()
+}
+
+// DeleteEndpoint removes the endpoint with its deliveries, it returns sql.ErrNoRows when there was none
+func (wr WebhookRepo) DeleteEndpoint(ctx context.Context, id string) error {
+	result, err := storage.Conn(ctx, wr.db).ExecContext(ctx, `DELETE FROM webhook_endpoints WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+const deliveryColumns = `id, endpoint_id, event_id, event_type, payload, status, attempts, next_attempt_at, last_error,
+	response_status, created_at, updated_at, delivered_at`
+
+func scanDelivery(row scanner) (*types.WebhookDelivery, error) {
+	var delivery types.WebhookDelivery
+	var payload []byte
+	var deliveredAt sql.NullTime
+	err := row.Scan(
+		&delivery.ID,
+		&delivery.EndpointId,
+		&delivery.EventId,
+		&delivery.EventType,
+		&payload,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.NextAttemptAt,
+		&delivery.LastError,
+		&delivery.ResponseStatus,
+		&delivery.CreatedAt,
+		&delivery.UpdatedAt,
+		&deliveredAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	delivery.Payload = payload
+	if deliveredAt.Valid {
+		delivery.DeliveredAt = &deliveredAt.Time
+	}
+	return &delivery, nil
+}
+
+func scanDeliveries(rows *sql.Rows) ([]*types.WebhookDelivery, error) {
+	defer rows.Close()
+	deliveries := []*types.WebhookDelivery{}
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+func (wr WebhookRepo) CreateDelivery(ctx context.Context, delivery *types.WebhookDelivery) error {
+	_, err := storage.Conn(ctx, wr.db).ExecContext(ctx,
+		`INSERT INTO webhook_deliveries (id, endpoint_id, event_id, event_type, payload, status, attempts, next_attempt_at,
+			last_error, response_status, created_at, updated_at, delivered_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+		delivery.ID, delivery.EndpointId, delivery.EventId, delivery.EventType, []byte(delivery.Payload), delivery.Status,
+		delivery.Attempts, delivery.NextAttemptAt, delivery.LastError, delivery.ResponseStatus, delivery.CreatedAt,
+		delivery.UpdatedAt, delivery.DeliveredAt)
+	return err
+}
+
+// GetDeliveryForUpdate reads the delivery with a row lock, so an attempt is claimed once
+func (wr WebhookRepo) GetDeliveryForUpdate(ctx context.Context, id string) (*types.WebhookDelivery, error) {
+	return scanDelivery(storage.Conn(ctx, wr.db).QueryRowContext(ctx,
+		"SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE id = $1 FOR UPDATE", id))
+}
+
+func (wr WebhookRepo) UpdateDelivery(ctx context.Context, delivery *types.WebhookDelivery) error {
+	_, err := storage.Conn(ctx, wr.db).ExecContext(ctx,
+		`UPDATE webhook_deliveries SET status = $1, attempts = $2, next_attempt_at = $3, last_error = $4, response_status = $5,
+			updated_at = $6, delivered_at = $7 WHERE id = $8`,
+		delivery.Status, delivery.Attempts, delivery.NextAttemptAt, delivery.LastError, delivery.ResponseStatus,
+		delivery.UpdatedAt, delivery.DeliveredAt, delivery.ID)
+	return err
+}
+
+// ListDueDeliveries returns up to limit pending deliveries with an attempt due at now, the most overdue first
+func (wr WebhookRepo) ListDueDeliveries(ctx context.Context, now time.Time, limit int) ([]*types.WebhookDelivery, error) {
+	rows, err := storage.Conn(ctx, wr.db).QueryContext(ctx,
+		"SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE status = $1 AND next_attempt_at <= $2 ORDER BY next_attempt_at, id LIMIT $3",
+		types.DeliveryPending, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanDeliveries(rows)
+}
+
+// ListDeadDeliveries pages through the dead letters, the latest to give up first
+func (wr WebhookRepo) ListDeadDeliveries(ctx context.Context, pagination requestparams.Pagination) (*types.Page[*types.WebhookDelivery], error) {
+	var where storage.Where
+	where.Add("status = ?", types.DeliveryDead)
+
+	var total *int64
+	if pagination.IncludeTotal() {
+		var count int64
+		err := storage.Conn(ctx, wr.db).QueryRowContext(ctx, "SELECT COUNT(*) FROM webhook_deliveries "+where.String(), where.Args()...).Scan(&count)
+		if err != nil {
+			return nil, err
+		}
+		total = &count
+	}
+
+	tail, err := storage.PageQuery(&where, pagination, "updated_at", "DESC")
+	if err != nil {
+		return nil, err
+	}
+	rows, err := storage.Conn(ctx, wr.db).QueryContext(ctx,
+		"SELECT "+deliveryColumns+" FROM webhook_deliveries "+where.String()+" "+tail, where.Args()...)
+	if err != nil {
+		return nil, err
+	}
+	deliveries, err := scanDeliveries(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	page := storage.NewPage(deliveries, pagination, func(delivery *types.WebhookDelivery) types.Cursor {
+		return types.NewTimeCursor("updated_at", delivery.UpdatedAt, delivery.ID)
+	})
+	page.Total = total
+	return page, nil
+}
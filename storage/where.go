@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Where builds a WHERE clause out of optional filters, each "?" in a condition
+// becomes the next positional parameter ($1, $2, ...)
+type Where struct {
+	conditions []string
+	args       []interface{}
+}
+
+func (w *Where) Add(condition string, args ...interface{}) {
+	var builder strings.Builder
+	next := 0
+	for _, r := range condition {
+		if r == '?' && next < len(args) {
+			builder.WriteString(w.Arg(args[next]))
+			next++
+			continue
+		}
+		builder.WriteRune(r)
+	}
+	w.conditions = append(w.conditions, builder.String())
+}
+
+// Arg registers a parameter without a condition and returns its placeholder
+func (w *Where) Arg(arg interface{}) string {
+	w.args = append(w.args, arg)
+	return fmt.Sprintf("$%d", len(w.args))
+}
+
+func (w *Where) Args() []interface{} {
+	return w.args
+}
+
+func (w *Where) String() string {
+	if len(w.conditions) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(w.conditions, " AND ")
+}
@@ -0,0 +1,25 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWhere(t *testing.T) {
+	t.Run("should number the placeholders in order", func(t *testing.T) {
+		var where Where
+		where.Add("from_account = ?", "some id")
+		where.Add("amount BETWEEN ? AND ?", 1, 2)
+		limit := where.Arg(10)
+
+		assert.Equal(t, "WHERE from_account = $1 AND amount BETWEEN $2 AND $3", where.String())
+		assert.Equal(t, "$4", limit)
+		assert.Equal(t, []interface{}{"some id", 1, 2, 10}, where.Args())
+	})
+
+	t.Run("should be empty without conditions", func(t *testing.T) {
+		var where Where
+		assert.Equal(t, "", where.String())
+	})
+}
@@ -1,31 +1,186 @@
 package types
 
 import (
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type AccountStatus string
+
+const (
+	AccountActive AccountStatus = "ACTIVE"
+	AccountFrozen AccountStatus = "FROZEN"
+	AccountClosed AccountStatus = "CLOSED"
+)
+
+// allowed account status changes, CLOSED is final
+var accountTransitions = map[AccountStatus][]AccountStatus{
+	AccountActive: {AccountFrozen, AccountClosed},
+	AccountFrozen: {AccountActive, AccountClosed},
+}
+
+type AccountType string
+
+const (
+	CheckingAccount AccountType = "CHECKING"
+	SavingsAccount  AccountType = "SAVINGS"
+	EscrowAccount   AccountType = "ESCROW"
+)
+
+func (t AccountType) IsValid() bool {
+	switch t {
+	case CheckingAccount, SavingsAccount, EscrowAccount:
+		return true
+	}
+	return false
+}
+
+var ErrInvalidAccountStatusTransition = errors.New("InvalidAccountStatusTransition")
+var ErrInvalidCreditLimit = errors.New("InvalidCreditLimit")
+var ErrCreditLimitInUse = errors.New("CreditLimitInUse")
+
+func (s AccountStatus) CanTransitionTo(next AccountStatus) bool {
+	for _, allowed := range accountTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
 type Account struct {
-	ID        string    `json:"id"`
-	Owner     string    `json:"owner_id"`
-	Balance   float64   `json:"balance"`
-	CreatedAt time.Time `json:"created"`
-	UpdatedAt time.Time `json:"updated"`
-	DeletedAt time.Time `json:"deleted"`
+	ID          string        `json:"id"`
+	Owner       string        `json:"owner_id"`
+	Type        AccountType   `json:"type"`
+	Nickname    string        `json:"nickname"`
+	Currency    Currency      `json:"currency"`
+	Balance     Money         `json:"balance"`
+	CreditLimit Money         `json:"credit_limit"`
+	HeldAmount  Money         `json:"held_amount"`
+	Status      AccountStatus `json:"status"`
+	CreatedAt   time.Time     `json:"created"`
+	UpdatedAt   time.Time     `json:"updated"`
+	DeletedAt   *time.Time    `json:"deleted,omitempty"`
+}
+
+// AccountStatusChange records who changed the status of an account and why
+type AccountStatusChange struct {
+	AccountId string        `json:"account_id"`
+	From      AccountStatus `json:"from"`
+	To        AccountStatus `json:"to"`
+	Reason    string        `json:"reason"`
+	Actor     string        `json:"actor"`
+	ChangedAt time.Time     `json:"changed_at"`
 }
 
-func NewAccount(owner_id string, balance float64) *Account {
+// NewAccount opens an account in the currency of its opening balance
+func NewAccount(owner_id string, balance Money) *Account {
+	if balance.Currency == "" {
+		balance.Currency = DefaultCurrency
+	}
 	return &Account{
-		ID:        uuid.NewString(),
-		Owner:     owner_id,
-		Balance:   balance,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-		DeletedAt: time.Now(),
+		ID:          uuid.NewString(),
+		Owner:       owner_id,
+		Type:        CheckingAccount,
+		Currency:    balance.Currency,
+		Balance:     balance,
+		CreditLimit: NewMoney(0, balance.Currency),
+		HeldAmount:  NewMoney(0, balance.Currency),
+		Status:      AccountActive,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
 	}
 }
 
 func (a *Account) ValidateAccount() bool {
-	return a.Owner != "" && a.Balance >= 0
+	return a.Owner != "" && !a.Balance.IsNegative() && a.Type.IsValid() && len(a.Nickname) <= 100 &&
+		a.Currency.IsValid() && a.Balance.Currency == a.Currency
+}
+
+// AvailableBalance is what the account can still spend: its balance plus the approved overdraft
+// minus the funds held by active holds
+func (a *Account) AvailableBalance() Money {
+	return a.Balance.Add(a.CreditLimit).Sub(a.HeldAmount)
+}
+
+// SetCreditLimit approves an overdraft of limit, it can not be lowered below what the account already owes
+func (a *Account) SetCreditLimit(limit Money) error {
+	if limit.IsNegative() || !limit.SameCurrency(NewMoney(0, a.Currency)) {
+		return ErrInvalidCreditLimit
+	}
+	if a.Balance.Add(limit).Sub(a.HeldAmount).IsNegative() {
+		return ErrCreditLimitInUse
+	}
+	a.CreditLimit = NewMoney(limit.Amount, a.Currency)
+	a.UpdatedAt = time.Now()
+	return nil
+}
+
+// AccountBalance separates the ledger balance of an account from what it can spend,
+// Holds lists the active holds making up Held
+type AccountBalance struct {
+	Balance     Money   `json:"balance"`
+	CreditLimit Money   `json:"credit_limit"`
+	Held        Money   `json:"held"`
+	Available   Money   `json:"available_balance"`
+	Holds       []*Hold `json:"holds"`
+}
+
+func NewAccountBalance(account *Account, holds []*Hold) *AccountBalance {
+	if holds == nil {
+		holds = []*Hold{}
+	}
+	return &AccountBalance{
+		Balance:     account.Balance,
+		CreditLimit: account.CreditLimit,
+		Held:        account.HeldAmount,
+		Available:   account.AvailableBalance(),
+		Holds:       holds,
+	}
+}
+
+// OwnerAccounts lists every account of an owner, Totals adds up the balances of the accounts
+// that are not closed, one total per currency
+type OwnerAccounts struct {
+	OwnerId  string             `json:"owner_id"`
+	Accounts []*Account         `json:"accounts"`
+	Totals   map[Currency]Money `json:"totals"`
+}
+
+func NewOwnerAccounts(ownerId string, accounts []*Account) *OwnerAccounts {
+	owned := &OwnerAccounts{OwnerId: ownerId, Accounts: accounts, Totals: map[Currency]Money{}}
+	if owned.Accounts == nil {
+		owned.Accounts = []*Account{}
+	}
+	for _, account := range accounts {
+		if account.Status != AccountClosed {
+			total := owned.Totals[account.Balance.Currency]
+			owned.Totals[account.Balance.Currency] = total.Add(account.Balance)
+		}
+	}
+	return owned
+}
+
+// TransitionTo changes the account status, closing it also soft deletes it
+func (a *Account) TransitionTo(status AccountStatus, reason string, actor string) (*AccountStatusChange, error) {
+	if !a.Status.CanTransitionTo(status) {
+		return nil, ErrInvalidAccountStatusTransition
+	}
+	now := time.Now()
+	change := &AccountStatusChange{
+		AccountId: a.ID,
+		From:      a.Status,
+		To:        status,
+		Reason:    reason,
+		Actor:     actor,
+		ChangedAt: now,
+	}
+	a.Status = status
+	a.UpdatedAt = now
+	if status == AccountClosed {
+		a.DeletedAt = &now
+	}
+	return change, nil
 }
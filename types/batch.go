@@ -0,0 +1,131 @@
+package types
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type BatchStatus string
+
+const (
+	BatchPending   BatchStatus = "PENDING"
+	BatchCompleted BatchStatus = "COMPLETED"
+	BatchFailed    BatchStatus = "FAILED"
+)
+
+type LegStatus string
+
+const (
+	LegPending    LegStatus = "PENDING"
+	LegCompleted  LegStatus = "COMPLETED"
+	LegFailed     LegStatus = "FAILED"
+	LegRolledBack LegStatus = "ROLLED_BACK"
+	LegSkipped    LegStatus = "SKIPPED"
+)
+
+// TransferLeg is the movement to a single recipient of a transfer
+type TransferLeg struct {
+	AccountId     string    `json:"account_id"`
+	Amount        Money     `json:"amount"`
+	Subject       string    `json:"subject,omitempty"`
+	TransactionId string    `json:"transaction_id,omitempty"`
+	Status        LegStatus `json:"status"`
+	Error         string    `json:"error,omitempty"`
+}
+
+// TransferBatch is the parent record of a multi-beneficiary transfer, its id is the
+// MultiBeneficiaryTransactionId of every leg and the legs are committed all together or not at all
+type TransferBatch struct {
+	ID            string         `json:"id"`
+	From          string         `json:"from"`
+	Amount        Money          `json:"amount"`
+	Subject       string         `json:"subject"`
+	Status        BatchStatus    `json:"status"`
+	FailureReason string         `json:"failure_reason,omitempty"`
+	Legs          []*TransferLeg `json:"legs"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+}
+
+func NewTransferBatch(from string, subject string, amount Money) *TransferBatch {
+	return &TransferBatch{
+		ID:        uuid.NewString(),
+		From:      from,
+		Amount:    amount,
+		Subject:   subject,
+		Status:    BatchPending,
+		Legs:      []*TransferLeg{},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+}
+
+func (b *TransferBatch) AddLeg(accountId string, amount Money) *TransferLeg {
+	leg := &TransferLeg{AccountId: accountId, Amount: amount, Status: LegPending}
+	b.Legs = append(b.Legs, leg)
+	return leg
+}
+
+// Fail marks the batch as failed because of the leg at index failed, the legs before it
+// were rolled back together with it and the ones after it never ran
+func (b *TransferBatch) Fail(failed int, err error) {
+	for i, leg := range b.Legs {
+		switch {
+		case i < failed:
+			leg.Status = LegRolledBack
+			leg.TransactionId = ""
+		case i == failed:
+			leg.Status = LegFailed
+			leg.Error = err.Error()
+			leg.TransactionId = ""
+		default:
+			leg.Status = LegSkipped
+		}
+	}
+	b.Status = BatchFailed
+	b.FailureReason = err.Error()
+	b.UpdatedAt = time.Now()
+}
+
+// Abort fails the batch before any of its legs ran
+func (b *TransferBatch) Abort(err error) {
+	for _, leg := range b.Legs {
+		leg.Status = LegSkipped
+	}
+	b.Status = BatchFailed
+	b.FailureReason = err.Error()
+	b.UpdatedAt = time.Now()
+}
+
+func (b *TransferBatch) Complete() {
+	b.Status = BatchCompleted
+	b.UpdatedAt = time.Now()
+}
+
+// MaxBulkTransferRows caps how many recipients a single bulk transfer can pay
+const MaxBulkTransferRows = 5000
+
+var ErrInvalidBulkTransfer = errors.New("InvalidBulkTransfer")
+
+// BulkRowError is why a row of a bulk transfer was refused, rows are numbered as in the uploaded file
+type BulkRowError struct {
+	Row       int    `json:"row"`
+	AccountId string `json:"account_id,omitempty"`
+	Error     string `json:"error"`
+}
+
+// BulkTransferError lists every invalid row of a bulk transfer, none of its rows runs.
+// It matches ErrInvalidBulkTransfer with errors.Is
+type BulkTransferError struct {
+	Rows []BulkRowError `json:"rows"`
+}
+
+func (e *BulkTransferError) Error() string {
+	return ErrInvalidBulkTransfer.Error()
+}
+
+func (e *BulkTransferError) Unwrap() error {
+	return ErrInvalidBulkTransfer
+}
@@ -0,0 +1,175 @@
+package types
+
+import (
+	"errors"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+)
+
+type FeeKind string
+
+const (
+	FixedFee      FeeKind = "FIXED"
+	PercentageFee FeeKind = "PERCENTAGE"
+	TieredFee     FeeKind = "TIERED"
+)
+
+// maxFeePercentage keeps a misconfigured schedule from charging more than the amount itself
+const maxFeePercentage = 100
+
+var ErrInvalidFeeSchedule = errors.New("InvalidFeeSchedule")
+
+func (k FeeKind) IsValid() bool {
+	switch k {
+	case FixedFee, PercentageFee, TieredFee:
+		return true
+	}
+	return false
+}
+
+// FeeTier charges Amount plus Percentage of the amount for amounts up to UpTo.
+// A zero UpTo has no upper bound and is only allowed on the last tier
+type FeeTier struct {
+	UpTo       Money  `json:"up_to"`
+	Amount     Money  `json:"amount"`
+	Percentage string `json:"percentage,omitempty"`
+}
+
+// FeeSchedule is the fee charged on an operation for accounts of a type and currency.
+// An empty AccountType applies to every type that has no schedule of its own
+type FeeSchedule struct {
+	Operation   string      `json:"operation"`
+	AccountType AccountType `json:"account_type"`
+	Currency    Currency    `json:"currency"`
+	Kind        FeeKind     `json:"kind"`
+	Amount      Money       `json:"amount"`
+	Percentage  string      `json:"percentage,omitempty"`
+	Tiers       []FeeTier   `json:"tiers,omitempty"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+// NewFeeSchedule normalises the schedule and checks that its amounts, percentages and tiers make sense
+func NewFeeSchedule(operation string, accountType AccountType, currency Currency, kind FeeKind, amount Money, percentage string, tiers []FeeTier) (*FeeSchedule, error) {
+	schedule := &FeeSchedule{
+		Operation:   strings.ToUpper(operation),
+		AccountType: AccountType(strings.ToUpper(string(accountType))),
+		Currency:    Currency(strings.ToUpper(string(currency))),
+		Kind:        FeeKind(strings.ToUpper(string(kind))),
+		UpdatedAt:   time.Now(),
+	}
+	if !schedule.Currency.IsValid() || !schedule.Kind.IsValid() {
+		return nil, ErrInvalidFeeSchedule
+	}
+	if schedule.AccountType != "" && !schedule.AccountType.IsValid() {
+		return nil, ErrInvalidFeeSchedule
+	}
+
+	var err error
+	switch schedule.Kind {
+	case FixedFee:
+		schedule.Amount, err = schedule.inCurrency(amount)
+	case PercentageFee:
+		schedule.Amount = NewMoney(0, schedule.Currency)
+		schedule.Percentage, err = parseFeePercentage(percentage, false)
+	case TieredFee:
+		schedule.Amount = NewMoney(0, schedule.Currency)
+		schedule.Tiers, err = schedule.normaliseTiers(tiers)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+func (s *FeeSchedule) inCurrency(amount Money) (Money, error) {
+	if amount.IsNegative() || (amount.Currency != "" && amount.Currency != s.Currency) {
+		return Money{}, ErrInvalidFeeSchedule
+	}
+	return NewMoney(amount.Amount, s.Currency), nil
+}
+
+// normaliseTiers sorts the tiers by their upper bound, the unbounded tier goes last
+func (s *FeeSchedule) normaliseTiers(tiers []FeeTier) ([]FeeTier, error) {
+	if len(tiers) == 0 {
+		return nil, ErrInvalidFeeSchedule
+	}
+	normalised := make([]FeeTier, len(tiers))
+	for i, tier := range tiers {
+		var err error
+		if normalised[i].UpTo, err = s.inCurrency(tier.UpTo); err != nil {
+			return nil, err
+		}
+		if normalised[i].Amount, err = s.inCurrency(tier.Amount); err != nil {
+			return nil, err
+		}
+		if normalised[i].Percentage, err = parseFeePercentage(tier.Percentage, true); err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(normalised, func(i, j int) bool {
+		if normalised[i].UpTo.IsZero() || normalised[j].UpTo.IsZero() {
+			return !normalised[i].UpTo.IsZero()
+		}
+		return normalised[i].UpTo.LessThan(normalised[j].UpTo)
+	})
+	for i := 1; i < len(normalised); i++ {
+		if normalised[i-1].UpTo.IsZero() || normalised[i-1].UpTo.Equal(normalised[i].UpTo) {
+			return nil, ErrInvalidFeeSchedule
+		}
+	}
+	return normalised, nil
+}
+
+// parseFeePercentage accepts a decimal percentage between 0 and 100, like "1.5" for 1.5%
+func parseFeePercentage(percentage string, optional bool) (string, error) {
+	if optional && strings.TrimSpace(percentage) == "" {
+		return "", nil
+	}
+	parsed, err := ParseRate(percentage)
+	if err != nil {
+		return "", ErrInvalidFeeSchedule
+	}
+	value, _ := new(big.Rat).SetString(parsed)
+	if value.Cmp(big.NewRat(maxFeePercentage, 1)) > 0 {
+		return "", ErrInvalidFeeSchedule
+	}
+	return parsed, nil
+}
+
+// Compute is the fee charged on amount, rounded half away from zero to the cent
+func (s *FeeSchedule) Compute(amount Money) Money {
+	switch s.Kind {
+	case FixedFee:
+		return NewMoney(s.Amount.Amount, amount.Currency)
+	case PercentageFee:
+		return NewMoney(percentageOf(amount, s.Percentage), amount.Currency)
+	case TieredFee:
+		for _, tier := range s.Tiers {
+			if tier.UpTo.IsZero() || !amount.GreaterThan(tier.UpTo) {
+				return NewMoney(tier.Amount.Amount+percentageOf(amount, tier.Percentage), amount.Currency)
+			}
+		}
+	}
+	return NewMoney(0, amount.Currency)
+}
+
+func percentageOf(amount Money, percentage string) int64 {
+	if percentage == "" {
+		return 0
+	}
+	rate, _ := new(big.Rat).SetString(percentage)
+	fee := new(big.Rat).Mul(new(big.Rat).SetInt64(amount.Amount), rate)
+	return roundRat(fee.Quo(fee, big.NewRat(100, 1)))
+}
+
+// FeeQuote previews what an operation would cost before it is executed. BalanceChange is what the
+// operation and its fee together do to the account balance
+type FeeQuote struct {
+	Operation     string `json:"operation"`
+	AccountId     string `json:"account_id"`
+	Amount        Money  `json:"amount"`
+	Fee           Money  `json:"fee"`
+	BalanceChange Money  `json:"balance_change"`
+}
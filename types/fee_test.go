@@ -0,0 +1,63 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeeSchedule(t *testing.T) {
+	t.Run("Compute should charge fixed and percentage fees in the amount currency", func(t *testing.T) {
+		fixed, err := NewFeeSchedule("withdraw", "", USD, FixedFee, MustParseMoney("2.50", USD), "", nil)
+		assert.Nil(t, err)
+		assert.Equal(t, MustParseMoney("2.50", USD), fixed.Compute(MustParseMoney("1000", USD)))
+
+		percentage, err := NewFeeSchedule("TRANSFER", CheckingAccount, AOA, PercentageFee, Money{}, "1.5", nil)
+		assert.Nil(t, err)
+		assert.Equal(t, "TRANSFER", percentage.Operation)
+		assert.Equal(t, MustParseMoney("1.50", AOA), percentage.Compute(MustParseMoney("100", AOA)))
+		assert.Equal(t, MustParseMoney("0.02", AOA), percentage.Compute(MustParseMoney("1.00", AOA)))
+	})
+
+	t.Run("Compute should pick the first tier covering the amount", func(t *testing.T) {
+		tiered, err := NewFeeSchedule("TRANSFER", "", AOA, TieredFee, Money{}, "", []FeeTier{
+			{UpTo: Money{}, Amount: MustParseMoney("50", AOA), Percentage: "0.1"},
+			{UpTo: MustParseMoney("1000", AOA), Amount: MustParseMoney("10", AOA)},
+			{UpTo: MustParseMoney("100", AOA)},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, MustParseMoney("100", AOA), tiered.Tiers[0].UpTo)
+		assert.True(t, tiered.Tiers[2].UpTo.IsZero())
+
+		assert.Equal(t, MustParseMoney("0", AOA), tiered.Compute(MustParseMoney("100", AOA)))
+		assert.Equal(t, MustParseMoney("10", AOA), tiered.Compute(MustParseMoney("100.01", AOA)))
+		assert.Equal(t, MustParseMoney("52", AOA), tiered.Compute(MustParseMoney("2000", AOA)))
+	})
+
+	t.Run("NewFeeSchedule should refuse inconsistent schedules", func(t *testing.T) {
+		cases := []func() (*FeeSchedule, error){
+			func() (*FeeSchedule, error) {
+				return NewFeeSchedule("WITHDRAW", "", "XYZ", FixedFee, Money{}, "", nil)
+			},
+			func() (*FeeSchedule, error) {
+				return NewFeeSchedule("WITHDRAW", "GOLD", AOA, FixedFee, Money{}, "", nil)
+			},
+			func() (*FeeSchedule, error) {
+				return NewFeeSchedule("WITHDRAW", "", AOA, FixedFee, MustParseMoney("1", USD), "", nil)
+			},
+			func() (*FeeSchedule, error) {
+				return NewFeeSchedule("WITHDRAW", "", AOA, PercentageFee, Money{}, "101", nil)
+			},
+			func() (*FeeSchedule, error) {
+				return NewFeeSchedule("WITHDRAW", "", AOA, TieredFee, Money{}, "", nil)
+			},
+			func() (*FeeSchedule, error) {
+				return NewFeeSchedule("WITHDRAW", "", AOA, TieredFee, Money{}, "", []FeeTier{{}, {}})
+			},
+		}
+		for i, newSchedule := range cases {
+			_, err := newSchedule()
+			assert.ErrorIs(t, err, ErrInvalidFeeSchedule, i)
+		}
+	})
+}
@@ -0,0 +1,87 @@
+package types
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// rates are stored as NUMERIC(20,10)
+const maxRateDecimals = 10
+
+var ErrInvalidExchangeRate = errors.New("InvalidExchangeRate")
+
+// ExchangeRate is how many units of Quote one unit of Base buys, Rate is a plain decimal like "830.25"
+type ExchangeRate struct {
+	Base      Currency  `json:"base"`
+	Quote     Currency  `json:"quote"`
+	Rate      string    `json:"rate"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func NewExchangeRate(base Currency, quote Currency, rate string) (*ExchangeRate, error) {
+	base = Currency(strings.ToUpper(string(base)))
+	quote = Currency(strings.ToUpper(string(quote)))
+	if !base.IsValid() || !quote.IsValid() || base == quote {
+		return nil, ErrInvalidExchangeRate
+	}
+	normalized, err := ParseRate(rate)
+	if err != nil {
+		return nil, err
+	}
+	return &ExchangeRate{Base: base, Quote: quote, Rate: normalized, UpdatedAt: time.Now()}, nil
+}
+
+// ParseRate checks the rate is a positive decimal with at most ten decimal places and drops its trailing zeros
+func ParseRate(rate string) (string, error) {
+	rate = strings.TrimSpace(rate)
+	integerPart, fractionPart, _ := strings.Cut(rate, ".")
+	if integerPart == "" || len(fractionPart) > maxRateDecimals || !isDigits(integerPart) || !isDigits(fractionPart) {
+		return "", ErrInvalidExchangeRate
+	}
+	value, ok := new(big.Rat).SetString(rate)
+	if !ok || value.Sign() <= 0 {
+		return "", ErrInvalidExchangeRate
+	}
+	return value.FloatString(len(strings.TrimRight(fractionPart, "0"))), nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *ExchangeRate) rat() *big.Rat {
+	value, _ := new(big.Rat).SetString(r.Rate)
+	return value
+}
+
+// Convert turns an amount in Base into Quote, rounding half away from zero to the cent
+func (r *ExchangeRate) Convert(amount Money) Money {
+	converted := new(big.Rat).Mul(new(big.Rat).SetInt64(amount.Amount), r.rat())
+	return NewMoney(roundRat(converted), r.Quote)
+}
+
+// Inverse is the rate of the opposite direction, rounded to ten decimal places
+func (r *ExchangeRate) Inverse() *ExchangeRate {
+	inverse := new(big.Rat).Inv(r.rat())
+	rate, _ := ParseRate(inverse.FloatString(maxRateDecimals))
+	return &ExchangeRate{Base: r.Quote, Quote: r.Base, Rate: rate, UpdatedAt: r.UpdatedAt}
+}
+
+func roundRat(value *big.Rat) int64 {
+	quotient, remainder := new(big.Int).QuoRem(value.Num(), value.Denom(), new(big.Int))
+	if new(big.Int).Mul(remainder.Abs(remainder), big.NewInt(2)).Cmp(value.Denom()) >= 0 {
+		if value.Sign() < 0 {
+			quotient.Sub(quotient, big.NewInt(1))
+		} else {
+			quotient.Add(quotient, big.NewInt(1))
+		}
+	}
+	return quotient.Int64()
+}
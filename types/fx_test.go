@@ -0,0 +1,57 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExchangeRate(t *testing.T) {
+	t.Run("NewExchangeRate should normalize the rate and refuse invalid ones", func(t *testing.T) {
+		rate, err := NewExchangeRate("usd", "aoa", "830.5000")
+		assert.Nil(t, err)
+		assert.Equal(t, USD, rate.Base)
+		assert.Equal(t, AOA, rate.Quote)
+		assert.Equal(t, "830.5", rate.Rate)
+
+		for _, invalid := range []string{"", "0", "-1", "1e3", "1/3", "1.00000000001", "abc"} {
+			_, err := NewExchangeRate(USD, AOA, invalid)
+			assert.ErrorIs(t, err, ErrInvalidExchangeRate, invalid)
+		}
+		_, err = NewExchangeRate(USD, USD, "1")
+		assert.ErrorIs(t, err, ErrInvalidExchangeRate)
+	})
+
+	t.Run("Convert should round half away from zero to the cent", func(t *testing.T) {
+		rate, err := NewExchangeRate(AOA, USD, "0.0012045")
+		assert.Nil(t, err)
+
+		assert.Equal(t, NewMoney(12, USD), rate.Convert(MustParseMoney("100", AOA)))
+		assert.Equal(t, NewMoney(1205, USD), rate.Convert(MustParseMoney("10000", AOA)))
+		assert.Equal(t, NewMoney(-1205, USD), rate.Convert(MustParseMoney("-10000", AOA)))
+	})
+
+	t.Run("an exchanged transaction should book a balanced journal entry through the FX account", func(t *testing.T) {
+		rate, err := NewExchangeRate(USD, AOA, "830.25")
+		assert.Nil(t, err)
+		transaction := NewTransaction(MustParseMoney("10", USD), "from", "to", "subject", "TRANSFER", "", false, "")
+		transaction.Exchange(rate)
+
+		assert.Equal(t, MustParseMoney("8302.50", AOA), transaction.DestinationAmount)
+		assert.Equal(t, MustParseMoney("4151.25", AOA), transaction.DestinationShare(MustParseMoney("5", USD)))
+
+		entry := NewTransactionJournalEntry(transaction)
+		assert.Len(t, entry.Postings, 4)
+		assert.True(t, entry.IsBalanced())
+	})
+
+	t.Run("Inverse should swap the currencies", func(t *testing.T) {
+		rate, err := NewExchangeRate(USD, AOA, "800")
+		assert.Nil(t, err)
+		inverse := rate.Inverse()
+
+		assert.Equal(t, AOA, inverse.Base)
+		assert.Equal(t, USD, inverse.Quote)
+		assert.Equal(t, "0.00125", inverse.Rate)
+	})
+}
@@ -0,0 +1,64 @@
+package types
+
+import "encoding/json"
+
+type EntryDirection string
+
+const (
+	Credit EntryDirection = "CREDIT"
+	Debit  EntryDirection = "DEBIT"
+)
+
+// HistoryEntry is a transaction seen from one account: whether money came in or went out,
+// who was on the other side and the account balance right after it
+type HistoryEntry struct {
+	*Transaction
+	Direction    EntryDirection
+	Counterparty string
+	BalanceAfter Money
+}
+
+func NewHistoryEntry(transaction *Transaction, accountId string, balanceAfter Money) *HistoryEntry {
+	entry := &HistoryEntry{
+		Transaction:  transaction,
+		Direction:    Debit,
+		Counterparty: transaction.To,
+		BalanceAfter: balanceAfter,
+	}
+	if transaction.To == accountId {
+		entry.Direction = Credit
+		entry.Counterparty = transaction.From
+	}
+	return entry
+}
+
+// Currency is the currency of the account the entry is seen from
+func (e *HistoryEntry) Currency() Currency {
+	if e.Direction == Credit {
+		return e.DestinationAmount.Currency
+	}
+	return e.Amount.Currency
+}
+
+// SignedAmount is what the entry adds to the account balance, failed and pending transactions move nothing
+func (e *HistoryEntry) SignedAmount() Money {
+	if e.Status != TransactionCompleted && e.Status != TransactionReversed {
+		return NewMoney(0, e.Currency())
+	}
+	if e.Direction == Debit {
+		return e.Amount.Neg()
+	}
+	return e.DestinationAmount
+}
+
+// MarshalJSON keeps the transaction fields at the top level so the history stays a list of transactions
+func (e HistoryEntry) MarshalJSON() ([]byte, error) {
+	type transaction Transaction
+	return json.Marshal(struct {
+		transaction
+		RefundableAmount Money          `json:"refundable_amount"`
+		Direction        EntryDirection `json:"direction"`
+		Counterparty     string         `json:"counterparty"`
+		BalanceAfter     Money          `json:"balance_after"`
+	}{transaction(*e.Transaction), e.RefundableAmount(), e.Direction, e.Counterparty, e.BalanceAfter})
+}
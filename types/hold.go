@@ -0,0 +1,109 @@
+package types
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type HoldStatus string
+
+const (
+	HoldActive   HoldStatus = "ACTIVE"
+	HoldCaptured HoldStatus = "CAPTURED"
+	HoldVoided   HoldStatus = "VOIDED"
+	HoldExpired  HoldStatus = "EXPIRED"
+)
+
+const (
+	DefaultHoldDuration = 7 * 24 * time.Hour
+	MaxHoldDuration     = 30 * 24 * time.Hour
+)
+
+var ErrHoldNotActive = errors.New("HoldNotActive")
+var ErrHoldExpired = errors.New("HoldExpired")
+var ErrCaptureExceedsHold = errors.New("CaptureExceedsHold")
+
+func (s HoldStatus) IsValid() bool {
+	switch s {
+	case HoldActive, HoldCaptured, HoldVoided, HoldExpired:
+		return true
+	}
+	return false
+}
+
+// Hold reserves Amount of an account for a later transfer to To. While it is ACTIVE the amount is taken out
+// of the available balance without moving any money, capturing it transfers up to Amount and releases the rest
+type Hold struct {
+	ID             string     `json:"id"`
+	AccountId      string     `json:"account_id"`
+	To             string     `json:"to"`
+	Subject        string     `json:"subject"`
+	Amount         Money      `json:"amount"`
+	CapturedAmount Money      `json:"captured_amount"`
+	Status         HoldStatus `json:"status"`
+	TransactionId  string     `json:"transaction_id,omitempty"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+func NewHold(accountId string, to string, subject string, amount Money, duration time.Duration) *Hold {
+	now := time.Now()
+	return &Hold{
+		ID:             uuid.NewString(),
+		AccountId:      accountId,
+		To:             to,
+		Subject:        subject,
+		Amount:         amount,
+		CapturedAmount: NewMoney(0, amount.Currency),
+		Status:         HoldActive,
+		ExpiresAt:      now.Add(duration),
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+}
+
+func (h *Hold) IsExpired(now time.Time) bool {
+	return !now.Before(h.ExpiresAt)
+}
+
+// Capture settles amount of the hold, the part that is not captured goes back to the available balance
+func (h *Hold) Capture(amount Money, transactionId string) error {
+	if h.Status != HoldActive {
+		return ErrHoldNotActive
+	}
+	if h.IsExpired(time.Now()) {
+		return ErrHoldExpired
+	}
+	if !amount.IsPositive() || !amount.SameCurrency(h.Amount) || amount.GreaterThan(h.Amount) {
+		return ErrCaptureExceedsHold
+	}
+	h.CapturedAmount = NewMoney(amount.Amount, h.Amount.Currency)
+	h.TransactionId = transactionId
+	h.close(HoldCaptured)
+	return nil
+}
+
+func (h *Hold) Void() error {
+	if h.Status != HoldActive {
+		return ErrHoldNotActive
+	}
+	h.close(HoldVoided)
+	return nil
+}
+
+// Expire releases a hold that was neither captured nor voided in time
+func (h *Hold) Expire(now time.Time) error {
+	if h.Status != HoldActive || !h.IsExpired(now) {
+		return ErrHoldNotActive
+	}
+	h.close(HoldExpired)
+	return nil
+}
+
+func (h *Hold) close(status HoldStatus) {
+	h.Status = status
+	h.UpdatedAt = time.Now()
+}
@@ -0,0 +1,49 @@
+package types
+
+import "time"
+
+// IdempotencyLease is how long a request keeps its Idempotency-Key reserved without completing it.
+// A key still reserved after that was left behind by a request that never finished and can be taken over
+const IdempotencyLease = 5 * time.Minute
+
+// IdempotencyRecord is the stored outcome of the first request sent with an Idempotency-Key.
+// StatusCode is 0 while that request is still being processed, ReservedAt is when it took the key
+type IdempotencyRecord struct {
+	Key          string     `json:"key"`
+	Method       string     `json:"method"`
+	Path         string     `json:"path"`
+	RequestHash  string     `json:"request_hash"`
+	StatusCode   int        `json:"status_code"`
+	ContentType  string     `json:"content_type"`
+	ResponseBody []byte     `json:"response_body"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ReservedAt   time.Time  `json:"reserved_at"`
+	CompletedAt  *time.Time `json:"completed_at"`
+}
+
+func NewIdempotencyRecord(key string, method string, path string, requestHash string) *IdempotencyRecord {
+	// the database keeps microseconds, the reservation must compare equal once stored
+	now := time.Now().Truncate(time.Microsecond)
+	return &IdempotencyRecord{
+		Key:         key,
+		Method:      method,
+		Path:        path,
+		RequestHash: requestHash,
+		CreatedAt:   now,
+		ReservedAt:  now,
+	}
+}
+
+func (r *IdempotencyRecord) IsCompleted() bool {
+	return r.CompletedAt != nil
+}
+
+// IsAbandoned reports whether the request holding the key stopped without completing it
+func (r *IdempotencyRecord) IsAbandoned(now time.Time) bool {
+	return !r.IsCompleted() && !now.Before(r.ReservedAt.Add(IdempotencyLease))
+}
+
+// CanTakeOver reports whether record, a retry of the request that reserved stored, may take the key over
+func (r *IdempotencyRecord) CanTakeOver(stored *IdempotencyRecord) bool {
+	return stored.RequestHash == r.RequestHash && stored.IsAbandoned(r.ReservedAt)
+}
@@ -0,0 +1,136 @@
+package types
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// system accounts are the other side of the movements that enter or leave the bank,
+// they only exist in the ledger and their posted balance is usually negative
+const (
+	SystemCashInAccount  = "system-cash-in"
+	SystemCashOutAccount = "system-cash-out"
+	SystemFeesAccount    = "system-fees"
+	SystemFXAccount      = "system-fx"
+)
+
+var ErrUnbalancedJournalEntry = errors.New("UnbalancedJournalEntry")
+
+func IsSystemAccount(accountId string) bool {
+	return accountId == SystemCashInAccount || accountId == SystemCashOutAccount || accountId == SystemFeesAccount || accountId == SystemFXAccount
+}
+
+// Posting is one side of a journal entry. Amounts follow the account balance:
+// a credit is positive and raises the balance, a debit is negative and lowers it
+type Posting struct {
+	ID        string    `json:"id"`
+	EntryId   string    `json:"entry_id"`
+	AccountId string    `json:"account_id"`
+	Amount    Money     `json:"amount"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// JournalEntry groups the postings of a single operation, they always sum to zero
+type JournalEntry struct {
+	ID            string     `json:"id"`
+	TransactionId string     `json:"transaction_id"`
+	Operation     string     `json:"operation"`
+	Postings      []*Posting `json:"postings"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+func NewJournalEntry(transactionId string, operation string) *JournalEntry {
+	return &JournalEntry{
+		ID:            uuid.NewString(),
+		TransactionId: transactionId,
+		Operation:     operation,
+		Postings:      []*Posting{},
+		CreatedAt:     time.Now(),
+	}
+}
+
+// NewTransactionJournalEntry books the movement of a transaction: debits From and credits To.
+// Currency exchanges go through the FX system account so every currency still sums to zero
+func NewTransactionJournalEntry(transaction *Transaction) *JournalEntry {
+	entry := NewJournalEntry(transaction.ID, transaction.Operation)
+	entry.Debit(transaction.From, transaction.Amount)
+	if transaction.IsExchange() {
+		entry.Credit(SystemFXAccount, transaction.Amount)
+		entry.Debit(SystemFXAccount, transaction.DestinationAmount)
+		entry.Credit(transaction.To, transaction.DestinationAmount)
+		return entry
+	}
+	entry.Credit(transaction.To, transaction.Amount)
+	return entry
+}
+
+func (e *JournalEntry) addPosting(accountId string, amount Money) {
+	e.Postings = append(e.Postings, &Posting{
+		ID:        uuid.NewString(),
+		EntryId:   e.ID,
+		AccountId: accountId,
+		Amount:    amount,
+		CreatedAt: e.CreatedAt,
+	})
+}
+
+func (e *JournalEntry) Debit(accountId string, amount Money) {
+	e.addPosting(accountId, amount.Neg())
+}
+
+func (e *JournalEntry) Credit(accountId string, amount Money) {
+	e.addPosting(accountId, amount)
+}
+
+func (e *JournalEntry) IsBalanced() bool {
+	if len(e.Postings) < 2 {
+		return false
+	}
+	sums := map[Currency]int64{}
+	for _, posting := range e.Postings {
+		sums[posting.Amount.Currency] += posting.Amount.Amount
+	}
+	for _, sum := range sums {
+		if sum != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// TrialBalanceLine compares what the postings say an account holds with the balance stored on it.
+// StoredBalance is nil for system accounts, which get a line per currency they hold
+type TrialBalanceLine struct {
+	AccountId     string `json:"account_id"`
+	PostedBalance Money  `json:"posted_balance"`
+	StoredBalance *Money `json:"stored_balance"`
+	InSync        bool   `json:"in_sync"`
+}
+
+type TrialBalance struct {
+	Lines []*TrialBalanceLine `json:"lines"`
+	// TotalPosted is the sum of the posted balances in each currency, every one of them is zero when Balanced
+	TotalPosted map[Currency]Money `json:"total_posted"`
+	Balanced    bool               `json:"balanced"`
+}
+
+func NewTrialBalance(lines []*TrialBalanceLine) *TrialBalance {
+	totals := map[Currency]Money{}
+	for _, line := range lines {
+		currency := line.PostedBalance.Currency
+		totals[currency] = totals[currency].Add(line.PostedBalance)
+		line.InSync = line.StoredBalance == nil ||
+			(line.StoredBalance.SameCurrency(line.PostedBalance) && line.StoredBalance.Equal(line.PostedBalance))
+	}
+	balanced := true
+	for _, total := range totals {
+		balanced = balanced && total.IsZero()
+	}
+	return &TrialBalance{
+		Lines:       lines,
+		TotalPosted: totals,
+		Balanced:    balanced,
+	}
+}
@@ -0,0 +1,153 @@
+package types
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrLimitExceeded = errors.New("LimitExceeded")
+var ErrInvalidLimit = errors.New("InvalidLimit")
+
+// names of the limits a LimitExceededError can refer to
+const (
+	PerTransactionLimit = "per_transaction"
+	DailyAmountLimit    = "daily_amount"
+	MonthlyAmountLimit  = "monthly_amount"
+	DailyCountLimit     = "daily_count"
+)
+
+// Limit caps how much an account can move with one operation type. A nil field has no limit
+type Limit struct {
+	AccountId      string    `json:"account_id"`
+	Operation      string    `json:"operation"`
+	PerTransaction *Money    `json:"per_transaction"`
+	DailyAmount    *Money    `json:"daily_amount"`
+	MonthlyAmount  *Money    `json:"monthly_amount"`
+	DailyCount     *int      `json:"daily_count"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// LimitUsage is what the account already moved with the operation today and this month
+type LimitUsage struct {
+	DailyAmount   Money `json:"daily_amount"`
+	MonthlyAmount Money `json:"monthly_amount"`
+	DailyCount    int   `json:"daily_count"`
+}
+
+// LimitAllowance is what is left of each limit, nil for the ones that are not set
+type LimitAllowance struct {
+	PerTransaction *Money `json:"per_transaction"`
+	DailyAmount    *Money `json:"daily_amount"`
+	MonthlyAmount  *Money `json:"monthly_amount"`
+	DailyCount     *int   `json:"daily_count"`
+}
+
+// LimitStatus reports a limit next to its current usage
+type LimitStatus struct {
+	Limit     *Limit          `json:"limit"`
+	Usage     LimitUsage      `json:"usage"`
+	Remaining *LimitAllowance `json:"remaining"`
+}
+
+// LimitExceededError tells which limit an operation went past and what is left of every limit.
+// It matches ErrLimitExceeded with errors.Is
+type LimitExceededError struct {
+	Operation string          `json:"operation"`
+	Limit     string          `json:"limit"`
+	Remaining *LimitAllowance `json:"remaining"`
+}
+
+func (e *LimitExceededError) Error() string {
+	return ErrLimitExceeded.Error()
+}
+
+func (e *LimitExceededError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// NewLimit checks that every amount set is positive and in currency and the count is not negative
+func NewLimit(accountId string, operation string, currency Currency, perTransaction, dailyAmount, monthlyAmount *Money, dailyCount *int) (*Limit, error) {
+	for _, amount := range []*Money{perTransaction, dailyAmount, monthlyAmount} {
+		if amount != nil && (!amount.IsPositive() || amount.Currency != currency) {
+			return nil, ErrInvalidLimit
+		}
+	}
+	if dailyCount != nil && *dailyCount < 0 {
+		return nil, ErrInvalidLimit
+	}
+	return &Limit{
+		AccountId:      accountId,
+		Operation:      operation,
+		PerTransaction: perTransaction,
+		DailyAmount:    dailyAmount,
+		MonthlyAmount:  monthlyAmount,
+		DailyCount:     dailyCount,
+		UpdatedAt:      time.Now(),
+	}, nil
+}
+
+// Remaining is what is left of each limit given the usage
+func (l *Limit) Remaining(usage LimitUsage) *LimitAllowance {
+	remaining := &LimitAllowance{PerTransaction: l.PerTransaction}
+	if l.DailyAmount != nil {
+		left := nonNegative(l.DailyAmount.Sub(usage.DailyAmount))
+		remaining.DailyAmount = &left
+	}
+	if l.MonthlyAmount != nil {
+		left := nonNegative(l.MonthlyAmount.Sub(usage.MonthlyAmount))
+		remaining.MonthlyAmount = &left
+	}
+	if l.DailyCount != nil {
+		left := *l.DailyCount - usage.DailyCount
+		if left < 0 {
+			left = 0
+		}
+		remaining.DailyCount = &left
+	}
+	return remaining
+}
+
+// Check refuses to move amounts, one transaction each, when that goes past any limit. The per transaction
+// limit applies to every amount on its own and the others to their sum
+func (l *Limit) Check(usage LimitUsage, amounts ...Money) error {
+	remaining := l.Remaining(usage)
+	amount, largest := NewMoney(0, usage.DailyAmount.Currency), NewMoney(0, usage.DailyAmount.Currency)
+	for _, a := range amounts {
+		amount = amount.Add(a)
+		if a.GreaterThan(largest) {
+			largest = a
+		}
+	}
+	exceeded := ""
+	switch {
+	case remaining.PerTransaction != nil && largest.GreaterThan(*remaining.PerTransaction):
+		exceeded = PerTransactionLimit
+	case remaining.DailyCount != nil && len(amounts) > *remaining.DailyCount:
+		exceeded = DailyCountLimit
+	case remaining.DailyAmount != nil && amount.GreaterThan(*remaining.DailyAmount):
+		exceeded = DailyAmountLimit
+	case remaining.MonthlyAmount != nil && amount.GreaterThan(*remaining.MonthlyAmount):
+		exceeded = MonthlyAmountLimit
+	default:
+		return nil
+	}
+	return &LimitExceededError{Operation: l.Operation, Limit: exceeded, Remaining: remaining}
+}
+
+func nonNegative(m Money) Money {
+	if m.IsNegative() {
+		return NewMoney(0, m.Currency)
+	}
+	return m
+}
+
+// StartOfDay and StartOfMonth are the local time boundaries the daily and monthly limits reset at
+func StartOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+func StartOfMonth(t time.Time) time.Time {
+	year, month, _ := t.Date()
+	return time.Date(year, month, 1, 0, 0, 0, 0, t.Location())
+}
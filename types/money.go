@@ -0,0 +1,290 @@
+package types
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Currency is an ISO 4217 currency code
+type Currency string
+
+const (
+	AOA Currency = "AOA"
+	USD Currency = "USD"
+	EUR Currency = "EUR"
+
+	DefaultCurrency = AOA
+)
+
+func (c Currency) IsValid() bool {
+	switch c {
+	case AOA, USD, EUR:
+		return true
+	}
+	return false
+}
+
+// every supported currency (and the postgres MONEY column) uses two decimal places
+const minorUnitsPerMajor = 100
+
+var ErrInvalidMoney = errors.New("InvalidMoney")
+
+// ErrMoneyOverflow is returned, or the panic, of an arithmetic whose result does not fit in int64 minor units
+var ErrMoneyOverflow = errors.New("MoneyOverflow")
+
+// Money is an exact monetary amount stored as integer minor units (cents) plus its currency.
+// Arithmetic and comparisons between two values panic when both have a currency and they differ,
+// use SameCurrency to check it first. A value without currency takes the one of the other.
+// Amounts a client sends are summed with CheckedAdd, Add and Sub panic on overflow.
+type Money struct {
+	Amount   int64
+	Currency Currency
+}
+
+func NewMoney(minorUnits int64, currency Currency) Money {
+	return Money{Amount: minorUnits, Currency: currency}
+}
+
+// ParseMoney parses a decimal string like "100", "-12.5" or "0.30" without going through float64
+func ParseMoney(value string, currency Currency) (Money, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return Money{}, ErrInvalidMoney
+	}
+
+	negative := false
+	switch value[0] {
+	case '-':
+		negative = true
+		value = value[1:]
+	case '+':
+		value = value[1:]
+	}
+
+	integerPart, fractionPart, hasFraction := strings.Cut(value, ".")
+	if integerPart == "" && fractionPart == "" {
+		return Money{}, ErrInvalidMoney
+	}
+	if hasFraction && fractionPart == "" {
+		return Money{}, ErrInvalidMoney
+	}
+	// numeric aggregates may come back with a wider scale, only zeros are allowed past the cents
+	if len(fractionPart) > 2 {
+		if strings.Trim(fractionPart[2:], "0") != "" {
+			return Money{}, ErrInvalidMoney
+		}
+		fractionPart = fractionPart[:2]
+	}
+	for len(fractionPart) < 2 {
+		fractionPart += "0"
+	}
+	if integerPart == "" {
+		integerPart = "0"
+	}
+
+	major, err := strconv.ParseUint(integerPart, 10, 63)
+	if err != nil {
+		return Money{}, ErrInvalidMoney
+	}
+	minor, err := strconv.ParseUint(fractionPart, 10, 8)
+	if err != nil {
+		return Money{}, ErrInvalidMoney
+	}
+	// math.MinInt64 is left out too, so every amount can be negated
+	if major > (math.MaxInt64-minor)/minorUnitsPerMajor {
+		return Money{}, ErrInvalidMoney
+	}
+
+	amount := int64(major)*minorUnitsPerMajor + int64(minor)
+	if negative {
+		amount = -amount
+	}
+	return NewMoney(amount, currency), nil
+}
+
+func MustParseMoney(value string, currency Currency) Money {
+	m, err := ParseMoney(value, currency)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+func (m Money) SameCurrency(other Money) bool {
+	return m.Currency == "" || other.Currency == "" || m.Currency == other.Currency
+}
+
+func (m Money) currencyWith(other Money) Currency {
+	if m.Currency != "" {
+		return m.Currency
+	}
+	return other.Currency
+}
+
+// mustSameCurrency panics when m and other can not be added or compared, it is a bug in the caller
+func (m Money) mustSameCurrency(other Money) {
+	if !m.SameCurrency(other) {
+		panic(fmt.Sprintf("types.Money: mixing %s with %s", m.Currency, other.Currency))
+	}
+}
+
+// Add panics with ErrMoneyOverflow when the sum does not fit in int64 minor units
+func (m Money) Add(other Money) Money {
+	sum, err := m.CheckedAdd(other)
+	if err != nil {
+		panic(err)
+	}
+	return sum
+}
+
+// CheckedAdd is Add returning ErrMoneyOverflow instead of panicking, for amounts a client sent
+func (m Money) CheckedAdd(other Money) (Money, error) {
+	m.mustSameCurrency(other)
+	sum := m.Amount + other.Amount
+	if (other.Amount > 0 && sum < m.Amount) || (other.Amount < 0 && sum > m.Amount) || sum == math.MinInt64 {
+		return Money{}, ErrMoneyOverflow
+	}
+	return NewMoney(sum, m.currencyWith(other)), nil
+}
+
+// Sub panics with ErrMoneyOverflow when the difference does not fit in int64 minor units
+func (m Money) Sub(other Money) Money {
+	m.mustSameCurrency(other)
+	difference := m.Amount - other.Amount
+	if (other.Amount > 0 && difference > m.Amount) || (other.Amount < 0 && difference < m.Amount) || difference == math.MinInt64 {
+		panic(ErrMoneyOverflow)
+	}
+	return NewMoney(difference, m.currencyWith(other))
+}
+
+// Neg panics with ErrMoneyOverflow on math.MinInt64, which has no positive counterpart
+func (m Money) Neg() Money {
+	if m.Amount == math.MinInt64 {
+		panic(ErrMoneyOverflow)
+	}
+	return NewMoney(-m.Amount, m.Currency)
+}
+
+// Cmp returns -1, 0 or 1 when m is less than, equal to or greater than other
+func (m Money) Cmp(other Money) int {
+	m.mustSameCurrency(other)
+	switch {
+	case m.Amount < other.Amount:
+		return -1
+	case m.Amount > other.Amount:
+		return 1
+	}
+	return 0
+}
+
+func (m Money) Equal(other Money) bool       { return m.Cmp(other) == 0 }
+func (m Money) GreaterThan(other Money) bool { return m.Cmp(other) > 0 }
+func (m Money) LessThan(other Money) bool    { return m.Cmp(other) < 0 }
+func (m Money) IsZero() bool                 { return m.Amount == 0 }
+func (m Money) IsPositive() bool             { return m.Amount > 0 }
+func (m Money) IsNegative() bool             { return m.Amount < 0 }
+
+// String formats the amount as a plain decimal, e.g. "-12.50"
+func (m Money) String() string {
+	sign := ""
+	amount := uint64(m.Amount)
+	if m.Amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, amount/minorUnitsPerMajor, amount%minorUnitsPerMajor)
+}
+
+type moneyJSON struct {
+	Amount   json.RawMessage `json:"amount"`
+	Currency Currency        `json:"currency"`
+}
+
+// MarshalJSON encodes the amount as a decimal string so clients never round trip it through a float
+func (m Money) MarshalJSON() ([]byte, error) {
+	currency := m.Currency
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	return json.Marshal(struct {
+		Amount   string   `json:"amount"`
+		Currency Currency `json:"currency"`
+	}{m.String(), currency})
+}
+
+// UnmarshalJSON accepts a bare number (100.5), a decimal string ("100.50")
+// or an object ({"amount": "100.50", "currency": "AOA"})
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	currency := m.Currency
+	if len(data) > 0 && data[0] == '{' {
+		var obj moneyJSON
+		if err := json.Unmarshal(data, &obj); err != nil {
+			return err
+		}
+		if obj.Currency != "" {
+			currency = Currency(strings.ToUpper(string(obj.Currency)))
+		}
+		data = bytes.TrimSpace(obj.Amount)
+	}
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+
+	raw := string(data)
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return err
+		}
+	}
+
+	parsed, err := ParseMoney(raw, currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Scan reads NUMERIC/MONEY columns, they must be selected as ::decimal so the driver hands over plain text
+func (m *Money) Scan(src interface{}) error {
+	currency := m.Currency
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+
+	var parsed Money
+	var err error
+	switch v := src.(type) {
+	case nil:
+		parsed = NewMoney(0, currency)
+	case []byte:
+		parsed, err = ParseMoney(string(v), currency)
+	case string:
+		parsed, err = ParseMoney(v, currency)
+	case int64:
+		parsed = NewMoney(v*minorUnitsPerMajor, currency)
+	default:
+		return fmt.Errorf("types.Money: unsupported scan type %T", src)
+	}
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Value writes the amount as a decimal literal, which postgres accepts for NUMERIC and MONEY
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
@@ -0,0 +1,107 @@
+package types
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMoney(t *testing.T) {
+	t.Run("ParseMoney should parse decimals exactly", func(t *testing.T) {
+		cases := map[string]int64{
+			"100":    10000,
+			"0.1":    10,
+			"0.30":   30,
+			"-12.5":  -1250,
+			".5":     50,
+			"7.1000": 710,
+		}
+		for input, expected := range cases {
+			got, err := ParseMoney(input, AOA)
+			assert.Nil(t, err, input)
+			assert.Equal(t, expected, got.Amount, input)
+		}
+	})
+
+	t.Run("ParseMoney should reject more than two significant decimals and garbage", func(t *testing.T) {
+		for _, input := range []string{"", "1.234", "abc", "1.", "1e5", "--1"} {
+			_, err := ParseMoney(input, AOA)
+			assert.ErrorIs(t, err, ErrInvalidMoney, input)
+		}
+	})
+
+	t.Run("0.1 + 0.2 should equal 0.3", func(t *testing.T) {
+		sum := MustParseMoney("0.1", AOA).Add(MustParseMoney("0.2", AOA))
+		assert.True(t, sum.Equal(MustParseMoney("0.3", AOA)))
+	})
+
+	t.Run("comparisons and arithmetic should panic on different currencies", func(t *testing.T) {
+		aoa, usd := NewMoney(100, AOA), NewMoney(100, USD)
+		assert.Panics(t, func() { aoa.Cmp(usd) })
+		assert.Panics(t, func() { aoa.Equal(usd) })
+		assert.Panics(t, func() { aoa.GreaterThan(usd) })
+		assert.Panics(t, func() { aoa.Add(usd) })
+		assert.Panics(t, func() { aoa.Sub(usd) })
+	})
+
+	t.Run("a value without currency should take the one of the other", func(t *testing.T) {
+		bare := NewMoney(100, "")
+		assert.True(t, bare.Equal(NewMoney(100, USD)))
+		assert.Equal(t, NewMoney(150, USD), bare.Add(NewMoney(50, USD)))
+	})
+
+	t.Run("Add and Sub should panic instead of wrapping around", func(t *testing.T) {
+		largest, smallest := NewMoney(math.MaxInt64, AOA), NewMoney(math.MinInt64, AOA)
+		assert.PanicsWithValue(t, ErrMoneyOverflow, func() { largest.Add(NewMoney(1, AOA)) })
+		assert.PanicsWithValue(t, ErrMoneyOverflow, func() { smallest.Add(NewMoney(-1, AOA)) })
+		assert.PanicsWithValue(t, ErrMoneyOverflow, func() { smallest.Sub(NewMoney(1, AOA)) })
+		assert.PanicsWithValue(t, ErrMoneyOverflow, func() { NewMoney(0, AOA).Sub(smallest) })
+		assert.Equal(t, NewMoney(math.MaxInt64, AOA), NewMoney(-1, AOA).Sub(smallest))
+		assert.PanicsWithValue(t, ErrMoneyOverflow, func() { smallest.Neg() })
+		assert.Equal(t, "-92233720368547758.08", smallest.String())
+	})
+
+	t.Run("CheckedAdd should return an error instead of panicking", func(t *testing.T) {
+		largest := MustParseMoney("92233720368547758.07", AOA)
+		_, err := largest.CheckedAdd(NewMoney(1, AOA))
+		assert.Equal(t, ErrMoneyOverflow, err)
+		sum, err := largest.CheckedAdd(NewMoney(-7, AOA))
+		assert.Nil(t, err)
+		assert.Equal(t, "92233720368547758.00", sum.String())
+	})
+
+	t.Run("ParseMoney should refuse amounts past the int64 range", func(t *testing.T) {
+		for _, value := range []string{"92233720368547758.08", "92233720368547758.99", "-92233720368547758.08", "92233720368547759"} {
+			_, err := ParseMoney(value, AOA)
+			assert.Equal(t, ErrInvalidMoney, err, value)
+		}
+	})
+
+	t.Run("String should format minor units as a decimal", func(t *testing.T) {
+		assert.Equal(t, "100.05", NewMoney(10005, AOA).String())
+		assert.Equal(t, "-0.50", NewMoney(-50, AOA).String())
+	})
+
+	t.Run("JSON should round trip and accept numbers, strings and objects", func(t *testing.T) {
+		var fromNumber, fromString, fromObject Money
+		assert.Nil(t, json.Unmarshal([]byte(`0.3`), &fromNumber))
+		assert.Nil(t, json.Unmarshal([]byte(`"0.30"`), &fromString))
+		assert.Nil(t, json.Unmarshal([]byte(`{"amount": "0.30", "currency": "usd"}`), &fromObject))
+
+		assert.Equal(t, NewMoney(30, AOA), fromNumber)
+		assert.Equal(t, NewMoney(30, AOA), fromString)
+		assert.Equal(t, NewMoney(30, USD), fromObject)
+
+		data, err := json.Marshal(fromObject)
+		assert.Nil(t, err)
+		assert.JSONEq(t, `{"amount": "0.30", "currency": "USD"}`, string(data))
+	})
+
+	t.Run("Scan should read decimal text from the driver", func(t *testing.T) {
+		var m Money
+		assert.Nil(t, m.Scan([]byte("1234.56")))
+		assert.Equal(t, NewMoney(123456, DefaultCurrency), m)
+	})
+}
@@ -0,0 +1,147 @@
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type OperationKind string
+
+const (
+	OperationTransfer     OperationKind = "TRANSFER"
+	OperationRefund       OperationKind = "REFUND"
+	OperationBulkTransfer OperationKind = "BULK_TRANSFER"
+)
+
+type OperationStatus string
+
+const (
+	OperationPending   OperationStatus = "PENDING"
+	OperationRunning   OperationStatus = "RUNNING"
+	OperationSucceeded OperationStatus = "SUCCEEDED"
+	OperationFailed    OperationStatus = "FAILED"
+)
+
+// operationLease is how long a worker may run an operation without renewing it before it is taken as interrupted
+const operationLease = 10 * time.Minute
+
+// OperationLeaseRenewal is how often a worker renews the lease of the operation it runs
+const OperationLeaseRenewal = operationLease / 4
+
+var ErrOperationNotPending = errors.New("OperationNotPending")
+
+// ErrOperationLeaseLost is returned to a worker whose operation was interrupted while it ran it
+var ErrOperationLeaseLost = errors.New("OperationLeaseLost")
+
+// Operation is a request accepted to run in the background. Request is its body as received and Result
+// what the synchronous endpoint would have answered, Error the code of the failure when there is one
+type Operation struct {
+	ID          string          `json:"id"`
+	Kind        OperationKind   `json:"kind"`
+	Request     json.RawMessage `json:"request"`
+	Status      OperationStatus `json:"status"`
+	Result      json.RawMessage `json:"result,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	LockedUntil *time.Time      `json:"-"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	CompletedAt *time.Time      `json:"completed_at,omitempty"`
+}
+
+func NewOperation(kind OperationKind, request interface{}) (*Operation, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return &Operation{
+		ID:        uuid.NewString(),
+		Kind:      kind,
+		Request:   body,
+		Status:    OperationPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}, nil
+}
+
+// IsInterrupted reports whether the operation was left running past its lease, by a server that stopped
+func (o *Operation) IsInterrupted(now time.Time) bool {
+	return o.Status == OperationRunning && o.LockedUntil != nil && !now.Before(*o.LockedUntil)
+}
+
+// Start claims a pending operation for a worker, so it runs once even with several servers
+func (o *Operation) Start(now time.Time) error {
+	if o.Status != OperationPending {
+		return ErrOperationNotPending
+	}
+	o.Status = OperationRunning
+	o.extendLease(now)
+	return nil
+}
+
+// HoldsLease reports whether the operation still runs under lease, the one a worker got from Start or Renew
+func (o *Operation) HoldsLease(lease time.Time) bool {
+	return o.Status == OperationRunning && o.LockedUntil != nil && o.LockedUntil.Equal(lease)
+}
+
+// Renew keeps the operation of the worker holding lease away from NextOperations for another operationLease
+func (o *Operation) Renew(lease time.Time, now time.Time) error {
+	if !o.HoldsLease(lease) {
+		return ErrOperationLeaseLost
+	}
+	o.extendLease(now)
+	return nil
+}
+
+func (o *Operation) extendLease(now time.Time) {
+	// the database keeps microseconds, the lease must compare equal once stored
+	lockedUntil := now.Add(operationLease).Truncate(time.Microsecond)
+	o.LockedUntil = &lockedUntil
+	o.UpdatedAt = time.Now()
+}
+
+// Interrupt puts an operation left running back to pending. It moved no money: the outcome of an
+// operation is stored together with the money it moves
+func (o *Operation) Interrupt(now time.Time) error {
+	if !o.IsInterrupted(now) {
+		return ErrOperationNotPending
+	}
+	o.Status = OperationPending
+	o.LockedUntil = nil
+	o.UpdatedAt = time.Now()
+	return nil
+}
+
+// Complete is Finish for the worker holding lease, a worker that lost the operation can not overwrite
+// what happened to it since
+func (o *Operation) Complete(lease time.Time, result interface{}, err error) error {
+	if !o.HoldsLease(lease) {
+		return ErrOperationLeaseLost
+	}
+	return o.Finish(result, err)
+}
+
+// Finish records the outcome of the operation, err is the reason it failed if it did. result is kept in
+// both cases, like a failed transfer still answers with its batch
+func (o *Operation) Finish(result interface{}, err error) error {
+	if result != nil {
+		body, marshalErr := json.Marshal(result)
+		if marshalErr != nil {
+			return marshalErr
+		}
+		o.Result = body
+	}
+	now := time.Now()
+	o.Status = OperationSucceeded
+	if err != nil {
+		o.Status = OperationFailed
+		o.Error = err.Error()
+	}
+	o.LockedUntil = nil
+	o.CompletedAt = &now
+	o.UpdatedAt = now
+	return nil
+}
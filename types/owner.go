@@ -0,0 +1,127 @@
+package types
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type KYCStatus string
+
+const (
+	KYCPending  KYCStatus = "PENDING"
+	KYCVerified KYCStatus = "VERIFIED"
+	KYCRejected KYCStatus = "REJECTED"
+)
+
+// allowed KYC changes, a rejected owner can resubmit documents and a verification can be revoked
+var kycTransitions = map[KYCStatus][]KYCStatus{
+	KYCPending:  {KYCVerified, KYCRejected},
+	KYCVerified: {KYCRejected},
+	KYCRejected: {KYCPending},
+}
+
+var ErrInvalidKYCStatusTransition = errors.New("InvalidKYCStatusTransition")
+
+// KYCTransferLimits caps the amount of a single transfer sent from an account, by the KYC status of its owner
+// and in the currency of the account, so a transfer never needs an exchange rate to be checked
+var KYCTransferLimits = map[KYCStatus]map[Currency]Money{
+	KYCPending: {
+		AOA: NewMoney(5000000, AOA),
+		USD: NewMoney(10000, USD),
+		EUR: NewMoney(10000, EUR),
+	},
+	KYCVerified: {
+		AOA: NewMoney(500000000, AOA),
+		USD: NewMoney(1000000, USD),
+		EUR: NewMoney(1000000, EUR),
+	},
+	KYCRejected: {
+		AOA: NewMoney(0, AOA),
+		USD: NewMoney(0, USD),
+		EUR: NewMoney(0, EUR),
+	},
+}
+
+func (s KYCStatus) IsValid() bool {
+	_, ok := kycTransitions[s]
+	return ok
+}
+
+func (s KYCStatus) CanTransitionTo(next KYCStatus) bool {
+	for _, allowed := range kycTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// TransferLimit is the largest single transfer in currency allowed, owners without a KYC status are treated
+// as pending and an amount without currency is in the default one
+func (s KYCStatus) TransferLimit(currency Currency) Money {
+	limits, ok := KYCTransferLimits[s]
+	if !ok {
+		limits = KYCTransferLimits[KYCPending]
+	}
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	return limits[currency]
+}
+
+type Owner struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	NationalId string     `json:"national_id"`
+	Email      string     `json:"email"`
+	Phone      string     `json:"phone"`
+	Address    string     `json:"address"`
+	KYCStatus  KYCStatus  `json:"kyc_status"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
+}
+
+func NewOwner(name string, nationalId string, email string, phone string, address string) *Owner {
+	now := time.Now()
+	return &Owner{
+		ID:         uuid.NewString(),
+		Name:       name,
+		NationalId: strings.ToUpper(nationalId),
+		Email:      email,
+		Phone:      phone,
+		Address:    address,
+		KYCStatus:  KYCPending,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+}
+
+// ValidateOwner requires a name and a national id (NIF or BI number: 9 to 14 letters and digits)
+func (o *Owner) ValidateOwner() bool {
+	if strings.TrimSpace(o.Name) == "" || len(o.NationalId) < 9 || len(o.NationalId) > 14 {
+		return false
+	}
+	for _, r := range o.NationalId {
+		if !(r >= '0' && r <= '9') && !(r >= 'A' && r <= 'Z') {
+			return false
+		}
+	}
+	return o.Email == "" || strings.Contains(o.Email, "@")
+}
+
+func (o *Owner) IsVerified() bool {
+	return o.KYCStatus == KYCVerified
+}
+
+func (o *Owner) SetKYCStatus(status KYCStatus) error {
+	if !o.KYCStatus.CanTransitionTo(status) {
+		return ErrInvalidKYCStatusTransition
+	}
+	o.KYCStatus = status
+	o.UpdatedAt = time.Now()
+	return nil
+}
@@ -0,0 +1,49 @@
+package types
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("InvalidCursor")
+
+// Cursor points at the row a page starts after, lists are ordered by (Column, id) and
+// Value is that row's Column as text. Backward cursors walk towards the beginning of the list
+type Cursor struct {
+	Column   string `json:"c"`
+	Value    string `json:"v"`
+	ID       string `json:"id"`
+	Backward bool   `json:"b,omitempty"`
+}
+
+func NewTimeCursor(column string, t time.Time, id string) Cursor {
+	return Cursor{Column: column, Value: t.Format(time.RFC3339Nano), ID: id}
+}
+
+// Encode turns the cursor into the opaque token handed to clients
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeCursor(token string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// Page is one page of a list, Total is only counted when asked for
+type Page[T any] struct {
+	Data       []T    `json:"data"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+	Total      *int64 `json:"total,omitempty"`
+}
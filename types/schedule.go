@@ -0,0 +1,107 @@
+package types
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ScheduleStatus string
+
+const (
+	ScheduleScheduled ScheduleStatus = "SCHEDULED"
+	ScheduleExecuting ScheduleStatus = "EXECUTING"
+	ScheduleExecuted  ScheduleStatus = "EXECUTED"
+	ScheduleFailed    ScheduleStatus = "FAILED"
+	ScheduleCancelled ScheduleStatus = "CANCELLED"
+)
+
+var ErrScheduledTransferNotPending = errors.New("ScheduledTransferNotPending")
+
+func (s ScheduleStatus) IsValid() bool {
+	switch s {
+	case ScheduleScheduled, ScheduleExecuting, ScheduleExecuted, ScheduleFailed, ScheduleCancelled:
+		return true
+	}
+	return false
+}
+
+// ScheduledTransfer is a transfer that runs once at ExecuteAt. Nothing is reserved while it waits,
+// the funds are checked when it executes. Once executed its legs hold the outcome of every recipient
+type ScheduledTransfer struct {
+	ID            string         `json:"id"`
+	From          string         `json:"from"`
+	Amount        Money          `json:"amount"`
+	Subject       string         `json:"subject"`
+	Legs          []*TransferLeg `json:"legs"`
+	ExecuteAt     time.Time      `json:"execute_at"`
+	Status        ScheduleStatus `json:"status"`
+	BatchId       string         `json:"batch_id,omitempty"`
+	FailureReason string         `json:"failure_reason,omitempty"`
+	ExecutedAt    *time.Time     `json:"executed_at,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+}
+
+func NewScheduledTransfer(from string, subject string, amount Money, executeAt time.Time) *ScheduledTransfer {
+	now := time.Now()
+	return &ScheduledTransfer{
+		ID:        uuid.NewString(),
+		From:      from,
+		Amount:    amount,
+		Subject:   subject,
+		Legs:      []*TransferLeg{},
+		ExecuteAt: executeAt,
+		Status:    ScheduleScheduled,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+func (s *ScheduledTransfer) AddLeg(accountId string, amount Money) {
+	s.Legs = append(s.Legs, &TransferLeg{AccountId: accountId, Amount: amount, Status: LegPending})
+}
+
+func (s *ScheduledTransfer) IsDue(now time.Time) bool {
+	return s.Status == ScheduleScheduled && !now.Before(s.ExecuteAt)
+}
+
+// Cancel is only possible until the scheduler picks the transfer up
+func (s *ScheduledTransfer) Cancel() error {
+	if s.Status != ScheduleScheduled {
+		return ErrScheduledTransferNotPending
+	}
+	s.Status = ScheduleCancelled
+	s.UpdatedAt = time.Now()
+	return nil
+}
+
+// Start refuses a transfer that is no longer due, it is called in the unit of work executing the
+// transfer so the transfer runs once even with several schedulers
+func (s *ScheduledTransfer) Start(now time.Time) error {
+	if !s.IsDue(now) {
+		return ErrScheduledTransferNotPending
+	}
+	s.Status = ScheduleExecuting
+	s.UpdatedAt = time.Now()
+	return nil
+}
+
+// Finish records the outcome of the transfer batch, err is the reason it failed if it did
+func (s *ScheduledTransfer) Finish(batch *TransferBatch, err error) {
+	now := time.Now()
+	if batch != nil {
+		s.Legs = batch.Legs
+		if len(batch.Legs) > 1 {
+			s.BatchId = batch.ID
+		}
+	}
+	s.Status = ScheduleExecuted
+	if err != nil {
+		s.Status = ScheduleFailed
+		s.FailureReason = err.Error()
+	}
+	s.ExecutedAt = &now
+	s.UpdatedAt = now
+}
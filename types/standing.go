@@ -0,0 +1,294 @@
+package types
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+)
+
+type StandingOrderStatus string
+
+const (
+	StandingOrderActive    StandingOrderStatus = "ACTIVE"
+	StandingOrderSuspended StandingOrderStatus = "SUSPENDED"
+	StandingOrderCompleted StandingOrderStatus = "COMPLETED"
+	StandingOrderCancelled StandingOrderStatus = "CANCELLED"
+)
+
+type StandingOrderRunStatus string
+
+const (
+	RunSucceeded StandingOrderRunStatus = "SUCCEEDED"
+	RunFailed    StandingOrderRunStatus = "FAILED"
+)
+
+const (
+	// StandingOrderRetryInterval is how long a run refused for lack of funds waits before trying again
+	StandingOrderRetryInterval = time.Hour
+	DefaultRetryWindow         = 24 * time.Hour
+	MaxRetryWindow             = 7 * 24 * time.Hour
+	DefaultMaxFailures         = 3
+
+	// standingOrderLease keeps other schedulers away from an order while one of them runs it
+	standingOrderLease = 10 * time.Minute
+)
+
+var ErrInvalidRecurrence = errors.New("InvalidRecurrence")
+var ErrStandingOrderNotDue = errors.New("StandingOrderNotDue")
+var ErrStandingOrderClosed = errors.New("StandingOrderClosed")
+var ErrStandingOrderNotSuspended = errors.New("StandingOrderNotSuspended")
+
+func (f Frequency) IsValid() bool {
+	switch f {
+	case Daily, Weekly, Monthly:
+		return true
+	}
+	return false
+}
+
+func (s StandingOrderStatus) IsValid() bool {
+	switch s {
+	case StandingOrderActive, StandingOrderSuspended, StandingOrderCompleted, StandingOrderCancelled:
+		return true
+	}
+	return false
+}
+
+// Recurrence is when a standing order runs: every day or week from StartAt, or every month on DayOfMonth
+// at the clock time of StartAt. Months shorter than DayOfMonth run on their last day. It ends after Count
+// occurrences or with the last one not after EndAt, whichever comes first. Without either it runs until cancelled
+type Recurrence struct {
+	Frequency  Frequency  `json:"frequency"`
+	DayOfMonth int        `json:"day_of_month,omitempty"`
+	StartAt    time.Time  `json:"start_at"`
+	EndAt      *time.Time `json:"end_at,omitempty"`
+	Count      *int       `json:"count,omitempty"`
+}
+
+func (r Recurrence) Validate() error {
+	if !r.Frequency.IsValid() || r.StartAt.IsZero() {
+		return ErrInvalidRecurrence
+	}
+	if (r.Frequency == Monthly) != (r.DayOfMonth != 0) || r.DayOfMonth < 0 || r.DayOfMonth > 31 {
+		return ErrInvalidRecurrence
+	}
+	if r.Count != nil && *r.Count <= 0 {
+		return ErrInvalidRecurrence
+	}
+	if r.EndAt != nil && r.Occurrence(0).After(*r.EndAt) {
+		return ErrInvalidRecurrence
+	}
+	return nil
+}
+
+// Occurrence is when the nth run is due, the first one is 0
+func (r Recurrence) Occurrence(n int) time.Time {
+	switch r.Frequency {
+	case Daily:
+		return r.StartAt.AddDate(0, 0, n)
+	case Weekly:
+		return r.StartAt.AddDate(0, 0, 7*n)
+	}
+	if dayOfMonth(r.StartAt, 0, r.DayOfMonth).Before(r.StartAt) {
+		n++
+	}
+	return dayOfMonth(r.StartAt, n, r.DayOfMonth)
+}
+
+// IsOver reports whether the recurrence has no nth occurrence
+func (r Recurrence) IsOver(n int) bool {
+	if r.Count != nil && n >= *r.Count {
+		return true
+	}
+	return r.EndAt != nil && r.Occurrence(n).After(*r.EndAt)
+}
+
+// dayOfMonth is day of the month months after the one of t at the clock time of t, capped to the last day
+func dayOfMonth(t time.Time, months int, day int) time.Time {
+	year, month, _ := t.Date()
+	first := time.Date(year, month+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), 0, t.Location())
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+// Beneficiary is a recipient of every run of a standing order
+type Beneficiary struct {
+	AccountId string `json:"account_id"`
+	Amount    Money  `json:"amount"`
+}
+
+// StandingOrder transfers Amount from an account to its beneficiaries on every occurrence of the recurrence.
+// A run refused for lack of funds is tried again every StandingOrderRetryInterval for up to RetryWindow
+// seconds after it was due, any other failure is final. The order is suspended after MaxFailures
+// occurrences in a row failed
+type StandingOrder struct {
+	ID                  string              `json:"id"`
+	From                string              `json:"from"`
+	Amount              Money               `json:"amount"`
+	Subject             string              `json:"subject"`
+	Beneficiaries       []Beneficiary       `json:"beneficiaries"`
+	Recurrence          Recurrence          `json:"recurrence"`
+	RetryWindow         int64               `json:"retry_window"`
+	MaxFailures         int                 `json:"max_failures"`
+	Status              StandingOrderStatus `json:"status"`
+	Occurrence          int                 `json:"occurrence"`
+	DueAt               time.Time           `json:"due_at"`
+	NextRunAt           time.Time           `json:"next_run_at"`
+	Attempts            int                 `json:"attempts"`
+	ConsecutiveFailures int                 `json:"consecutive_failures"`
+	CreatedAt           time.Time           `json:"created_at"`
+	UpdatedAt           time.Time           `json:"updated_at"`
+}
+
+func NewStandingOrder(from string, subject string, amount Money, beneficiaries []Beneficiary, recurrence Recurrence, retryWindow time.Duration, maxFailures int) (*StandingOrder, error) {
+	if err := recurrence.Validate(); err != nil {
+		return nil, err
+	}
+	if retryWindow < 0 || retryWindow > MaxRetryWindow || retryWindow%time.Second != 0 || maxFailures <= 0 {
+		return nil, ErrInvalidRecurrence
+	}
+	now := time.Now()
+	due := recurrence.Occurrence(0)
+	return &StandingOrder{
+		ID:            uuid.NewString(),
+		From:          from,
+		Amount:        amount,
+		Subject:       subject,
+		Beneficiaries: beneficiaries,
+		Recurrence:    recurrence,
+		RetryWindow:   int64(retryWindow / time.Second),
+		MaxFailures:   maxFailures,
+		Status:        StandingOrderActive,
+		DueAt:         due,
+		NextRunAt:     due,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}, nil
+}
+
+func (o *StandingOrder) IsDue(now time.Time) bool {
+	return o.Status == StandingOrderActive && !now.Before(o.NextRunAt)
+}
+
+// Claim starts an attempt at the due occurrence and keeps the order away from other schedulers until
+// the outcome is recorded with Succeed or Fail
+func (o *StandingOrder) Claim(now time.Time) error {
+	if !o.IsDue(now) {
+		return ErrStandingOrderNotDue
+	}
+	o.Attempts++
+	o.NextRunAt = now.Add(standingOrderLease)
+	o.UpdatedAt = time.Now()
+	return nil
+}
+
+func (o *StandingOrder) Succeed() {
+	o.ConsecutiveFailures = 0
+	o.advance()
+}
+
+// Fail records a failed attempt and reports whether it will be tried again. Only retryable
+// failures are, while the next attempt still falls inside the retry window and before the next occurrence
+func (o *StandingOrder) Fail(now time.Time, retryable bool) bool {
+	retryAt := now.Add(StandingOrderRetryInterval)
+	if retryable && !retryAt.After(o.DueAt.Add(time.Duration(o.RetryWindow)*time.Second)) && retryAt.Before(o.Recurrence.Occurrence(o.Occurrence+1)) {
+		o.NextRunAt = retryAt
+		o.UpdatedAt = time.Now()
+		return true
+	}
+	o.ConsecutiveFailures++
+	if o.Status == StandingOrderActive && o.ConsecutiveFailures >= o.MaxFailures {
+		o.Status = StandingOrderSuspended
+	}
+	o.advance()
+	return false
+}
+
+// advance moves on to the next occurrence, completing the order after the last one
+func (o *StandingOrder) advance() {
+	o.Occurrence++
+	o.Attempts = 0
+	o.DueAt = o.Recurrence.Occurrence(o.Occurrence)
+	o.NextRunAt = o.DueAt
+	if o.Recurrence.IsOver(o.Occurrence) && o.Status != StandingOrderCancelled {
+		o.Status = StandingOrderCompleted
+	}
+	o.UpdatedAt = time.Now()
+}
+
+func (o *StandingOrder) Cancel() error {
+	if o.Status == StandingOrderCancelled || o.Status == StandingOrderCompleted {
+		return ErrStandingOrderClosed
+	}
+	o.Status = StandingOrderCancelled
+	o.UpdatedAt = time.Now()
+	return nil
+}
+
+// Resume reactivates a suspended order from its first occurrence after now, the ones missed
+// while it was suspended are skipped
+func (o *StandingOrder) Resume(now time.Time) error {
+	if o.Status != StandingOrderSuspended {
+		return ErrStandingOrderNotSuspended
+	}
+	o.Status = StandingOrderActive
+	o.ConsecutiveFailures = 0
+	o.Attempts = 0
+	for o.DueAt.Before(now) && o.Status == StandingOrderActive {
+		o.advance()
+	}
+	o.NextRunAt = o.DueAt
+	o.UpdatedAt = time.Now()
+	return nil
+}
+
+// StandingOrderRun is one attempt at an occurrence of a standing order
+type StandingOrderRun struct {
+	ID            string                 `json:"id"`
+	OrderId       string                 `json:"order_id"`
+	Occurrence    int                    `json:"occurrence"`
+	DueAt         time.Time              `json:"due_at"`
+	Attempt       int                    `json:"attempt"`
+	Status        StandingOrderRunStatus `json:"status"`
+	BatchId       string                 `json:"batch_id,omitempty"`
+	Legs          []*TransferLeg         `json:"legs"`
+	FailureReason string                 `json:"failure_reason,omitempty"`
+	WillRetry     bool                   `json:"will_retry"`
+	RanAt         time.Time              `json:"ran_at"`
+}
+
+// NewStandingOrderRun records the outcome of the current attempt of the order, before the order moves on
+func NewStandingOrderRun(order *StandingOrder, batch *TransferBatch, err error) *StandingOrderRun {
+	run := &StandingOrderRun{
+		ID:         uuid.NewString(),
+		OrderId:    order.ID,
+		Occurrence: order.Occurrence,
+		DueAt:      order.DueAt,
+		Attempt:    order.Attempts,
+		Status:     RunSucceeded,
+		Legs:       []*TransferLeg{},
+		RanAt:      time.Now(),
+	}
+	if batch != nil {
+		run.Legs = batch.Legs
+		if len(batch.Legs) > 1 {
+			run.BatchId = batch.ID
+		}
+	}
+	if err != nil {
+		run.Status = RunFailed
+		run.FailureReason = err.Error()
+	}
+	return run
+}
@@ -0,0 +1,82 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStandingOrder(t *testing.T) {
+	start := time.Date(2026, time.January, 20, 8, 0, 0, 0, time.UTC)
+
+	t.Run("Occurrence should run monthly on the day of month, capped to the last day", func(t *testing.T) {
+		monthly := Recurrence{Frequency: Monthly, DayOfMonth: 31, StartAt: start}
+		assert.Nil(t, monthly.Validate())
+		assert.Equal(t, time.Date(2026, time.January, 31, 8, 0, 0, 0, time.UTC), monthly.Occurrence(0))
+		assert.Equal(t, time.Date(2026, time.February, 28, 8, 0, 0, 0, time.UTC), monthly.Occurrence(1))
+		assert.Equal(t, time.Date(2026, time.March, 31, 8, 0, 0, 0, time.UTC), monthly.Occurrence(2))
+
+		early := Recurrence{Frequency: Monthly, DayOfMonth: 5, StartAt: start}
+		assert.Equal(t, time.Date(2026, time.February, 5, 8, 0, 0, 0, time.UTC), early.Occurrence(0))
+
+		weekly := Recurrence{Frequency: Weekly, StartAt: start}
+		assert.Equal(t, start.AddDate(0, 0, 14), weekly.Occurrence(2))
+	})
+
+	t.Run("IsOver should end the recurrence by count or end date", func(t *testing.T) {
+		count := 2
+		counted := Recurrence{Frequency: Daily, StartAt: start, Count: &count}
+		assert.False(t, counted.IsOver(1))
+		assert.True(t, counted.IsOver(2))
+
+		end := start.AddDate(0, 0, 10)
+		dated := Recurrence{Frequency: Weekly, StartAt: start, EndAt: &end}
+		assert.False(t, dated.IsOver(1))
+		assert.True(t, dated.IsOver(2))
+	})
+
+	t.Run("Validate should refuse inconsistent recurrences", func(t *testing.T) {
+		zero := 0
+		before := start.Add(-time.Hour)
+		for _, recurrence := range []Recurrence{
+			{Frequency: "YEARLY", StartAt: start},
+			{Frequency: Daily},
+			{Frequency: Monthly, StartAt: start},
+			{Frequency: Weekly, DayOfMonth: 3, StartAt: start},
+			{Frequency: Monthly, DayOfMonth: 32, StartAt: start},
+			{Frequency: Daily, StartAt: start, Count: &zero},
+			{Frequency: Daily, StartAt: start, EndAt: &before},
+		} {
+			assert.Equal(t, ErrInvalidRecurrence, recurrence.Validate())
+		}
+	})
+
+	t.Run("Fail should retry within the window and suspend after too many failed occurrences", func(t *testing.T) {
+		order, err := NewStandingOrder("from", "Renda", MustParseMoney("100", AOA), nil, Recurrence{Frequency: Daily, StartAt: start}, 2*time.Hour, 2)
+		assert.Nil(t, err)
+
+		assert.Nil(t, order.Claim(start))
+		assert.Equal(t, ErrStandingOrderNotDue, order.Claim(start))
+		assert.True(t, order.Fail(start, true))
+		assert.Equal(t, start.Add(StandingOrderRetryInterval), order.NextRunAt)
+
+		retry := order.NextRunAt
+		assert.Nil(t, order.Claim(retry))
+		assert.True(t, order.Fail(retry, true))
+		assert.Nil(t, order.Claim(order.NextRunAt))
+		assert.False(t, order.Fail(order.NextRunAt, true))
+		assert.Equal(t, 1, order.ConsecutiveFailures)
+		assert.Equal(t, 1, order.Occurrence)
+		assert.Equal(t, start.AddDate(0, 0, 1), order.NextRunAt)
+
+		assert.Nil(t, order.Claim(order.DueAt))
+		assert.False(t, order.Fail(order.DueAt, false))
+		assert.Equal(t, StandingOrderSuspended, order.Status)
+
+		assert.Nil(t, order.Resume(start.AddDate(0, 0, 5)))
+		assert.Equal(t, StandingOrderActive, order.Status)
+		assert.Equal(t, start.AddDate(0, 0, 5), order.DueAt)
+		assert.Equal(t, 0, order.ConsecutiveFailures)
+	})
+}
@@ -1,39 +1,174 @@
 package types
 
 import (
+	"encoding/json"
+	"errors"
+	"math/big"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type TransactionStatus string
+
+const (
+	TransactionPending   TransactionStatus = "PENDING"
+	TransactionCompleted TransactionStatus = "COMPLETED"
+	TransactionFailed    TransactionStatus = "FAILED"
+	TransactionReversed  TransactionStatus = "REVERSED"
+)
+
+// allowed status transitions, FAILED and REVERSED are final
+var transactionTransitions = map[TransactionStatus][]TransactionStatus{
+	TransactionPending:   {TransactionCompleted, TransactionFailed},
+	TransactionCompleted: {TransactionReversed},
+}
+
+var ErrInvalidStatusTransition = errors.New("InvalidStatusTransition")
+var ErrRefundExceedsRefundableAmount = errors.New("RefundExceedsRefundableAmount")
+
+func (s TransactionStatus) CanTransitionTo(next TransactionStatus) bool {
+	for _, allowed := range transactionTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+type StatusTransition struct {
+	Status    TransactionStatus `json:"status"`
+	Reason    string            `json:"reason,omitempty"`
+	ChangedAt time.Time         `json:"changed_at"`
+}
+
 type Transaction struct {
-	ID                            string    `json:"id"`
-	From                          string    `json:"from"`
-	To                            string    `json:"to"`
-	Subject                       string    `json:"subject"`
-	Operation                     string    `json:"operation"`
-	Amount                        float64   `json:"amount"`
-	MultiBeneficiaryTransactionId string    `json:"multi_Beneficiary_transaction_id"`
-	IsRefund                      bool      `json:"is_refunded"`
-	RefundedTransactionId         string    `json:"refunded_transaction_id"`
-	CreatedAt                     time.Time `json:"created_at"`
+	ID                            string              `json:"id"`
+	From                          string              `json:"from"`
+	To                            string              `json:"to"`
+	Subject                       string              `json:"subject"`
+	Operation                     string              `json:"operation"`
+	Amount                        Money               `json:"amount"`
+	DestinationAmount             Money               `json:"destination_amount"`
+	ExchangeRate                  string              `json:"exchange_rate,omitempty"`
+	RefundedAmount                Money               `json:"refunded_amount"`
+	Status                        TransactionStatus   `json:"status"`
+	FailureReason                 string              `json:"failure_reason,omitempty"`
+	MultiBeneficiaryTransactionId string              `json:"multi_Beneficiary_transaction_id"`
+	IsRefund                      bool                `json:"is_refunded"`
+	RefundedTransactionId         string              `json:"refunded_transaction_id"`
+	LinkedTransactionId           string              `json:"linked_transaction_id,omitempty"`
+	CreatedAt                     time.Time           `json:"created_at"`
+	UpdatedAt                     time.Time           `json:"updated_at"`
+	StatusHistory                 []*StatusTransition `json:"status_history,omitempty"`
+	Fee                           *Transaction        `json:"fee,omitempty"`
 }
 
 func ValidateTransaction(u *Account) bool { return true }
 
-func NewTransaction(amount float64, from string, to string, subject string, operation string, MultiBeneficiaryTransactionId string, isRefund bool, RefundedTransactionId string) *Transaction {
+func NewTransaction(amount Money, from string, to string, subject string, operation string, MultiBeneficiaryTransactionId string, isRefund bool, RefundedTransactionId string) *Transaction {
+	now := time.Now()
 	return &Transaction{
 		ID:                            uuid.NewString(),
 		From:                          from,
 		To:                            to,
 		Subject:                       subject,
 		Operation:                     operation,
+		Status:                        TransactionPending,
 		MultiBeneficiaryTransactionId: MultiBeneficiaryTransactionId,
 		IsRefund:                      isRefund,
 		RefundedTransactionId:         RefundedTransactionId,
 		Amount:                        amount,
-		CreatedAt:                     time.Now(),
+		DestinationAmount:             amount,
+		RefundedAmount:                NewMoney(0, amount.Currency),
+		CreatedAt:                     now,
+		UpdatedAt:                     now,
+		StatusHistory: []*StatusTransition{
+			{Status: TransactionPending, ChangedAt: now},
+		},
+	}
+}
+
+// Exchange settles the transaction in the quote currency of rate: Amount leaves From and DestinationAmount reaches To
+func (tr *Transaction) Exchange(rate *ExchangeRate) {
+	tr.DestinationAmount = rate.Convert(tr.Amount)
+	tr.ExchangeRate = rate.Rate
+}
+
+func (tr *Transaction) IsExchange() bool {
+	return tr.ExchangeRate != ""
+}
+
+// DestinationShare is the part of DestinationAmount matching amount of the source amount,
+// used to take back the right amount from the destination when refunding
+func (tr *Transaction) DestinationShare(amount Money) Money {
+	if amount.Equal(tr.Amount) || tr.Amount.IsZero() {
+		return tr.DestinationAmount
+	}
+	share := new(big.Rat).SetInt64(tr.DestinationAmount.Amount)
+	share.Mul(share, big.NewRat(amount.Amount, tr.Amount.Amount))
+	return NewMoney(roundRat(share), tr.DestinationAmount.Currency)
+}
+
+// TransitionTo moves the transaction to status and records when and why it happened
+func (tr *Transaction) TransitionTo(status TransactionStatus, reason string) error {
+	if !tr.Status.CanTransitionTo(status) {
+		return ErrInvalidStatusTransition
+	}
+	now := time.Now()
+	tr.Status = status
+	tr.UpdatedAt = now
+	if status == TransactionFailed {
+		tr.FailureReason = reason
+	}
+	tr.StatusHistory = append(tr.StatusHistory, &StatusTransition{Status: status, Reason: reason, ChangedAt: now})
+	return nil
+}
+
+// Abort marks the transaction FAILED after its unit of work was rolled back,
+// dropping the transitions that were rolled back with it
+func (tr *Transaction) Abort(reason string) {
+	if tr.Status != TransactionPending && len(tr.StatusHistory) > 0 {
+		tr.Status = TransactionPending
+		tr.StatusHistory = tr.StatusHistory[:1]
+	}
+	tr.TransitionTo(TransactionFailed, reason)
+}
+
+// RefundableAmount is what is left to refund, only completed non refund transactions can be refunded
+func (tr *Transaction) RefundableAmount() Money {
+	if tr.IsRefund || tr.Status != TransactionCompleted {
+		return NewMoney(0, tr.Amount.Currency)
+	}
+	return tr.Amount.Sub(tr.RefundedAmount)
+}
+
+// ApplyRefund adds amount to the refunded total, the transaction is REVERSED once it is fully refunded
+func (tr *Transaction) ApplyRefund(amount Money, refundId string) error {
+	if !amount.IsPositive() || amount.GreaterThan(tr.RefundableAmount()) {
+		return ErrRefundExceedsRefundableAmount
+	}
+	tr.RefundedAmount = tr.RefundedAmount.Add(amount)
+	tr.UpdatedAt = time.Now()
+	if tr.RefundedAmount.Equal(tr.Amount) {
+		return tr.TransitionTo(TransactionReversed, "refunded by "+refundId)
+	}
+	return nil
+}
+
+func (tr Transaction) MarshalJSON() ([]byte, error) {
+	type transaction Transaction
+	return json.Marshal(struct {
+		transaction
+		RefundableAmount Money `json:"refundable_amount"`
+	}{transaction(tr), tr.RefundableAmount()})
+}
+
+func (tr *Transaction) LastTransition() *StatusTransition {
+	if len(tr.StatusHistory) == 0 {
+		return nil
 	}
+	return tr.StatusHistory[len(tr.StatusHistory)-1]
 }
 
 func (tr *Transaction) ValidateTransaction() bool {
@@ -0,0 +1,45 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransactionStatus(t *testing.T) {
+	t.Run("TransitionTo should only allow the lifecycle transitions", func(t *testing.T) {
+		transaction := NewTransaction(NewMoney(100, AOA), "from", "to", "subject", "TRANSFER", "", false, "")
+
+		assert.ErrorIs(t, transaction.TransitionTo(TransactionReversed, ""), ErrInvalidStatusTransition)
+		assert.Nil(t, transaction.TransitionTo(TransactionCompleted, ""))
+		assert.Nil(t, transaction.TransitionTo(TransactionReversed, "refunded"))
+		assert.ErrorIs(t, transaction.TransitionTo(TransactionCompleted, ""), ErrInvalidStatusTransition)
+		assert.Len(t, transaction.StatusHistory, 3)
+	})
+
+	t.Run("Abort should drop rolled back transitions and mark the transaction FAILED", func(t *testing.T) {
+		transaction := NewTransaction(NewMoney(100, AOA), "from", "to", "subject", "TRANSFER", "", false, "")
+		transaction.TransitionTo(TransactionCompleted, "")
+
+		transaction.Abort("InsufficientFunds")
+
+		assert.Equal(t, TransactionFailed, transaction.Status)
+		assert.Equal(t, "InsufficientFunds", transaction.FailureReason)
+		assert.Len(t, transaction.StatusHistory, 2)
+	})
+	t.Run("JSON should expose the refunded and refundable amounts", func(t *testing.T) {
+		transaction := NewTransaction(NewMoney(1000, AOA), "from", "to", "subject", "TRANSFER", "", false, "")
+		transaction.TransitionTo(TransactionCompleted, "")
+		transaction.ApplyRefund(NewMoney(250, AOA), "refund")
+
+		data, err := json.Marshal(transaction)
+		assert.Nil(t, err)
+		var decoded map[string]interface{}
+		json.Unmarshal(data, &decoded)
+
+		assert.Equal(t, "2.50", decoded["refunded_amount"].(map[string]interface{})["amount"])
+		assert.Equal(t, "7.50", decoded["refundable_amount"].(map[string]interface{})["amount"])
+	})
+
+}
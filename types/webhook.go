@@ -0,0 +1,223 @@
+package types
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type EventType string
+
+const (
+	EventDepositCompleted    EventType = "deposit.completed"
+	EventWithdrawalCompleted EventType = "withdrawal.completed"
+	EventTransferCompleted   EventType = "transfer.completed"
+	EventRefundCompleted     EventType = "refund.completed"
+	EventFeeCharged          EventType = "fee.charged"
+)
+
+func (t EventType) IsValid() bool {
+	switch t {
+	case EventDepositCompleted, EventWithdrawalCompleted, EventTransferCompleted, EventRefundCompleted, EventFeeCharged:
+		return true
+	}
+	return false
+}
+
+// Event is a change of the ledger, written to the outbox in the unit of work that made it. It is sent to
+// the webhook endpoints as is, Data being the transaction
+type Event struct {
+	ID           string          `json:"id"`
+	Type         EventType       `json:"type"`
+	Data         json.RawMessage `json:"data"`
+	CreatedAt    time.Time       `json:"created_at"`
+	DispatchedAt *time.Time      `json:"-"`
+}
+
+func NewEvent(eventType EventType, data interface{}) (*Event, error) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return &Event{
+		ID:        uuid.NewString(),
+		Type:      eventType,
+		Data:      body,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// WebhookEndpoint receives the events of EventTypes, every event when it is empty. Secret signs the deliveries,
+// it is only shown when the endpoint is registered
+type WebhookEndpoint struct {
+	ID         string      `json:"id"`
+	URL        string      `json:"url"`
+	Secret     string      `json:"secret,omitempty"`
+	EventTypes []EventType `json:"event_types"`
+	CreatedAt  time.Time   `json:"created_at"`
+}
+
+func NewWebhookEndpoint(url string, eventTypes []EventType) (*WebhookEndpoint, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	if eventTypes == nil {
+		eventTypes = []EventType{}
+	}
+	return &WebhookEndpoint{
+		ID:         uuid.NewString(),
+		URL:        url,
+		Secret:     hex.EncodeToString(secret),
+		EventTypes: eventTypes,
+		CreatedAt:  time.Now(),
+	}, nil
+}
+
+func (e *WebhookEndpoint) Accepts(eventType EventType) bool {
+	if len(e.EventTypes) == 0 {
+		return true
+	}
+	for _, t := range e.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Sign is the hex HMAC-SHA256 of "timestamp.body" with the secret of the endpoint. Including the timestamp
+// lets receivers refuse old deliveries replayed by someone else
+func (e *WebhookEndpoint) Sign(timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(e.Secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "PENDING"
+	DeliveryDelivered DeliveryStatus = "DELIVERED"
+	DeliveryDead      DeliveryStatus = "DEAD"
+)
+
+const (
+	// WebhookMaxAttempts is how many times a delivery is tried before it goes to the dead letters
+	WebhookMaxAttempts = 8
+	webhookBaseBackoff = 30 * time.Second
+	webhookMaxBackoff  = time.Hour
+	// webhookDeliveryLease is how long a dispatcher may take to send a delivery before another one retries it
+	webhookDeliveryLease = time.Minute
+)
+
+var ErrDeliveryNotDue = errors.New("DeliveryNotDue")
+var ErrDeliveryNotDead = errors.New("DeliveryNotDead")
+
+// WebhookDelivery is the sending of one event to one endpoint. Failed attempts are retried with an
+// exponential backoff until WebhookMaxAttempts, then the delivery is DEAD until it is replayed
+type WebhookDelivery struct {
+	ID             string          `json:"id"`
+	EndpointId     string          `json:"endpoint_id"`
+	EventId        string          `json:"event_id"`
+	EventType      EventType       `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         DeliveryStatus  `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastError      string          `json:"last_error,omitempty"`
+	ResponseStatus int             `json:"response_status,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
+
+func NewWebhookDelivery(endpoint *WebhookEndpoint, event *Event) (*WebhookDelivery, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return &WebhookDelivery{
+		ID:            uuid.NewString(),
+		EndpointId:    endpoint.ID,
+		EventId:       event.ID,
+		EventType:     event.Type,
+		Payload:       payload,
+		Status:        DeliveryPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}, nil
+}
+
+func (d *WebhookDelivery) IsDue(now time.Time) bool {
+	return d.Status == DeliveryPending && !now.Before(d.NextAttemptAt)
+}
+
+// Claim takes a due delivery for one attempt. Its next attempt moves past the lease, so another
+// dispatcher only sends it again if this one stopped before recording the outcome
+func (d *WebhookDelivery) Claim(now time.Time) error {
+	if !d.IsDue(now) {
+		return ErrDeliveryNotDue
+	}
+	d.NextAttemptAt = now.Add(webhookDeliveryLease)
+	d.UpdatedAt = time.Now()
+	return nil
+}
+
+func (d *WebhookDelivery) Succeed(responseStatus int) {
+	now := time.Now()
+	d.Attempts++
+	d.Status = DeliveryDelivered
+	d.ResponseStatus = responseStatus
+	d.LastError = ""
+	d.DeliveredAt = &now
+	d.UpdatedAt = now
+}
+
+// Fail records a failed attempt, responseStatus is 0 when the endpoint did not answer. It schedules the
+// next attempt or, after WebhookMaxAttempts, sends the delivery to the dead letters
+func (d *WebhookDelivery) Fail(now time.Time, responseStatus int, reason string) {
+	d.Attempts++
+	d.ResponseStatus = responseStatus
+	d.LastError = reason
+	d.UpdatedAt = time.Now()
+	if d.Attempts >= WebhookMaxAttempts {
+		d.Status = DeliveryDead
+		return
+	}
+	d.NextAttemptAt = now.Add(WebhookBackoff(d.Attempts))
+}
+
+// Replay gives a dead delivery a new round of attempts, starting right away
+func (d *WebhookDelivery) Replay(now time.Time) error {
+	if d.Status != DeliveryDead {
+		return ErrDeliveryNotDead
+	}
+	d.Status = DeliveryPending
+	d.Attempts = 0
+	d.NextAttemptAt = now
+	d.UpdatedAt = time.Now()
+	return nil
+}
+
+// WebhookBackoff is the wait after the given number of failed attempts: 30s, 1m, 2m... up to an hour
+func WebhookBackoff(attempts int) time.Duration {
+	backoff := webhookBaseBackoff
+	for i := 1; i < attempts && backoff < webhookMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > webhookMaxBackoff {
+		return webhookMaxBackoff
+	}
+	return backoff
+}
@@ -0,0 +1,63 @@
+package types
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookDelivery(t *testing.T) {
+	t.Run("WebhookBackoff should double from 30 seconds up to an hour", func(t *testing.T) {
+		assert.Equal(t, 30*time.Second, WebhookBackoff(1))
+		assert.Equal(t, time.Minute, WebhookBackoff(2))
+		assert.Equal(t, 4*time.Minute, WebhookBackoff(4))
+		assert.Equal(t, time.Hour, WebhookBackoff(10))
+	})
+
+	t.Run("Fail should schedule retries and go to the dead letters after the last attempt", func(t *testing.T) {
+		endpoint, err := NewWebhookEndpoint("https://example.com/hooks", nil)
+		assert.Nil(t, err)
+		event, err := NewEvent(EventDepositCompleted, map[string]string{"id": "some transaction"})
+		assert.Nil(t, err)
+		delivery, err := NewWebhookDelivery(endpoint, event)
+		assert.Nil(t, err)
+		now := delivery.NextAttemptAt
+		assert.True(t, delivery.IsDue(now))
+
+		assert.Nil(t, delivery.Claim(now))
+		assert.Equal(t, ErrDeliveryNotDue, delivery.Claim(now))
+		delivery.Fail(now, 500, "Internal Server Error")
+		assert.Equal(t, DeliveryPending, delivery.Status)
+		assert.Equal(t, now.Add(30*time.Second), delivery.NextAttemptAt)
+		assert.Equal(t, ErrDeliveryNotDead, delivery.Replay(now))
+
+		for delivery.Attempts < WebhookMaxAttempts {
+			delivery.Fail(now, 0, "connection refused")
+		}
+		assert.Equal(t, DeliveryDead, delivery.Status)
+		assert.False(t, delivery.IsDue(now.Add(24*time.Hour)))
+
+		assert.Nil(t, delivery.Replay(now))
+		assert.Equal(t, 0, delivery.Attempts)
+		assert.True(t, delivery.IsDue(now))
+		delivery.Succeed(204)
+		assert.Equal(t, DeliveryDelivered, delivery.Status)
+		assert.Empty(t, delivery.LastError)
+	})
+
+	t.Run("Sign should be the HMAC of the timestamp and body with the endpoint secret", func(t *testing.T) {
+		endpoint, err := NewWebhookEndpoint("https://example.com/hooks", []EventType{EventRefundCompleted})
+		assert.Nil(t, err)
+		assert.Len(t, endpoint.Secret, 64)
+		assert.True(t, endpoint.Accepts(EventRefundCompleted))
+		assert.False(t, endpoint.Accepts(EventFeeCharged))
+
+		mac := hmac.New(sha256.New, []byte(endpoint.Secret))
+		mac.Write([]byte("1700000000.{}"))
+		assert.Equal(t, hex.EncodeToString(mac.Sum(nil)), endpoint.Sign(1700000000, []byte("{}")))
+	})
+}